@@ -1,6 +1,7 @@
 package appveyor
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -24,7 +25,7 @@ type job struct {
 	Id string `json:"jobId"`
 }
 
-func ArtifactUrl(url string, headers []string) (string, error) {
+func ArtifactUrl(ctx context.Context, url string, headers []string) (string, error) {
 	parts := ArtifactURL.FindStringSubmatch(url)
 	account := parts[1]
 	project := parts[2]
@@ -32,7 +33,7 @@ func ArtifactUrl(url string, headers []string) (string, error) {
 	artifact := parts[4]
 	buildUrl := "https://ci.appveyor.com/api/projects/" + account + "/" + project + "/build/" + buildNumber
 
-	req, err := http.NewRequest("GET", buildUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", buildUrl, nil)
 	if err != nil {
 		return "", err
 	}
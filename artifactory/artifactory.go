@@ -0,0 +1,175 @@
+// Package artifactory makes getme aware of JFrog Artifactory: urls are
+// authenticated with an api key or an access token, and urls with wildcards,
+// like `https://example.com/artifactory/libs/tool/tool-*.tgz`, are resolved to
+// the latest matching artifact with AQL.
+//
+// Artifactory urls are the urls under `--artifactory-url` or, by default, the
+// urls whose path starts with `/artifactory/`.
+package artifactory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/logs"
+)
+
+// Options configures access to an Artifactory server.
+type Options struct {
+	// URL of the server, like https://example.com/artifactory.
+	URL string
+
+	// APIKey is sent as X-JFrog-Art-Api. Defaults to $ARTIFACTORY_API_KEY.
+	APIKey string
+
+	// Token is an access token. Defaults to $ARTIFACTORY_ACCESS_TOKEN.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Artifact is an artifact, or a pattern of artifacts, of a repository.
+type Artifact struct {
+	// Base is the url of the server.
+	Base string
+	Repo string
+	Path string
+}
+
+// ParseURL tells if an url points to an Artifactory server and splits it
+// into the server, the repository and the path of the artifact.
+func ParseURL(rawURL string, options Options) (Artifact, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return Artifact{}, false
+	}
+
+	contextPath := "/artifactory"
+	if configured, err := url.Parse(strings.TrimSuffix(options.URL, "/")); err == nil && options.URL != "" && configured.Host == parsed.Host {
+		contextPath = configured.Path
+	}
+	if !strings.HasPrefix(parsed.Path, contextPath+"/") {
+		return Artifact{}, false
+	}
+	base, rest := parsed.Scheme+"://"+parsed.Host+contextPath, strings.TrimPrefix(parsed.Path, contextPath+"/")
+
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[0] == "api" || parts[1] == "" {
+		return Artifact{}, false
+	}
+
+	return Artifact{Base: base, Repo: parts[0], Path: parts[1]}, true
+}
+
+// URL gives the download url of the artifact.
+func (a Artifact) URL() string {
+	return a.Base + "/" + a.Repo + "/" + a.Path
+}
+
+// IsPattern tells if the path has wildcards.
+func (a Artifact) IsPattern() bool {
+	return strings.ContainsAny(a.Path, "*?")
+}
+
+// AuthHeaders gives the headers that authenticate to Artifactory, if any.
+func (o Options) AuthHeaders() []string {
+	if apiKey := firstNonEmpty(o.APIKey, os.Getenv("ARTIFACTORY_API_KEY")); apiKey != "" {
+		return []string{"X-JFrog-Art-Api=" + apiKey}
+	}
+	if token := firstNonEmpty(o.Token, os.Getenv("ARTIFACTORY_ACCESS_TOKEN")); token != "" {
+		return []string{"Authorization=Bearer " + token}
+	}
+	return nil
+}
+
+type aqlResults struct {
+	Results []struct {
+		Repo string `json:"repo"`
+		Path string `json:"path"`
+		Name string `json:"name"`
+	} `json:"results"`
+}
+
+// Latest resolves an artifact with wildcards to the most recently created
+// artifact that matches.
+func Latest(ctx context.Context, a Artifact, options Options) (Artifact, error) {
+	if !a.IsPattern() {
+		return a, nil
+	}
+
+	dir, name := path.Split(a.Path)
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+
+	query := fmt.Sprintf(`items.find({"repo":%s,"path":{"$match":%s},"name":{"$match":%s}}).include("repo","path","name","created").sort({"$desc":["created"]}).limit(1)`, quote(a.Repo), quote(dir), quote(name))
+	logs.From(ctx).Debugln("AQL query:", query)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.Base+"/api/search/aql", bytes.NewReader([]byte(query)))
+	if err != nil {
+		return Artifact{}, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	if err := http_headers.Add(options.Headers, req); err != nil {
+		return Artifact{}, err
+	}
+	if err := http_headers.Add(options.AuthHeaders(), req); err != nil {
+		return Artifact{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return Artifact{}, fmt.Errorf("Unable to search %s: %w", a.URL(), &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	found := aqlResults{}
+	if err := json.Unmarshal(body, &found); err != nil {
+		return Artifact{}, err
+	}
+
+	if len(found.Results) == 0 {
+		return Artifact{}, errdefs.Errorf(errdefs.ErrNotFound, "No artifact matches %s", a.URL())
+	}
+
+	latest := found.Results[0]
+	a.Repo, a.Path = latest.Repo, path.Join(latest.Path, latest.Name)
+	return a, nil
+}
+
+// quote encodes a string as a json string, for AQL.
+func quote(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
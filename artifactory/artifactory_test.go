@@ -0,0 +1,78 @@
+package artifactory
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	artifact, ok := ParseURL("https://example.com/artifactory/libs/tool/1.0/tool.tgz", Options{})
+	assert.True(t, ok)
+	assert.Equal(t, Artifact{Base: "https://example.com/artifactory", Repo: "libs", Path: "tool/1.0/tool.tgz"}, artifact)
+	assert.Equal(t, "https://example.com/artifactory/libs/tool/1.0/tool.tgz", artifact.URL())
+	assert.False(t, artifact.IsPattern())
+
+	artifact, ok = ParseURL("https://repo.example.com/libs/tool/*/tool.tgz", Options{URL: "https://repo.example.com/"})
+	assert.True(t, ok)
+	assert.Equal(t, Artifact{Base: "https://repo.example.com", Repo: "libs", Path: "tool/*/tool.tgz"}, artifact)
+	assert.True(t, artifact.IsPattern())
+
+	_, ok = ParseURL("https://repo.example.com/libs/tool.tgz", Options{})
+	assert.False(t, ok)
+	_, ok = ParseURL("https://example.com/artifactory/api/search/aql", Options{})
+	assert.False(t, ok)
+	_, ok = ParseURL("s3://bucket/artifactory/libs/tool.tgz", Options{})
+	assert.False(t, ok)
+}
+
+func TestAuthHeaders(t *testing.T) {
+	os.Unsetenv("ARTIFACTORY_API_KEY")
+	os.Unsetenv("ARTIFACTORY_ACCESS_TOKEN")
+
+	assert.Empty(t, Options{}.AuthHeaders())
+	assert.Equal(t, []string{"X-JFrog-Art-Api=key"}, Options{APIKey: "key", Token: "token"}.AuthHeaders())
+	assert.Equal(t, []string{"Authorization=Bearer token"}, Options{Token: "token"}.AuthHeaders())
+}
+
+func TestLatest(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/artifactory/api/search/aql", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		query, _ := ioutil.ReadAll(r.Body)
+		queries = append(queries, string(query))
+
+		if len(queries) == 1 {
+			w.Write([]byte(`{"results":[{"repo":"libs","path":"tool/1.2","name":"tool-1.2.tgz","created":"2026-10-01T00:00:00.000Z"}]}`))
+		} else {
+			w.Write([]byte(`{"results":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	options := Options{Token: "token"}
+	artifact, _ := ParseURL(server.URL+"/artifactory/libs/tool/*/tool-*.tgz", options)
+
+	latest, err := Latest(context.Background(), artifact, options)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/artifactory/libs/tool/1.2/tool-1.2.tgz", latest.URL())
+	assert.Equal(t, `items.find({"repo":"libs","path":{"$match":"tool/*"},"name":{"$match":"tool-*.tgz"}}).include("repo","path","name","created").sort({"$desc":["created"]}).limit(1)`, queries[0])
+
+	_, err = Latest(context.Background(), artifact, options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	pinned, _ := ParseURL(server.URL+"/artifactory/libs/tool/1.2/tool-1.2.tgz", options)
+	latest, err = Latest(context.Background(), pinned, options)
+	assert.NoError(t, err)
+	assert.Equal(t, pinned, latest)
+	assert.Len(t, queries, 2)
+}
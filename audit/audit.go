@@ -0,0 +1,92 @@
+// Package audit records the provenance of the fetched files in an append-only
+// log, one json record per line.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// Record tells where a file comes from, what it is and who fetched it.
+type Record struct {
+	Time time.Time `json:"time"`
+	// URL is the url that was asked for. ResolvedURL is the one the file
+	// was fetched from, once versions, release assets and hooks are applied.
+	URL         string `json:"url"`
+	ResolvedURL string `json:"resolved_url"`
+	Sha256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+	// Cached tells if the file was already in the cache.
+	Cached bool   `json:"cached"`
+	User   string `json:"user"`
+	Host   string `json:"host"`
+}
+
+// Log is an append-only audit log.
+type Log struct {
+	path string
+	user string
+	host string
+
+	lock sync.Mutex
+}
+
+// Open opens an audit log, creating it if it doesn't exist.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open the audit log: %s", err)
+	}
+	file.Close()
+
+	host, _ := os.Hostname()
+	return &Log{path: path, user: currentUser(), host: host}, nil
+}
+
+// Write appends a record to the log. Its time, user and host are set if
+// they're missing. Each record is written at once, so that records of
+// concurrent getme processes don't mix.
+func (l *Log) Write(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	if record.User == "" {
+		record.User = l.user
+	}
+	if record.Host == "" {
+		record.Host = l.host
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to write to the audit log: %s", err)
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("Unable to write to the audit log: %s", err)
+	}
+	return file.Close()
+}
+
+func currentUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-audit-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.jsonl")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{\"url\":\"https://example.com/old.tgz\"}\n"), 0644))
+
+	log, err := Open(path)
+	assert.NoError(t, err)
+	assert.NoError(t, log.Write(Record{URL: "https://example.com/{{.Version}}.tgz", ResolvedURL: "https://example.com/1.0.tgz", Sha256: "abc", Size: 42}))
+	assert.NoError(t, log.Write(Record{URL: "https://example.com/other.tgz", Cached: true}))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+
+	assert.Len(t, records, 3)
+	assert.Equal(t, "https://example.com/old.tgz", records[0].URL)
+	assert.Equal(t, "https://example.com/1.0.tgz", records[1].ResolvedURL)
+	assert.Equal(t, "abc", records[1].Sha256)
+	assert.Equal(t, int64(42), records[1].Size)
+	assert.False(t, records[1].Time.IsZero())
+	assert.NotEmpty(t, records[1].Host)
+	assert.True(t, records[2].Cached)
+
+	_, err = Open(filepath.Join(dir, "missing", "audit.jsonl"))
+	assert.Error(t, err)
+}
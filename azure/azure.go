@@ -0,0 +1,199 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// ErrNotFound is returned when a blob doesn't exist.
+var ErrNotFound = fmt.Errorf("404 %w", errdefs.ErrNotFound)
+
+// BlobURL matches urls to Azure Blob Storage.
+var BlobURL = regexp.MustCompile(`^https://([a-z0-9]+)\.blob\.core\.windows\.net/([^/?]+)/([^?]+)`)
+
+const (
+	apiVersion = "2019-12-12"
+	resource   = "https://storage.azure.com/"
+)
+
+// Options configures access to Azure Blob Storage.
+type Options struct {
+	// SASToken is a shared access signature. Defaults to AZURE_STORAGE_SAS_TOKEN.
+	SASToken string
+}
+
+// Blob identifies a blob in a storage account.
+type Blob struct {
+	Account   string
+	Container string
+	Name      string
+}
+
+// ParseURL parses `az://account/container/blob` urls and https urls to
+// `<account>.blob.core.windows.net`.
+func ParseURL(rawURL string) (Blob, bool) {
+	if strings.HasPrefix(rawURL, "az://") {
+		parts := strings.SplitN(strings.TrimPrefix(rawURL, "az://"), "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return Blob{}, false
+		}
+		return Blob{Account: parts[0], Container: parts[1], Name: parts[2]}, true
+	}
+
+	parts := BlobURL.FindStringSubmatch(rawURL)
+	if parts == nil {
+		return Blob{}, false
+	}
+	return Blob{Account: parts[1], Container: parts[2], Name: parts[3]}, true
+}
+
+// Open opens a blob for reading. It authenticates with a SAS token if one is
+// given, or else with an Azure AD token from a service principal or a managed
+// identity. Requests are anonymous if no credentials can be found.
+func Open(ctx context.Context, blob Blob, options Options) (io.ReadCloser, error) {
+	blobURL := "https://" + blob.Account + ".blob.core.windows.net/" + blob.Container + "/" + blob.Name
+
+	sas := options.SASToken
+	if sas == "" {
+		sas = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+
+	var token string
+	if sas != "" {
+		blobURL += "?" + strings.TrimPrefix(sas, "?")
+	} else {
+		var err error
+		if token, err = AccessToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", blobURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Version", apiVersion)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp.Body, nil
+}
+
+var (
+	tokenLock  sync.Mutex
+	token      string
+	tokenFound bool
+)
+
+// AccessToken gets an Azure AD token for Azure Storage, either for the
+// service principal described by AZURE_TENANT_ID, AZURE_CLIENT_ID and
+// AZURE_CLIENT_SECRET or for the managed identity of the machine. The token
+// is only looked up once, unless that fails.
+func AccessToken(ctx context.Context) (string, error) {
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+
+	if tokenFound {
+		return token, nil
+	}
+
+	found, err := findAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	token, tokenFound = found, true
+	return token, nil
+}
+
+func findAccessToken(ctx context.Context) (string, error) {
+	tenant := os.Getenv("AZURE_TENANT_ID")
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	secret := os.Getenv("AZURE_CLIENT_SECRET")
+
+	if tenant != "" && clientID != "" && secret != "" {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"scope":         {resource + ".default"},
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", "https://login.microsoftonline.com/"+tenant+"/oauth2/v2.0/token", strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		return decodeToken(resp)
+	}
+
+	// Not being able to reach the instance metadata service quickly means
+	// that there's no managed identity.
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://169.254.169.254/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		// A cancelled request doesn't mean there's no managed identity.
+		return "", ctx.Err()
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return "", nil
+	}
+	return decodeToken(resp)
+}
+
+func decodeToken(resp *http.Response) (string, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("Unable to get an Azure AD token: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.AccessToken, nil
+}
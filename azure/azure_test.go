@@ -0,0 +1,22 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	blob, ok := ParseURL("az://account/builds/windows/docker.zip")
+	assert.True(t, ok)
+	assert.Equal(t, Blob{Account: "account", Container: "builds", Name: "windows/docker.zip"}, blob)
+
+	blob, ok = ParseURL("https://account.blob.core.windows.net/builds/docker.zip?sv=2019-12-12&sig=abc")
+	assert.True(t, ok)
+	assert.Equal(t, Blob{Account: "account", Container: "builds", Name: "docker.zip"}, blob)
+
+	_, ok = ParseURL("az://account/builds")
+	assert.False(t, ok)
+	_, ok = ParseURL("https://example.com/builds/docker.zip")
+	assert.False(t, ok)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/logs"
+)
+
+const (
+	// throttledRetries is how many times a job is run again after its host
+	// answered 429 or 503.
+	throttledRetries = 3
+	// throttledBackoff is how long a throttled job waits before it's run
+	// again the first time. It doubles with every retry.
+	throttledBackoff = time.Second
+	// maxRetryAfter is the longest Retry-After that's waited for. Jobs on
+	// hosts that ask for more fail.
+	maxRetryAfter = time.Minute
+)
+
+// runBatch runs jobs with a pool of --concurrency workers. All the jobs are
+// run, even if some fail. Their errors are then reported together.
+//
+// Jobs on a host that answers 429 or 503 are run again, after a backoff or
+// the Retry-After of the host, and the number of jobs run at the same time on
+// that host is halved. It grows back by one after as many successes as the
+// current limit, up to --concurrency.
+//
+// Once the --deadline is reached, no new job is started. Running jobs are
+// finished or cancelled, depending on --on-deadline, and the report lists
+// the jobs that weren't run.
+func runBatch(ctx context.Context, batch [][]string, run func(ctx context.Context, i int, job []string) error) error {
+	if onDeadline != "finish" && onDeadline != "cancel" {
+		return fmt.Errorf("Invalid --on-deadline %s. Should be finish or cancel", onDeadline)
+	}
+
+	workers := concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+
+	jobCtx, expired := ctx, func() bool { return false }
+	if deadline > 0 {
+		end := time.Now().Add(deadline)
+		expired = func() bool { return !time.Now().Before(end) }
+
+		if onDeadline == "cancel" {
+			var cancel context.CancelFunc
+			jobCtx, cancel = context.WithDeadline(ctx, end)
+			defer cancel()
+		}
+	}
+
+	errs := make([]error, len(batch))
+	started := make([]bool, len(batch))
+	throttled := make([]int, len(batch))
+	retrying := make([]bool, len(batch))
+	pending := make([]int, len(batch))
+	for i := range pending {
+		pending[i] = i
+	}
+	limits := &hostLimits{max: workers, hosts: map[string]*hostLimit{}}
+	delayed := 0
+
+	var mu sync.Mutex
+	done := sync.NewCond(&mu)
+
+	// Waiting workers are woken up when the batch is cancelled or when the
+	// deadline is reached, even if no job finishes.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		var reached <-chan time.Time
+		if deadline > 0 {
+			timer := time.NewTimer(deadline)
+			defer timer.Stop()
+			reached = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-reached:
+		case <-finished:
+			return
+		}
+		mu.Lock()
+		done.Broadcast()
+		mu.Unlock()
+	}()
+
+	// next picks the first pending job whose host can take one more download.
+	// Workers wait for a running job to finish, or for a throttled job to be
+	// pending again, when no host can.
+	next := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for (len(pending) > 0 || delayed > 0) && !expired() && ctx.Err() == nil {
+			for k, i := range pending {
+				if limits.acquire(hostOf(batch[i][0])) {
+					pending = append(pending[:k], pending[k+1:]...)
+					return i
+				}
+			}
+			done.Wait()
+		}
+		return -1
+	}
+
+	// retry runs a throttled job again once its host has slowed down.
+	retry := func(i int, wait time.Duration) {
+		logs.Infof("Retry %s in %s", batch[i][0], wait)
+
+		delayed++
+		retrying[i] = true
+		time.AfterFunc(wait, func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			delayed--
+			retrying[i] = false
+			pending = append([]int{i}, pending...)
+			done.Broadcast()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := next(); i >= 0; i = next() {
+				started[i] = true
+				errs[i] = run(jobCtx, i, batch[i])
+
+				mu.Lock()
+				if limits.release(hostOf(batch[i][0]), errs[i]) && throttled[i] < throttledRetries {
+					if wait := throttledWait(errs[i], throttled[i]); wait <= maxRetryAfter {
+						throttled[i]++
+						retry(i, wait)
+					}
+				}
+				done.Broadcast()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failures, skipped []string
+	var failed []error
+	exceeded := false
+	mu.Lock()
+	for i, err := range errs {
+		switch {
+		case !started[i]:
+			skipped = append(skipped, fmt.Sprintf("  %s: not started", batch[i][0]))
+			exceeded = true
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("  %s: %s", batch[i][0], err))
+			failed = append(failed, err)
+			// Throttled jobs might not be run again before the deadline.
+			if errors.Is(err, context.DeadlineExceeded) && jobCtx.Err() != nil || retrying[i] && expired() {
+				exceeded = true
+			}
+		}
+	}
+	mu.Unlock()
+
+	if exceeded {
+		return &batchError{
+			message: fmt.Sprintf("The deadline of %s is exceeded: %d of %d urls are done, %d failed and %d weren't started:\n%s",
+				deadline, len(batch)-len(failures)-len(skipped), len(batch), len(failures), len(skipped), strings.Join(append(failures, skipped...), "\n")),
+			errs:     failed,
+			deadline: true,
+		}
+	}
+
+	if len(batch) == 1 {
+		return errs[0]
+	}
+	if len(failures) > 0 {
+		return &batchError{
+			message: fmt.Sprintf("%d of %d urls failed:\n%s", len(failures), len(batch), strings.Join(failures, "\n")),
+			errs:    failed,
+		}
+	}
+
+	return nil
+}
+
+// hostLimits are the numbers of jobs that can run at the same time on each
+// host, adapted like TCP congestion windows: additive increase,
+// multiplicative decrease.
+type hostLimits struct {
+	max   int
+	hosts map[string]*hostLimit
+}
+
+type hostLimit struct {
+	limit     int
+	running   int
+	successes int
+}
+
+// acquire tells if a job can be started on a host. If so, it's counted as
+// running until it's released.
+func (l *hostLimits) acquire(host string) bool {
+	limit, found := l.hosts[host]
+	if !found {
+		limit = &hostLimit{limit: l.max}
+		l.hosts[host] = limit
+	}
+
+	if limit.running >= limit.limit {
+		return false
+	}
+	limit.running++
+	return true
+}
+
+// release adapts the limit of a host to the result of a job. It tells if the
+// job was throttled.
+func (l *hostLimits) release(host string, err error) bool {
+	limit := l.hosts[host]
+	limit.running--
+
+	if isThrottled(err) {
+		limit.limit = (limit.limit + 1) / 2
+		limit.successes = 0
+		logs.Infof("%s is throttling downloads. Run at most %d at the same time", host, limit.limit)
+		return true
+	}
+
+	if err == nil && limit.limit < l.max {
+		if limit.successes++; limit.successes >= limit.limit {
+			limit.limit++
+			limit.successes = 0
+		}
+	}
+	return false
+}
+
+// throttledWait is how long a throttled job waits before it's run again: the
+// Retry-After given by its host or an exponential backoff, whichever is
+// longer.
+func throttledWait(err error, retries int) time.Duration {
+	wait := throttledBackoff << uint(retries)
+
+	var status *errdefs.StatusError
+	if errors.As(err, &status) && status.RetryAfter > wait {
+		wait = status.RetryAfter
+	}
+	return wait
+}
+
+func isThrottled(err error) bool {
+	var status *errdefs.StatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	return status.StatusCode == http.StatusTooManyRequests || status.StatusCode == http.StatusServiceUnavailable
+}
+
+// hostOf gives the host of an url, or an empty string for local files.
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
@@ -0,0 +1,284 @@
+// Package buildkite downloads the artifacts of Buildkite builds, given as
+// `buildkite://org/pipeline/<artifact path>`.
+//
+// Without a `build` query parameter, the latest passed build of a branch is
+// used. The branch is given with `?branch=` and defaults to the default branch
+// of the pipeline. When several jobs upload the same path, `?job=` picks the
+// job by step key or label.
+package buildkite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// DefaultAPIURL is the api of buildkite.com.
+const DefaultAPIURL = "https://api.buildkite.com/v2"
+
+// Options configures access to Buildkite.
+type Options struct {
+	// API is the url of the api. Defaults to DefaultAPIURL.
+	API string
+
+	// Token is an api access token with the read_builds and read_artifacts
+	// scopes. Defaults to $BUILDKITE_API_TOKEN.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Artifact points to an artifact of a build.
+type Artifact struct {
+	Org      string
+	Pipeline string
+	Path     string
+	Job      string
+
+	// Build is the number of the build. Zero for the latest passed build of
+	// Branch.
+	Build  int
+	Branch string
+}
+
+// IsArtifactURL tells if an url is a buildkite:// url.
+func IsArtifactURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "buildkite://")
+}
+
+// ParseURL parses a buildkite:// url.
+func ParseURL(rawURL string) (Artifact, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(parsed.Path, "/"), "/", 2)
+	if parsed.Scheme != "buildkite" || parsed.Host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Artifact{}, fmt.Errorf("Invalid Buildkite url. Should be buildkite://org/pipeline/<artifact path>: %s", rawURL)
+	}
+
+	query := parsed.Query()
+	artifact := Artifact{
+		Org:      parsed.Host,
+		Pipeline: parts[0],
+		Path:     parts[1],
+		Job:      query.Get("job"),
+		Branch:   query.Get("branch"),
+	}
+
+	if build := query.Get("build"); build != "" {
+		if artifact.Build, err = strconv.Atoi(build); err != nil || artifact.Build <= 0 {
+			return Artifact{}, fmt.Errorf("Invalid Buildkite build [%s]. Should be a number", build)
+		}
+	}
+
+	return artifact, nil
+}
+
+// URL gives the buildkite:// url of the artifact.
+func (a Artifact) URL() string {
+	query := url.Values{}
+	if a.Build != 0 {
+		query.Set("build", strconv.Itoa(a.Build))
+	} else if a.Branch != "" {
+		query.Set("branch", a.Branch)
+	}
+	if a.Job != "" {
+		query.Set("job", a.Job)
+	}
+
+	u := url.URL{Scheme: "buildkite", Host: a.Org, Path: "/" + a.Pipeline + "/" + a.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func (a Artifact) pipelinePath() string {
+	return "/organizations/" + url.PathEscape(a.Org) + "/pipelines/" + url.PathEscape(a.Pipeline)
+}
+
+type build struct {
+	Number int `json:"number"`
+	Jobs   []struct {
+		ID      string `json:"id"`
+		StepKey string `json:"step_key"`
+		Name    string `json:"name"`
+	} `json:"jobs"`
+}
+
+type artifact struct {
+	JobID       string `json:"job_id"`
+	Path        string `json:"path"`
+	DownloadURL string `json:"download_url"`
+}
+
+// Pin turns an artifact of the latest passed build into the artifact of an
+// actual build, so that it's cached as such.
+func Pin(ctx context.Context, a Artifact, options Options) (Artifact, error) {
+	if a.Build != 0 {
+		return a, nil
+	}
+
+	branch := a.Branch
+	if branch == "" {
+		pipeline := struct {
+			DefaultBranch string `json:"default_branch"`
+		}{}
+		if err := options.get(ctx, a.pipelinePath(), &pipeline); err != nil {
+			return Artifact{}, err
+		}
+		branch = pipeline.DefaultBranch
+	}
+
+	var builds []build
+	params := url.Values{"branch": {branch}, "state": {"passed"}, "per_page": {"1"}}
+	if err := options.get(ctx, a.pipelinePath()+"/builds?"+params.Encode(), &builds); err != nil {
+		return Artifact{}, err
+	}
+
+	if len(builds) == 0 {
+		return Artifact{}, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find a passed build of %s/%s on %s", a.Org, a.Pipeline, branch)
+	}
+
+	a.Build = builds[0].Number
+	return a, nil
+}
+
+// ArtifactURL finds the download url of an artifact.
+func ArtifactURL(ctx context.Context, a Artifact, options Options) (string, error) {
+	a, err := Pin(ctx, a, options)
+	if err != nil {
+		return "", err
+	}
+
+	buildPath := a.pipelinePath() + "/builds/" + strconv.Itoa(a.Build)
+
+	jobs := map[string]bool{}
+	if a.Job != "" {
+		var b build
+		if err := options.get(ctx, buildPath, &b); err != nil {
+			return "", err
+		}
+		for _, job := range b.Jobs {
+			if job.StepKey == a.Job || job.Name == a.Job {
+				jobs[job.ID] = true
+			}
+		}
+		if len(jobs) == 0 {
+			return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find job %s in build %d of %s/%s", a.Job, a.Build, a.Org, a.Pipeline)
+		}
+	}
+
+	var artifacts []artifact
+	params := url.Values{"per_page": {"100"}}
+	for page := 1; ; page++ {
+		var found []artifact
+		params.Set("page", strconv.Itoa(page))
+		if err := options.get(ctx, buildPath+"/artifacts?"+params.Encode(), &found); err != nil {
+			return "", err
+		}
+		artifacts = append(artifacts, found...)
+		if len(found) < 100 {
+			break
+		}
+	}
+
+	for _, found := range artifacts {
+		if found.Path == a.Path && (a.Job == "" || jobs[found.JobID]) {
+			return found.DownloadURL, nil
+		}
+	}
+
+	return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find artifact %s in build %d of %s/%s", a.Path, a.Build, a.Org, a.Pipeline)
+}
+
+// Open downloads an artifact.
+func Open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, error) {
+	a, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadURL, err := ArtifactURL(ctx, a, options)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := options.do(ctx, downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (o Options) get(ctx context.Context, path string, v interface{}) error {
+	api := o.API
+	if api == "" {
+		api = DefaultAPIURL
+	}
+
+	resp, err := o.do(ctx, strings.TrimSuffix(api, "/")+path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (o Options) do(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return nil, err
+	}
+
+	token := o.Token
+	if token == "" {
+		token = os.Getenv("BUILDKITE_API_TOKEN")
+	}
+	if token == "" {
+		return nil, errdefs.Errorf(errdefs.ErrUnauthorized, "Buildkite requires an api token. Use --buildkite-token or $BUILDKITE_API_TOKEN")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// Artifacts redirect to where they're actually stored. The token is only
+	// sent to Buildkite itself.
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Host != via[0].URL.Host {
+				req.Header.Del("Authorization")
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,103 @@
+package buildkite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	artifact, err := ParseURL("buildkite://org/pipeline/dist/tool.tgz?branch=release&job=package")
+	assert.NoError(t, err)
+	assert.Equal(t, Artifact{Org: "org", Pipeline: "pipeline", Path: "dist/tool.tgz", Job: "package", Branch: "release"}, artifact)
+	assert.Equal(t, "buildkite://org/pipeline/dist/tool.tgz?branch=release&job=package", artifact.URL())
+
+	artifact.Build = 42
+	assert.Equal(t, "buildkite://org/pipeline/dist/tool.tgz?build=42&job=package", artifact.URL())
+
+	_, err = ParseURL("buildkite://org/pipeline")
+	assert.Error(t, err)
+	_, err = ParseURL("buildkite://org/pipeline/tool.tgz?build=latest")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	os.Unsetenv("BUILDKITE_API_TOKEN")
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+		fmt.Fprint(w, "artifact of "+r.URL.Path)
+	}))
+	defer storage.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/organizations/org/pipelines/pipeline":
+			fmt.Fprint(w, `{"default_branch":"main"}`)
+		case "/organizations/org/pipelines/pipeline/builds":
+			assert.Equal(t, "main", r.URL.Query().Get("branch"))
+			assert.Equal(t, "passed", r.URL.Query().Get("state"))
+			fmt.Fprint(w, `[{"number":7}]`)
+		case "/organizations/org/pipelines/pipeline/builds/7":
+			fmt.Fprint(w, `{"number":7,"jobs":[{"id":"j1","step_key":"test"},{"id":"j2","name":":package: Package"}]}`)
+		case "/organizations/org/pipelines/pipeline/builds/7/artifacts":
+			fmt.Fprint(w, `[
+				{"job_id":"j1","path":"dist/tool.tgz","download_url":"`+server.URL+`/download/1"},
+				{"job_id":"j2","path":"dist/tool.tgz","download_url":"`+server.URL+`/download/2"}
+			]`)
+		case "/download/1", "/download/2":
+			http.Redirect(w, r, storage.URL+r.URL.Path, http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{API: server.URL, Token: "secret"}
+
+	artifact, err := Pin(context.Background(), Artifact{Org: "org", Pipeline: "pipeline", Path: "dist/tool.tgz"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, artifact.Build)
+
+	content, err := read("buildkite://org/pipeline/dist/tool.tgz", options)
+	assert.NoError(t, err)
+	assert.Equal(t, "artifact of /download/1", content)
+
+	content, err = read("buildkite://org/pipeline/dist/tool.tgz?build=7&job=:package: Package", options)
+	assert.NoError(t, err)
+	assert.Equal(t, "artifact of /download/2", content)
+
+	_, err = read("buildkite://org/pipeline/dist/missing.tgz?build=7", options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = read("buildkite://org/pipeline/dist/tool.tgz?build=7&job=unknown", options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = read("buildkite://org/pipeline/dist/tool.tgz?build=7", Options{API: server.URL})
+	assert.True(t, errors.Is(err, errdefs.ErrUnauthorized))
+}
+
+func read(url string, options Options) (string, error) {
+	reader, err := Open(context.Background(), url, options)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/gcs"
+	"github.com/dgageot/getme/s3"
+	"github.com/pkg/errors"
+)
+
+// Backend stores cached files. Whatever the backend, cached files are made
+// available on the local disk so that they can be copied or extracted.
+type Backend interface {
+	// LocalPath gives the path where a cached file is made available on disk.
+	LocalPath(name string) (string, error)
+	// Fetch makes a cached file available at its local path. It returns
+	// false if the file is not in the cache.
+	Fetch(ctx context.Context, name string, localPath string) (bool, error)
+	// Store saves a file that was downloaded to its local path.
+	Store(ctx context.Context, name string, localPath string) error
+}
+
+var backend Backend = &Disk{}
+
+// UseBackend changes the backend used to cache files.
+func UseBackend(b Backend) {
+	backend = b
+}
+
+// LocalDir gives the directory where cached files are made available on disk.
+func LocalDir() (string, error) {
+	return backend.LocalPath("")
+}
+
+// NewBackend creates a backend given its location: a directory on disk,
+// `memory`, an `s3://bucket/prefix` or a `gs://bucket/prefix` url.
+func NewBackend(location string, options files.Options) (Backend, error) {
+	switch {
+	case location == "":
+		return &Disk{}, nil
+	case location == "memory":
+		return &Memory{}, nil
+	case strings.HasPrefix(location, "s3://"):
+		parts := strings.SplitN(strings.TrimPrefix(location, "s3://"), "/", 2)
+		if parts[0] == "" {
+			return nil, errors.New("Invalid S3 cache location: " + location)
+		}
+
+		b := &S3{Bucket: parts[0], Options: options.S3()}
+		if len(parts) == 2 {
+			b.Prefix = parts[1]
+		}
+		return b, nil
+	case strings.HasPrefix(location, "gs://"):
+		parts := strings.SplitN(strings.TrimPrefix(location, "gs://"), "/", 2)
+		if parts[0] == "" {
+			return nil, errors.New("Invalid GCS cache location: " + location)
+		}
+
+		b := &GCS{Bucket: parts[0], Options: gcs.Options{CredentialsFile: options.GCSCredentials, Scope: gcs.ReadWriteScope}}
+		if len(parts) == 2 {
+			b.Prefix = parts[1]
+		}
+		return b, nil
+	default:
+		return &Disk{Dir: location}, nil
+	}
+}
+
+// Disk caches files in a directory. Defaults to `~/.getme`.
+type Disk struct {
+	Dir string
+}
+
+func (d *Disk) LocalPath(name string) (string, error) {
+	if d.Dir == "" {
+		return PathToFileInCache(name)
+	}
+	return filepath.Join(d.Dir, name), nil
+}
+
+func (d *Disk) Fetch(ctx context.Context, name string, localPath string) (bool, error) {
+	_, err := os.Stat(localPath)
+	return err == nil, nil
+}
+
+func (d *Disk) Store(ctx context.Context, name string, localPath string) error {
+	return nil
+}
+
+// Memory caches files in memory. It's meant for tests and short lived
+// programs that use getme as a library. Files are still made available on
+// disk, in a private temporary directory.
+type Memory struct {
+	lock  sync.Mutex
+	files map[string][]byte
+	dir   string
+}
+
+func (m *Memory) LocalPath(name string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.dir == "" {
+		dir, err := ioutil.TempDir("", "getme-memory-")
+		if err != nil {
+			return "", err
+		}
+		m.dir = dir
+	}
+	return filepath.Join(m.dir, name), nil
+}
+
+func (m *Memory) Fetch(ctx context.Context, name string, localPath string) (bool, error) {
+	m.lock.Lock()
+	content, found := m.files[name]
+	m.lock.Unlock()
+
+	if !found {
+		return false, nil
+	}
+
+	return true, fetchTo(localPath, bytes.NewReader(content))
+}
+
+func (m *Memory) Store(ctx context.Context, name string, localPath string) error {
+	content, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[name] = content
+
+	return nil
+}
+
+// S3 caches files in an S3 bucket, for ephemeral runners that have no
+// persistent disk.
+type S3 struct {
+	Bucket  string
+	Prefix  string
+	Options s3.Options
+}
+
+// LocalPath gives a path in the user's cache directory, like `~/.cache` on
+// Linux, that other users can't read or write.
+func (b *S3) LocalPath(name string) (string, error) {
+	return privateCachePath("getme-s3", name)
+}
+
+func (b *S3) Fetch(ctx context.Context, name string, localPath string) (bool, error) {
+	reader, err := s3.Open(ctx, b.Bucket, prefixed(b.Prefix, name), b.Options)
+	if err == s3.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	return true, fetchTo(localPath, reader)
+}
+
+func (b *S3) Store(ctx context.Context, name string, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s3.Put(ctx, b.Bucket, prefixed(b.Prefix, name), file, b.Options)
+}
+
+// GCS caches files in a Google Cloud Storage bucket, like S3.
+type GCS struct {
+	Bucket  string
+	Prefix  string
+	Options gcs.Options
+}
+
+// LocalPath gives a path in the user's cache directory that other users
+// can't read or write.
+func (b *GCS) LocalPath(name string) (string, error) {
+	return privateCachePath("getme-gcs", name)
+}
+
+func (b *GCS) Fetch(ctx context.Context, name string, localPath string) (bool, error) {
+	reader, err := gcs.Open(ctx, b.Bucket, prefixed(b.Prefix, name), b.Options)
+	if err == gcs.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	return true, fetchTo(localPath, reader)
+}
+
+func (b *GCS) Store(ctx context.Context, name string, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return gcs.Put(ctx, b.Bucket, prefixed(b.Prefix, name), file, b.Options)
+}
+
+// privateCachePath gives a path in a directory of the user's cache directory,
+// like `~/.cache` on Linux, that other users can't read or write.
+func privateCachePath(dir, name string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(cacheDir, dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func prefixed(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + name
+}
+
+// fetchTo writes a file fetched from a backend. It's renamed once complete so
+// that a partial file is never mistaken for a cached file.
+func fetchTo(localPath string, reader io.Reader) error {
+	tmp, err := files.CreateTemp(localPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		return err
+	}
+	return files.Commit(tmp, localPath)
+}
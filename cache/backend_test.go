@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/gcs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackend(t *testing.T) {
+	b, err := NewBackend("gs://bucket/some/prefix/", files.Options{GCSCredentials: "credentials.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, &GCS{Bucket: "bucket", Prefix: "some/prefix/", Options: gcs.Options{CredentialsFile: "credentials.json", Scope: gcs.ReadWriteScope}}, b)
+	assert.Equal(t, "some/prefix/file", prefixed(b.(*GCS).Prefix, "file"))
+
+	b, err = NewBackend("s3://bucket", files.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket", b.(*S3).Bucket)
+	assert.Equal(t, "file", prefixed(b.(*S3).Prefix, "file"))
+
+	_, err = NewBackend("gs://", files.Options{})
+	assert.EqualError(t, err, "Invalid GCS cache location: gs://")
+}
+
+func TestMemoryBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	memory := &Memory{}
+
+	found, err := memory.Fetch(context.Background(), "file", filepath.Join(dir, "fetched"))
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	stored := filepath.Join(dir, "stored")
+	assert.NoError(t, ioutil.WriteFile(stored, []byte("content"), 0666))
+	assert.NoError(t, memory.Store(context.Background(), "file", stored))
+
+	found, err = memory.Fetch(context.Background(), "file", filepath.Join(dir, "fetched"))
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "fetched"))
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	local, err := memory.LocalPath("file")
+	assert.NoError(t, err)
+	defer os.RemoveAll(filepath.Dir(local))
+	info, err := os.Stat(filepath.Dir(local))
+	assert.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+}
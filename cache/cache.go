@@ -1,16 +1,22 @@
 package cache
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/tracing"
+	"github.com/dgageot/getme/urls"
 	"github.com/pkg/errors"
 )
 
@@ -57,52 +63,246 @@ func PathToFileInCache(name string) (string, error) {
 	return filepath.Join(folderCache, name), nil
 }
 
+// Entry is a file in the cache.
+type Entry struct {
+	// URL is the url the file was downloaded from, once resolved.
+	URL  string
+	Path string
+
+	// Sha256 is the sha256 of the file, when it's known without reading the
+	// file again: when it's downloaded or verified.
+	Sha256 string
+
+	// Filename is the name of the file given by the server when it was
+	// downloaded, if any. Use Cache.Filename to read it for cached files.
+	Filename string
+
+	// Cached tells if the file was already in the cache.
+	Cached bool
+}
+
+// ChecksumError is returned when a downloaded file doesn't have the expected
+// sha256.
+type ChecksumError = files.ChecksumError
+
+// Cache downloads files to a backend.
+type Cache struct {
+	Backend Backend
+}
+
+// Default gives the cache that uses the backend set with UseBackend.
+func Default() Cache {
+	return Cache{Backend: backend}
+}
+
 // Download downloads an url to the cache if needed. Additional headers can be given.
 // This is helpful to pass authentication tokens.
-func Download(url string, options files.Options, force bool) (path string, err error) {
-	destination, err := PathToUrl(url)
+func Download(ctx context.Context, url string, options files.Options, force bool) (path string, err error) {
+	return Default().Download(ctx, url, options, force)
+}
+
+// Get downloads an url to the cache if needed and describes the cached file.
+func Get(ctx context.Context, url string, options files.Options, force bool) (Entry, error) {
+	return Default().Get(ctx, url, options, force)
+}
+
+// Download downloads an url to the cache if needed and gives the path to the
+// cached file.
+func (c Cache) Download(ctx context.Context, url string, options files.Options, force bool) (path string, err error) {
+	entry, err := c.Get(ctx, url, options, force)
 	if err != nil {
 		return "", err
 	}
+	return entry.Path, nil
+}
+
+// Get downloads an url to the cache if needed and describes the cached file.
+// Cancelling the context stops the download, leaving nothing in the cache.
+func (c Cache) Get(ctx context.Context, url string, options files.Options, force bool) (entry Entry, err error) {
+	ctx, span := tracing.Start(ctx, "cache.get", tracing.Attributes{"url": url})
+	defer func() {
+		span.Set("cached", entry.Cached)
+		span.End(err)
+	}()
+
+	url, err = files.Resolve(ctx, url, options)
+	if err != nil {
+		return Entry{}, err
+	}
+	span.Set("url", url)
+	name := sanitizeUrl(url)
+	defer lock(name)()
+
+	destination, err := c.Backend.LocalPath(name)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	logs.From(ctx).Debugln("Cache path of", url, "is", destination)
 
 	inCache := false
-	if _, err := os.Stat(destination); err == nil {
-		log.Println("Already in cache:", url)
-		inCache = true
+	if !force {
+		if inCache, err = c.Backend.Fetch(ctx, name, destination); err != nil {
+			return Entry{}, err
+		}
+		if !inCache {
+			logs.From(ctx).Event(logs.Debug, "cache_miss", logs.Fields{"url": url, "path": destination}, "Not in cache:", url)
+		}
+	} else {
+		logs.From(ctx).Debugln("Forced download of", url)
 	}
 
-	if !force && inCache && options.Sha256 != "" {
-		sha, err := getSha256(destination)
-		if err != nil {
-			return "", err
+	// A cached file is only used if it has the expected sha256. It could be
+	// corrupted, or be another file downloaded from the same url. Downloaded
+	// files are hashed and verified while they are written, before they
+	// replace the cached file.
+	var sha, filename string
+	if inCache && options.Sha256 != "" {
+		if sha, err = checksum(ctx, destination, options); err != nil {
+			return Entry{}, err
 		}
 
 		if sha != options.Sha256 {
-			log.Println("Invalid sha256 for ", url)
+			logs.From(ctx).Event(logs.Info, "checksum", logs.Fields{"url": url, "sha256": sha, "expected": options.Sha256, "valid": false}, "Cached", url, "has an invalid sha256, downloading it again")
 			force = true
 		}
 	}
+	if inCache && !force {
+		logs.From(ctx).Event(logs.Info, "cache_hit", logs.Fields{"url": url, "path": destination}, "Already in cache:", url)
+	}
 
 	if force || !inCache {
-		log.Println("Download", url, "to", destination)
+		logs.From(ctx).Event(logs.Info, "download_start", logs.Fields{"url": url, "path": destination}, "Download", url, "to", destination)
 
-		if err := files.Download(url, destination, options); err != nil {
-			return "", err
+		start := time.Now()
+		downloadCtx, downloadSpan := tracing.Start(ctx, "download", tracing.Attributes{"url": url})
+		downloaded, err := files.Download(downloadCtx, url, destination, options)
+		if err != nil {
+			downloadSpan.End(err)
+			if checksumErr, ok := err.(*ChecksumError); ok {
+				logs.From(ctx).Event(logs.Info, "checksum", logs.Fields{"url": url, "sha256": checksumErr.Actual, "expected": checksumErr.Expected, "valid": false}, "Invalid sha256 for", url)
+			}
+			logs.From(ctx).Event(logs.Info, "download_error", logs.Fields{"url": url, "error": err.Error()}, "Unable to download", url)
+			return Entry{}, err
 		}
+
+		fields := logs.Fields{"url": url, "path": destination, "duration": time.Since(start).Seconds()}
+		if info, err := os.Stat(destination); err == nil {
+			fields["size"] = info.Size()
+			downloadSpan.Set("size", info.Size())
+		}
+		sha, filename = downloaded.Sha256, downloaded.Filename
+		downloadSpan.Set("sha256", sha)
+		downloadSpan.End(nil)
+		logs.From(ctx).Event(logs.Debug, "download_finish", fields, "Downloaded", url, "in", time.Since(start))
 	}
 
 	if options.Sha256 != "" {
-		sha, err := getSha256(destination)
+		logs.From(ctx).Event(logs.Debug, "checksum", logs.Fields{"url": url, "sha256": sha, "valid": true}, "Valid sha256 for", url)
+	}
+
+	if force || !inCache {
+		if err := c.Backend.Store(ctx, name, destination); err != nil {
+			return Entry{}, err
+		}
+		if err := c.storeFilename(ctx, name, filename); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	if err := fetched(options, url, destination, sha, inCache && !force); err != nil {
+		return Entry{}, err
+	}
+
+	return Entry{URL: url, Path: destination, Sha256: sha, Filename: filename, Cached: inCache && !force}, nil
+}
+
+// Filename gives the name of a cached file: the one given by the server when
+// it was downloaded, or the one at the end of its url.
+func (c Cache) Filename(ctx context.Context, entry Entry) (string, error) {
+	if entry.Filename != "" {
+		return entry.Filename, nil
+	}
+
+	// Names given by servers are cached along the files.
+	name := sanitizeUrl(entry.URL) + filenameSuffix
+	path, err := c.Backend.LocalPath(name)
+	if err != nil {
+		return "", err
+	}
+	found, err := c.Backend.Fetch(ctx, name, path)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		content, err := ioutil.ReadFile(path)
 		if err != nil {
 			return "", err
 		}
+		if filename := urls.Basename(strings.TrimSpace(string(content))); filename != "" {
+			return filename, nil
+		}
+	}
 
-		if sha != options.Sha256 {
-			return "", errors.New("Invalid sha256 for " + url)
+	if filename := urls.Filename(entry.URL); filename != "" {
+		return filename, nil
+	}
+	return "", fmt.Errorf("Unable to find the name of the file downloaded from %s", entry.URL)
+}
+
+// filenameSuffix is added to the name of a cached file to give the name of
+// the file that records its original name.
+const filenameSuffix = ".filename"
+
+// storeFilename records the name of a file given by the server, if any.
+func (c Cache) storeFilename(ctx context.Context, name string, filename string) error {
+	if filename == "" {
+		return nil
+	}
+
+	path, err := c.Backend.LocalPath(name + filenameSuffix)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(filename), 0666); err != nil {
+		return err
+	}
+	return c.Backend.Store(ctx, name+filenameSuffix, path)
+}
+
+// fetched tells the Fetched callback of the options about a cached file. Its
+// sha256 is computed if it's not known yet.
+func fetched(options files.Options, url string, path string, sha string, cached bool) error {
+	if options.Fetched == nil {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if sha == "" {
+		if sha, err = Sha256(path); err != nil {
+			return err
 		}
 	}
 
-	return destination, nil
+	return options.Fetched(files.FetchedFile{URL: url, Sha256: sha, Size: info.Size(), Cached: cached})
+}
+
+// checksum computes the sha256 of a file, as a span of the download.
+func checksum(ctx context.Context, filePath string, options files.Options) (sha string, err error) {
+	if options.Verifying != nil {
+		options.Verifying()
+	}
+
+	_, span := tracing.Start(ctx, "checksum", tracing.Attributes{"path": filePath})
+	defer func() {
+		span.Set("sha256", sha)
+		span.End(err)
+	}()
+
+	return Sha256(filePath)
 }
 
 func sanitizeUrl(url string) string {
@@ -112,7 +312,8 @@ func sanitizeUrl(url string) string {
 	return sanitizedUrl
 }
 
-func getSha256(filePath string) (string, error) {
+// Sha256 computes the hex encoded sha256 of a file.
+func Sha256(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
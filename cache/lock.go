@@ -0,0 +1,23 @@
+package cache
+
+import "sync"
+
+var (
+	locksLock sync.Mutex
+	locks     = map[string]*sync.Mutex{}
+)
+
+// lock prevents a file from being downloaded to the cache by several
+// goroutines at the same time. It gives the function that unlocks it.
+func lock(name string) func() {
+	locksLock.Lock()
+	l, found := locks[name]
+	if !found {
+		l = &sync.Mutex{}
+		locks[name] = l
+	}
+	locksLock.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/tracing"
+)
+
+// Stream reads an url without downloading it to the cache first. If the url
+// is already cached, the cached file is read instead. With tee, the streamed
+// content is also stored in the cache as it's read. The url is always read to
+// the end so that an error is returned if it doesn't match its digest, even
+// if the consumer already returned.
+func Stream(ctx context.Context, url string, options files.Options, force bool, tee bool, consume func(io.Reader) error) error {
+	return Default().Stream(ctx, url, options, force, tee, consume)
+}
+
+// Stream reads an url without downloading it to the cache first, like the
+// Stream function.
+func (c Cache) Stream(ctx context.Context, url string, options files.Options, force bool, tee bool, consume func(io.Reader) error) (err error) {
+	ctx, span := tracing.Start(ctx, "cache.stream", tracing.Attributes{"url": url, "tee": tee})
+	defer func() { span.End(err) }()
+
+	url, err = files.Resolve(ctx, url, options)
+	if err != nil {
+		return err
+	}
+	name := sanitizeUrl(url)
+	defer lock(name)()
+
+	destination, err := c.Backend.LocalPath(name)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		inCache, err := c.Backend.Fetch(ctx, name, destination)
+		if err != nil {
+			return err
+		}
+
+		if inCache {
+			valid, err := hasSha256(ctx, destination, options)
+			if err != nil {
+				return err
+			}
+
+			if valid {
+				logs.From(ctx).Event(logs.Info, "cache_hit", logs.Fields{"url": url, "path": destination}, "Already in cache:", url)
+				if err := fetched(options, url, destination, options.Sha256, true); err != nil {
+					return err
+				}
+				return consumeFile(destination, consume)
+			}
+			logs.From(ctx).Event(logs.Info, "checksum", logs.Fields{"url": url, "expected": options.Sha256, "valid": false}, "Cached", url, "has an invalid sha256, streaming it again")
+		}
+	}
+
+	reader, err := files.Open(ctx, url, options)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	source := io.TeeReader(reader, io.MultiWriter(hash, counter))
+
+	var tmp *os.File
+	if tee {
+		if tmp, err = files.CreateTemp(destination); err != nil {
+			return err
+		}
+		// The partial file is removed if the stream fails or is cancelled.
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		source = io.TeeReader(source, tmp)
+	}
+
+	if err := consume(source); err != nil {
+		return err
+	}
+
+	// Read what the consumer didn't need, like the padding at the end of a
+	// tar archive, so that the whole file is hashed, cached and audited.
+	// Downloaders that verify a digest given by the server or the registry
+	// only fail once they've read everything.
+	if _, err := io.Copy(ioutil.Discard, source); err != nil {
+		return err
+	}
+
+	if options.Sha256 != "" && options.Verifying != nil {
+		options.Verifying()
+	}
+	sha := hex.EncodeToString(hash.Sum(nil))
+	if options.Sha256 != "" && sha != options.Sha256 {
+		return &ChecksumError{URL: url, Expected: options.Sha256, Actual: sha}
+	}
+
+	if options.Fetched != nil {
+		if err := options.Fetched(files.FetchedFile{URL: url, Sha256: sha, Size: counter.size}); err != nil {
+			return err
+		}
+	}
+
+	if !tee {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := files.Commit(tmp, destination); err != nil {
+		return err
+	}
+
+	return c.Backend.Store(ctx, name, destination)
+}
+
+type countingWriter struct {
+	size int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return len(p), nil
+}
+
+func consumeFile(path string, consume func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return consume(file)
+}
+
+func hasSha256(ctx context.Context, path string, options files.Options) (bool, error) {
+	if options.Sha256 == "" {
+		return true, nil
+	}
+
+	sha, err := checksum(ctx, path, options)
+	if err != nil {
+		return false, err
+	}
+
+	return sha == options.Sha256, nil
+}
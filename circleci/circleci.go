@@ -0,0 +1,344 @@
+// Package circleci downloads the artifacts of CircleCI jobs, given as
+// `circleci://org/repo/<workflow>/<job>/<artifact path>`.
+//
+// Without a `pipeline` query parameter, the latest pipeline of a branch whose
+// workflow succeeded is used. The branch is given with `?branch=` and defaults
+// to the default branch of the project. Projects are on GitHub unless another
+// vcs is given, like `?vcs=bb` for Bitbucket.
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// DefaultAPIURL is the api of circleci.com.
+const DefaultAPIURL = "https://circleci.com/api/v2"
+
+// maxPages is how many pages of pipelines are looked at to find the latest
+// successful one.
+const maxPages = 5
+
+// Options configures access to CircleCI.
+type Options struct {
+	// API is the url of the api. Defaults to DefaultAPIURL.
+	API string
+
+	// Token is a personal api token. Defaults to $CIRCLE_TOKEN.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Artifact points to an artifact of a job.
+type Artifact struct {
+	VCS      string
+	Org      string
+	Repo     string
+	Workflow string
+	Job      string
+	Path     string
+
+	// Pipeline is the number of the pipeline. Zero for the latest successful
+	// pipeline of Branch.
+	Pipeline int
+	Branch   string
+}
+
+// IsArtifactURL tells if an url is a circleci:// url.
+func IsArtifactURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "circleci://")
+}
+
+// ParseURL parses a circleci:// url.
+func ParseURL(rawURL string) (Artifact, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(parsed.Path, "/"), "/", 4)
+	if parsed.Scheme != "circleci" || parsed.Host == "" || len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return Artifact{}, fmt.Errorf("Invalid CircleCI url. Should be circleci://org/repo/<workflow>/<job>/<artifact path>: %s", rawURL)
+	}
+
+	query := parsed.Query()
+	artifact := Artifact{
+		VCS:      query.Get("vcs"),
+		Org:      parsed.Host,
+		Repo:     parts[0],
+		Workflow: parts[1],
+		Job:      parts[2],
+		Path:     parts[3],
+		Branch:   query.Get("branch"),
+	}
+	if artifact.VCS == "" {
+		artifact.VCS = "gh"
+	}
+
+	if pipeline := query.Get("pipeline"); pipeline != "" {
+		if artifact.Pipeline, err = strconv.Atoi(pipeline); err != nil || artifact.Pipeline <= 0 {
+			return Artifact{}, fmt.Errorf("Invalid CircleCI pipeline [%s]. Should be a number", pipeline)
+		}
+	}
+
+	return artifact, nil
+}
+
+// URL gives the circleci:// url of the artifact.
+func (a Artifact) URL() string {
+	query := url.Values{}
+	if a.VCS != "gh" {
+		query.Set("vcs", a.VCS)
+	}
+	if a.Pipeline != 0 {
+		query.Set("pipeline", strconv.Itoa(a.Pipeline))
+	} else if a.Branch != "" {
+		query.Set("branch", a.Branch)
+	}
+
+	u := url.URL{Scheme: "circleci", Host: a.Org, Path: "/" + a.Repo + "/" + a.Workflow + "/" + a.Job + "/" + a.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func (a Artifact) slug() string {
+	return a.VCS + "/" + a.Org + "/" + a.Repo
+}
+
+type pipeline struct {
+	ID     string `json:"id"`
+	Number int    `json:"number"`
+}
+
+type workflow struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type job struct {
+	Name   string `json:"name"`
+	Number int    `json:"job_number"`
+}
+
+type artifact struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+// Pin turns an artifact of the latest successful pipeline into the artifact
+// of an actual pipeline, so that it's cached as such.
+func Pin(ctx context.Context, a Artifact, options Options) (Artifact, error) {
+	if a.Pipeline != 0 {
+		return a, nil
+	}
+
+	branch := a.Branch
+	if branch == "" {
+		project := struct {
+			VCSInfo struct {
+				DefaultBranch string `json:"default_branch"`
+			} `json:"vcs_info"`
+		}{}
+		if err := options.get(ctx, "/project/"+a.slug(), &project); err != nil {
+			return Artifact{}, err
+		}
+		branch = project.VCSInfo.DefaultBranch
+	}
+
+	pageToken := ""
+	for page := 0; page < maxPages; page++ {
+		pipelines := struct {
+			Items         []pipeline `json:"items"`
+			NextPageToken string     `json:"next_page_token"`
+		}{}
+		params := url.Values{"branch": {branch}}
+		if pageToken != "" {
+			params.Set("page-token", pageToken)
+		}
+		if err := options.get(ctx, "/project/"+a.slug()+"/pipeline?"+params.Encode(), &pipelines); err != nil {
+			return Artifact{}, err
+		}
+
+		for _, p := range pipelines.Items {
+			succeeded, err := options.workflow(ctx, p.ID, a.Workflow)
+			if err != nil {
+				return Artifact{}, err
+			}
+			if succeeded != nil {
+				a.Pipeline = p.Number
+				return a, nil
+			}
+		}
+
+		if pageToken = pipelines.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	return Artifact{}, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find a successful %s workflow of %s on %s", a.Workflow, a.slug(), branch)
+}
+
+// workflow finds the successful workflow of a pipeline with a given name. It
+// gives nil if there's none.
+func (o Options) workflow(ctx context.Context, pipelineID, name string) (*workflow, error) {
+	workflows := struct {
+		Items []workflow `json:"items"`
+	}{}
+	if err := o.get(ctx, "/pipeline/"+pipelineID+"/workflow", &workflows); err != nil {
+		return nil, err
+	}
+
+	for _, w := range workflows.Items {
+		if w.Name == name && w.Status == "success" {
+			return &w, nil
+		}
+	}
+	return nil, nil
+}
+
+// ArtifactURL finds the download url of an artifact.
+func ArtifactURL(ctx context.Context, a Artifact, options Options) (string, error) {
+	a, err := Pin(ctx, a, options)
+	if err != nil {
+		return "", err
+	}
+
+	var p pipeline
+	if err := options.get(ctx, "/project/"+a.slug()+"/pipeline/"+strconv.Itoa(a.Pipeline), &p); err != nil {
+		return "", err
+	}
+
+	w, err := options.workflow(ctx, p.ID, a.Workflow)
+	if err != nil {
+		return "", err
+	}
+	if w == nil {
+		return "", errdefs.Errorf(errdefs.ErrNotFound, "Workflow %s of pipeline %d of %s didn't succeed", a.Workflow, a.Pipeline, a.slug())
+	}
+
+	jobs := struct {
+		Items []job `json:"items"`
+	}{}
+	if err := options.get(ctx, "/workflow/"+w.ID+"/job", &jobs); err != nil {
+		return "", err
+	}
+
+	for _, j := range jobs.Items {
+		if j.Name != a.Job {
+			continue
+		}
+
+		artifacts := struct {
+			Items []artifact `json:"items"`
+		}{}
+		if err := options.get(ctx, "/project/"+a.slug()+"/"+strconv.Itoa(j.Number)+"/artifacts", &artifacts); err != nil {
+			return "", err
+		}
+
+		for _, found := range artifacts.Items {
+			if found.Path == a.Path {
+				return found.URL, nil
+			}
+		}
+		return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find artifact %s of job %s #%d", a.Path, a.Job, j.Number)
+	}
+
+	return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find job %s in workflow %s of pipeline %d", a.Job, a.Workflow, a.Pipeline)
+}
+
+// Open downloads an artifact.
+func Open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, error) {
+	a, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactURL, err := ArtifactURL(ctx, a, options)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := options.do(ctx, artifactURL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (o Options) get(ctx context.Context, path string, v interface{}) error {
+	api := o.API
+	if api == "" {
+		api = DefaultAPIURL
+	}
+
+	resp, err := o.do(ctx, strings.TrimSuffix(api, "/")+path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (o Options) do(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return nil, err
+	}
+
+	token := o.Token
+	if token == "" {
+		token = os.Getenv("CIRCLE_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("Circle-Token", token)
+	}
+
+	// Artifacts redirect to where they're actually stored. The token is only
+	// sent to CircleCI itself.
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Host != via[0].URL.Host {
+				req.Header.Del("Circle-Token")
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		statusErr := &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized) && token == "" {
+			return nil, fmt.Errorf("%w. Private projects require --circle-token or $CIRCLE_TOKEN", statusErr)
+		}
+		return nil, statusErr
+	}
+
+	return resp, nil
+}
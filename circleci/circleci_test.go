@@ -0,0 +1,107 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	artifact, err := ParseURL("circleci://org/repo/build/package/dist/tool.tgz?branch=release")
+	assert.NoError(t, err)
+	assert.Equal(t, Artifact{VCS: "gh", Org: "org", Repo: "repo", Workflow: "build", Job: "package", Path: "dist/tool.tgz", Branch: "release"}, artifact)
+	assert.Equal(t, "circleci://org/repo/build/package/dist/tool.tgz?branch=release", artifact.URL())
+
+	artifact, err = ParseURL("circleci://org/repo/build/package/tool.tgz?vcs=bb&pipeline=42")
+	assert.NoError(t, err)
+	assert.Equal(t, "bb/org/repo", artifact.slug())
+	assert.Equal(t, 42, artifact.Pipeline)
+	assert.Equal(t, "circleci://org/repo/build/package/tool.tgz?pipeline=42&vcs=bb", artifact.URL())
+
+	_, err = ParseURL("circleci://org/repo/build/tool.tgz")
+	assert.Error(t, err)
+	_, err = ParseURL("circleci://org/repo/build/package/tool.tgz?pipeline=latest")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	os.Unsetenv("CIRCLE_TOKEN")
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Circle-Token"))
+		fmt.Fprint(w, "artifact of "+r.URL.Path)
+	}))
+	defer storage.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Circle-Token") != "secret" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/project/gh/org/repo":
+			fmt.Fprint(w, `{"vcs_info":{"default_branch":"main"}}`)
+		case "/project/gh/org/repo/pipeline":
+			assert.Equal(t, "main", r.URL.Query().Get("branch"))
+			fmt.Fprint(w, `{"items":[{"id":"p3","number":3},{"id":"p2","number":2}]}`)
+		case "/project/gh/org/repo/pipeline/2":
+			fmt.Fprint(w, `{"id":"p2","number":2}`)
+		case "/project/gh/org/repo/pipeline/3":
+			fmt.Fprint(w, `{"id":"p3","number":3}`)
+		case "/pipeline/p3/workflow":
+			fmt.Fprint(w, `{"items":[{"id":"w3","name":"build","status":"failed"}]}`)
+		case "/pipeline/p2/workflow":
+			fmt.Fprint(w, `{"items":[{"id":"w1","name":"lint","status":"success"},{"id":"w2","name":"build","status":"success"}]}`)
+		case "/workflow/w2/job":
+			fmt.Fprint(w, `{"items":[{"name":"test","job_number":20},{"name":"package","job_number":21}]}`)
+		case "/project/gh/org/repo/21/artifacts":
+			fmt.Fprint(w, `{"items":[{"path":"dist/tool.tgz","url":"`+server.URL+`/artifacts/21/dist/tool.tgz"}]}`)
+		case "/artifacts/21/dist/tool.tgz":
+			http.Redirect(w, r, storage.URL+"/21/tool.tgz", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{API: server.URL, Token: "secret"}
+
+	artifact, err := Pin(context.Background(), Artifact{VCS: "gh", Org: "org", Repo: "repo", Workflow: "build", Job: "package", Path: "dist/tool.tgz"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, artifact.Pipeline)
+
+	content, err := read("circleci://org/repo/build/package/dist/tool.tgz", options)
+	assert.NoError(t, err)
+	assert.Equal(t, "artifact of /21/tool.tgz", content)
+
+	_, err = read("circleci://org/repo/build/package/dist/tool.tgz?pipeline=3", options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = read("circleci://org/repo/build/package/dist/missing.tgz?pipeline=2", options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = read("circleci://org/repo/build/package/dist/tool.tgz", Options{API: server.URL})
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+	assert.Contains(t, err.Error(), "$CIRCLE_TOKEN")
+}
+
+func read(url string, options Options) (string, error) {
+	reader, err := Open(context.Background(), url, options)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Config holds the settings of a config file, like:
+//
+//	cache: /var/cache/getme
+//	retries: 3
+//	header:
+//	  - "X-Build: 42"
+//	hosts:
+//	  artifacts.example.com:
+//	    headers:
+//	      - "X-Api-Key: KEY"
+//	    token-env: ARTIFACTS_TOKEN
+//	    ca-cert: /etc/ssl/certs/corp.pem
+//	    insecure-skip-verify: false
+//
+// Top level settings are the default values of the flags with the same name.
+type Config struct {
+	Flags map[string][]string
+	Hosts Hosts
+}
+
+// Host holds the settings of a single host.
+type Host struct {
+	// Headers are added to every request to the host.
+	Headers []string
+	// TokenEnv is the env variable containing the api token of the host.
+	TokenEnv string
+	// CACert is a pem file with the certificates trusted for the host.
+	CACert string
+	// InsecureSkipVerify disables the verification of the host certificate.
+	InsecureSkipVerify bool
+}
+
+// Hosts are the settings of each host.
+type Hosts map[string]Host
+
+// Path gives the path to the config file: `$XDG_CONFIG_HOME/getme/config.yaml`,
+// which defaults to `~/.config/getme/config.yaml`.
+func Path() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if runtime.GOOS == "windows" {
+			home = os.Getenv("USERPROFILE")
+		}
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "getme", "config.yaml")
+}
+
+// Load reads a config file. A missing file gives an empty config.
+func Load(path string) (Config, error) {
+	if path == "" {
+		return Config{}, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	defer file.Close()
+
+	config, err := parse(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("Invalid config file %s: %s", path, err)
+	}
+	return config, nil
+}
+
+func parse(r io.Reader) (Config, error) {
+	document, err := parseYAML(r)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{Flags: map[string][]string{}, Hosts: Hosts{}}
+	for name, value := range document {
+		if name != "hosts" {
+			values, err := valuesOf(name, value)
+			if err != nil {
+				return Config{}, err
+			}
+			config.Flags[name] = values
+			continue
+		}
+
+		hosts, ok := value.(map[string]interface{})
+		if !ok && value != "" {
+			return Config{}, errors.New("Hosts should be a mapping")
+		}
+		for host, settings := range hosts {
+			if config.Hosts[host], err = parseHost(host, settings); err != nil {
+				return Config{}, err
+			}
+		}
+	}
+
+	return config, nil
+}
+
+func parseHost(host string, value interface{}) (Host, error) {
+	settings, ok := value.(map[string]interface{})
+	if !ok {
+		return Host{}, fmt.Errorf("Settings of host %s should be a mapping", host)
+	}
+
+	var config Host
+	for name, value := range settings {
+		values, err := valuesOf(host+"."+name, value)
+		if err != nil {
+			return Host{}, err
+		}
+
+		switch name {
+		case "headers":
+			config.Headers = values
+		case "token-env":
+			config.TokenEnv, err = single(host+"."+name, values)
+		case "ca-cert":
+			config.CACert, err = single(host+"."+name, values)
+		case "insecure-skip-verify":
+			var insecure string
+			if insecure, err = single(host+"."+name, values); err == nil {
+				config.InsecureSkipVerify = insecure == "true"
+				if insecure != "true" && insecure != "false" {
+					err = fmt.Errorf("%s.%s should be true or false", host, name)
+				}
+			}
+		default:
+			err = fmt.Errorf("Unknown setting %s.%s", host, name)
+		}
+		if err != nil {
+			return Host{}, err
+		}
+	}
+
+	return config, nil
+}
+
+// valuesOf reads a scalar or a list of scalars.
+func valuesOf(name string, value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case string:
+		return []string{value}, nil
+	case []string:
+		return value, nil
+	}
+	return nil, fmt.Errorf("%s should be a value or a list of values", name)
+}
+
+func single(name string, values []string) (string, error) {
+	if len(values) != 1 {
+		return "", fmt.Errorf("%s should be a single value", name)
+	}
+	return values[0], nil
+}
+
+// For gives the settings of a host. The port, if any, is ignored unless
+// settings are given for this specific port.
+func (h Hosts) For(host string) (Host, bool) {
+	if settings, found := h[host]; found {
+		return settings, true
+	}
+
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		settings, found := h[host[:i]]
+		return settings, found
+	}
+	return Host{}, false
+}
+
+// Token gives the api token of the host, read from its env variable.
+func (h Host) Token() string {
+	if h.TokenEnv == "" {
+		return ""
+	}
+	return os.Getenv(h.TokenEnv)
+}
+
+// Transport uses the tls settings of each host. Requests to other hosts go
+// through the base transport.
+func (h Hosts) Transport(base http.RoundTripper) (http.RoundTripper, error) {
+	transports := map[string]http.RoundTripper{}
+	for host, settings := range h {
+		if settings.CACert == "" && !settings.InsecureSkipVerify {
+			continue
+		}
+
+		transport, ok := base.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("Tls settings of host %s can't be applied", host)
+		}
+		transport = transport.Clone()
+
+		tlsConfig := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify}
+		if settings.CACert != "" {
+			pem, err := ioutil.ReadFile(settings.CACert)
+			if err != nil {
+				return nil, err
+			}
+
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("No certificate found in %s", settings.CACert)
+			}
+		}
+		transport.TLSClientConfig = tlsConfig
+
+		transports[host] = transport
+	}
+
+	if len(transports) == 0 {
+		return base, nil
+	}
+	return hostTransport{base: base, transports: transports}, nil
+}
+
+type hostTransport struct {
+	base       http.RoundTripper
+	transports map[string]http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, found := t.transports[req.URL.Host]; found {
+		return transport.RoundTrip(req)
+	}
+	if transport, found := t.transports[req.URL.Hostname()]; found {
+		return transport.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
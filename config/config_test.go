@@ -0,0 +1,101 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const content = `# Defaults
+cache: /var/cache/getme
+retries: 3 # transient errors
+header:
+- "X-Build: 42"
+hosts:
+  artifacts.example.com:
+    headers:
+      - 'X-Api-Key: it''s secret'
+    token-env: ARTIFACTS_TOKEN
+  localhost:8443:
+    insecure-skip-verify: true
+`
+
+func TestParse(t *testing.T) {
+	config, err := parse(strings.NewReader(content))
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"cache":   {"/var/cache/getme"},
+		"retries": {"3"},
+		"header":  {"X-Build: 42"},
+	}, config.Flags)
+	assert.Equal(t, Hosts{
+		"artifacts.example.com": {Headers: []string{"X-Api-Key: it's secret"}, TokenEnv: "ARTIFACTS_TOKEN"},
+		"localhost:8443":        {InsecureSkipVerify: true},
+	}, config.Hosts)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, invalid := range []string{
+		"cache",
+		"cache: /tmp\n  retries: 3",
+		"cache: /tmp\ncache: /var",
+		"cache: \"/tmp",
+		"hosts:\n  example.com:\n    token: TOKEN",
+		"hosts:\n  example.com:\n    insecure-skip-verify: yes",
+		"hosts:\n  example.com: TOKEN",
+	} {
+		_, err := parse(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHostsFor(t *testing.T) {
+	hosts := Hosts{
+		"example.com":    {TokenEnv: "DEFAULT"},
+		"localhost:8443": {TokenEnv: "LOCAL"},
+	}
+
+	host, found := hosts.For("example.com:443")
+	assert.True(t, found)
+	assert.Equal(t, "DEFAULT", host.TokenEnv)
+
+	host, found = hosts.For("localhost:8443")
+	assert.True(t, found)
+	assert.Equal(t, "LOCAL", host.TokenEnv)
+
+	_, found = hosts.For("localhost:8080")
+	assert.False(t, found)
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-config-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	config, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, config.Hosts, 2)
+
+	config, err = Load(filepath.Join(dir, "missing.yaml"))
+	assert.NoError(t, err)
+	assert.Empty(t, config.Flags)
+}
+
+func TestTransport(t *testing.T) {
+	transport, err := Hosts{"example.com": {TokenEnv: "TOKEN"}}.Transport(http.DefaultTransport)
+	assert.NoError(t, err)
+	assert.Equal(t, http.DefaultTransport, transport)
+
+	transport, err = Hosts{"localhost": {InsecureSkipVerify: true}}.Transport(http.DefaultTransport)
+	assert.NoError(t, err)
+	assert.True(t, transport.(hostTransport).transports["localhost"].(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+}
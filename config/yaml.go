@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// line is a significant line of a yaml document.
+type line struct {
+	number int
+	indent int
+	text   string
+}
+
+// parseYAML reads the subset of yaml used by config files: nested mappings,
+// lists of scalars and plain or quoted scalars. Mappings are given as
+// map[string]interface{}, lists as []string and scalars as string.
+func parseYAML(r io.Reader) (map[string]interface{}, error) {
+	var lines []line
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("Line %d: tabs can't be used for indentation", number)
+		}
+
+		lines = append(lines, line{number: number, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	value, rest, err := parseBlock(lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("Line %d: unexpected indentation", rest[0].number)
+	}
+
+	mapping, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Line %d: a mapping is expected", lines[0].number)
+	}
+	return mapping, nil
+}
+
+// parseBlock reads the lines indented like the first one, and their children.
+func parseBlock(lines []line) (interface{}, []line, error) {
+	indent := lines[0].indent
+
+	if isListItem(lines[0].text) {
+		var list []string
+		for len(lines) > 0 && lines[0].indent == indent && isListItem(lines[0].text) {
+			value, err := scalar(strings.TrimSpace(strings.TrimPrefix(lines[0].text, "-")), lines[0].number)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, value)
+			lines = lines[1:]
+		}
+		return list, lines, nil
+	}
+
+	mapping := map[string]interface{}{}
+	for len(lines) > 0 && lines[0].indent == indent {
+		current := lines[0]
+		lines = lines[1:]
+
+		key, rawValue, err := keyValue(current)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, found := mapping[key]; found {
+			return nil, nil, fmt.Errorf("Line %d: duplicate key [%s]", current.number, key)
+		}
+
+		if rawValue != "" {
+			if mapping[key], err = scalar(rawValue, current.number); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		// Lists can be indented like their key.
+		if len(lines) > 0 && (lines[0].indent > indent || (lines[0].indent == indent && isListItem(lines[0].text))) {
+			if mapping[key], lines, err = parseBlock(lines); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			mapping[key] = ""
+		}
+	}
+
+	if len(lines) > 0 && lines[0].indent > indent {
+		return nil, nil, fmt.Errorf("Line %d: unexpected indentation", lines[0].number)
+	}
+	return mapping, lines, nil
+}
+
+func isListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// keyValue reads `key: value`. Keys can contain colons, like `host:8080`.
+func keyValue(l line) (string, string, error) {
+	i := -1
+	for j := 0; j < len(l.text) && i == -1; j++ {
+		if l.text[j] == ':' && (j+1 == len(l.text) || l.text[j+1] == ' ') {
+			i = j
+		}
+	}
+	if i <= 0 {
+		return "", "", fmt.Errorf("Line %d: `key: value` is expected", l.number)
+	}
+
+	key, err := scalar(strings.TrimSpace(l.text[:i]), l.number)
+	if err != nil {
+		return "", "", err
+	}
+	value := strings.TrimSpace(l.text[i+1:])
+	if strings.HasPrefix(value, "#") {
+		value = ""
+	}
+	return key, value, nil
+}
+
+// scalar reads a plain, single quoted or double quoted value. Comments are
+// removed from plain values.
+func scalar(value string, number int) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := closingQuote(value)
+		if end == -1 {
+			return "", fmt.Errorf("Line %d: unterminated string", number)
+		}
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("Line %d: invalid string %s", number, value[:end+1])
+		}
+		return unquoted, trailing(value[end+1:], number)
+	case strings.HasPrefix(value, "'"):
+		for i := 1; i < len(value); i++ {
+			if value[i] != '\'' {
+				continue
+			}
+			if i+1 < len(value) && value[i+1] == '\'' {
+				i++
+				continue
+			}
+			return strings.Replace(value[1:i], "''", "'", -1), trailing(value[i+1:], number)
+		}
+		return "", fmt.Errorf("Line %d: unterminated string", number)
+	}
+
+	if i := strings.Index(value, " #"); i != -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// trailing checks that only a comment follows a quoted value.
+func trailing(rest string, number int) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("Line %d: unexpected %s after string", number, rest)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Jar is a cookie jar that reads and writes the Netscape cookie files used by
+// curl. Cookies given on the command line are sent to every host.
+type Jar struct {
+	jar *cookiejar.Jar
+
+	lock    sync.Mutex
+	entries map[string]entry
+	fixed   []*http.Cookie
+}
+
+type entry struct {
+	domain    string
+	hostOnly  bool
+	path      string
+	secure    bool
+	httpOnly  bool
+	expires   time.Time
+	name      string
+	value     string
+	persisted bool
+}
+
+// NewJar creates an empty cookie jar.
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(nil)
+
+	return &Jar{
+		jar:     jar,
+		entries: map[string]entry{},
+	}
+}
+
+// SetCookies implements http.CookieJar.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		e := entry{
+			domain:   strings.TrimPrefix(cookie.Domain, "."),
+			path:     cookie.Path,
+			secure:   cookie.Secure,
+			httpOnly: cookie.HttpOnly,
+			name:     cookie.Name,
+			value:    cookie.Value,
+		}
+		if e.domain == "" {
+			e.domain = u.Hostname()
+			e.hostOnly = true
+		}
+		if e.path == "" || !strings.HasPrefix(e.path, "/") {
+			e.path = "/"
+		}
+
+		switch {
+		case cookie.MaxAge < 0:
+			e.expires = now
+		case cookie.MaxAge > 0:
+			e.expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			e.persisted = true
+		case !cookie.Expires.IsZero():
+			e.expires = cookie.Expires
+			e.persisted = true
+		}
+
+		key := e.domain + ";" + e.path + ";" + e.name
+		if !e.expires.IsZero() && !e.expires.After(now) {
+			delete(j.entries, key)
+		} else {
+			j.entries[key] = e
+		}
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	cookies := j.jar.Cookies(u)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for _, fixed := range j.fixed {
+		if !contains(cookies, fixed.Name) {
+			cookies = append(cookies, fixed)
+		}
+	}
+
+	return cookies
+}
+
+func contains(cookies []*http.Cookie, name string) bool {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddHeader adds cookies given as in a Cookie header, like
+// `name=value; other=value`. They are sent to every host.
+func (j *Jar) AddHeader(header string) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for _, pair := range strings.Split(header, ";") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("Invalid cookie [%s]. Should be [name=value]", pair)
+		}
+		j.fixed = append(j.fixed, &http.Cookie{Name: parts[0], Value: parts[1]})
+	}
+
+	return nil
+}
+
+// Load reads cookies from a Netscape cookie file.
+func (j *Jar) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("Invalid line in cookie file %s: %s", path, line)
+		}
+
+		domain := strings.TrimPrefix(fields[0], ".")
+		secure := fields[3] == "TRUE"
+		cookie := &http.Cookie{
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if fields[1] == "TRUE" {
+			cookie.Domain = domain
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		j.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: cookie.Path}, []*http.Cookie{cookie})
+	}
+
+	return scanner.Err()
+}
+
+// Save writes the cookies to a Netscape cookie file.
+func (j *Jar) Save(path string) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	keys := make([]string, 0, len(j.entries))
+	for key := range j.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, "# Netscape HTTP Cookie File")
+	fmt.Fprintln(w)
+	for _, key := range keys {
+		e := j.entries[key]
+
+		domain := e.domain
+		if !e.hostOnly {
+			domain = "." + domain
+		}
+		if e.httpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+
+		var expires int64
+		if e.persisted {
+			expires = e.expires.Unix()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, flag(!e.hostOnly), e.path, flag(e.secure), expires, e.name, e.value)
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func flag(value bool) string {
+	if value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
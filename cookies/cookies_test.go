@@ -0,0 +1,93 @@
+package cookies
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionCookieAcrossRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1234", Path: "/", MaxAge: 3600})
+			http.Redirect(w, r, "/asset", http.StatusFound)
+		case "/asset":
+			session, err := r.Cookie("session")
+			if err != nil || session.Value != "1234" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, "asset")
+		}
+	}))
+	defer server.Close()
+
+	jar := NewJar()
+	resp, err := (&http.Client{Jar: jar}).Get(server.URL + "/download")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "asset", string(body))
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-cookies-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	u, _ := url.Parse("https://portal.example.com/login")
+	jar := NewJar()
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1234", Path: "/", MaxAge: 3600, Secure: true, HttpOnly: true},
+		{Name: "sso", Value: "abcd", Domain: ".example.com"},
+		{Name: "deleted", Value: "x", MaxAge: -1},
+	})
+
+	path := filepath.Join(dir, "cookies.txt")
+	assert.NoError(t, jar.Save(path))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "#HttpOnly_portal.example.com\tFALSE\t/\tTRUE\t")
+	assert.Contains(t, string(content), ".example.com\tTRUE\t/\tFALSE\t0\tsso\tabcd\n")
+	assert.NotContains(t, string(content), "deleted")
+
+	loaded := NewJar()
+	assert.NoError(t, loaded.Load(path))
+
+	assetURL, _ := url.Parse("https://downloads.example.com/asset")
+	assert.Equal(t, []string{"sso=abcd"}, names(loaded.Cookies(assetURL)))
+
+	portalURL, _ := url.Parse("https://portal.example.com/asset")
+	assert.Equal(t, []string{"sso=abcd", "session=1234"}, names(loaded.Cookies(portalURL)))
+}
+
+func TestAddHeader(t *testing.T) {
+	jar := NewJar()
+	assert.NoError(t, jar.AddHeader("session=1234; lang=en"))
+
+	u, _ := url.Parse("https://any.example.com/")
+	assert.Equal(t, []string{"session=1234", "lang=en"}, names(jar.Cookies(u)))
+
+	assert.Error(t, jar.AddHeader("invalid"))
+}
+
+func names(cookies []*http.Cookie) []string {
+	var pairs []string
+	for _, cookie := range cookies {
+		pairs = append(pairs, cookie.Name+"="+strings.TrimSpace(cookie.Value))
+	}
+	return pairs
+}
@@ -0,0 +1,96 @@
+package credhelper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Credentials are given by a credential helper. A helper that only gives a
+// password gives a bearer token.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Authorization gives the value of the Authorization header.
+func (c Credentials) Authorization() string {
+	if c.Username == "" {
+		return "Bearer " + c.Password
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+}
+
+// Helpers configures the credential helpers. Each one is either a command,
+// used for every host, or `host=command`, used for a single host.
+type Helpers []string
+
+// For gives the command of the credential helper of a host, if any.
+func (h Helpers) For(host string) string {
+	command := ""
+	for _, helper := range h {
+		name, hostCommand, ok := split(helper)
+		if !ok {
+			command = helper
+		} else if name == host {
+			return hostCommand
+		}
+	}
+	return command
+}
+
+// split reads `host=command`. A helper whose first `=` comes after a space
+// is a command with arguments.
+func split(helper string) (string, string, bool) {
+	i := strings.Index(helper, "=")
+	if i <= 0 || strings.ContainsAny(helper[:i], " \t") {
+		return "", "", false
+	}
+	return helper[:i], helper[i+1:], true
+}
+
+// Get runs a credential helper the way git does: the command is given the
+// `get` argument and reads `protocol=https` and `host=example.com` lines
+// on stdin. It writes `username=...` and `password=...` lines on stdout.
+func Get(command, protocol, host string) (Credentials, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return Credentials{}, errors.New("The credential helper is empty")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], append(args[1:], "get")...)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\n\n", protocol, host))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return Credentials{}, fmt.Errorf("Credential helper %s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var credentials Credentials
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "username":
+			credentials.Username = parts[1]
+		case "password":
+			credentials.Password = parts[1]
+		}
+	}
+
+	if credentials.Password == "" {
+		return Credentials{}, fmt.Errorf("Credential helper %s gave no password for %s", args[0], host)
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,56 @@
+package credhelper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFor(t *testing.T) {
+	helpers := Helpers{"issue-token --ttl 1h", "nexus.example.com=nexus-helper", "cdn.example.com=cdn-helper --env=prod"}
+
+	assert.Equal(t, "nexus-helper", helpers.For("nexus.example.com"))
+	assert.Equal(t, "cdn-helper --env=prod", helpers.For("cdn.example.com"))
+	assert.Equal(t, "issue-token --ttl 1h", helpers.For("other.example.com"))
+	assert.Equal(t, "", Helpers{"nexus.example.com=nexus-helper"}.For("other.example.com"))
+}
+
+func TestAuthorization(t *testing.T) {
+	assert.Equal(t, "Bearer token", Credentials{Password: "token"}.Authorization())
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", Credentials{Username: "user", Password: "secret"}.Authorization())
+}
+
+func TestGet(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-credhelper-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	helper := filepath.Join(dir, "helper")
+	script := `#!/bin/sh
+[ "$1" = "get" ] || exit 1
+while read line && [ -n "$line" ]; do
+  case "$line" in host=*) host="${line#host=}";; esac
+done
+echo "username=user"
+echo "password=secret-for-$host"
+`
+	assert.NoError(t, ioutil.WriteFile(helper, []byte(script), 0755))
+
+	credentials, err := Get(helper, "https", "nexus.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "user", Password: "secret-for-nexus.example.com"}, credentials)
+
+	_, err = Get("false", "https", "nexus.example.com")
+	assert.Error(t, err)
+
+	_, err = Get("true", "https", "nexus.example.com")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Package digest checks downloaded content against the digests published by
+// servers and registries, like the sha1 of Maven artifacts or the sha512 of
+// npm packages.
+package digest
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// Verify checks that a reader gives content of an expected hex encoded
+// digest. The algorithm is sha1, sha256 or sha512. A mismatch is an error
+// once the content is read.
+func Verify(reader io.ReadCloser, url, algorithm, expected string) (io.ReadCloser, error) {
+	var h hash.Hash
+	switch algorithm {
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		reader.Close()
+		return nil, fmt.Errorf("Unsupported digest [%s]. Should be sha1, sha256 or sha512", algorithm)
+	}
+
+	return &verifier{ReadCloser: reader, url: url, algorithm: algorithm, expected: strings.ToLower(expected), hash: h}, nil
+}
+
+type verifier struct {
+	io.ReadCloser
+	url       string
+	algorithm string
+	expected  string
+	hash      hash.Hash
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected {
+			return n, errdefs.Errorf(errdefs.ErrChecksumMismatch, "Invalid %s for %s: expected %s, got %s", v.algorithm, v.url, v.expected, actual)
+		}
+	}
+	return n, err
+}
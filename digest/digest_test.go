@@ -0,0 +1,27 @@
+package digest
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	reader, err := Verify(ioutil.NopCloser(strings.NewReader("content")), "https://example.com/tool.tgz", "sha256", "ED7002B439E9AC845F22357D822BAC1444730FBDB6016D3EC9432297B9EC9F73")
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	reader, err = Verify(ioutil.NopCloser(strings.NewReader("corrupted")), "https://example.com/tool.tgz", "sha1", "040f06fd774092478d450774f5ba30c5da78acc8")
+	assert.NoError(t, err)
+	_, err = ioutil.ReadAll(reader)
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+
+	_, err = Verify(ioutil.NopCloser(strings.NewReader("content")), "https://example.com/tool.tgz", "md5", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package doctor
+
+func freeSpace(dir string) (uint64, bool) {
+	return 0, false
+}
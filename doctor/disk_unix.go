@@ -0,0 +1,16 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package doctor
+
+import (
+	"syscall"
+)
+
+func freeSpace(dir string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true
+}
@@ -0,0 +1,240 @@
+package doctor
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgageot/getme/s3"
+	"github.com/pkg/errors"
+)
+
+// DefaultURLs are checked for connectivity when no url is given.
+var DefaultURLs = []string{
+	"https://github.com",
+	"https://api.github.com",
+	"https://s3.amazonaws.com",
+	"https://ci.appveyor.com",
+}
+
+// minFreeSpace is the free space under which the cache is reported as full.
+const minFreeSpace = 1 << 30
+
+// Config describes what should be diagnosed.
+type Config struct {
+	CacheDir  string
+	AuthToken string
+	S3        s3.Options
+	URLs      []string
+}
+
+type check struct {
+	name string
+	run  func() (string, error)
+}
+
+// Run diagnoses the environment and prints a report. It fails if any check fails.
+func Run(out io.Writer, config Config) error {
+	checks := []check{
+		{"Cache directory " + config.CacheDir, func() (string, error) { return checkCacheDir(config.CacheDir) }},
+		{"Proxy configuration", checkProxy},
+	}
+
+	urls := config.URLs
+	if len(urls) == 0 {
+		urls = DefaultURLs
+	}
+	for _, u := range urls {
+		u := u
+		checks = append(checks, check{"Connectivity to " + u, func() (string, error) { return checkURL(u) }})
+	}
+
+	if config.AuthToken != "" {
+		checks = append(checks, check{"Github authentication token", func() (string, error) { return checkGithubToken(config.AuthToken) }})
+	}
+	checks = append(checks, check{"Amazon S3 credentials", func() (string, error) { return checkS3Credentials(config.S3) }})
+
+	failures := 0
+	for _, c := range checks {
+		details, err := c.run()
+		if err != nil {
+			failures++
+			fmt.Fprintf(out, "[KO] %s: %v\n", c.name, err)
+			continue
+		}
+
+		if details != "" {
+			fmt.Fprintf(out, "[OK] %s: %s\n", c.name, details)
+		} else {
+			fmt.Fprintf(out, "[OK] %s\n", c.name)
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+	return nil
+}
+
+func checkCacheDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "Unable to create the cache directory. Check its permissions or use --cache")
+	}
+
+	probe, err := ioutil.TempFile(dir, "doctor")
+	if err != nil {
+		return "", errors.Wrap(err, "The cache directory is not writable. Check its permissions or use --cache")
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, known := freeSpace(dir)
+	if !known {
+		return "writable", nil
+	}
+	if free < minFreeSpace {
+		return "", fmt.Errorf("Only %s free. Clean up the disk or use --cache", humanize(free))
+	}
+	return fmt.Sprintf("writable, %s free", humanize(free)), nil
+}
+
+func checkProxy() (string, error) {
+	var found []string
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		value := os.Getenv(name)
+		if value == "" {
+			value = os.Getenv(strings.ToLower(name))
+		}
+		if value == "" {
+			continue
+		}
+
+		if name != "NO_PROXY" {
+			proxy, err := url.Parse(value)
+			if err != nil || proxy.Host == "" {
+				return "", fmt.Errorf("Invalid %s [%s]. Should be an url like http://proxy:3128", name, value)
+			}
+		}
+		found = append(found, name+"="+value)
+	}
+
+	if len(found) == 0 {
+		return "no proxy", nil
+	}
+	return strings.Join(found, ", "), nil
+}
+
+func checkURL(rawURL string) (string, error) {
+	req, err := http.NewRequest("HEAD", rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		return "", errors.Wrap(err, "Invalid proxy configuration")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", explain(err, proxy)
+	}
+	resp.Body.Close()
+
+	details := resp.Status
+	if proxy != nil {
+		details += " through proxy " + proxy.Host
+	}
+	return details, nil
+}
+
+func checkGithubToken(token string) (string, error) {
+	req, err := http.NewRequest("GET", "https://api.github.com/rate_limit", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", errors.New("The token was rejected by Github. It might be expired or revoked")
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", errors.New(resp.Status)
+	}
+	return "accepted, " + resp.Header.Get("X-RateLimit-Remaining") + " api calls remaining", nil
+}
+
+func checkS3Credentials(options s3.Options) (string, error) {
+	credentials, err := s3.ResolveCredentials(options)
+	if err != nil {
+		return "", err
+	}
+	if credentials.AccessKey == "" {
+		return "none found, only public buckets are accessible", nil
+	}
+
+	if err := s3.CheckCredentials(credentials); err != nil {
+		return "", err
+	}
+	return "valid", nil
+}
+
+// explain turns low level network errors into actionable messages.
+func explain(err error, proxy *url.URL) error {
+	cause := err
+	if urlErr, ok := cause.(*url.Error); ok {
+		cause = urlErr.Err
+	}
+	if opErr, ok := cause.(*net.OpError); ok {
+		cause = opErr.Err
+	}
+
+	switch cause.(type) {
+	case x509.UnknownAuthorityError:
+		return errors.Wrap(err, "Certificate signed by an unknown authority. A TLS intercepting proxy might require its CA to be added with SSL_CERT_FILE")
+	case x509.HostnameError:
+		return errors.Wrap(err, "Certificate doesn't match the host. A proxy might be intercepting TLS connections")
+	case x509.CertificateInvalidError:
+		return errors.Wrap(err, "Invalid certificate. Check that the system clock is correct")
+	case *net.DNSError:
+		return errors.Wrap(err, "Unable to resolve the host. Check the DNS configuration or set HTTPS_PROXY")
+	}
+
+	if netErr, ok := cause.(net.Error); ok && netErr.Timeout() {
+		if proxy != nil {
+			return errors.Wrap(err, "Timeout. Check that the proxy "+proxy.Host+" is reachable")
+		}
+		return errors.Wrap(err, "Timeout. A firewall might be blocking the connection, or a proxy might be needed")
+	}
+
+	return err
+}
+
+func humanize(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,68 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SharedURL matches Dropbox share links, either legacy `/s/` and `/sh/` links
+// or `/scl/` links.
+var SharedURL = regexp.MustCompile(`^https://(www\.)?dropbox\.com/(s|sh|scl)/`)
+
+// DirectURL rewrites a share link, that shows a preview page, into a direct
+// download link. Other query parameters, such as `rlkey`, are kept.
+func DirectURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := parsed.Query()
+	query.Del("raw")
+	query.Set("dl", "1")
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String(), nil
+}
+
+// Open downloads the file behind a share link. Dropbox redirects a few times
+// and sets cookies along the way, so those are kept between redirects.
+func Open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	direct, err := DirectURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Jar: jar}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", direct, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		resp.Body.Close()
+		return nil, errors.New("Dropbox returned an html page instead of the file. The link might have expired or be restricted")
+	}
+
+	return resp.Body, nil
+}
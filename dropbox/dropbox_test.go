@@ -0,0 +1,30 @@
+package dropbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedURL(t *testing.T) {
+	assert.True(t, SharedURL.MatchString("https://www.dropbox.com/s/abcdef/archive.tgz?dl=0"))
+	assert.True(t, SharedURL.MatchString("https://www.dropbox.com/scl/fi/abcdef/archive.tgz?rlkey=xyz&dl=0"))
+	assert.True(t, SharedURL.MatchString("https://dropbox.com/sh/abcdef/folder?dl=0"))
+
+	assert.False(t, SharedURL.MatchString("https://dl.dropboxusercontent.com/s/abcdef/archive.tgz"))
+	assert.False(t, SharedURL.MatchString("https://www.dropbox.com/home"))
+}
+
+func TestDirectURL(t *testing.T) {
+	direct, err := DirectURL("https://www.dropbox.com/s/abcdef/archive.tgz?dl=0")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.dropbox.com/s/abcdef/archive.tgz?dl=1", direct)
+
+	direct, err = DirectURL("https://www.dropbox.com/scl/fi/abcdef/archive.tgz?rlkey=xyz&dl=0")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.dropbox.com/scl/fi/abcdef/archive.tgz?dl=1&rlkey=xyz", direct)
+
+	direct, err = DirectURL("https://www.dropbox.com/s/abcdef/archive.tgz?raw=1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.dropbox.com/s/abcdef/archive.tgz?dl=1", direct)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/hooks"
+	"github.com/dgageot/getme/logs"
+)
+
+// Ensure downloads an url. If it doesn't exist, a trigger is run to build it,
+// then the url is checked until it exists, for at most a given timeout. The
+// trigger is either a webhook, that receives the url in a json post, or a
+// command, like hooks.
+func Ensure(ctx context.Context, url, trigger string, interval, timeout time.Duration, options files.Options) error {
+	err := Download(ctx, url, options)
+	if !errors.Is(err, errdefs.ErrNotFound) {
+		return err
+	}
+
+	resolved, err := files.Resolve(ctx, url, options)
+	if err != nil {
+		return err
+	}
+
+	logs.Infoln(resolved, "doesn't exist. Trigger", trigger)
+	if err := runTrigger(ctx, trigger, resolved, options); err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		found, err := exists(waitCtx, resolved, options)
+		if found {
+			break
+		}
+		if err == nil {
+			logs.Infoln("Waiting for", resolved)
+			err = sleep(waitCtx, interval)
+		}
+		if err != nil {
+			if ctx.Err() == nil && waitCtx.Err() == context.DeadlineExceeded {
+				return errdefs.Errorf(errdefs.ErrNotFound, "Timed out after %s waiting for %s", timeout, resolved)
+			}
+			return err
+		}
+	}
+
+	return Download(ctx, url, options)
+}
+
+func runTrigger(ctx context.Context, trigger, url string, options files.Options) error {
+	if !strings.HasPrefix(trigger, "http://") && !strings.HasPrefix(trigger, "https://") {
+		return hooks.Run(ctx, []string{trigger}, hooks.Data{URL: url})
+	}
+
+	body, err := json.Marshal(map[string]string{"url": url})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", trigger, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := options.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Unable to trigger %s: %s", trigger, resp.Status)
+	}
+	return nil
+}
+
+// exists tells if an url can be downloaded, without downloading it.
+func exists(ctx context.Context, url string, options files.Options) (bool, error) {
+	options.Progress = nil
+
+	reader, err := files.Open(ctx, url, options)
+	if errors.Is(err, errdefs.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	reader.Close()
+	return true, nil
+}
+
+// sleep waits for a given duration, unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,94 @@
+// Package errdefs defines the causes of failure of getme. Callers can branch
+// on them with errors.Is, whatever the package that returned the error.
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a file, a release or an archive entry
+	// doesn't exist.
+	ErrNotFound = errors.New("Not Found")
+	// ErrUnauthorized is returned when credentials are missing or rejected.
+	ErrUnauthorized = errors.New("Unauthorized")
+	// ErrChecksumMismatch is returned when a file doesn't have the expected
+	// sha256.
+	ErrChecksumMismatch = errors.New("Checksum mismatch")
+	// ErrUnsupportedArchive is returned for files that can't be extracted.
+	ErrUnsupportedArchive = errors.New("Unsupported archive")
+)
+
+// StatusError is returned when a server answers with an error status. 404 and
+// 410 are ErrNotFound, 401 and 403 are ErrUnauthorized.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the server asks to wait before retrying, with
+	// a Retry-After header, or zero.
+	RetryAfter time.Duration
+}
+
+// NewStatusError describes the error status of a response, and the
+// Retry-After it gives, if any.
+func NewStatusError(resp *http.Response) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+}
+
+// retryAfter reads a Retry-After header, given in seconds or as a date.
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(time.Now()) {
+		return time.Until(date)
+	}
+	return 0
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+// Is implements the interface used by errors.Is.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// Errorf formats an error that is also a given cause, without repeating its
+// message. Like with fmt.Errorf, another error can be wrapped with %w.
+func Errorf(cause error, format string, a ...interface{}) error {
+	return Mark(cause, fmt.Errorf(format, a...))
+}
+
+// Mark makes an error also be a given cause, keeping its message.
+func Mark(cause error, err error) error {
+	return &marked{err: err, cause: cause}
+}
+
+type marked struct {
+	err   error
+	cause error
+}
+
+func (e *marked) Error() string {
+	return e.err.Error()
+}
+
+func (e *marked) Unwrap() error {
+	return e.err
+}
+
+func (e *marked) Is(target error) bool {
+	return target == e.cause
+}
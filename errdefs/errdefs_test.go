@@ -0,0 +1,54 @@
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusError(t *testing.T) {
+	notFound := fmt.Errorf("Unable to download: %w", &StatusError{StatusCode: 404, Status: "404 Not Found"})
+	assert.EqualError(t, notFound, "Unable to download: 404 Not Found")
+	assert.True(t, errors.Is(notFound, ErrNotFound))
+	assert.False(t, errors.Is(notFound, ErrUnauthorized))
+
+	forbidden := &StatusError{StatusCode: 403, Status: "403 Forbidden"}
+	assert.True(t, errors.Is(forbidden, ErrUnauthorized))
+
+	var status *StatusError
+	assert.True(t, errors.As(notFound, &status))
+	assert.Equal(t, 404, status.StatusCode)
+}
+
+func TestNewStatusError(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Status: "429 Too Many Requests", Header: http.Header{}}
+	assert.Equal(t, time.Duration(0), NewStatusError(resp).RetryAfter)
+
+	resp.Header.Set("Retry-After", "120")
+	assert.Equal(t, 2*time.Minute, NewStatusError(resp).RetryAfter)
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, float64(time.Hour), float64(NewStatusError(resp).RetryAfter), float64(2*time.Second))
+
+	resp.Header.Set("Retry-After", "soon")
+	assert.Equal(t, time.Duration(0), NewStatusError(resp).RetryAfter)
+}
+
+func TestMark(t *testing.T) {
+	_, err := os.Open("/missing/file")
+	marked := Mark(ErrNotFound, err)
+
+	assert.Equal(t, err.Error(), marked.Error())
+	assert.True(t, errors.Is(marked, ErrNotFound))
+	assert.True(t, errors.Is(marked, os.ErrNotExist))
+
+	err = Errorf(ErrNotFound, "Files not found in %s", "tool.zip")
+	assert.EqualError(t, err, "Files not found in tool.zip")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, errors.Is(err, ErrUnsupportedArchive))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// Exit codes tell wrapper scripts why getme failed, for example to retry
+// network timeouts but not missing artifacts.
+const (
+	exitFailure            = 1
+	exitChecksumMismatch   = 2
+	exitNotFound           = 3
+	exitUnauthorized       = 4
+	exitUnsupportedArchive = 5
+	exitTimeout            = 6
+	exitDeadline           = 7
+	// exitInterrupted is the code of shells for processes killed by SIGINT.
+	exitInterrupted = 130
+)
+
+// batchError is returned when some urls of a batch failed, or weren't all
+// downloaded before the deadline.
+type batchError struct {
+	message  string
+	errs     []error
+	deadline bool
+}
+
+func (e *batchError) Error() string {
+	return e.message
+}
+
+// exitCode gives the exit code matching an error. Failures of a batch give a
+// specific code only if they all have the same cause.
+func exitCode(err error) int {
+	var batch *batchError
+	if errors.As(err, &batch) {
+		if batch.deadline {
+			return exitDeadline
+		}
+
+		code := 0
+		for _, err := range batch.errs {
+			if code != 0 && exitCode(err) != code {
+				return exitFailure
+			}
+			code = exitCode(err)
+		}
+		return code
+	}
+
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, errdefs.ErrChecksumMismatch):
+		return exitChecksumMismatch
+	case errors.Is(err, errdefs.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, errdefs.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, errdefs.ErrUnsupportedArchive):
+		return exitUnsupportedArchive
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return exitTimeout
+	}
+	return exitFailure
+}
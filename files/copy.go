@@ -1,9 +1,11 @@
 package files
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 )
 
 // Copy copies a file to a given destination. It makes sur parent folders are
@@ -39,3 +41,43 @@ func CopyFrom(dst string, mode os.FileMode, reader io.Reader) error {
 	_, err := io.Copy(out, reader)
 	return err
 }
+
+// CreateTemp creates a file next to a destination, to be renamed to the
+// destination once it's completely written. Its name is unique so that
+// concurrent processes never write to the same file.
+func CreateTemp(destination string) (*os.File, error) {
+	if err := MkdirAll(filepath.Dir(destination)); err != nil {
+		return nil, err
+	}
+
+	for {
+		file, err := os.OpenFile(tempName(destination), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		return file, err
+	}
+}
+
+var tempCount uint64
+
+// tempName gives a name, next to a destination, that's unique to this
+// process.
+func tempName(destination string) string {
+	return fmt.Sprintf("%s.%d-%d.tmp", destination, os.Getpid(), atomic.AddUint64(&tempCount, 1))
+}
+
+// Commit flushes a file created with CreateTemp to the disk and renames it
+// to its destination. Whatever happens, the destination is either the
+// previous file or the complete new one.
+func Commit(file *os.File, destination string) error {
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), destination)
+}
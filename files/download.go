@@ -1,177 +1,645 @@
 package files
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
-
-	"github.com/dgageot/getme/appveyor"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dgageot/getme/artifactory"
+	"github.com/dgageot/getme/buildkite"
+	"github.com/dgageot/getme/circleci"
+	"github.com/dgageot/getme/config"
+	"github.com/dgageot/getme/credhelper"
+	"github.com/dgageot/getme/digest"
+	"github.com/dgageot/getme/errdefs"
 	"github.com/dgageot/getme/github"
+	"github.com/dgageot/getme/gomod"
 	http_headers "github.com/dgageot/getme/headers"
-	"github.com/minio/minio-go"
+	"github.com/dgageot/getme/helm"
+	"github.com/dgageot/getme/keychain"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/maven"
+	"github.com/dgageot/getme/netrc"
+	"github.com/dgageot/getme/nexus"
+	"github.com/dgageot/getme/npm"
+	"github.com/dgageot/getme/pypi"
+	"github.com/dgageot/getme/s3"
+	"github.com/dgageot/getme/semver"
+	"github.com/dgageot/getme/terraform"
+	"github.com/dgageot/getme/urls"
 	"github.com/pkg/errors"
 )
 
 type Options struct {
 	AuthToken            string
 	AuthTokenEnvVariable string
+	AuthTokenVaultPath   string
+	User                 string
+	Headers              []string
+	Hosts                config.Hosts
+	Jar                  http.CookieJar
+	HTTPClient           *http.Client
+	Negotiate            bool
 	S3AccessKey          string
 	S3SecretKey          string
-	Sha256               string
+	S3AccessKeyVaultPath string
+	S3SecretKeyVaultPath string
+	S3SessionToken       string
+	S3Profile            string
+	S3RoleArn            string
+	S3ExternalID         string
+	S3Endpoint           string
+	S3ForcePathStyle     bool
+	GCSCredentials       string
+	AzureSASToken        string
+	GoogleAPIKey         string
+	Version              string
+	PlatformNames        []string
+	GitHubTag            string
+	GitHubAsset          string
+	GitHubAutoAsset      bool
+	GitHubAPIURL         string
+	GitHubCommit         string
+	GitHubEnvToken       bool
+	GitlabToken          string
+	GiteaURL             string
+	GiteaToken           string
+	CircleToken          string
+	BuildkiteToken       string
+	ArtifactoryURL       string
+	ArtifactoryAPIKey    string
+	ArtifactoryToken     string
+	NexusURL             string
+	NexusToken           string
+	MavenRepository      string
+	NPMRegistry          string
+	NPMToken             string
+	PyPIIndex            string
+	GoProxy              string
+	GoSumDB              string
+	TerraformToken       string
+	CredentialHelpers    []string
+	IPFSGateway          string
+	WebDAVUser           string
+	WebDAVPassword       string
+	S3RequesterPays      bool
+	Retries              int
+	Progress             ProgressFunc
+	// Verifying, when set, is called before the sha256 of a file is checked.
+	Verifying func()
+	// Fetched, when set, is called once a file is fetched through the cache,
+	// whether it was cached or not. An error fails the fetch.
+	Fetched func(FetchedFile) error
+	Sha256  string
+	// Resolved tells that urls were already resolved by Resolve, so that
+	// they are downloaded as is.
+	Resolved bool
+	// Anonymous downloads http and https urls as is, without credentials,
+	// cookies or headers, and without running commands like curl or aria2c.
+	// It's used for urls given by untrusted clients.
+	Anonymous bool
+}
+
+// FetchedFile describes a file fetched through the cache.
+type FetchedFile struct {
+	// URL is the url the file was fetched from, once resolved.
+	URL    string
+	Sha256 string
+	Size   int64
+	Cached bool
+}
+
+// ChecksumError is returned when a downloaded file doesn't have the expected
+// sha256.
+type ChecksumError struct {
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return "Invalid sha256 for " + e.URL
+}
+
+// Is tells that the error is an errdefs.ErrChecksumMismatch.
+func (e *ChecksumError) Is(target error) bool {
+	return target == errdefs.ErrChecksumMismatch
+}
+
+// Downloaded describes a downloaded file.
+type Downloaded struct {
+	// Sha256 is computed while the file is written.
+	Sha256 string
+	// Filename is the name of the file given by the server, if any.
+	Filename string
 }
 
 // Download downloads an url to a destination file. Additional headers can be given.
-// This is helpful to pass authentication tokens.
-func Download(rawURL string, destination string, options Options) error {
-	parsedUrl, err := url.Parse(rawURL)
+// This is helpful to pass authentication tokens. Failed downloads are retried
+// as many times as configured, waiting a bit longer each time. Cancelling the
+// context stops the download and the retries. The destination is only
+// replaced once the download is complete and has the sha256 of the options,
+// if any.
+func Download(ctx context.Context, rawURL string, destination string, options Options) (Downloaded, error) {
+	downloaded, err := download(ctx, rawURL, destination, options)
+	for attempt := 1; err != nil && ctx.Err() == nil && attempt <= options.Retries; attempt++ {
+		logs.From(ctx).Event(logs.Info, "download_retry", logs.Fields{"url": rawURL, "attempt": attempt, "error": err.Error()}, "Retrying", rawURL, "after", err)
+
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-ctx.Done():
+			return Downloaded{}, ctx.Err()
+		}
+
+		downloaded, err = download(ctx, rawURL, destination, options)
+	}
+	return downloaded, err
+}
+
+func download(ctx context.Context, rawURL string, destination string, options Options) (Downloaded, error) {
+	reader, metadata, err := openWithProgress(ctx, rawURL, options)
 	if err != nil {
-		return err
+		return Downloaded{}, err
 	}
+	defer reader.Close()
 
-	destinationTmp := destination + ".tmp"
+	// The download is written to a temporary file that's only renamed to the
+	// destination once it's complete and verified. The temporary file is
+	// removed if the download fails or is cancelled.
+	tmp, err := CreateTemp(destination)
+	if err != nil {
+		return Downloaded{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	if parsedUrl.Scheme == "s3" {
-		if err := downloadS3(parsedUrl, destinationTmp, options); err != nil {
-			return err
+	hash := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(reader, hash)); err != nil {
+		return Downloaded{}, err
+	}
+
+	sha := hex.EncodeToString(hash.Sum(nil))
+	if options.Sha256 != "" {
+		if options.Verifying != nil {
+			options.Verifying()
 		}
-	} else {
-		if err := downloadHTTP(rawURL, destinationTmp, options.httpHeaders()); err != nil {
-			return err
+		if sha != options.Sha256 {
+			return Downloaded{}, &ChecksumError{URL: rawURL, Expected: options.Sha256, Actual: sha}
 		}
 	}
 
-	if _, err := os.Stat(destination); err == nil {
-		if err := os.Remove(destination); err != nil {
-			return err
-		}
+	// Some downloaders stop at the end of what they could read when they're
+	// cancelled, without an error.
+	if err := ctx.Err(); err != nil {
+		return Downloaded{}, err
 	}
 
-	return os.Rename(destinationTmp, destination)
+	if err := Commit(tmp, destination); err != nil {
+		return Downloaded{}, err
+	}
+	return Downloaded{Sha256: sha, Filename: metadata.Filename}, nil
 }
 
-func downloadS3(url *url.URL, destination string, options Options) error {
-	s3Client, err := minio.New("s3.amazonaws.com", options.S3AccessKey, options.S3SecretKey, true)
-	if err != nil {
-		return err
+// Resolve gives the actual url to download. Url templates are expanded for
+// the current platform. Urls to the latest GitHub release are resolved to the
+// latest tag and assets can be picked by pattern, so that they are cached as
+// such. Resolving an url twice gives the same url.
+func Resolve(ctx context.Context, rawURL string, options Options) (string, error) {
+	if options.Resolved || options.Anonymous {
+		return rawURL, nil
 	}
 
-	reader, err := s3Client.GetObject(url.Host, url.Path[1:len(url.Path)])
-	if err != nil {
-		return err
+	if semver.IsConstraint(options.Version) {
+		version, err := resolveVersion(ctx, rawURL, options)
+		if err != nil {
+			return "", err
+		}
+
+		logs.From(ctx).Infoln("Version is:", version)
+		if !urls.IsTemplate(rawURL) && options.GitHubTag == "" {
+			options.GitHubTag = version
+		}
+		options.Version = version
 	}
-	defer reader.Close()
 
-	return CopyFrom(destination, 0666, reader)
-}
+	if urls.IsTemplate(rawURL) {
+		platform, err := urls.CurrentPlatform(options.Version, options.PlatformNames)
+		if err != nil {
+			return "", err
+		}
 
-func downloadHTTP(url string, destination string, headers []string) error {
-	actualUrl := url
-	actualHeaders := headers
+		expanded, err := urls.Expand(rawURL, platform)
+		if err != nil {
+			return "", err
+		}
 
-	if github.ReleaseURL.MatchString(url) {
-		log.Println("Github release url detected")
+		logs.From(ctx).Infoln("Url is:", expanded)
+		rawURL = expanded
+	}
 
-		isPublic, err := isPublicUrl(url)
+	if circleci.IsArtifactURL(rawURL) {
+		return resolveCircleCI(ctx, rawURL, options)
+	}
+	if buildkite.IsArtifactURL(rawURL) {
+		return resolveBuildkite(ctx, rawURL, options)
+	}
+	if nexus.IsArtifactURL(rawURL) {
+		return resolveNexus(ctx, rawURL, options)
+	}
+	if maven.IsArtifactURL(rawURL) {
+		return resolveMaven(ctx, rawURL, options)
+	}
+	if npm.IsPackageURL(rawURL) {
+		return resolveNPM(ctx, rawURL, options)
+	}
+	if pypi.IsPackageURL(rawURL) {
+		return resolvePyPI(ctx, rawURL, options)
+	}
+	if gomod.IsModuleURL(rawURL) {
+		return resolveGoModule(ctx, rawURL, options)
+	}
+	if helm.IsChartURL(rawURL) {
+		return resolveHelm(ctx, rawURL, options)
+	}
+	if terraform.IsProviderURL(rawURL) {
+		return resolveTerraform(ctx, rawURL, options)
+	}
+	if artifact, ok := artifactory.ParseURL(rawURL, options.artifactory()); ok && artifact.IsPattern() {
+		latest, err := artifactory.Latest(ctx, artifact, options.artifactory())
 		if err != nil {
-			return err
+			return "", err
 		}
 
-		if isPublic {
-			log.Println("Github public release url detected")
-		} else {
-			log.Println("Github private release url detected")
+		logs.From(ctx).Infoln("Artifactory artifact url is:", latest.URL())
+		return latest.URL(), nil
+	}
 
-			assetUrl, err := github.AssetUrl(url, headers)
-			if err != nil {
-				return err
-			}
+	release, ok := github.ParseReleaseURL(rawURL, options.GitHubAPIURL)
+	if !ok {
+		return rawURL, nil
+	}
 
-			log.Println("Github asset url is:", assetUrl)
+	release, err := github.Pin(ctx, release, options.GitHubTag, options.GitHubHeaders(ctx, release.API))
+	if err != nil {
+		return "", err
+	}
 
-			actualUrl = assetUrl
-			actualHeaders = append(actualHeaders, "Accept=application/octet-stream")
+	if options.GitHubAsset != "" {
+		if release, err = github.MatchAsset(ctx, release, options.GitHubAsset, options.GitHubHeaders(ctx, release.API)); err != nil {
+			return "", err
+		}
+	} else if options.GitHubAutoAsset {
+		if release, err = github.AutoAsset(ctx, release, runtime.GOOS, runtime.GOARCH, options.GitHubHeaders(ctx, release.API)); err != nil {
+			return "", err
 		}
+	}
 
-	} else if appveyor.ArtifactURL.MatchString(url) {
-		log.Println("Appveyor url detected")
+	resolvedURL := release.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("Github release url is:", resolvedURL)
+	}
+	return resolvedURL, nil
+}
 
-		artifactUrl, err := appveyor.ArtifactUrl(url, headers)
-		if err != nil {
-			return err
-		}
+// resolveCircleCI pins an artifact of the latest successful CircleCI pipeline
+// to the number of that pipeline.
+func resolveCircleCI(ctx context.Context, rawURL string, options Options) (string, error) {
+	artifact, err := circleci.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
 
-		log.Println("Appveyor artifact url is:", artifactUrl)
+	if artifact, err = circleci.Pin(ctx, artifact, options.circleCI()); err != nil {
+		return "", err
+	}
+
+	resolvedURL := artifact.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("CircleCI artifact url is:", resolvedURL)
+	}
+	return resolvedURL, nil
+}
+
+// resolveBuildkite pins an artifact of the latest passed Buildkite build to
+// the number of that build.
+func resolveBuildkite(ctx context.Context, rawURL string, options Options) (string, error) {
+	artifact, err := buildkite.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
 
-		actualUrl = artifactUrl
+	if artifact, err = buildkite.Pin(ctx, artifact, options.buildkite()); err != nil {
+		return "", err
 	}
 
-	return downloadURL(actualUrl, destination, actualHeaders)
+	resolvedURL := artifact.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("Buildkite artifact url is:", resolvedURL)
+	}
+	return resolvedURL, nil
 }
 
-func isPublicUrl(url string) (bool, error) {
-	req, err := http.NewRequest("HEAD", url, nil)
+// resolveNexus pins the LATEST and RELEASE versions of a Nexus artifact.
+func resolveNexus(ctx context.Context, rawURL string, options Options) (string, error) {
+	artifact, err := nexus.ParseURL(rawURL)
 	if err != nil {
-		return false, nil
+		return "", err
 	}
 
-	// Do not follow redirects. Only the first 404 or 302 is of interest.
-	client := &http.Client{
-		CheckRedirect: noCheckRedirect,
+	if artifact, err = nexus.Pin(ctx, artifact, options.nexus()); err != nil {
+		return "", err
 	}
 
-	resp, err := client.Do(req)
+	resolvedURL := artifact.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("Nexus artifact url is:", resolvedURL)
+	}
+	return resolvedURL, nil
+}
+
+// resolveVersion finds the highest version that matches the constraint given
+// with --version. Versions are listed from the releases of the project, whose
+// url can be a template.
+func resolveVersion(ctx context.Context, rawURL string, options Options) (string, error) {
+	constraint, err := semver.ParseConstraint(options.Version)
 	if err != nil {
-		return false, nil
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= http.StatusBadRequest {
-		return false, nil
+	release, ok := github.ParseReleaseURL(rawURL, options.GitHubAPIURL)
+	if !ok {
+		return "", errors.New("Version constraints are only supported for Github releases: " + rawURL)
 	}
 
-	return true, nil
+	return github.MatchVersion(ctx, release, constraint, options.GitHubHeaders(ctx, release.API))
+}
+
+// Open opens an url for reading. Cancelling the context aborts the requests
+// and the commands run to download the url. The progress function of the options, if any, is called as
+// the url is read.
+func Open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, error) {
+	reader, _, err := openWithProgress(ctx, rawURL, options)
+	return reader, err
 }
 
-func downloadURL(url string, destination string, headers []string) error {
-	req, err := http.NewRequest("GET", url, nil)
+func openWithProgress(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	reader, metadata, err := open(ctx, rawURL, options)
+	if err != nil || options.Progress == nil {
+		return reader, metadata, err
+	}
+
+	size := metadata.Size
+	if size < 0 {
+		size = sizeOf(reader)
+	}
+	return withProgress(reader, size, options.Progress), metadata, nil
+}
+
+// open finds the downloader of an url: a registered one, a built-in one or a
+// getme-<scheme> helper. Urls that nothing else handles are downloaded over
+// http.
+func open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	if options.Anonymous {
+		if !(httpDownloader{}).CanHandle(rawURL) {
+			return nil, Metadata{}, errors.New("Only http and https urls can be downloaded anonymously: " + rawURL)
+		}
+		return openURL(ctx, rawURL, nil, options)
+	}
+
+	for _, downloader := range downloaders(options) {
+		if downloader.CanHandle(rawURL) {
+			return downloader.Fetch(ctx, rawURL, options)
+		}
+	}
+
+	return httpDownloader{}.Fetch(ctx, rawURL, options)
+}
+
+// openFile opens a local file, or one on a network mount, given a
+// `file:///path/to/file` url.
+func openFile(u *url.URL) (io.ReadCloser, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, errors.New("Only local file urls are supported. Should be file:///path/to/file: " + u.String())
+	}
+
+	path := u.Path
+	// file:///C:/path/to/file on Windows.
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+
+	file, err := os.Open(filepath.FromSlash(path))
+	if os.IsNotExist(err) {
+		return nil, errdefs.Mark(errdefs.ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func openURL(ctx context.Context, url string, headers []string, options Options) (io.ReadCloser, Metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return err
+		return nil, Metadata{}, err
 	}
 
 	if err := http_headers.Add(headers, req); err != nil {
-		return err
+		return nil, Metadata{}, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	if host, found := options.Hosts.For(req.URL.Host); found && !options.Anonymous {
+		if err := http_headers.Add(host.Headers, req); err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	if req.Header.Get("Authorization") == "" && !options.Anonymous {
+		if authorization, found := options.hostAuthorization(ctx, req.URL); found {
+			req.Header.Set("Authorization", authorization)
+		}
+	}
+
+	client := options.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+		if !options.Anonymous {
+			client.Jar = options.Jar
+		}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, Metadata{}, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= http.StatusBadRequest {
-		return errors.New(resp.Status)
+		resp.Body.Close()
+		return nil, Metadata{}, errdefs.NewStatusError(resp)
+	}
+
+	// Artifactory, among others, gives the sha256 of the files it serves.
+	if sha := resp.Header.Get("X-Checksum-Sha256"); sha != "" && resp.StatusCode == http.StatusOK {
+		reader, err := digest.Verify(resp.Body, url, "sha256", sha)
+		return reader, Metadata{Size: resp.ContentLength, Filename: filename(resp.Header)}, err
 	}
 
-	return CopyFrom(destination, 0666, resp.Body)
+	return resp.Body, Metadata{Size: resp.ContentLength, Filename: filename(resp.Header)}, nil
+}
+
+// filename reads the name of a file in a Content-Disposition header, without
+// the directories the server might give.
+func filename(header http.Header) string {
+	_, params, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+	return urls.Basename(params["filename"])
 }
 
 func noCheckRedirect(req *http.Request, via []*http.Request) error {
 	return http.ErrUseLastResponse
 }
 
-func (o *Options) authToken() string {
+// Token gives the api authentication token, if any.
+func (o *Options) Token() string {
 	if o.AuthTokenEnvVariable != "" {
 		return os.Getenv(o.AuthTokenEnvVariable)
 	}
 	return o.AuthToken
 }
 
-func (o *Options) httpHeaders() []string {
-	authToken := o.authToken()
-	if authToken == "" {
-		return nil
+// HTTPHeaders gives the headers of http requests: the authentication, with
+// basic auth taking precedence over the token, and the additional headers.
+func (o *Options) HTTPHeaders() []string {
+	var headers []string
+	if o.User != "" {
+		headers = append(headers, "Authorization=Basic "+base64.StdEncoding.EncodeToString([]byte(o.User)))
+	} else if authToken := o.Token(); authToken != "" {
+		headers = append(headers, fmt.Sprintf("Authorization=Bearer %s", authToken))
+	}
+	return append(headers, o.Headers...)
+}
+
+// splitUser reads `user:password` credentials.
+func splitUser(user string) (string, string) {
+	parts := strings.SplitN(user, ":", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// GitHubHeaders gives the headers used to authenticate to a Github api. Unless
+// disabled, $GITHUB_TOKEN or $GH_TOKEN is used for github.com when no token is
+// given. Otherwise, the credentials of the api host are read from the keychain
+// or the netrc file.
+func (o *Options) GitHubHeaders(ctx context.Context, api string) []string {
+	if o.User != "" || o.Token() != "" {
+		return o.HTTPHeaders()
+	}
+
+	if o.GitHubEnvToken && api == github.DefaultAPIURL {
+		for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+			if token := os.Getenv(name); token != "" {
+				return append([]string{fmt.Sprintf("Authorization=Bearer %s", token)}, o.Headers...)
+			}
+		}
+	}
+
+	if u, err := url.Parse(api); err == nil {
+		if authorization, found := o.hostAuthorization(ctx, u); found {
+			return append([]string{"Authorization=" + authorization}, o.Headers...)
+		}
+	}
+
+	return o.Headers
+}
+
+// hostAuthorization finds the credentials of a host. They are given by the
+// token env variable of the host in the config file, by its credential helper,
+// if any, or found in the keychain, as stored by `getme login`, or in the
+// netrc file.
+func (o *Options) hostAuthorization(ctx context.Context, u *url.URL) (string, bool) {
+	host := u.Host
+
+	if settings, found := o.Hosts.For(host); found {
+		if token := settings.Token(); token != "" {
+			return "Bearer " + token, true
+		}
+	}
+
+	if helper := credhelper.Helpers(o.CredentialHelpers).For(host); helper != "" {
+		credentials, err := credhelper.Get(helper, u.Scheme, host)
+		if err != nil {
+			logs.From(ctx).Infoln(err)
+		} else {
+			return credentials.Authorization(), true
+		}
+	}
+
+	if token, found := keychain.Lookup(host); found {
+		return "Bearer " + token, true
+	}
+
+	if machine, found := netrc.Lookup(host); found {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(machine.Login+":"+machine.Password)), true
+	}
+
+	return "", false
+}
+
+// GitHubAPI gives the url of the Github api.
+func (o *Options) GitHubAPI() string {
+	if o.GitHubAPIURL == "" {
+		return github.DefaultAPIURL
+	}
+	return strings.TrimSuffix(o.GitHubAPIURL, "/")
+}
+
+// circleCI gives the options to download CircleCI artifacts.
+func (o *Options) circleCI() circleci.Options {
+	return circleci.Options{Token: o.CircleToken, Headers: o.Headers}
+}
+
+// buildkite gives the options to download Buildkite artifacts.
+func (o *Options) buildkite() buildkite.Options {
+	return buildkite.Options{Token: o.BuildkiteToken, Headers: o.Headers}
+}
+
+// artifactory gives the options to access Artifactory.
+func (o *Options) artifactory() artifactory.Options {
+	return artifactory.Options{URL: o.ArtifactoryURL, APIKey: o.ArtifactoryAPIKey, Token: o.ArtifactoryToken, Headers: o.Headers}
+}
+
+// nexus gives the options to access Nexus.
+func (o *Options) nexus() nexus.Options {
+	return nexus.Options{URL: o.NexusURL, Token: o.NexusToken, Headers: o.Headers}
+}
+
+// S3 gives the options to access Amazon S3.
+func (o *Options) S3() s3.Options {
+	return s3.Options{
+		AccessKey:     o.S3AccessKey,
+		SecretKey:     o.S3SecretKey,
+		SessionToken:  o.S3SessionToken,
+		Profile:       o.S3Profile,
+		RoleArn:       o.S3RoleArn,
+		ExternalID:    o.S3ExternalID,
+		RequesterPays: o.S3RequesterPays,
+
+		Endpoint:       o.S3Endpoint,
+		ForcePathStyle: o.S3ForcePathStyle,
 	}
-	return []string{fmt.Sprintf("Authorization=Bearer %s", authToken)}
 }
@@ -0,0 +1,192 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-download-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "archive.tar.gz")
+	assert.NoError(t, ioutil.WriteFile(source, []byte("content"), 0644))
+
+	destination := filepath.Join(dir, "copy.tar.gz")
+	downloaded, err := Download(context.Background(), fileURL(source), destination, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73", downloaded.Sha256)
+
+	content, err := ioutil.ReadFile(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	_, err = Download(context.Background(), fileURL(filepath.Join(dir, "missing.tar.gz")), destination, Options{})
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = Download(context.Background(), "file://server/share/archive.tar.gz", destination, Options{})
+	assert.Error(t, err)
+}
+
+func TestCancelledDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-download-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	destination := filepath.Join(dir, "file")
+	_, err = Download(ctx, server.URL+"/file", destination, Options{Retries: 3})
+	assert.Error(t, err)
+
+	left, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+func TestInvalidSha256(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-download-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "archive.tar.gz")
+	assert.NoError(t, ioutil.WriteFile(source, []byte("corrupted"), 0644))
+	destination := filepath.Join(dir, "copy.tar.gz")
+	assert.NoError(t, ioutil.WriteFile(destination, []byte("content"), 0644))
+
+	_, err = Download(context.Background(), fileURL(source), destination, Options{Sha256: "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"})
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+
+	// The previous file is left untouched.
+	content, err := ioutil.ReadFile(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	left, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, left, 2)
+}
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-download-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "7")
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	var totals []int64
+	var done, total int64
+	options := Options{Progress: func(bytesDone, bytesTotal int64) {
+		totals = append(totals, bytesTotal)
+		done, total = bytesDone, bytesTotal
+	}}
+
+	_, err = Download(context.Background(), server.URL+"/file", filepath.Join(dir, "file"), options)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), done)
+	assert.Equal(t, int64(7), total)
+	for _, bytesTotal := range totals {
+		assert.Equal(t, int64(7), bytesTotal)
+	}
+
+	// The size of the file is only known once it's read.
+	totals = nil
+	_, err = ioutil.ReadAll(withProgress(ioutil.NopCloser(iotest.OneByteReader(strings.NewReader("content"))), -1, options.Progress))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), done)
+	assert.Equal(t, int64(7), total)
+	assert.Equal(t, int64(-1), totals[0])
+}
+
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+func TestGitHubHeaders(t *testing.T) {
+	defer os.Setenv("GITHUB_TOKEN", os.Getenv("GITHUB_TOKEN"))
+	defer os.Setenv("GH_TOKEN", os.Getenv("GH_TOKEN"))
+	os.Setenv("GITHUB_TOKEN", "")
+	os.Setenv("GH_TOKEN", "gh-token")
+
+	options := Options{GitHubEnvToken: true}
+	assert.Equal(t, []string{"Authorization=Bearer gh-token"}, options.GitHubHeaders(context.Background(), "https://api.github.com"))
+	assert.Empty(t, options.GitHubHeaders(context.Background(), "https://github.example.com/api/v3"))
+
+	os.Setenv("GITHUB_TOKEN", "github-token")
+	assert.Equal(t, []string{"Authorization=Bearer github-token"}, options.GitHubHeaders(context.Background(), "https://api.github.com"))
+
+	options.AuthToken = "token"
+	assert.Equal(t, []string{"Authorization=Bearer token"}, options.GitHubHeaders(context.Background(), "https://api.github.com"))
+
+	options = Options{}
+	assert.Empty(t, options.GitHubHeaders(context.Background(), "https://api.github.com"))
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	options := Options{AuthToken: "token", Headers: []string{"X-Cdn-Key: key"}}
+	assert.Equal(t, []string{"Authorization=Bearer token", "X-Cdn-Key: key"}, options.HTTPHeaders())
+
+	options.User = "user:secret"
+	assert.Equal(t, []string{"Authorization=Basic dXNlcjpzZWNyZXQ=", "X-Cdn-Key: key"}, options.HTTPHeaders())
+	assert.Equal(t, []string{"Authorization=Basic dXNlcjpzZWNyZXQ=", "X-Cdn-Key: key"}, options.GitHubHeaders(context.Background(), "https://api.github.com"))
+
+	assert.Empty(t, (&Options{}).HTTPHeaders())
+}
+
+func TestArtifactory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-download-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.Header.Get("X-JFrog-Art-Api"))
+		switch r.URL.Path {
+		case "/artifactory/libs/tool.tgz":
+			w.Header().Set("X-Checksum-Sha256", "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73")
+			w.Write([]byte("content"))
+		case "/artifactory/libs/corrupted.tgz":
+			w.Header().Set("X-Checksum-Sha256", "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73")
+			w.Write([]byte("corrupted"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{ArtifactoryAPIKey: "key"}
+	destination := filepath.Join(dir, "tool.tgz")
+
+	_, err = Download(context.Background(), server.URL+"/artifactory/libs/tool.tgz", destination, options)
+	assert.NoError(t, err)
+
+	_, err = Download(context.Background(), server.URL+"/artifactory/libs/corrupted.tgz", destination, options)
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+}
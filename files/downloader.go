@@ -0,0 +1,245 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/dgageot/getme/appveyor"
+	"github.com/dgageot/getme/artifactory"
+	"github.com/dgageot/getme/github"
+	"github.com/dgageot/getme/helper"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/negotiate"
+	"github.com/dgageot/getme/s3"
+	"github.com/pkg/errors"
+)
+
+// Downloader opens the urls it can handle. Custom schemes are supported by
+// registering a Downloader with Register.
+type Downloader interface {
+	CanHandle(url string) bool
+	Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error)
+}
+
+// Metadata describes what a Downloader opened. Size is -1 if it's unknown.
+type Metadata struct {
+	Size int64
+	// Filename is the name of the file given by the server, like with a
+	// Content-Disposition header, if any.
+	Filename string
+}
+
+var unknownSize = Metadata{Size: -1}
+
+var (
+	registryLock sync.Mutex
+	registry     []Downloader
+)
+
+// Register adds a Downloader that's tried before the built-in ones. The
+// downloaders registered last are tried first.
+func Register(downloader Downloader) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry = append([]Downloader{downloader}, registry...)
+}
+
+// downloaders gives the downloaders to try, in order: the registered ones,
+// then the built-in ones.
+func downloaders(options Options) []Downloader {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	return append(append([]Downloader{}, registry...), builtins(options)...)
+}
+
+// unsized adapts an opener that doesn't know the size of what it reads.
+func unsized(reader io.ReadCloser, err error) (io.ReadCloser, Metadata, error) {
+	return reader, unknownSize, err
+}
+
+// s3Downloader downloads s3:// urls and, when they are signed, https urls to
+// S3 objects.
+type s3Downloader struct {
+	signed bool
+}
+
+func (d s3Downloader) CanHandle(url string) bool {
+	if urlScheme(url) == "s3" {
+		return true
+	}
+	_, _, ok := s3.ParseURL(url)
+	return ok && d.signed
+}
+
+func (s3Downloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	bucket, key, ok := s3.ParseURL(url)
+	if !ok {
+		return nil, Metadata{}, errors.New("Invalid S3 url. Should be s3://bucket/key: " + url)
+	}
+
+	if options.S3RequesterPays {
+		logs.From(ctx).Infoln("Requester pays bucket: the transfer will be billed to your AWS account")
+	}
+
+	return unsized(s3.Open(ctx, bucket, key, options.S3()))
+}
+
+// githubDownloader downloads the sources and the release assets of GitHub
+// repositories, public or private.
+type githubDownloader struct{}
+
+func (githubDownloader) CanHandle(url string) bool {
+	if github.IsSourceURL(url) {
+		return true
+	}
+	_, ok := github.ParseReleaseURL(url, "")
+	return ok
+}
+
+func (githubDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	if github.IsSourceURL(url) {
+		return unsized(github.OpenSource(ctx, url, options.GitHubAPI(), options.GitHubCommit, options.GitHubHeaders(ctx, options.GitHubAPI())))
+	}
+
+	release, ok := github.ParseReleaseURL(url, options.GitHubAPIURL)
+	if !ok {
+		return httpDownloader{}.Fetch(ctx, url, options)
+	}
+
+	logs.From(ctx).Infoln("Github release url detected")
+	headers := options.GitHubHeaders(ctx, release.API)
+
+	isPublic, err := isPublicUrl(ctx, url)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if isPublic {
+		logs.From(ctx).Infoln("Github public release url detected")
+		return fetchHTTP(ctx, url, headers, options)
+	}
+
+	logs.From(ctx).Infoln("Github private release url detected")
+
+	assetUrl, err := github.AssetUrl(ctx, release, headers)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	logs.From(ctx).Infoln("Github asset url is:", assetUrl)
+
+	return fetchHTTP(ctx, assetUrl, append(headers, "Accept=application/octet-stream"), options)
+}
+
+// httpDownloader downloads http and https urls. It's used for the urls that
+// no other downloader handles.
+type httpDownloader struct{}
+
+func (httpDownloader) CanHandle(rawURL string) bool {
+	scheme := urlScheme(rawURL)
+	return scheme == "http" || scheme == "https"
+}
+
+func (httpDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	if _, ok := github.ParseReleaseURL(url, options.GitHubAPIURL); ok {
+		return githubDownloader{}.Fetch(ctx, url, options)
+	}
+
+	headers := options.HTTPHeaders()
+
+	if strings.HasPrefix(url, options.GitHubAPI()+"/") {
+		headers = options.GitHubHeaders(ctx, options.GitHubAPI())
+	} else if _, ok := artifactory.ParseURL(url, options.artifactory()); ok {
+		// Basic auth and tokens given explicitly take precedence.
+		if options.User == "" && options.Token() == "" {
+			headers = append(headers, options.artifactory().AuthHeaders()...)
+		}
+	} else if appveyor.ArtifactURL.MatchString(url) {
+		logs.From(ctx).Infoln("Appveyor url detected")
+
+		artifactUrl, err := appveyor.ArtifactUrl(ctx, url, headers)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		logs.From(ctx).Infoln("Appveyor artifact url is:", artifactUrl)
+
+		url = artifactUrl
+	}
+
+	return fetchHTTP(ctx, url, headers, options)
+}
+
+func fetchHTTP(ctx context.Context, url string, headers []string, options Options) (io.ReadCloser, Metadata, error) {
+	if options.Negotiate {
+		return unsized(negotiate.Open(ctx, url, headers))
+	}
+
+	return openURL(ctx, url, headers, options)
+}
+
+// helperDownloader downloads the urls of unknown schemes with a getme-<scheme>
+// executable found on the PATH.
+type helperDownloader struct{}
+
+func (helperDownloader) CanHandle(rawURL string) bool {
+	scheme := urlScheme(rawURL)
+	if scheme == "http" || scheme == "https" {
+		return false
+	}
+
+	_, found := helper.Find(scheme)
+	return found
+}
+
+func (helperDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	scheme := urlScheme(url)
+	path, found := helper.Find(scheme)
+	if !found {
+		return nil, Metadata{}, fmt.Errorf("Unsupported url scheme [%s]. Install %s on the PATH", scheme, helper.Name(scheme))
+	}
+
+	logs.From(ctx).Infoln("Download", url, "with", path)
+
+	reader, size, err := helper.Open(ctx, path, url)
+	return reader, Metadata{Size: size}, err
+}
+
+func urlScheme(rawURL string) string {
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsedUrl.Scheme
+}
+
+func isPublicUrl(ctx context.Context, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return false, nil
+	}
+
+	// Do not follow redirects. Only the first 404 or 302 is of interest.
+	client := &http.Client{
+		CheckRedirect: noCheckRedirect,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package files
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memDownloader map[string]string
+
+func (m memDownloader) CanHandle(url string) bool {
+	return strings.HasPrefix(url, "mem://")
+}
+
+func (m memDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	content := m[strings.TrimPrefix(url, "mem://")]
+	return ioutil.NopCloser(strings.NewReader(content)), Metadata{Size: int64(len(content))}, nil
+}
+
+func TestRegister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-downloader-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	Register(memDownloader{"file": "content"})
+
+	var totals []int64
+	options := Options{Progress: func(bytesDone, bytesTotal int64) {
+		totals = append(totals, bytesTotal)
+	}}
+
+	destination := filepath.Join(dir, "file")
+	_, err = Download(context.Background(), "mem://file", destination, options)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.Equal(t, int64(7), totals[0])
+
+	assert.True(t, httpDownloader{}.CanHandle("https://example.com/file"))
+	assert.False(t, httpDownloader{}.CanHandle("mem://file"))
+	assert.True(t, githubDownloader{}.CanHandle("https://github.com/dgageot/getme/releases/download/v1.0.0/getme"))
+	assert.True(t, s3Downloader{}.CanHandle("s3://bucket/key"))
+	assert.False(t, s3Downloader{}.CanHandle("https://bucket.s3.amazonaws.com/key"))
+	assert.True(t, s3Downloader{signed: true}.CanHandle("https://bucket.s3.amazonaws.com/key"))
+	assert.True(t, azureDownloader{}.CanHandle("az://account/container/blob"))
+	assert.False(t, azureDownloader{}.CanHandle("https://account.blob.core.windows.net/container/blob?sig=abc"))
+	assert.True(t, fileDownloader{}.CanHandle("file:///tmp/file"))
+}
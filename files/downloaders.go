@@ -0,0 +1,435 @@
+package files
+
+import (
+	"context"
+	"io"
+	"net/url"
+
+	"github.com/dgageot/getme/azure"
+	"github.com/dgageot/getme/buildkite"
+	"github.com/dgageot/getme/circleci"
+	"github.com/dgageot/getme/dropbox"
+	"github.com/dgageot/getme/ftp"
+	"github.com/dgageot/getme/gcs"
+	"github.com/dgageot/getme/gdrive"
+	"github.com/dgageot/getme/git"
+	"github.com/dgageot/getme/gitea"
+	"github.com/dgageot/getme/github"
+	"github.com/dgageot/getme/gitlab"
+	"github.com/dgageot/getme/gomod"
+	"github.com/dgageot/getme/helm"
+	"github.com/dgageot/getme/ipfs"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/maven"
+	"github.com/dgageot/getme/nexus"
+	"github.com/dgageot/getme/npm"
+	"github.com/dgageot/getme/oci"
+	"github.com/dgageot/getme/pypi"
+	"github.com/dgageot/getme/sftp"
+	"github.com/dgageot/getme/terraform"
+	"github.com/dgageot/getme/torrent"
+	"github.com/dgageot/getme/webdav"
+	"github.com/pkg/errors"
+)
+
+// builtins gives the built-in downloaders, in the order they're tried. Some
+// of them only handle an url given the options: https urls to S3 objects, for
+// example, are only signed when credentials are given explicitly.
+func builtins(options Options) []Downloader {
+	return []Downloader{
+		imageDownloader{},
+		torrentDownloader{},
+		// Maven coordinates and npm packages aren't valid urls.
+		mavenDownloader{},
+		npmDownloader{},
+		pypiDownloader{},
+		goModuleDownloader{},
+		helmDownloader{},
+		terraformDownloader{},
+		s3Downloader{signed: options.S3AccessKey != "" || options.S3Profile != "" || options.S3RoleArn != ""},
+		gcsDownloader{authenticated: options.GCSCredentials != ""},
+		sftpDownloader{},
+		fileDownloader{},
+		webdavDownloader{},
+		ipfsDownloader{},
+		ociDownloader{},
+		gitDownloader{},
+		ftpDownloader{},
+		gdriveDownloader{},
+		azureDownloader{sas: options.AzureSASToken != ""},
+		githubSourceDownloader{},
+		gitlabDownloader{},
+		giteaDownloader{gitea.Options{URL: options.GiteaURL, Token: options.GiteaToken, Headers: options.Headers}},
+		circleCIDownloader{},
+		buildkiteDownloader{},
+		nexusDownloader{},
+		dropboxDownloader{},
+		helperDownloader{},
+	}
+}
+
+// imageDownloader gives the root filesystem of docker:// container images.
+type imageDownloader struct{}
+
+func (imageDownloader) CanHandle(url string) bool {
+	return oci.IsImageURL(url)
+}
+
+func (imageDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	return unsized(oci.OpenImage(ctx, url))
+}
+
+// torrentDownloader downloads magnet links and .torrent urls.
+type torrentDownloader struct{}
+
+func (torrentDownloader) CanHandle(url string) bool {
+	return torrent.IsTorrentURL(url)
+}
+
+func (torrentDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	return unsized(torrent.Open(ctx, url))
+}
+
+// mavenDownloader downloads Maven artifacts.
+type mavenDownloader struct{}
+
+func (mavenDownloader) CanHandle(url string) bool {
+	return maven.IsArtifactURL(url)
+}
+
+func (mavenDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Maven url detected")
+	return openMaven(ctx, url, options)
+}
+
+// npmDownloader downloads npm packages.
+type npmDownloader struct{}
+
+func (npmDownloader) CanHandle(url string) bool {
+	return npm.IsPackageURL(url)
+}
+
+func (npmDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("npm url detected")
+	return openNPM(ctx, url, options)
+}
+
+// pypiDownloader downloads PyPI packages.
+type pypiDownloader struct{}
+
+func (pypiDownloader) CanHandle(url string) bool {
+	return pypi.IsPackageURL(url)
+}
+
+func (pypiDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("PyPI url detected")
+	return openPyPI(ctx, url, options)
+}
+
+// goModuleDownloader downloads Go modules.
+type goModuleDownloader struct{}
+
+func (goModuleDownloader) CanHandle(url string) bool {
+	return gomod.IsModuleURL(url)
+}
+
+func (goModuleDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Go module url detected")
+	return openGoModule(ctx, url, options)
+}
+
+// helmDownloader downloads Helm charts.
+type helmDownloader struct{}
+
+func (helmDownloader) CanHandle(url string) bool {
+	return helm.IsChartURL(url)
+}
+
+func (helmDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Helm url detected")
+	return openHelm(ctx, url, options)
+}
+
+// terraformDownloader downloads Terraform providers.
+type terraformDownloader struct{}
+
+func (terraformDownloader) CanHandle(url string) bool {
+	return terraform.IsProviderURL(url)
+}
+
+func (terraformDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Terraform url detected")
+	return openTerraform(ctx, url, options)
+}
+
+// gcsDownloader downloads gs:// urls and, when credentials are given
+// explicitly, https urls to Google Cloud Storage.
+type gcsDownloader struct {
+	authenticated bool
+}
+
+func (d gcsDownloader) CanHandle(url string) bool {
+	if urlScheme(url) == "gs" {
+		return true
+	}
+	_, _, ok := gcs.ParseURL(url)
+	return ok && d.authenticated
+}
+
+func (gcsDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	bucket, object, ok := gcs.ParseURL(url)
+	if !ok {
+		return nil, Metadata{}, errors.New("Invalid Google Cloud Storage url. Should be gs://bucket/object: " + url)
+	}
+
+	return unsized(gcs.Open(ctx, bucket, object, gcs.Options{CredentialsFile: options.GCSCredentials}))
+}
+
+// sftpDownloader downloads sftp:// and scp:// urls.
+type sftpDownloader struct{}
+
+func (sftpDownloader) CanHandle(url string) bool {
+	return hasScheme(url, "sftp", "scp")
+}
+
+func (sftpDownloader) Fetch(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return unsized(sftp.Open(ctx, u))
+}
+
+// fileDownloader opens file:// urls.
+type fileDownloader struct{}
+
+func (fileDownloader) CanHandle(url string) bool {
+	return hasScheme(url, "file")
+}
+
+func (fileDownloader) Fetch(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return unsized(openFile(u))
+}
+
+// webdavDownloader downloads dav:// and davs:// urls.
+type webdavDownloader struct{}
+
+func (webdavDownloader) CanHandle(url string) bool {
+	return hasScheme(url, "dav", "davs")
+}
+
+func (webdavDownloader) Fetch(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	username, password := options.WebDAVUser, options.WebDAVPassword
+	if username == "" && options.User != "" {
+		username, password = splitUser(options.User)
+	}
+
+	return unsized(webdav.Open(ctx, u, webdav.Options{
+		Username: username,
+		Password: password,
+		Token:    options.Token(),
+		Headers:  options.Headers,
+	}))
+}
+
+// ipfsDownloader downloads ipfs:// urls.
+type ipfsDownloader struct{}
+
+func (ipfsDownloader) CanHandle(url string) bool {
+	return hasScheme(url, "ipfs")
+}
+
+func (ipfsDownloader) Fetch(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return unsized(ipfs.Open(ctx, u, ipfs.Options{Gateway: options.IPFSGateway}))
+}
+
+// ociDownloader downloads oci:// artifacts.
+type ociDownloader struct{}
+
+func (ociDownloader) CanHandle(url string) bool {
+	return hasScheme(url, "oci")
+}
+
+func (ociDownloader) Fetch(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return unsized(oci.Open(ctx, u))
+}
+
+// gitDownloader gives the content of git repositories.
+type gitDownloader struct{}
+
+func (gitDownloader) CanHandle(url string) bool {
+	return git.IsRepositoryURL(url)
+}
+
+func (gitDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	return unsized(git.Open(ctx, url))
+}
+
+// ftpDownloader downloads ftp://, ftps:// and ftpes:// urls.
+type ftpDownloader struct{}
+
+func (ftpDownloader) CanHandle(url string) bool {
+	return hasScheme(url, "ftp", "ftps", "ftpes")
+}
+
+func (ftpDownloader) Fetch(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return unsized(ftp.Open(ctx, u))
+}
+
+// gdriveDownloader downloads Google Drive files.
+type gdriveDownloader struct{}
+
+func (gdriveDownloader) CanHandle(url string) bool {
+	_, ok := gdrive.FileID(url)
+	return ok
+}
+
+func (gdriveDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	id, _ := gdrive.FileID(url)
+	return unsized(gdrive.Open(ctx, id, gdrive.Options{APIKey: options.GoogleAPIKey, CredentialsFile: options.GCSCredentials}))
+}
+
+// azureDownloader downloads az:// urls and https urls to Azure Blob Storage.
+// Https urls that already carry a SAS token are downloaded as is, over http,
+// unless another SAS token is given.
+type azureDownloader struct {
+	sas bool
+}
+
+func (d azureDownloader) CanHandle(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "az" {
+		return true
+	}
+	_, ok := azure.ParseURL(rawURL)
+	return ok && (d.sas || u.RawQuery == "")
+}
+
+func (azureDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	blob, ok := azure.ParseURL(url)
+	if !ok {
+		return nil, Metadata{}, errors.New("Invalid Azure Blob Storage url. Should be az://account/container/blob: " + url)
+	}
+	return unsized(azure.Open(ctx, blob, azure.Options{SASToken: options.AzureSASToken}))
+}
+
+// githubSourceDownloader downloads the sources of GitHub repositories.
+// Release assets are downloaded over http.
+type githubSourceDownloader struct{}
+
+func (githubSourceDownloader) CanHandle(url string) bool {
+	return github.IsSourceURL(url)
+}
+
+func (githubSourceDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	return githubDownloader{}.Fetch(ctx, url, options)
+}
+
+// gitlabDownloader downloads GitLab release assets and packages.
+type gitlabDownloader struct{}
+
+func (gitlabDownloader) CanHandle(url string) bool {
+	return gitlab.IsGitlabURL(url)
+}
+
+func (gitlabDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Gitlab url detected")
+	return unsized(gitlab.Open(ctx, url, gitlab.Options{Token: options.GitlabToken, Headers: options.Headers}))
+}
+
+// giteaDownloader downloads Gitea and Forgejo release assets.
+type giteaDownloader struct {
+	options gitea.Options
+}
+
+func (d giteaDownloader) CanHandle(url string) bool {
+	return gitea.IsReleaseURL(url, d.options)
+}
+
+func (d giteaDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Gitea release url detected")
+	return unsized(gitea.Open(ctx, url, d.options))
+}
+
+// circleCIDownloader downloads CircleCI artifacts.
+type circleCIDownloader struct{}
+
+func (circleCIDownloader) CanHandle(url string) bool {
+	return circleci.IsArtifactURL(url)
+}
+
+func (circleCIDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("CircleCI url detected")
+	return unsized(circleci.Open(ctx, url, options.circleCI()))
+}
+
+// buildkiteDownloader downloads Buildkite artifacts.
+type buildkiteDownloader struct{}
+
+func (buildkiteDownloader) CanHandle(url string) bool {
+	return buildkite.IsArtifactURL(url)
+}
+
+func (buildkiteDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Buildkite url detected")
+	return unsized(buildkite.Open(ctx, url, options.buildkite()))
+}
+
+// nexusDownloader downloads Nexus artifacts.
+type nexusDownloader struct{}
+
+func (nexusDownloader) CanHandle(url string) bool {
+	return nexus.IsArtifactURL(url)
+}
+
+func (nexusDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Nexus url detected")
+	return unsized(nexus.Open(ctx, url, options.nexus()))
+}
+
+// dropboxDownloader downloads Dropbox share links.
+type dropboxDownloader struct{}
+
+func (dropboxDownloader) CanHandle(url string) bool {
+	return dropbox.SharedURL.MatchString(url)
+}
+
+func (dropboxDownloader) Fetch(ctx context.Context, url string, options Options) (io.ReadCloser, Metadata, error) {
+	logs.From(ctx).Infoln("Dropbox share link detected")
+	return unsized(dropbox.Open(ctx, url))
+}
+
+// hasScheme tells if an url has one of the given schemes.
+func hasScheme(rawURL string, schemes ...string) bool {
+	scheme := urlScheme(rawURL)
+	for _, candidate := range schemes {
+		if scheme == candidate {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dgageot/getme/logs"
+)
+
+// IfExists tells what to do when a destination file already exists.
+type IfExists string
+
+const (
+	// Overwrite replaces existing files. It's the default.
+	Overwrite IfExists = "overwrite"
+	// Skip leaves existing files untouched.
+	Skip IfExists = "skip"
+	// Fail aborts when a file already exists.
+	Fail IfExists = "error"
+	// Update replaces existing files that are older than the source.
+	Update IfExists = "update"
+)
+
+// ParseIfExists validates a policy for existing files.
+func ParseIfExists(value string) (IfExists, error) {
+	switch policy := IfExists(value); policy {
+	case Overwrite, Skip, Fail, Update:
+		return policy, nil
+	case "":
+		return Overwrite, nil
+	}
+	return "", fmt.Errorf("Invalid value [%s]. Should be overwrite, skip, error or update", value)
+}
+
+// ShouldWrite tells if a destination file should be written, given the
+// modification time of its source. Skipped files are logged to log.
+func (p IfExists) ShouldWrite(log logs.Log, dst string, modTime time.Time) (bool, error) {
+	if dst == "-" {
+		return true, nil
+	}
+
+	info, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch p {
+	case Skip:
+		log.Infoln("Skip", dst, "since it already exists")
+		return false, nil
+	case Fail:
+		return false, fmt.Errorf("%s already exists", dst)
+	case Update:
+		if !modTime.After(info.ModTime()) {
+			log.Infoln("Skip", dst, "since it's up to date")
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,35 @@
+package files
+
+import (
+	"context"
+	"io"
+
+	"github.com/dgageot/getme/gomod"
+	"github.com/dgageot/getme/logs"
+)
+
+// resolveGoModule pins the latest version of a Go module.
+func resolveGoModule(ctx context.Context, rawURL string, options Options) (string, error) {
+	module, err := gomod.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if module, err = gomod.Pin(ctx, module, options.goModules()); err != nil {
+		return "", err
+	}
+
+	resolvedURL := module.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("Go module url is:", resolvedURL)
+	}
+	return resolvedURL, nil
+}
+
+func openGoModule(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	return unsized(gomod.Open(ctx, rawURL, options.goModules()))
+}
+
+func (o *Options) goModules() gomod.Options {
+	return gomod.Options{Proxy: o.GoProxy, SumDB: o.GoSumDB, Headers: o.Headers}
+}
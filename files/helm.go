@@ -0,0 +1,57 @@
+package files
+
+import (
+	"context"
+	"io"
+
+	"github.com/dgageot/getme/digest"
+	"github.com/dgageot/getme/helm"
+	"github.com/dgageot/getme/logs"
+)
+
+// resolveHelm pins the version of a Helm chart.
+func resolveHelm(ctx context.Context, rawURL string, options Options) (string, error) {
+	chart, err := helm.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := helm.Find(ctx, chart, options.HTTPHeaders())
+	if err != nil {
+		return "", err
+	}
+
+	chart.Version = entry.Version
+	if chart.URL() != rawURL {
+		logs.From(ctx).Infoln("Helm chart url is:", chart.URL())
+	}
+	return chart.URL(), nil
+}
+
+// openHelm downloads a Helm chart and verifies its digest.
+func openHelm(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	chart, err := helm.ParseURL(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	entry, err := helm.Find(ctx, chart, options.HTTPHeaders())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	logs.From(ctx).Infoln("Helm chart is:", entry.URLs[0])
+
+	// Credentials are only sent to the repository itself.
+	headers := options.Headers
+	if hostOf(entry.URLs[0]) == hostOf(chart.RepositoryURL()) {
+		headers = options.HTTPHeaders()
+	}
+
+	reader, metadata, err := fetchHTTP(ctx, entry.URLs[0], headers, options)
+	if err != nil || entry.Digest == "" {
+		return reader, metadata, err
+	}
+
+	verified, err := digest.Verify(reader, entry.URLs[0], "sha256", entry.Digest)
+	return verified, metadata, err
+}
@@ -0,0 +1,148 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dgageot/getme/logs"
+)
+
+// Link tells how a cached file is placed at its destination.
+type Link string
+
+const (
+	// CopyLink copies the content of the file. It's the default.
+	CopyLink Link = "copy"
+	// HardLink links the destination to the cached file. Both must be on the
+	// same filesystem. Modifying the destination modifies the cached file.
+	HardLink Link = "hard"
+	// Reflink clones the cached file. The clone shares the content of the
+	// file until either of them is modified. It's supported by btrfs, xfs
+	// and other copy-on-write filesystems, on Linux.
+	Reflink Link = "reflink"
+	// SymbolicLink makes the destination a symbolic link to the cached file.
+	SymbolicLink Link = "symlink"
+)
+
+// ParseLink validates a way to place cached files.
+func ParseLink(value string) (Link, error) {
+	switch link := Link(value); link {
+	case CopyLink, HardLink, Reflink, SymbolicLink:
+		return link, nil
+	case "":
+		return CopyLink, nil
+	}
+	return "", fmt.Errorf("Invalid value [%s]. Should be hard, reflink, symlink or copy", value)
+}
+
+// SharesFile tells if destinations placed this way are the cached file itself,
+// so that changing their mode or attributes changes the cached file too.
+func (l Link) SharesFile() bool {
+	return l == HardLink || l == SymbolicLink
+}
+
+// Place places a file at a destination, `-` being stdout. Files that can't
+// be linked, for example because they are on different filesystems, are
+// copied instead, which is logged to log.
+func (l Link) Place(log logs.Log, src, dst string) error {
+	if l != "" && l != CopyLink && dst != "-" {
+		err := l.link(src, dst)
+		if err == nil {
+			return nil
+		}
+		log.Infoln("Copy", src, "to", dst, "since it can't be linked:", err)
+	}
+
+	if dst == "-" {
+		return Copy(src, dst)
+	}
+	return replace(src, dst)
+}
+
+// replace copies a file to a temporary file that then replaces the
+// destination. Unlike writing to the destination, it doesn't modify the file
+// the destination links to, if any, like a cached file. The permissions of
+// the destination are kept.
+func replace(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := CreateTemp(dst)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if info, err := os.Lstat(dst); err == nil && info.Mode().IsRegular() {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// link links a file to a temporary file that then replaces the destination.
+func (l Link) link(src, dst string) error {
+	if err := MkdirAll(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	// Renaming a hard link over another link to the same file does nothing.
+	if l == HardLink && sameFile(src, dst) {
+		return nil
+	}
+
+	tmp := tempName(dst)
+
+	var err error
+	switch l {
+	case HardLink:
+		err = os.Link(src, tmp)
+	case Reflink:
+		err = reflink(src, tmp)
+	case SymbolicLink:
+		var target string
+		if target, err = filepath.Abs(src); err == nil {
+			err = os.Symlink(target, tmp)
+		}
+	default:
+		err = fmt.Errorf("Unknown link %s", l)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// sameFile tells if a destination is the same file as a source, not a
+// symbolic link to it.
+func sameFile(src, dst string) bool {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(srcInfo, dstInfo)
+}
@@ -0,0 +1,59 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dgageot/getme/logs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-link-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "cached")
+	assert.NoError(t, ioutil.WriteFile(src, []byte("content"), 0644))
+
+	for _, link := range []Link{CopyLink, HardLink, Reflink, SymbolicLink} {
+		dst := filepath.Join(dir, "workspace", string(link))
+		assert.NoError(t, link.Place(logs.Log{}, src, dst))
+
+		content, err := ioutil.ReadFile(dst)
+		assert.NoError(t, err)
+		assert.Equal(t, "content", string(content))
+	}
+
+	if runtime.GOOS != "windows" {
+		assert.True(t, sameFile(src, filepath.Join(dir, "workspace", "hard")))
+		info, err := os.Lstat(filepath.Join(dir, "workspace", "symlink"))
+		assert.NoError(t, err)
+		assert.True(t, info.Mode()&os.ModeSymlink != 0)
+	}
+
+	// Copies replace links instead of writing to the linked file.
+	other := filepath.Join(dir, "other")
+	assert.NoError(t, ioutil.WriteFile(other, []byte("other"), 0644))
+	for _, name := range []string{"hard", "symlink"} {
+		dst := filepath.Join(dir, "workspace", name)
+		assert.NoError(t, CopyLink.Place(logs.Log{}, other, dst))
+
+		content, err := ioutil.ReadFile(dst)
+		assert.NoError(t, err)
+		assert.Equal(t, "other", string(content))
+	}
+	content, err := ioutil.ReadFile(src)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	left, err := ioutil.ReadDir(filepath.Join(dir, "workspace"))
+	assert.NoError(t, err)
+	assert.Len(t, left, 4)
+
+	_, err = ParseLink("junction")
+	assert.Error(t, err)
+}
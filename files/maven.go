@@ -0,0 +1,70 @@
+package files
+
+import (
+	"context"
+	"io"
+
+	"github.com/dgageot/getme/digest"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/maven"
+)
+
+// resolveMaven pins the LATEST and RELEASE versions of a Maven artifact.
+func resolveMaven(ctx context.Context, rawURL string, options Options) (string, error) {
+	artifact, err := maven.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if artifact, err = maven.Pin(ctx, artifact, options.mavenRepository(), options.HTTPHeaders()); err != nil {
+		return "", err
+	}
+
+	resolvedURL := artifact.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("Maven artifact url is:", resolvedURL)
+	}
+	return resolvedURL, nil
+}
+
+// openMaven downloads a Maven artifact and verifies it against the checksum
+// published by the repository.
+func openMaven(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	artifact, err := maven.ParseURL(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	headers := options.HTTPHeaders()
+	if artifact, err = maven.Pin(ctx, artifact, options.mavenRepository(), headers); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	location := artifact.Location(options.mavenRepository())
+	logs.From(ctx).Infoln("Maven artifact location is:", location)
+
+	algorithm, checksum, err := maven.Checksum(ctx, location, headers)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	reader, metadata, err := fetchHTTP(ctx, location, headers, options)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if checksum == "" {
+		logs.From(ctx).Infoln("No checksum is published for", location)
+		return reader, metadata, nil
+	}
+
+	verified, err := digest.Verify(reader, location, algorithm, checksum)
+	return verified, metadata, err
+}
+
+func (o *Options) mavenRepository() string {
+	if o.MavenRepository != "" {
+		return o.MavenRepository
+	}
+	return maven.DefaultRepository
+}
@@ -1,6 +1,14 @@
 package files
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/logs"
 	"github.com/gobwas/glob"
 )
 
@@ -9,6 +17,60 @@ type ExtractedFile struct {
 	Destination string
 }
 
+// Entry describes a file in an archive.
+type Entry struct {
+	Name     string
+	Size     int64
+	Mode     os.FileMode
+	Linkname string
+}
+
+// ExtractOptions configures how archives are extracted.
+type ExtractOptions struct {
+	Excludes    Patterns
+	AllowUnsafe bool
+	// Dereference replaces links with copies of their targets.
+	Dereference bool
+	IfExists    IfExists
+
+	// Chmod, when not zero, overrides the mode of extracted files.
+	Chmod os.FileMode
+	// Umask is removed from the mode of extracted files.
+	Umask os.FileMode
+
+	// Origin, when set, is recorded on every extracted file.
+	Origin *Origin
+
+	// Progress, when set, is called as files are extracted.
+	Progress ExtractProgressFunc
+
+	// Written, when set, is called with the path of every file or link
+	// written to the disk.
+	Written func(path string)
+
+	// Log receives the logs of the extraction.
+	Log logs.Log
+}
+
+// ExtractProgressFunc is called after a file is extracted with the number of
+// files extracted so far and the number of files to extract, or -1 if it's
+// unknown, like for tar archives.
+type ExtractProgressFunc func(file string, filesDone, filesTotal int)
+
+// Extracted reports the progress of an extraction.
+func (o ExtractOptions) Extracted(file string, filesDone, filesTotal int) {
+	if o.Progress != nil {
+		o.Progress(file, filesDone, filesTotal)
+	}
+}
+
+// Wrote reports that a file or a link was written to the disk.
+func (o ExtractOptions) Wrote(path string) {
+	if o.Written != nil {
+		o.Written(path)
+	}
+}
+
 // FindExtractedFile find a file to be extracted by its name.
 func FindExtractedFile(name string, files []ExtractedFile) *ExtractedFile {
 	for _, file := range files {
@@ -19,3 +81,81 @@ func FindExtractedFile(name string, files []ExtractedFile) *ExtractedFile {
 	}
 	return nil
 }
+
+// Patterns are compiled glob patterns, like the exclude patterns of an
+// extraction.
+type Patterns []glob.Glob
+
+// ParsePatterns compiles glob patterns where `**` crosses directories.
+func ParsePatterns(patterns []string) (Patterns, error) {
+	var compiled Patterns
+	for _, pattern := range patterns {
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern [%s]: %s", pattern, err)
+		}
+		compiled = append(compiled, g)
+	}
+	return compiled, nil
+}
+
+// IsExcluded tells if an archive entry matches one of the exclude patterns.
+// Patterns are matched against the full path, and against the base name so
+// that `*.md` excludes markdown files at any depth.
+func (o ExtractOptions) IsExcluded(name string) bool {
+	name = strings.TrimSuffix(name, "/")
+
+	for _, g := range o.Excludes {
+		if g.Match(name) || g.Match(path.Base(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteFile writes an extracted file to its destination, given the
+// information found in the archive. Its mode is forced, even if the file
+// already existed.
+func (o ExtractOptions) WriteFile(dst string, info os.FileInfo, reader io.Reader) error {
+	write, err := o.IfExists.ShouldWrite(o.Log, dst, info.ModTime())
+	if err != nil || !write {
+		return err
+	}
+
+	mode := o.fileMode(info.Mode())
+
+	if err := CopyFrom(dst, mode, reader); err != nil {
+		return err
+	}
+	o.Log.Event(logs.Debug, "extract_entry", logs.Fields{"path": dst, "size": info.Size(), "mode": fmt.Sprintf("%o", mode)}, "Extracted", dst)
+
+	if dst == "-" {
+		return nil
+	}
+	o.Wrote(dst)
+
+	if err := os.Chmod(dst, mode); err != nil {
+		return err
+	}
+
+	if o.Origin != nil {
+		return SetOrigin(dst, *o.Origin)
+	}
+	return nil
+}
+
+func (o ExtractOptions) fileMode(mode os.FileMode) os.FileMode {
+	if o.Chmod != 0 {
+		mode = o.Chmod
+	}
+	return mode.Perm() &^ o.Umask
+}
+
+// ParseMode parses an octal file mode such as `0755`.
+func ParseMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("Invalid mode [%s]. Should be octal, like 0755", value)
+	}
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,43 @@
+package files
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsExcluded(t *testing.T) {
+	excludes, err := ParsePatterns([]string{"docs/**", "*.md"})
+	assert.NoError(t, err)
+	options := ExtractOptions{Excludes: excludes}
+
+	assert.True(t, options.IsExcluded("docs/index.html"))
+	assert.True(t, options.IsExcluded("docs/api/index.html"))
+	assert.True(t, options.IsExcluded("README.md"))
+	assert.True(t, options.IsExcluded("src/CHANGELOG.md"))
+
+	assert.False(t, options.IsExcluded("bin/docker"))
+	assert.False(t, options.IsExcluded("src/docs/index.html"))
+	assert.False(t, ExtractOptions{}.IsExcluded("README.md"))
+
+	_, err = ParsePatterns([]string{"docs/[a-"})
+	assert.Error(t, err)
+}
+
+func TestFileMode(t *testing.T) {
+	assert.Equal(t, os.FileMode(0755), ExtractOptions{}.fileMode(0755))
+	assert.Equal(t, os.FileMode(0755), ExtractOptions{Umask: 022}.fileMode(0777))
+	assert.Equal(t, os.FileMode(0700), ExtractOptions{Chmod: 0755, Umask: 077}.fileMode(0644))
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("0755")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), mode)
+
+	_, err = ParseMode("rwx")
+	assert.Error(t, err)
+	_, err = ParseMode("17777")
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package files
+
+import (
+	"context"
+	"io"
+	"net/url"
+
+	"github.com/dgageot/getme/digest"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/npm"
+)
+
+// resolveNPM pins the dist-tag of an npm package, like latest, to the
+// version it points to.
+func resolveNPM(ctx context.Context, rawURL string, options Options) (string, error) {
+	pkg, err := npm.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	dist, err := npm.Resolve(ctx, pkg, options.npm())
+	if err != nil {
+		return "", err
+	}
+
+	pkg.Version = dist.Version
+	if pkg.URL() != rawURL {
+		logs.From(ctx).Infoln("npm package url is:", pkg.URL())
+	}
+	return pkg.URL(), nil
+}
+
+// openNPM downloads the tarball of an npm package and verifies its
+// integrity.
+func openNPM(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	pkg, err := npm.ParseURL(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	dist, err := npm.Resolve(ctx, pkg, options.npm())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	logs.From(ctx).Infoln("npm tarball is:", dist.Tarball)
+
+	// The token is only sent to the registry itself.
+	headers := options.Headers
+	if hostOf(dist.Tarball) == hostOf(options.npm().RegistryURL()) {
+		headers = append(options.npm().AuthHeaders(), headers...)
+	}
+
+	reader, metadata, err := fetchHTTP(ctx, dist.Tarball, headers, options)
+	if err != nil || dist.Digest == "" {
+		return reader, metadata, err
+	}
+
+	verified, err := digest.Verify(reader, dist.Tarball, dist.Algorithm, dist.Digest)
+	return verified, metadata, err
+}
+
+func (o *Options) npm() npm.Options {
+	return npm.Options{Registry: o.NPMRegistry, Token: o.NPMToken, Headers: o.Headers}
+}
+
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"io"
+	"os"
+)
+
+// ProgressFunc is called as a file is read with the number of bytes read so
+// far and the size of the file, or -1 if it's unknown. The last call, with
+// bytesDone equal to bytesTotal, tells that the whole file was read.
+type ProgressFunc func(bytesDone, bytesTotal int64)
+
+type progressReader struct {
+	io.ReadCloser
+	done     int64
+	total    int64
+	finished bool
+	progress ProgressFunc
+}
+
+func withProgress(reader io.ReadCloser, size int64, progress ProgressFunc) io.ReadCloser {
+	return &progressReader{ReadCloser: reader, total: size, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.done += int64(n)
+	if r.finished {
+		return n, err
+	}
+
+	if err == io.EOF {
+		r.total = r.done
+	}
+	if n > 0 || err == io.EOF {
+		r.finished = r.done == r.total
+		r.progress(r.done, r.total)
+	}
+
+	return n, err
+}
+
+// sizeOf gives the size of what a reader reads, or -1 if it's unknown.
+func sizeOf(reader io.Reader) int64 {
+	if file, ok := reader.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+	return -1
+}
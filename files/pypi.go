@@ -0,0 +1,54 @@
+package files
+
+import (
+	"context"
+	"io"
+
+	"github.com/dgageot/getme/digest"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/pypi"
+)
+
+// resolvePyPI pins the version of a PyPI package and the file to download.
+func resolvePyPI(ctx context.Context, rawURL string, options Options) (string, error) {
+	pkg, err := pypi.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	pinned, _, err := pypi.Resolve(ctx, pkg, options.pypi())
+	if err != nil {
+		return "", err
+	}
+
+	if pinned.URL() != rawURL {
+		logs.From(ctx).Infoln("PyPI package url is:", pinned.URL())
+	}
+	return pinned.URL(), nil
+}
+
+// openPyPI downloads a file of a PyPI package and verifies its sha256.
+func openPyPI(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	pkg, err := pypi.ParseURL(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	_, file, err := pypi.Resolve(ctx, pkg, options.pypi())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	logs.From(ctx).Infoln("PyPI file is:", file.URL)
+
+	reader, metadata, err := fetchHTTP(ctx, file.URL, options.Headers, options)
+	if err != nil || file.Digests.Sha256 == "" {
+		return reader, metadata, err
+	}
+
+	verified, err := digest.Verify(reader, file.URL, "sha256", file.Digests.Sha256)
+	return verified, metadata, err
+}
+
+func (o *Options) pypi() pypi.Options {
+	return pypi.Options{Index: o.PyPIIndex, Headers: o.Headers}
+}
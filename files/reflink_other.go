@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import "errors"
+
+func reflink(src, dst string) error {
+	return errors.New("Reflinks are only supported on Linux")
+}
@@ -0,0 +1,163 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EntryPath computes where an archive entry should be extracted. Unless
+// unsafe extraction is allowed, entries that would escape the destination
+// folder are rejected, be it with their name or through links extracted by
+// earlier entries.
+func (o ExtractOptions) EntryPath(destinationFolder, name string) (string, error) {
+	path := filepath.Join(destinationFolder, name)
+	if o.AllowUnsafe {
+		return path, nil
+	}
+
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("Unsafe archive entry [%s]: absolute path", name)
+	}
+	for _, part := range strings.FieldsFunc(name, isSeparator) {
+		if part == ".." {
+			return "", fmt.Errorf("Unsafe archive entry [%s]: path traversal", name)
+		}
+	}
+
+	if err := o.CheckPath(destinationFolder, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CheckPath makes sure a path, once the links it goes through are resolved,
+// is within the destination folder.
+func (o ExtractOptions) CheckPath(destinationFolder, path string) error {
+	if o.AllowUnsafe {
+		return nil
+	}
+
+	within, err := resolvesWithin(destinationFolder, path)
+	if err != nil {
+		return err
+	}
+	if !within {
+		return fmt.Errorf("Unsafe archive entry [%s]: goes through a link out of the destination", path)
+	}
+	return nil
+}
+
+// CheckLink makes sure a link extracted at a given path doesn't point outside
+// of the destination folder. The target is resolved from the real folder of
+// the link, following the links that already exist.
+func (o ExtractOptions) CheckLink(destinationFolder, path, target string) error {
+	if o.AllowUnsafe {
+		return nil
+	}
+
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("Unsafe link [%s -> %s]: absolute target", path, target)
+	}
+
+	if err := o.CheckPath(destinationFolder, filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	// The target isn't cleaned before it's resolved: `link/..` isn't the
+	// same as `.` if link is a link.
+	within, err := resolvesWithin(destinationFolder, filepath.Dir(path)+string(filepath.Separator)+target)
+	if err != nil {
+		return err
+	}
+	if !within {
+		return fmt.Errorf("Unsafe link [%s -> %s]: escapes the destination", path, target)
+	}
+
+	return nil
+}
+
+func resolvesWithin(folder, path string) (bool, error) {
+	realFolder, err := realPath(folder)
+	if err != nil {
+		return false, err
+	}
+	realPath, err := realPath(path)
+	if err != nil {
+		return false, err
+	}
+	return isWithin(realFolder, realPath), nil
+}
+
+// realPath resolves the links of a path, one element at a time, like the
+// system does. Unlike filepath.EvalSymlinks, the path doesn't have to exist:
+// what follows the first missing element is kept as is.
+func realPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path = cwd + string(filepath.Separator) + path
+	}
+
+	volume := filepath.VolumeName(path)
+	real := volume + string(filepath.Separator)
+	rest := strings.FieldsFunc(path[len(volume):], isSeparator)
+
+	for links := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+
+		switch part {
+		case ".":
+			continue
+		case "..":
+			real = filepath.Dir(real)
+			continue
+		}
+
+		next := filepath.Join(real, part)
+		info, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			// Nothing under a missing element can be a link.
+			return filepath.Join(append([]string{next}, rest...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			real = next
+			continue
+		}
+
+		if links++; links > 255 {
+			return "", errors.New("Too many levels of links in " + path)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			volume = filepath.VolumeName(target)
+			real, target = volume+string(filepath.Separator), target[len(volume):]
+		}
+		rest = append(strings.FieldsFunc(target, isSeparator), rest...)
+	}
+
+	return real, nil
+}
+
+func isWithin(folder, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(folder), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
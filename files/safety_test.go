@@ -0,0 +1,71 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryPath(t *testing.T) {
+	options := ExtractOptions{}
+
+	path, err := options.EntryPath("dest", "bin/docker")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("dest", "bin", "docker"), path)
+
+	_, err = options.EntryPath("dest", "../etc/passwd")
+	assert.Error(t, err)
+	_, err = options.EntryPath("dest", "bin/../../etc/passwd")
+	assert.Error(t, err)
+	_, err = options.EntryPath("dest", "/etc/passwd")
+	assert.Error(t, err)
+	_, err = options.EntryPath("dest", `..\windows`)
+	assert.Error(t, err)
+
+	_, err = ExtractOptions{AllowUnsafe: true}.EntryPath("dest", "../etc/passwd")
+	assert.NoError(t, err)
+}
+
+func TestCheckLink(t *testing.T) {
+	options := ExtractOptions{}
+
+	assert.NoError(t, options.CheckLink("dest", filepath.Join("dest", "lib", "libfoo.so"), "libfoo.so.1"))
+	assert.NoError(t, options.CheckLink("dest", filepath.Join("dest", "bin", "foo"), "../lib/foo"))
+
+	assert.Error(t, options.CheckLink("dest", filepath.Join("dest", "lib", "libfoo.so"), "/lib/libfoo.so.1"))
+	assert.Error(t, options.CheckLink("dest", filepath.Join("dest", "bin", "foo"), "../../etc"))
+
+	assert.NoError(t, ExtractOptions{AllowUnsafe: true}.CheckLink("dest", filepath.Join("dest", "foo"), "/etc"))
+}
+
+func TestLinkedParents(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symbolic links need privileges on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-safety-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dest := filepath.Join(dir, "dest")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dest, "a", "b", "c"), 0755))
+	assert.NoError(t, os.Symlink("../../..", filepath.Join(dest, "a", "b", "c", "d")))
+	assert.NoError(t, os.Symlink(dir, filepath.Join(dest, "out")))
+	options := ExtractOptions{}
+
+	// Each link stays in the destination, but chaining them doesn't.
+	assert.NoError(t, options.CheckLink(dest, filepath.Join(dest, "a", "b", "c", "d"), "../../.."))
+	assert.Error(t, options.CheckLink(dest, filepath.Join(dest, "a", "b", "c", "d", "l"), "../../.."))
+
+	_, err = options.EntryPath(dest, "out/victim")
+	assert.Error(t, err)
+	_, err = options.EntryPath(dest, "out")
+	assert.Error(t, err)
+	path, err := options.EntryPath(dest, "a/b/c/d/a/file")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, "a", "b", "c", "d", "a", "file"), path)
+}
@@ -0,0 +1,42 @@
+package files
+
+import (
+	"github.com/dgageot/getme/vault"
+)
+
+// LoadSecrets reads the secrets given by Vault paths, like
+// `secret/data/ci#token`, into the options. Vault is configured with the
+// standard VAULT_* environment variables.
+func (o *Options) LoadSecrets() error {
+	secrets := []struct {
+		path  string
+		value *string
+	}{
+		{o.AuthTokenVaultPath, &o.AuthToken},
+		{o.S3AccessKeyVaultPath, &o.S3AccessKey},
+		{o.S3SecretKeyVaultPath, &o.S3SecretKey},
+	}
+
+	var client *vault.Client
+	for _, secret := range secrets {
+		if secret.path == "" {
+			continue
+		}
+
+		if client == nil {
+			fromEnv, err := vault.FromEnv()
+			if err != nil {
+				return err
+			}
+			client = &fromEnv
+		}
+
+		value, err := client.ReadField(secret.path)
+		if err != nil {
+			return err
+		}
+		*secret.value = value
+	}
+
+	return nil
+}
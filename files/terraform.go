@@ -0,0 +1,64 @@
+package files
+
+import (
+	"context"
+	"io"
+
+	"github.com/dgageot/getme/digest"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/terraform"
+)
+
+// resolveTerraform pins the version and the platform of a Terraform provider.
+func resolveTerraform(ctx context.Context, rawURL string, options Options) (string, error) {
+	provider, err := terraform.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if provider, err = terraform.Pin(ctx, provider, options.terraform()); err != nil {
+		return "", err
+	}
+
+	resolvedURL := provider.URL()
+	if resolvedURL != rawURL {
+		logs.From(ctx).Infoln("Terraform provider url is:", resolvedURL)
+	}
+	return resolvedURL, nil
+}
+
+// openTerraform downloads a Terraform provider and verifies it against its
+// signed SHA256SUMS.
+func openTerraform(ctx context.Context, rawURL string, options Options) (io.ReadCloser, Metadata, error) {
+	provider, err := terraform.ParseURL(rawURL)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if provider, err = terraform.Pin(ctx, provider, options.terraform()); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	download, err := terraform.Find(ctx, provider, options.terraform())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	logs.From(ctx).Infoln("Terraform provider is:", download.DownloadURL)
+
+	sha256, err := terraform.Checksum(ctx, download, options.Headers)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	reader, metadata, err := fetchHTTP(ctx, download.DownloadURL, options.Headers, options)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	verified, err := digest.Verify(reader, download.DownloadURL, "sha256", sha256)
+	return verified, metadata, err
+}
+
+func (o *Options) terraform() terraform.Options {
+	return terraform.Options{Token: o.TerraformToken, Headers: o.Headers}
+}
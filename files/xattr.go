@@ -0,0 +1,21 @@
+package files
+
+// Origin describes where a file on disk comes from.
+type Origin struct {
+	URL    string
+	Sha256 string
+}
+
+const (
+	xattrURL    = "user.getme.url"
+	xattrSha256 = "user.getme.sha256"
+)
+
+// SetOrigin stores the origin of a file as extended attributes. It's a no-op
+// on file systems and platforms that don't support them.
+func SetOrigin(path string, origin Origin) error {
+	if err := setXattr(path, xattrURL, origin.URL); err != nil {
+		return err
+	}
+	return setXattr(path, xattrSha256, origin.Sha256)
+}
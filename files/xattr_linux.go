@@ -0,0 +1,13 @@
+package files
+
+import (
+	"syscall"
+)
+
+func setXattr(path, name, value string) error {
+	err := syscall.Setxattr(path, name, []byte(value), 0)
+	if err == syscall.ENOTSUP {
+		return nil
+	}
+	return err
+}
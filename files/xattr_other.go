@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+func setXattr(path, name, value string) error {
+	return nil
+}
@@ -0,0 +1,252 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const timeout = 30 * time.Second
+
+var pasvAddress = regexp.MustCompile(`\((\d+),(\d+),(\d+),(\d+),(\d+),(\d+)\)`)
+
+// Open downloads a file from an FTP server. Supported schemes are `ftp`,
+// `ftps` for implicit TLS and `ftpes` for explicit TLS. Logins default to
+// anonymous.
+func Open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	secure := u.Scheme == "ftps" || u.Scheme == "ftpes"
+
+	port := u.Port()
+	if port == "" {
+		port = "21"
+		if u.Scheme == "ftps" {
+			port = "990"
+		}
+	}
+	address := net.JoinHostPort(u.Hostname(), port)
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: timeout}
+	if u.Scheme == "ftps" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &client{raw: conn, conn: textproto.NewConn(conn), host: u.Hostname(), tlsConfig: tlsConfig}
+	c.track(conn)
+
+	// Cancelling the context closes the connections, which unblocks any
+	// pending read.
+	stop := context.AfterFunc(ctx, c.abort)
+
+	reader, err := c.retrieve(ctx, u, secure)
+	if err != nil {
+		stop()
+		c.abort()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	reader.stop = stop
+	return reader, nil
+}
+
+type client struct {
+	raw       net.Conn
+	conn      *textproto.Conn
+	host      string
+	tlsConfig *tls.Config
+	secure    bool
+
+	lock  sync.Mutex
+	conns []net.Conn
+}
+
+// track records a connection to be closed if the download is aborted.
+func (c *client) track(conn net.Conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.conns = append(c.conns, conn)
+}
+
+// abort closes all the connections.
+func (c *client) abort() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+func (c *client) retrieve(ctx context.Context, u *url.URL, secure bool) (*response, error) {
+	if _, _, err := c.conn.ReadResponse(220); err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "ftpes" {
+		if _, err := c.cmd(234, "AUTH TLS"); err != nil {
+			return nil, err
+		}
+
+		c.raw = tls.Client(c.raw, c.tlsConfig)
+		c.conn = textproto.NewConn(c.raw)
+	}
+
+	if secure {
+		if _, err := c.cmd(200, "PBSZ 0"); err != nil {
+			return nil, err
+		}
+		if _, err := c.cmd(200, "PROT P"); err != nil {
+			return nil, err
+		}
+		c.secure = true
+	}
+
+	user, password := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	code, msg, err := c.send("USER " + user)
+	if err != nil {
+		return nil, err
+	}
+	if code == 331 {
+		if _, err := c.cmd(230, "PASS "+password); err != nil {
+			return nil, err
+		}
+	} else if code != 230 {
+		return nil, fmt.Errorf("FTP login failed: %d %s", code, msg)
+	}
+
+	if _, err := c.cmd(200, "TYPE I"); err != nil {
+		return nil, err
+	}
+
+	data, err := c.openDataConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code, msg, err = c.send("RETR " + u.Path)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	if code != 125 && code != 150 {
+		data.Close()
+		return nil, fmt.Errorf("Unable to retrieve %s: %d %s", u.Path, code, msg)
+	}
+
+	return &response{data: data, client: c}, nil
+}
+
+// openDataConn opens a passive data connection, trying EPSV before PASV.
+func (c *client) openDataConn(ctx context.Context) (net.Conn, error) {
+	var port int
+
+	code, msg, err := c.send("EPSV")
+	if err != nil {
+		return nil, err
+	}
+	if code == 229 {
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("Invalid EPSV response: %s", msg)
+		}
+		if port, err = strconv.Atoi(msg[start+4 : end]); err != nil {
+			return nil, err
+		}
+	} else {
+		msg, err := c.cmd(227, "PASV")
+		if err != nil {
+			return nil, err
+		}
+
+		parts := pasvAddress.FindStringSubmatch(msg)
+		if parts == nil {
+			return nil, fmt.Errorf("Invalid PASV response: %s", msg)
+		}
+		high, _ := strconv.Atoi(parts[5])
+		low, _ := strconv.Atoi(parts[6])
+		port = high*256 + low
+	}
+
+	// The address advertised by PASV is ignored since it's often wrong
+	// behind NAT. The data connection goes to the same host.
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", net.JoinHostPort(c.host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	c.track(conn)
+
+	if c.secure {
+		return tls.Client(conn, c.tlsConfig), nil
+	}
+	return conn, nil
+}
+
+func (c *client) send(command string) (int, string, error) {
+	id, err := c.conn.Cmd("%s", command)
+	if err != nil {
+		return 0, "", err
+	}
+
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+
+	return c.conn.ReadResponse(0)
+}
+
+func (c *client) cmd(expectedCode int, command string) (string, error) {
+	code, msg, err := c.send(command)
+	if err != nil {
+		return "", err
+	}
+	if code != expectedCode {
+		verb := strings.SplitN(command, " ", 2)[0]
+		return "", fmt.Errorf("FTP command %s failed: %d %s", verb, code, msg)
+	}
+	return msg, nil
+}
+
+// response reads the data connection and terminates the session once closed.
+type response struct {
+	data   net.Conn
+	client *client
+	stop   func() bool
+}
+
+func (r *response) Read(p []byte) (int, error) {
+	return r.data.Read(p)
+}
+
+func (r *response) Close() error {
+	r.stop()
+	r.data.Close()
+	r.client.conn.ReadResponse(226)
+	r.client.send("QUIT")
+	return r.client.conn.Close()
+}
@@ -0,0 +1,99 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	address := fakeServer(t, "/pub/gnu/hello.tar.gz", "content")
+
+	u, err := url.Parse("ftp://" + address + "/pub/gnu/hello.tar.gz")
+	assert.NoError(t, err)
+
+	reader, err := Open(context.Background(), u)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.NoError(t, reader.Close())
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	address := fakeServer(t, "/pub/gnu/hello.tar.gz", "content")
+
+	u, err := url.Parse("ftp://" + address + "/pub/unknown.tar.gz")
+	assert.NoError(t, err)
+
+	_, err = Open(context.Background(), u)
+	assert.Error(t, err)
+}
+
+// fakeServer serves a single file over passive FTP.
+func fakeServer(t *testing.T, path, content string) string {
+	control, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	data, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go func() {
+		defer control.Close()
+		defer data.Close()
+
+		conn, err := control.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 Welcome")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+
+			parts := strings.SplitN(line, " ", 2)
+			switch parts[0] {
+			case "USER":
+				text.PrintfLine("331 Password required")
+			case "PASS":
+				text.PrintfLine("230 Logged in")
+			case "TYPE":
+				text.PrintfLine("200 Binary mode")
+			case "EPSV":
+				text.PrintfLine("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+			case "RETR":
+				if parts[1] != path {
+					text.PrintfLine("550 File not found")
+					continue
+				}
+				dataConn, err := data.Accept()
+				if err != nil {
+					return
+				}
+				text.PrintfLine("150 Opening data connection")
+				fmt.Fprint(dataConn, content)
+				dataConn.Close()
+				text.PrintfLine("226 Transfer complete")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	return control.Addr().String()
+}
@@ -0,0 +1,242 @@
+package gcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultScope  = "https://www.googleapis.com/auth/devstorage.read_only"
+	tokenURL      = "https://oauth2.googleapis.com/token"
+	metadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// credentialsFile is either a service account key or the authorized user
+// written by `gcloud auth application-default login`.
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// Service account
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	// Authorized user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
+var (
+	tokensLock sync.Mutex
+	tokens     = map[Options]string{}
+)
+
+// AccessToken finds an OAuth2 access token using, in order, the given
+// credentials file, GOOGLE_APPLICATION_CREDENTIALS, the gcloud application
+// default credentials and the GCE metadata server. It returns an empty token
+// if no credentials can be found.
+func AccessToken(ctx context.Context, options Options) (string, error) {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	if token, found := tokens[options]; found {
+		return token, nil
+	}
+
+	token, err := findAccessToken(ctx, options)
+	if err != nil {
+		return "", err
+	}
+
+	tokens[options] = token
+	return token, nil
+}
+
+func findAccessToken(ctx context.Context, options Options) (string, error) {
+	path := options.CredentialsFile
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path != "" {
+		return tokenFromFile(ctx, path, options)
+	}
+
+	if path = wellKnownFile(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return tokenFromFile(ctx, path, options)
+		}
+	}
+
+	return tokenFromMetadata(ctx)
+}
+
+func tokenFromFile(ctx context.Context, path string, options Options) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var credentials credentialsFile
+	if err := json.Unmarshal(content, &credentials); err != nil {
+		return "", fmt.Errorf("Invalid credentials file %s: %v", path, err)
+	}
+
+	switch credentials.Type {
+	case "service_account":
+		return serviceAccountToken(ctx, credentials, options.scope())
+	case "authorized_user":
+		return requestToken(ctx, tokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {credentials.ClientID},
+			"client_secret": {credentials.ClientSecret},
+			"refresh_token": {credentials.RefreshToken},
+		})
+	}
+	return "", fmt.Errorf("Unsupported credentials type [%s] in %s", credentials.Type, path)
+}
+
+// serviceAccountToken exchanges a JWT signed with the service account key
+// for an access token.
+func serviceAccountToken(ctx context.Context, credentials credentialsFile, scope string) (string, error) {
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return "", errors.New("Invalid service account private key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("Service account private key should be an RSA key")
+	}
+
+	audience := credentials.TokenURI
+	if audience == "" {
+		audience = tokenURL
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   credentials.ClientEmail,
+		"scope": scope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return requestToken(ctx, audience, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+}
+
+func requestToken(ctx context.Context, endpoint string, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("Unable to get a Google access token: %s", resp.Status)
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// tokenFromMetadata gets a token for the default service account of a GCE
+// instance. Not being able to reach the metadata server quickly means that
+// we are not running on GCE.
+func tokenFromMetadata(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", metadataToken, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		// A cancelled request doesn't mean there's no metadata server.
+		return "", ctx.Err()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func (o Options) scope() string {
+	if o.Scope != "" {
+		return o.Scope
+	}
+	return defaultScope
+}
+
+func wellKnownFile() string {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return ""
+		}
+		return filepath.Join(appData, "gcloud", "application_default_credentials.json")
+	}
+
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
@@ -0,0 +1,132 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// ErrNotFound is returned when an object doesn't exist.
+var ErrNotFound = fmt.Errorf("404 %w", errdefs.ErrNotFound)
+
+// ReadWriteScope gives read and write access to Cloud Storage, as needed by Put.
+const ReadWriteScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// Options configures access to Google Cloud Storage.
+type Options struct {
+	// CredentialsFile is a service account or authorized user json file.
+	// Defaults to Application Default Credentials.
+	CredentialsFile string
+
+	// Scope of the access token. Defaults to read only access to Cloud Storage.
+	Scope string
+}
+
+// Open opens a Google Cloud Storage object for reading. Requests are
+// anonymous if no credentials can be found.
+func Open(ctx context.Context, bucket, object string, options Options) (io.ReadCloser, error) {
+	token, err := AccessToken(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := "https://storage.googleapis.com/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(object) + "?alt=media"
+	if token == "" {
+		objectURL = "https://storage.googleapis.com/" + bucket + "/" + object
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", objectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if token == "" && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return nil, fmt.Errorf("%w. The bucket might be private, use --gcsCredentials or GOOGLE_APPLICATION_CREDENTIALS", &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp.Body, nil
+}
+
+// Put uploads a file to a Google Cloud Storage object. It requires
+// credentials with the ReadWriteScope.
+func Put(ctx context.Context, bucket, object string, file *os.File, options Options) error {
+	token, err := AccessToken(ctx, options)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("No credentials found to upload to Google Cloud Storage, use --gcsCredentials or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	uploadURL := "https://storage.googleapis.com/upload/storage/v1/b/" + url.PathEscape(bucket) + "/o?uploadType=media&name=" + url.QueryEscape(object)
+	req, err := http.NewRequestWithContext(ctx, "POST", uploadURL, ioutil.NopCloser(file))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// ParseURL extracts the bucket and the object from a `gs://bucket/object` url
+// or from an https url to storage.googleapis.com.
+func ParseURL(rawURL string) (bucket, object string, ok bool) {
+	var path string
+	switch {
+	case strings.HasPrefix(rawURL, "gs://"):
+		path = strings.TrimPrefix(rawURL, "gs://")
+	case strings.HasPrefix(rawURL, "https://storage.googleapis.com/"):
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", false
+		}
+		path = strings.TrimPrefix(parsed.Path, "/")
+	default:
+		return "", "", false
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
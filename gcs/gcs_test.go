@@ -0,0 +1,24 @@
+package gcs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	bucket, object, ok := ParseURL("gs://bucket/path/to/file.tgz")
+	assert.True(t, ok)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "path/to/file.tgz", object)
+
+	bucket, object, ok = ParseURL("https://storage.googleapis.com/bucket/file.tgz")
+	assert.True(t, ok)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "file.tgz", object)
+
+	_, _, ok = ParseURL("gs://bucket")
+	assert.False(t, ok)
+	_, _, ok = ParseURL("https://example.com/bucket/file.tgz")
+	assert.False(t, ok)
+}
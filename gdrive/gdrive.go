@@ -0,0 +1,201 @@
+package gdrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dgageot/getme/gcs"
+	"golang.org/x/net/html"
+)
+
+const driveScope = "https://www.googleapis.com/auth/drive.readonly"
+
+// Endpoints, overridden by tests.
+var (
+	downloadURL = "https://drive.usercontent.google.com/download"
+	apiURL      = "https://www.googleapis.com/drive/v3/files/"
+)
+
+// ErrPrivate is returned when a file can't be downloaded anonymously.
+var ErrPrivate = errors.New("Google Drive returned an html page instead of the file. The file might be private or over its download quota. Use --googleApiKey or Google credentials")
+
+// Options configures access to Google Drive.
+type Options struct {
+	APIKey          string
+	CredentialsFile string
+}
+
+var (
+	filePathURL = regexp.MustCompile(`^https://drive\.google\.com/file/d/([^/?#]+)`)
+	driveHosts  = map[string]bool{"drive.google.com": true, "docs.google.com": true, "drive.usercontent.google.com": true}
+)
+
+// FileID extracts the id of a file from a share link. Supported forms are
+// `https://drive.google.com/file/d/ID/view`, `https://drive.google.com/open?id=ID`
+// and `https://drive.google.com/uc?id=ID`.
+func FileID(rawURL string) (string, bool) {
+	if parts := filePathURL.FindStringSubmatch(rawURL); parts != nil {
+		return parts[1], true
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || !driveHosts[parsed.Host] {
+		return "", false
+	}
+	if id := parsed.Query().Get("id"); id != "" {
+		return id, true
+	}
+	return "", false
+}
+
+// Open downloads a file from Google Drive. With an api key, the Drive api is
+// used. Otherwise, the file is downloaded like a browser would, going through
+// the confirmation page shown for large files. Private files are downloaded
+// through the api with an OAuth token, if Google credentials are found.
+func Open(ctx context.Context, id string, options Options) (io.ReadCloser, error) {
+	if options.APIKey != "" {
+		return openAPI(ctx, id, "key="+url.QueryEscape(options.APIKey), "")
+	}
+
+	body, err := openPublic(ctx, id)
+	if err != ErrPrivate {
+		return body, err
+	}
+
+	token, tokenErr := gcs.AccessToken(ctx, gcs.Options{CredentialsFile: options.CredentialsFile, Scope: driveScope})
+	if tokenErr != nil || token == "" {
+		return nil, err
+	}
+
+	return openAPI(ctx, id, "", token)
+}
+
+func openPublic(ctx context.Context, id string) (io.ReadCloser, error) {
+	resp, err := get(ctx, downloadURL+"?export=download&id="+url.QueryEscape(id), "")
+	if err != nil {
+		return nil, err
+	}
+	if !isHTML(resp) {
+		return resp.Body, nil
+	}
+
+	// Files too large to be scanned for viruses need a confirmation.
+	confirm, err := confirmationURL(resp.Body, resp.Request.URL)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, err = get(ctx, confirm, ""); err != nil {
+		return nil, err
+	}
+	if isHTML(resp) {
+		resp.Body.Close()
+		return nil, ErrPrivate
+	}
+
+	return resp.Body, nil
+}
+
+func openAPI(ctx context.Context, id, query, token string) (io.ReadCloser, error) {
+	rawURL := apiURL + url.PathEscape(id) + "?alt=media&supportsAllDrives=true"
+	if query != "" {
+		rawURL += "&" + query
+	}
+
+	resp, err := get(ctx, rawURL, token)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func get(ctx context.Context, rawURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to download from Google Drive: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+func isHTML(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html")
+}
+
+// confirmationURL finds the url submitted by the download form of the
+// confirmation page.
+func confirmationURL(page io.Reader, base *url.URL) (string, error) {
+	root, err := html.Parse(page)
+	if err != nil {
+		return "", err
+	}
+
+	form := find(root, func(node *html.Node) bool {
+		return node.Data == "form" && attribute(node, "id") == "download-form"
+	})
+	if form == nil {
+		return "", ErrPrivate
+	}
+
+	action, err := base.Parse(attribute(form, "action"))
+	if err != nil {
+		return "", err
+	}
+
+	query := action.Query()
+	for _, input := range findAll(form, func(node *html.Node) bool { return node.Data == "input" }) {
+		if name := attribute(input, "name"); name != "" {
+			query.Set(name, attribute(input, "value"))
+		}
+	}
+	action.RawQuery = query.Encode()
+
+	return action.String(), nil
+}
+
+func find(node *html.Node, match func(*html.Node) bool) *html.Node {
+	if all := findAll(node, match); len(all) > 0 {
+		return all[0]
+	}
+	return nil
+}
+
+func findAll(node *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	if node.Type == html.ElementNode && match(node) {
+		found = append(found, node)
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		found = append(found, findAll(child, match)...)
+	}
+	return found
+}
+
+func attribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
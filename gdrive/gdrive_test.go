@@ -0,0 +1,80 @@
+package gdrive
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileID(t *testing.T) {
+	for _, rawURL := range []string{
+		"https://drive.google.com/file/d/1AbC-dEf/view?usp=sharing",
+		"https://drive.google.com/open?id=1AbC-dEf",
+		"https://drive.google.com/uc?id=1AbC-dEf&export=download",
+		"https://drive.usercontent.google.com/download?id=1AbC-dEf",
+	} {
+		id, ok := FileID(rawURL)
+		assert.True(t, ok, rawURL)
+		assert.Equal(t, "1AbC-dEf", id, rawURL)
+	}
+
+	_, ok := FileID("https://drive.google.com/drive/folders/1AbC-dEf")
+	assert.False(t, ok)
+	_, ok = FileID("https://example.com/uc?id=1AbC-dEf")
+	assert.False(t, ok)
+}
+
+func TestOpenLargeFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("confirm") != "t" || r.URL.Query().Get("uuid") != "1234" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<html><body><p>Google Drive can't scan this file for viruses.</p>
+<form id="download-form" action="/download" method="get">
+<input type="submit" value="Download anyway"/>
+<input type="hidden" name="id" value="`+r.URL.Query().Get("id")+`">
+<input type="hidden" name="export" value="download">
+<input type="hidden" name="confirm" value="t">
+<input type="hidden" name="uuid" value="1234">
+</form></body></html>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, "weights of "+r.URL.Query().Get("id"))
+	}))
+	defer server.Close()
+	defer func(previous string) { downloadURL = previous }(downloadURL)
+	downloadURL = server.URL + "/download"
+
+	reader, err := Open(context.Background(), "model", Options{})
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "weights of model", string(content))
+}
+
+func TestOpenWithAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/files/model", r.URL.Path)
+		assert.Equal(t, "media", r.URL.Query().Get("alt"))
+		assert.Equal(t, "KEY", r.URL.Query().Get("key"))
+		fmt.Fprint(w, "weights")
+	}))
+	defer server.Close()
+	defer func(previous string) { apiURL = previous }(apiURL)
+	apiURL = server.URL + "/files/"
+
+	reader, err := Open(context.Background(), "model", Options{APIKey: "KEY"})
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "weights", string(content))
+}
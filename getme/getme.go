@@ -0,0 +1,580 @@
+// Package getme downloads files through a cache, then copies or extracts them.
+// It's what the getme command line is built on. Every method takes a context
+// whose cancellation stops the download.
+package getme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgageot/getme/audit"
+	"github.com/dgageot/getme/cache"
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/hooks"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/tar"
+	"github.com/dgageot/getme/tracing"
+	"github.com/dgageot/getme/urls"
+	"github.com/dgageot/getme/zip"
+)
+
+// Client downloads, copies and extracts files. Its zero value caches files
+// in ~/.getme and overwrites existing files.
+type Client struct {
+	// CacheDir is where files are cached. Defaults to ~/.getme, or the
+	// backend given to cache.UseBackend.
+	CacheDir string
+	// Backend, when set, stores cached files instead of CacheDir, like an
+	// S3 bucket.
+	Backend cache.Backend
+	// HTTPClient, when set, is used for http and https urls.
+	HTTPClient *http.Client
+
+	// Options configure the downloads: credentials, checksum...
+	Options files.Options
+	// Force downloads files even if they are cached.
+	Force bool
+
+	// IfMissing skips copies and extractions whose destinations exist.
+	IfMissing bool
+	// IfExists tells what to do with existing destination files.
+	IfExists files.IfExists
+	// Link tells how copies are placed at their destination. They are plain
+	// copies by default.
+	Link files.Link
+	// ExtractOptions configure extractions. Their IfExists is replaced by
+	// the one of the client.
+	ExtractOptions files.ExtractOptions
+	// Stream extracts tar archives while they are downloaded. With
+	// StreamToCache, they are also stored in the cache.
+	Stream        bool
+	StreamToCache bool
+	// Xattrs records the source url and sha256 of copied and extracted
+	// files as extended attributes.
+	Xattrs bool
+	// Hooks are commands run to rewrite urls, and after files are
+	// downloaded, copied or extracted.
+	Hooks hooks.Hooks
+	// Audit, when set, records every file fetched through the cache.
+	Audit *audit.Log
+	// Logger, when set, receives the logs of the client instead of the
+	// logger given to logs.SetLogger.
+	Logger logs.Logger
+
+	// Progress, when set, is called as files are downloaded.
+	Progress files.ProgressFunc
+	// Events, when set, receives the progress of the operations: when they
+	// start, how much is downloaded, verified or extracted and when they're
+	// done. It must be drained while the client downloads files.
+	Events chan<- ProgressEvent
+}
+
+// Phase tells what's being done with an url.
+type Phase string
+
+// Phases of an operation, in the order they happen.
+const (
+	PhaseStarted    Phase = "started"
+	PhaseProgress   Phase = "progress"
+	PhaseVerifying  Phase = "verifying"
+	PhaseExtracting Phase = "extracting"
+	PhaseDone       Phase = "done"
+	PhaseFailed     Phase = "failed"
+)
+
+// ProgressEvent tells how an operation on an url is going.
+type ProgressEvent struct {
+	URL   string
+	Phase Phase
+
+	// BytesDone and BytesTotal follow a download. BytesTotal is -1 until
+	// the end of the download if the size of the file is unknown.
+	BytesDone  int64
+	BytesTotal int64
+
+	// File is the last extracted file. FilesTotal is -1 if the number of
+	// files to extract is unknown.
+	File       string
+	FilesDone  int
+	FilesTotal int
+
+	// Err is why an operation failed.
+	Err error
+}
+
+// UnsupportedArchiveError is returned for files that are neither zip nor tar
+// archives.
+type UnsupportedArchiveError struct {
+	Source string
+}
+
+func (e *UnsupportedArchiveError) Error() string {
+	return "Unsupported archive: " + e.Source
+}
+
+// Is tells that the error is an errdefs.ErrUnsupportedArchive.
+func (e *UnsupportedArchiveError) Is(target error) bool {
+	return target == errdefs.ErrUnsupportedArchive
+}
+
+// Cache gives the cache used by the client.
+func (c *Client) Cache() cache.Cache {
+	switch {
+	case c.Backend != nil:
+		return cache.Cache{Backend: c.Backend}
+	case c.CacheDir != "":
+		return cache.Cache{Backend: &cache.Disk{Dir: c.CacheDir}}
+	}
+	return cache.Default()
+}
+
+// context gives the context of an operation. Its logs go to the logger of
+// the client, if any.
+func (c *Client) context(ctx context.Context) context.Context {
+	if c.Logger == nil {
+		return ctx
+	}
+	return logs.WithLogger(ctx, c.Logger)
+}
+
+func (c *Client) options(url string) files.Options {
+	options := c.Options
+	if c.HTTPClient != nil {
+		options.HTTPClient = c.HTTPClient
+	}
+	if c.Progress != nil || c.Events != nil {
+		options.Progress = func(bytesDone, bytesTotal int64) {
+			if c.Progress != nil {
+				c.Progress(bytesDone, bytesTotal)
+			}
+			c.event(ProgressEvent{URL: url, Phase: PhaseProgress, BytesDone: bytesDone, BytesTotal: bytesTotal})
+		}
+	}
+	if c.Events != nil {
+		options.Verifying = func() {
+			c.event(ProgressEvent{URL: url, Phase: PhaseVerifying})
+		}
+	}
+	if c.Audit != nil {
+		options.Fetched = func(file files.FetchedFile) error {
+			return c.Audit.Write(audit.Record{URL: url, ResolvedURL: file.URL, Sha256: file.Sha256, Size: file.Size, Cached: file.Cached})
+		}
+	}
+	return options
+}
+
+func (c *Client) event(event ProgressEvent) {
+	if c.Events != nil {
+		c.Events <- event
+	}
+}
+
+// track sends the event of an operation that starts. The returned function
+// sends the event of its end.
+func (c *Client) track(url string) func(*error) {
+	c.event(ProgressEvent{URL: url, Phase: PhaseStarted})
+
+	return func(err *error) {
+		if *err != nil {
+			c.event(ProgressEvent{URL: url, Phase: PhaseFailed, Err: *err})
+		} else {
+			c.event(ProgressEvent{URL: url, Phase: PhaseDone})
+		}
+	}
+}
+
+// Download retrieves an url from the cache or downloads it if it's absent.
+func (c *Client) Download(ctx context.Context, url string) (entry cache.Entry, err error) {
+	ctx = c.context(ctx)
+	defer c.track(url)(&err)
+
+	options := c.options(url)
+	if url, err = c.Hooks.Rewrite(ctx, url); err != nil {
+		return cache.Entry{}, err
+	}
+
+	entry, err = c.Cache().Get(ctx, url, options, c.Force)
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, hooks.Run(ctx, c.Hooks.PostDownload, hooks.Data{URL: entry.URL, Path: entry.Path})
+}
+
+// Copy retrieves an url from the cache or downloads it if it's absent.
+// Then it copies the file to a destination path, `-` being stdout. A
+// destination that's a directory, or that ends with a separator, gets the
+// name of the file given by the server or at the end of the url.
+func (c *Client) Copy(ctx context.Context, url string, destination string) (err error) {
+	ctx = c.context(ctx)
+	defer c.track(url)(&err)
+
+	intoDirectory := isDirectory(destination)
+	if destination != "-" && !intoDirectory && c.IfMissing && exists(destination) {
+		logs.From(ctx).Infoln("Skip", url, "since", destination, "already exists")
+		return nil
+	}
+
+	options := c.options(url)
+	if url, err = c.Hooks.Rewrite(ctx, url); err != nil {
+		return err
+	}
+
+	entry, err := c.Cache().Get(ctx, url, options, c.Force)
+	if err != nil {
+		return err
+	}
+	source := entry.Path
+
+	if intoDirectory {
+		name, err := c.Cache().Filename(ctx, entry)
+		if err != nil {
+			return fmt.Errorf("%w. Give the path of the destination file instead of a directory", err)
+		}
+		destination = filepath.Join(destination, name)
+
+		if c.IfMissing && exists(destination) {
+			logs.From(ctx).Infoln("Skip", url, "since", destination, "already exists")
+			return nil
+		}
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	write, err := c.IfExists.ShouldWrite(logs.From(ctx), destination, info.ModTime())
+	if err != nil || !write {
+		return err
+	}
+
+	logs.From(ctx).Infoln("Copy", url, "to", destination)
+
+	// Extended attributes and post-copy hooks modify the destination. They
+	// must not modify the cached file through a link.
+	link := c.Link
+	if link.SharesFile() && (c.Xattrs || len(c.Hooks.PostCopy) > 0) {
+		logs.From(ctx).Infoln("Copy", url, "instead of linking it since", destination, "is modified once copied")
+		link = files.CopyLink
+	}
+
+	if err := link.Place(logs.From(ctx), source, destination); err != nil {
+		return err
+	}
+
+	if destination == "-" {
+		return nil
+	}
+
+	if c.Xattrs {
+		origin, err := originOf(url, entry)
+		if err != nil {
+			return err
+		}
+
+		if err := files.SetOrigin(destination, *origin); err != nil {
+			return err
+		}
+	}
+
+	return hooks.Run(ctx, c.Hooks.PostCopy, hooks.Data{URL: url, Path: source, Dest: destination})
+}
+
+// Extract retrieves an url from the cache or downloads it if it's absent.
+// Then it extracts the archive to a destination directory.
+func (c *Client) Extract(ctx context.Context, url string, destinationDirectory string) (err error) {
+	ctx = c.context(ctx)
+	defer c.track(url)(&err)
+
+	if c.IfMissing && exists(destinationDirectory) {
+		logs.From(ctx).Infoln("Skip", url, "since", destinationDirectory, "already exists")
+		return nil
+	}
+
+	extractOptions := c.extractOptions(ctx, url)
+	url, options, err := c.resolve(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	source, err := c.extract(ctx, url, options, extractOptions, destinationDirectory)
+	if err != nil {
+		return err
+	}
+
+	return hooks.Run(ctx, c.Hooks.PostExtract, hooks.Data{URL: url, Path: source, Dest: destinationDirectory})
+}
+
+// extract extracts an archive and gives where it's cached, if it is.
+func (c *Client) extract(ctx context.Context, url string, options files.Options, extractOptions files.ExtractOptions, destinationDirectory string) (source string, err error) {
+	ctx, span := tracing.Start(ctx, "extract", tracing.Attributes{"url": url, "destination": destinationDirectory, "stream": c.Stream})
+	defer func() { span.End(err) }()
+
+	if c.streamed(ctx, url, options) {
+		logs.From(ctx).Infoln("Stream", url, "to", destinationDirectory)
+
+		if c.Xattrs {
+			extractOptions.Origin = &files.Origin{URL: url, Sha256: options.Sha256}
+		}
+		return "", c.stream(ctx, url, options, extractOptions, func(reader io.Reader, extractOptions files.ExtractOptions) error {
+			return tar.ExtractFrom(url, reader, destinationDirectory, extractOptions)
+		})
+	}
+
+	entry, err := c.Cache().Get(ctx, url, options, c.Force)
+	if err != nil {
+		return "", err
+	}
+	source = entry.Path
+
+	logs.From(ctx).Infoln("Extract", url, "to", destinationDirectory)
+
+	if c.Xattrs {
+		if extractOptions.Origin, err = originOf(url, entry); err != nil {
+			return "", err
+		}
+	}
+
+	if urls.IsZipArchive(url) {
+		return source, zip.Extract(source, destinationDirectory, extractOptions)
+	}
+	if urls.IsTarArchive(url) {
+		return source, tar.Extract(url, source, destinationDirectory, extractOptions)
+	}
+
+	return "", &UnsupportedArchiveError{source}
+}
+
+// ExtractFiles retrieves an url from the cache or downloads it if it's absent.
+// Then it extracts some files of the archive to destination paths.
+func (c *Client) ExtractFiles(ctx context.Context, url string, filesToExtract []files.ExtractedFile) (err error) {
+	ctx = c.context(ctx)
+	defer c.track(url)(&err)
+
+	if c.IfMissing && allExist(filesToExtract) {
+		logs.From(ctx).Infoln("Skip", url, "since all the destinations already exist")
+		return nil
+	}
+
+	extractOptions := c.extractOptions(ctx, url)
+	url, options, err := c.resolve(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	source, err := c.extractFiles(ctx, url, options, extractOptions, filesToExtract)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range filesToExtract {
+		if file.Destination == "-" {
+			continue
+		}
+		if err := hooks.Run(ctx, c.Hooks.PostExtract, hooks.Data{URL: url, Path: source, Dest: file.Destination}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractFirst extracts the first of several patterns that matches a file of
+// an archive, like `tool` then `*/tool`, to a destination path.
+func (c *Client) ExtractFirst(ctx context.Context, url string, sources []string, destination string) error {
+	for i, source := range sources {
+		err := c.ExtractFiles(ctx, url, []files.ExtractedFile{{Source: source, Destination: destination}})
+		if err == nil || !errors.Is(err, errdefs.ErrNotFound) || i == len(sources)-1 {
+			return err
+		}
+	}
+	return errdefs.Errorf(errdefs.ErrNotFound, "Files not found")
+}
+
+// extractFiles extracts some files of an archive and gives where it's
+// cached, if it is.
+func (c *Client) extractFiles(ctx context.Context, url string, options files.Options, extractOptions files.ExtractOptions, filesToExtract []files.ExtractedFile) (source string, err error) {
+	ctx, span := tracing.Start(ctx, "extract", tracing.Attributes{"url": url, "files": len(filesToExtract), "stream": c.Stream})
+	defer func() { span.End(err) }()
+
+	for _, file := range filesToExtract {
+		logs.From(ctx).Infoln("Extract", file.Source, "from", url, "to", file.Destination)
+	}
+
+	if c.streamed(ctx, url, options) {
+		if c.Xattrs {
+			extractOptions.Origin = &files.Origin{URL: url, Sha256: options.Sha256}
+		}
+		return "", c.stream(ctx, url, options, extractOptions, func(reader io.Reader, extractOptions files.ExtractOptions) error {
+			return tar.ExtractFilesFrom(url, reader, filesToExtract, extractOptions)
+		})
+	}
+
+	entry, err := c.Cache().Get(ctx, url, options, c.Force)
+	if err != nil {
+		return "", err
+	}
+	source = entry.Path
+
+	if c.Xattrs {
+		if extractOptions.Origin, err = originOf(url, entry); err != nil {
+			return "", err
+		}
+	}
+
+	if urls.IsZipArchive(url) {
+		return source, zip.ExtractFiles(source, filesToExtract, extractOptions)
+	}
+	if urls.IsTarArchive(url) {
+		return source, tar.ExtractFiles(url, source, filesToExtract, extractOptions)
+	}
+
+	return "", &UnsupportedArchiveError{source}
+}
+
+// List retrieves an url from the cache or downloads it if it's absent.
+// Then it lists the entries of the archive.
+func (c *Client) List(ctx context.Context, url string) (entries []files.Entry, err error) {
+	ctx = c.context(ctx)
+	defer c.track(url)(&err)
+
+	url, options, err := c.resolve(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := c.Cache().Download(ctx, url, options, c.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case urls.IsZipArchive(url):
+		return zip.List(source)
+	case urls.IsTarArchive(url):
+		return tar.List(url, source)
+	}
+	return nil, &UnsupportedArchiveError{source}
+}
+
+// resolve rewrites an url with the PreDownload hooks, then resolves its
+// version and release asset, once for all the steps of an operation.
+// Events are sent with the url given by the caller.
+func (c *Client) resolve(ctx context.Context, url string) (string, files.Options, error) {
+	options := c.options(url)
+
+	url, err := c.Hooks.Rewrite(ctx, url)
+	if err != nil {
+		return "", files.Options{}, err
+	}
+
+	if url, err = files.Resolve(ctx, url, options); err != nil {
+		return "", files.Options{}, err
+	}
+
+	// The cache won't resolve the url again.
+	options.Resolved = true
+	return url, options, nil
+}
+
+// extractOptions gives the options of an extraction. Extractions can run
+// concurrently so each one has its own options.
+func (c *Client) extractOptions(ctx context.Context, url string) files.ExtractOptions {
+	options := c.ExtractOptions
+	options.IfExists = c.IfExists
+	options.Log = logs.From(ctx)
+	if c.Events != nil {
+		options.Progress = func(file string, filesDone, filesTotal int) {
+			c.event(ProgressEvent{URL: url, Phase: PhaseExtracting, File: file, FilesDone: filesDone, FilesTotal: filesTotal})
+		}
+	}
+	return options
+}
+
+// streamed tells if an archive should be extracted while it's downloaded.
+// Only tar archives can be streamed since zip archives need random access.
+// Archives with an expected sha256 aren't streamed either: they can only be
+// verified once they are fully read, and their files must not be extracted
+// before that.
+func (c *Client) streamed(ctx context.Context, url string, options files.Options) bool {
+	if !c.Stream {
+		return false
+	}
+
+	if !urls.IsTarArchive(url) {
+		logs.From(ctx).Infoln("Only tar archives can be streamed. Download", url, "first")
+		return false
+	}
+
+	if options.Sha256 != "" {
+		logs.From(ctx).Infoln("Archives are verified before they are extracted. Download", url, "first")
+		return false
+	}
+
+	return true
+}
+
+// stream extracts an archive while it's downloaded. The digest given by the
+// server or the registry can only be verified once the archive is fully read
+// so the files already extracted are removed if the stream fails.
+func (c *Client) stream(ctx context.Context, url string, options files.Options, extractOptions files.ExtractOptions, extract func(io.Reader, files.ExtractOptions) error) error {
+	var written []string
+	extractOptions.Written = func(path string) {
+		written = append(written, path)
+	}
+
+	err := c.Cache().Stream(ctx, url, options, c.Force, c.StreamToCache, func(reader io.Reader) error {
+		return extract(reader, extractOptions)
+	})
+	if err != nil {
+		for i := len(written) - 1; i >= 0; i-- {
+			os.Remove(written[i])
+		}
+	}
+	return err
+}
+
+// originOf describes where a cached file comes from. The file is only hashed
+// if its sha256 isn't known yet.
+func originOf(url string, entry cache.Entry) (*files.Origin, error) {
+	if entry.Sha256 != "" {
+		return &files.Origin{URL: url, Sha256: entry.Sha256}, nil
+	}
+
+	sha, err := cache.Sha256(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &files.Origin{URL: url, Sha256: sha}, nil
+}
+
+// isDirectory tells if a destination is a directory, existing or not.
+func isDirectory(destination string) bool {
+	if strings.HasSuffix(destination, "/") || strings.HasSuffix(destination, string(filepath.Separator)) {
+		return true
+	}
+	info, err := os.Stat(destination)
+	return err == nil && info.IsDir()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func allExist(extractedFiles []files.ExtractedFile) bool {
+	for _, file := range extractedFiles {
+		if file.Destination == "-" || !exists(file.Destination) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,447 @@
+package getme
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dgageot/getme/audit"
+	"github.com/dgageot/getme/cache"
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/hooks"
+	"github.com/dgageot/getme/logs"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeArchive(t *testing.T, path string, entries map[string]string) {
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	for name, content := range entries {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache")}
+	ctx := context.Background()
+
+	entry, err := client.Download(ctx, url)
+	assert.NoError(t, err)
+	assert.False(t, entry.Cached)
+	assert.Equal(t, filepath.Join(dir, "cache"), filepath.Dir(entry.Path))
+	sha, err := cache.Sha256(archive)
+	assert.NoError(t, err)
+	assert.Equal(t, sha, entry.Sha256)
+
+	entry, err = client.Download(ctx, url)
+	assert.NoError(t, err)
+	assert.True(t, entry.Cached)
+
+	entries, err := client.List(ctx, url)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "tool/README.md", entries[0].Name)
+
+	assert.NoError(t, client.Extract(ctx, url, filepath.Join(dir, "extracted")))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "extracted", "tool", "README.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "readme", string(content))
+
+	destination := filepath.Join(dir, "README.md")
+	assert.NoError(t, client.ExtractFiles(ctx, url, []files.ExtractedFile{{Source: "*/README.md", Destination: destination}}))
+	content, err = ioutil.ReadFile(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "readme", string(content))
+
+	client.IfExists = files.Fail
+	assert.Error(t, client.Copy(ctx, url, destination))
+}
+
+func TestLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	var global, buf bytes.Buffer
+	logs.SetLogger(log.New(&global, "", 0))
+	defer logs.SetLogger(log.New(os.Stderr, "", log.LstdFlags))
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Logger: log.New(&buf, "", 0)}
+	assert.NoError(t, client.Extract(context.Background(), url, filepath.Join(dir, "extracted")))
+
+	assert.Contains(t, buf.String(), "Extract "+url)
+	assert.Empty(t, global.String())
+}
+
+func TestExtractFirst(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool_1.0/tool": "binary", "tool_1.0/README.md": "readme"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache")}
+	ctx := context.Background()
+
+	destination := filepath.Join(dir, "tool")
+	assert.NoError(t, client.ExtractFirst(ctx, url, []string{"tool", "*/tool"}, destination))
+	content, err := ioutil.ReadFile(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", string(content))
+
+	err = client.ExtractFirst(ctx, url, []string{"other", "*/other"}, filepath.Join(dir, "other"))
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+}
+
+func TestResolveOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/org/tool/releases":
+			lookups++
+			w.Write([]byte(`[{"tag_name":"v1.2.0"},{"tag_name":"v2.0.0"}]`))
+		case "/org/tool/releases/download/v1.2.0/tool.tar.gz":
+			http.ServeFile(w, r, archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Options: files.Options{Version: "<2.0", GitHubAPIURL: server.URL + "/api/v3"}}
+
+	entries, err := client.List(context.Background(), server.URL+"/org/tool/releases/latest/download/tool.tar.gz")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, 1, lookups)
+}
+
+func TestCorruptedCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache")}
+	entry, err := client.Download(context.Background(), url)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(entry.Path, []byte("corrupted"), 0644))
+
+	// Without a sha256, the cache is trusted.
+	entry, err = client.Download(context.Background(), url)
+	assert.NoError(t, err)
+	assert.True(t, entry.Cached)
+
+	// With a sha256, the corrupted file is downloaded again.
+	sha, err := cache.Sha256(archive)
+	assert.NoError(t, err)
+	client.Options.Sha256 = sha
+
+	entry, err = client.Download(context.Background(), url)
+	assert.NoError(t, err)
+	assert.False(t, entry.Cached)
+	assert.Equal(t, sha, entry.Sha256)
+
+	entry, err = client.Download(context.Background(), url)
+	assert.NoError(t, err)
+	assert.True(t, entry.Cached)
+}
+
+func TestStreamWithSha256(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Stream: true}
+	client.Options.Sha256 = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+	destination := filepath.Join(dir, "extracted")
+	err = client.Extract(context.Background(), url, destination)
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+	_, err = os.Stat(destination)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStreamWithServerChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Checksum-Sha256", "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+		http.ServeFile(w, r, archive)
+	}))
+	defer server.Close()
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Stream: true}
+
+	destination := filepath.Join(dir, "extracted")
+	err = client.Extract(context.Background(), server.URL+"/archive.tar.gz", destination)
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+	_, err = os.Stat(filepath.Join(destination, "tool", "README.md"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopyIntoDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/download" {
+			w.Header().Set("Content-Disposition", `attachment; filename="../tool-1.0.tgz"`)
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	workspace := filepath.Join(dir, "workspace")
+	client := &Client{CacheDir: filepath.Join(dir, "cache")}
+
+	assert.NoError(t, client.Copy(context.Background(), server.URL+"/files/tool.zip?token=secret", workspace+string(filepath.Separator)))
+	assert.NoError(t, client.Copy(context.Background(), server.URL+"/download?id=1", workspace))
+
+	// The name given by the server is cached along the file.
+	client = &Client{CacheDir: filepath.Join(dir, "cache"), Link: files.HardLink}
+	assert.NoError(t, client.Copy(context.Background(), server.URL+"/download?id=1", filepath.Join(dir, "other")+string(filepath.Separator)))
+
+	for _, path := range []string{"workspace/tool.zip", "workspace/tool-1.0.tgz", "other/tool-1.0.tgz"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, path))
+		assert.NoError(t, err)
+		assert.Equal(t, "content", string(content))
+	}
+
+	err = client.Copy(context.Background(), server.URL+"/", workspace)
+	assert.Error(t, err)
+}
+
+func TestCopyLinkWithHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "tool")
+	assert.NoError(t, ioutil.WriteFile(source, []byte("binary"), 0644))
+	url := "file://" + filepath.ToSlash(source)
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Link: files.HardLink}
+	entry, err := client.Download(context.Background(), url)
+	assert.NoError(t, err)
+
+	linked := filepath.Join(dir, "linked")
+	assert.NoError(t, client.Copy(context.Background(), url, linked))
+	assert.True(t, sameFile(t, entry.Path, linked))
+
+	// The hook must not change the mode of the cached file.
+	client.Hooks.PostCopy = []string{"chmod 755 {{.Dest}}"}
+	copied := filepath.Join(dir, "copied")
+	assert.NoError(t, client.Copy(context.Background(), url, copied))
+	assert.False(t, sameFile(t, entry.Path, copied))
+
+	info, err := os.Stat(entry.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func sameFile(t *testing.T, path, other string) bool {
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	otherInfo, err := os.Stat(other)
+	assert.NoError(t, err)
+	return os.SameFile(info, otherInfo)
+}
+
+func TestUnsupportedArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "file.txt")
+	assert.NoError(t, ioutil.WriteFile(source, []byte("content"), 0644))
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache")}
+	ctx := context.Background()
+	err = client.Extract(ctx, "file://"+filepath.ToSlash(source), filepath.Join(dir, "extracted"))
+
+	_, unsupported := err.(*UnsupportedArchiveError)
+	assert.True(t, unsupported)
+	assert.True(t, errors.Is(err, errdefs.ErrUnsupportedArchive))
+
+	err = client.ExtractFiles(context.Background(), "file://"+filepath.ToSlash(source)+".zip", nil)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+}
+
+func TestProgressEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme", "tool/LICENSE": "license"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	sha, err := cache.Sha256(archive)
+	assert.NoError(t, err)
+
+	events := make(chan ProgressEvent, 64)
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Events: events, Options: files.Options{Sha256: sha}}
+
+	assert.NoError(t, client.Extract(context.Background(), url, filepath.Join(dir, "extracted")))
+	close(events)
+
+	var phases []Phase
+	var downloaded, extracted ProgressEvent
+	for event := range events {
+		assert.Equal(t, url, event.URL)
+		if len(phases) == 0 || phases[len(phases)-1] != event.Phase {
+			phases = append(phases, event.Phase)
+		}
+		switch event.Phase {
+		case PhaseProgress:
+			downloaded = event
+		case PhaseExtracting:
+			extracted = event
+		}
+	}
+
+	assert.Equal(t, []Phase{PhaseStarted, PhaseProgress, PhaseVerifying, PhaseExtracting, PhaseDone}, phases)
+	assert.Equal(t, downloaded.BytesTotal, downloaded.BytesDone)
+	assert.Equal(t, 2, extracted.FilesDone)
+	assert.Equal(t, -1, extracted.FilesTotal)
+
+	events = make(chan ProgressEvent, 64)
+	client.Events = events
+	assert.Error(t, client.Copy(context.Background(), url+".missing", filepath.Join(dir, "copy")))
+	close(events)
+
+	var last ProgressEvent
+	for event := range events {
+		last = event
+	}
+	assert.Equal(t, PhaseFailed, last.Phase)
+	assert.True(t, errors.Is(last.Err, errdefs.ErrNotFound))
+}
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/bin/tool": "binary"})
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Hooks: hooks.Hooks{
+		PreDownload: []string{"echo file://" + filepath.ToSlash(archive)},
+		PostExtract: []string{"chmod +x {{.Dest}}/tool/bin/*"},
+	}}
+
+	destination := filepath.Join(dir, "extracted")
+	assert.NoError(t, client.Extract(context.Background(), "https://example.com/archive.tar.gz", destination))
+
+	info, err := os.Stat(filepath.Join(destination, "tool", "bin", "tool"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+func TestAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-client-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive.tar.gz")
+	writeArchive(t, archive, map[string]string{"tool/README.md": "readme"})
+	url := "file://" + filepath.ToSlash(archive)
+
+	sha, err := cache.Sha256(archive)
+	assert.NoError(t, err)
+	info, err := os.Stat(archive)
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "audit.jsonl")
+	log, err := audit.Open(path)
+	assert.NoError(t, err)
+
+	client := &Client{CacheDir: filepath.Join(dir, "cache"), Audit: log}
+	_, err = client.Download(context.Background(), url)
+	assert.NoError(t, err)
+
+	client.Stream = true
+	assert.NoError(t, client.Extract(context.Background(), url, filepath.Join(dir, "extracted")))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+
+	for i, line := range lines {
+		var record audit.Record
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, url, record.URL)
+		assert.Equal(t, url, record.ResolvedURL)
+		assert.Equal(t, sha, record.Sha256)
+		assert.Equal(t, info.Size(), record.Size)
+		assert.Equal(t, i == 1, record.Cached)
+	}
+}
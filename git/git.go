@@ -0,0 +1,117 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dgageot/getme/tempfile"
+)
+
+// IsRepositoryURL tells if an url points to a git repository, such as
+// `git+https://github.com/user/repo.git@v1.0.0`.
+func IsRepositoryURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "git+")
+}
+
+// ParseURL splits a `git+https://host/repo.git@ref` url into the url of the
+// repository and a tag, branch or commit. The ref defaults to HEAD.
+func ParseURL(rawURL string) (repository, ref string, ok bool) {
+	if !IsRepositoryURL(rawURL) {
+		return "", "", false
+	}
+	repository = strings.TrimPrefix(rawURL, "git+")
+
+	schemeEnd := strings.Index(repository, "://")
+	if schemeEnd == -1 {
+		return "", "", false
+	}
+
+	// The authority can carry a user, so only look for a ref in the path.
+	pathStart := strings.Index(repository[schemeEnd+3:], "/")
+	if pathStart == -1 {
+		return "", "", false
+	}
+	pathStart += schemeEnd + 3
+
+	ref = "HEAD"
+	if at := strings.LastIndex(repository, "@"); at > pathStart {
+		repository, ref = repository[:at], repository[at+1:]
+	}
+	if ref == "" || repository[pathStart:] == "/" {
+		return "", "", false
+	}
+
+	return repository, ref, true
+}
+
+// Open shallow clones a git repository at a given ref and gives its content
+// as a tar archive. It relies on the git command so that credential helpers
+// and ssh keys are honored.
+func Open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	repository, ref, ok := ParseURL(rawURL)
+	if !ok {
+		return nil, fmt.Errorf("Invalid git url. Should be git+https://host/repo.git@ref: %s", rawURL)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("Downloading git urls requires the git command")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-git")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp, err := ioutil.TempFile("", "getme-git")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+
+	commands := [][]string{
+		{"init", "-q", dir},
+		{"-C", dir, "fetch", "-q", "--depth", "1", repository, ref},
+		{"-C", dir, "archive", "--format=tar", "-o", tmp.Name(), "FETCH_HEAD"},
+	}
+	for _, args := range commands {
+		if err := run(ctx, args); err != nil {
+			os.Remove(tmp.Name())
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("Unable to fetch %s at %s: %s", repository, ref, err)
+		}
+	}
+
+	file, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tempfile.New(file), nil
+}
+
+func run(ctx context.Context, args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	// Never prompt for a password.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%s", message)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	repository, ref, ok := ParseURL("git+https://github.com/dgageot/getme.git@v1.0.0")
+	assert.True(t, ok)
+	assert.Equal(t, "https://github.com/dgageot/getme.git", repository)
+	assert.Equal(t, "v1.0.0", ref)
+
+	repository, ref, ok = ParseURL("git+ssh://git@github.com/dgageot/getme.git")
+	assert.True(t, ok)
+	assert.Equal(t, "ssh://git@github.com/dgageot/getme.git", repository)
+	assert.Equal(t, "HEAD", ref)
+
+	_, _, ok = ParseURL("git+https://github.com/")
+	assert.False(t, ok)
+	_, _, ok = ParseURL("git+https://github.com/dgageot/getme.git@")
+	assert.False(t, ok)
+	_, _, ok = ParseURL("https://github.com/dgageot/getme.git")
+	assert.False(t, ok)
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-git-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("v1"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "README"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "v1"},
+		{"tag", "v1"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		assert.NoError(t, cmd.Run())
+	}
+
+	reader, err := Open(context.Background(), "git+file://"+filepath.ToSlash(dir)+"@v1")
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	tarReader := tar.NewReader(reader)
+	header, err := tarReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(tar.TypeXGlobalHeader), header.Typeflag)
+
+	header, err = tarReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "README", header.Name)
+
+	content, err := ioutil.ReadAll(tarReader)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	_, err = tarReader.Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,148 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+var releaseURL = regexp.MustCompile(`^(https?://([^/]+))/([^/]+)/([^/]+)/releases/download/([^/]+)/(.+)$`)
+
+// Hosts known to run Gitea or Forgejo.
+var knownHosts = map[string]bool{
+	"codeberg.org": true,
+	"gitea.com":    true,
+}
+
+// Options configures access to a Gitea or Forgejo server.
+type Options struct {
+	// URL of a self-hosted server.
+	URL string
+
+	// Token is an access token. Defaults to $GITEA_TOKEN.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+type asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type release struct {
+	Assets []asset `json:"assets"`
+}
+
+// IsReleaseURL tells if an url points to a release asset on a Gitea or
+// Forgejo server, such as `https://codeberg.org/org/repo/releases/download/v1.0.0/tool.tgz`.
+// Servers are recognized by name, if they start with `gitea.` or `forgejo.`,
+// or if their url is given.
+func IsReleaseURL(rawURL string, options Options) bool {
+	parts := releaseURL.FindStringSubmatch(rawURL)
+	if parts == nil {
+		return false
+	}
+
+	host := parts[2]
+	if knownHosts[host] || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo.") {
+		return true
+	}
+
+	configured, err := url.Parse(options.URL)
+	return err == nil && options.URL != "" && configured.Host == host
+}
+
+// Open downloads a release asset. Assets of private repositories are looked
+// up with the api and downloaded with the token.
+func Open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, error) {
+	token := options.Token
+	if token == "" {
+		token = os.Getenv("GITEA_TOKEN")
+	}
+
+	resp, err := get(ctx, rawURL, token, options.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp.Body, nil
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound || token == "" {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s. Private repositories require --giteaToken or $GITEA_TOKEN", resp.Status)
+		}
+		return nil, errors.New(resp.Status)
+	}
+
+	assetURL, err := findAsset(ctx, rawURL, token, options.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, err = get(ctx, assetURL, token, options.Headers); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// findAsset finds the download url of an asset with the api.
+func findAsset(ctx context.Context, rawURL, token string, headers []string) (string, error) {
+	parts := releaseURL.FindStringSubmatch(rawURL)
+	server, owner, repo, tag, name := parts[1], parts[3], parts[4], parts[5], parts[6]
+
+	resp, err := get(ctx, server+"/api/v1/repos/"+owner+"/"+repo+"/releases/tags/"+tag, token, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", errors.New(resp.Status)
+	}
+
+	var rel release
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return "", err
+	}
+
+	for _, asset := range rel.Assets {
+		if asset.Name == name {
+			return asset.BrowserDownloadURL, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unable to find this release: %s", rawURL)
+}
+
+func get(ctx context.Context, rawURL, token string, headers []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := http_headers.Add(headers, req); err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	return http.DefaultClient.Do(req)
+}
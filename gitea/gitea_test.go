@@ -0,0 +1,52 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReleaseURL(t *testing.T) {
+	assert.True(t, IsReleaseURL("https://codeberg.org/forgejo/forgejo/releases/download/v1.21.0/forgejo-1.21.0-linux-amd64", Options{}))
+	assert.True(t, IsReleaseURL("https://gitea.example.com/org/tool/releases/download/v1.0.0/tool.tgz", Options{}))
+	assert.True(t, IsReleaseURL("https://git.example.com/org/tool/releases/download/v1.0.0/tool.tgz", Options{URL: "https://git.example.com"}))
+
+	assert.False(t, IsReleaseURL("https://git.example.com/org/tool/releases/download/v1.0.0/tool.tgz", Options{}))
+	assert.False(t, IsReleaseURL("https://github.com/org/tool/releases/download/v1.0.0/tool.tgz", Options{}))
+}
+
+func TestOpenPrivate(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/repos/org/tool/releases/tags/v1.0.0":
+			fmt.Fprintf(w, `{"assets":[{"name":"tool.tgz","browser_download_url":"%s/attachments/1234"}]}`, server.URL)
+		case "/attachments/1234":
+			fmt.Fprint(w, "tool")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	reader, err := Open(context.Background(), server.URL+"/org/tool/releases/download/v1.0.0/tool.tgz", Options{Token: "secret"})
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "tool", string(content))
+
+	_, err = Open(context.Background(), server.URL+"/org/tool/releases/download/v1.0.0/unknown.tgz", Options{Token: "secret"})
+	assert.Error(t, err)
+}
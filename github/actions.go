@@ -0,0 +1,88 @@
+package github
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+type artifacts struct {
+	Artifacts []struct {
+		Id                 int64  `json:"id"`
+		Name               string `json:"name"`
+		Expired            bool   `json:"expired"`
+		ArchiveDownloadURL string `json:"archive_download_url"`
+	} `json:"artifacts"`
+}
+
+type workflowRuns struct {
+	WorkflowRuns []struct {
+		Id int64 `json:"id"`
+	} `json:"workflow_runs"`
+}
+
+// ArtifactQuery selects an artifact of a Github Actions workflow run. Without
+// a run id, the latest successful run, optionally of a workflow and a branch,
+// is used.
+type ArtifactQuery struct {
+	Org      string
+	Project  string
+	Name     string
+	RunID    int64
+	Branch   string
+	Workflow string
+}
+
+// ArtifactUrl finds the url of the zip archive wrapping an artifact.
+func ArtifactUrl(ctx context.Context, api string, query ArtifactQuery, headers []string) (string, error) {
+	repo := api + "/repos/" + query.Org + "/" + query.Project
+
+	runID := query.RunID
+	if runID == 0 {
+		var err error
+		if runID, err = latestSuccessfulRun(ctx, repo, query, headers); err != nil {
+			return "", err
+		}
+	}
+
+	found := artifacts{}
+	if err := getJSON(ctx, repo+"/actions/runs/"+strconv.FormatInt(runID, 10)+"/artifacts?name="+url.QueryEscape(query.Name), headers, &found); err != nil {
+		return "", err
+	}
+
+	for _, artifact := range found.Artifacts {
+		if artifact.Name != query.Name {
+			continue
+		}
+		if artifact.Expired {
+			return "", errdefs.Errorf(errdefs.ErrNotFound, "Artifact %s of run %d has expired", query.Name, runID)
+		}
+		return artifact.ArchiveDownloadURL, nil
+	}
+
+	return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find artifact %s in run %d", query.Name, runID)
+}
+
+func latestSuccessfulRun(ctx context.Context, repo string, query ArtifactQuery, headers []string) (int64, error) {
+	runsURL := repo + "/actions/runs"
+	if query.Workflow != "" {
+		runsURL = repo + "/actions/workflows/" + url.PathEscape(query.Workflow) + "/runs"
+	}
+
+	params := url.Values{"status": {"success"}, "per_page": {"1"}}
+	if query.Branch != "" {
+		params.Set("branch", query.Branch)
+	}
+
+	runs := workflowRuns{}
+	if err := getJSON(ctx, runsURL+"?"+params.Encode(), headers, &runs); err != nil {
+		return 0, err
+	}
+
+	if len(runs.WorkflowRuns) == 0 {
+		return 0, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find a successful run of %s/%s", query.Org, query.Project)
+	}
+	return runs.WorkflowRuns[0].Id, nil
+}
@@ -0,0 +1,44 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactUrl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/tool/actions/workflows/build.yml/runs":
+			assert.Equal(t, "main", r.URL.Query().Get("branch"))
+			assert.Equal(t, "success", r.URL.Query().Get("status"))
+			fmt.Fprint(w, `{"workflow_runs":[{"id":42}]}`)
+		case "/repos/org/tool/actions/runs/42/artifacts", "/repos/org/tool/actions/runs/7/artifacts":
+			fmt.Fprint(w, `{"artifacts":[
+				{"id":1,"name":"binaries","archive_download_url":"https://api.github.com/repos/org/tool/actions/artifacts/1/zip"},
+				{"id":2,"name":"old","expired":true}
+			]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	url, err := ArtifactUrl(context.Background(), server.URL, ArtifactQuery{Org: "org", Project: "tool", Name: "binaries", Branch: "main", Workflow: "build.yml"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.github.com/repos/org/tool/actions/artifacts/1/zip", url)
+
+	url, err = ArtifactUrl(context.Background(), server.URL, ArtifactQuery{Org: "org", Project: "tool", Name: "binaries", RunID: 7}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.github.com/repos/org/tool/actions/artifacts/1/zip", url)
+
+	_, err = ArtifactUrl(context.Background(), server.URL, ArtifactQuery{Org: "org", Project: "tool", Name: "old", RunID: 7}, nil)
+	assert.EqualError(t, err, "Artifact old of run 7 has expired")
+
+	_, err = ArtifactUrl(context.Background(), server.URL, ArtifactQuery{Org: "org", Project: "tool", Name: "unknown", RunID: 7}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// Names used by releases for operating systems and architectures.
+var (
+	osAliases = map[string][]string{
+		"darwin":  {"darwin", "macos", "macosx", "mac", "osx", "apple"},
+		"linux":   {"linux"},
+		"windows": {"windows", "win", "win64", "win32"},
+		"freebsd": {"freebsd"},
+	}
+
+	archAliases = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x86-64", "x64", "64bit", "64-bit"},
+		"arm64": {"arm64", "aarch64", "armv8"},
+		"386":   {"386", "i386", "i686", "x86", "32bit", "32-bit"},
+		"arm":   {"arm", "armv6", "armv7", "armhf", "armv7l"},
+	}
+
+	// Assets that are never the binary of a release.
+	ignoredAsset = regexp.MustCompile(`(?i)(\.(sha256|sha256sum|sha512|sha512sum|md5|asc|sig|pem|cert|sbom|spdx|json|txt|deb|rpm|apk|msi|pkg|dmg)$|checksums|sha256sums)`)
+)
+
+// AutoAsset picks the asset of a release built for a platform, given by its
+// GOOS and GOARCH, using the naming conventions of most projects: `linux` or
+// `Linux`, `amd64` or `x86_64`, `darwin` or `macos`... Archives in the format
+// usual for the platform are preferred.
+func AutoAsset(ctx context.Context, rel Release, goos, goarch string, headers []string) (Release, error) {
+	assets, err := releaseAssets(ctx, rel, headers)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var names []string
+	for _, relAsset := range assets {
+		names = append(names, relAsset.Name)
+	}
+
+	best, err := pickAsset(names, goos, goarch)
+	if err != nil {
+		return Release{}, fmt.Errorf("%s/%s %s: %w", rel.Org, rel.Project, rel.Tag, err)
+	}
+
+	rel.Asset = best
+	return rel, nil
+}
+
+func pickAsset(names []string, goos, goarch string) (string, error) {
+	bestScore := 0
+	var best []string
+	for _, name := range names {
+		score := scoreAsset(name, goos, goarch)
+		switch {
+		case score <= 0 || score < bestScore:
+		case score > bestScore:
+			bestScore, best = score, []string{name}
+		default:
+			best = append(best, name)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return "", errdefs.Errorf(errdefs.ErrNotFound, "No asset is built for %s/%s. Assets are [%s]. Use --asset", goos, goarch, strings.Join(names, ", "))
+	case 1:
+		return best[0], nil
+	}
+	return "", fmt.Errorf("Several assets are built for %s/%s: [%s]. Use --asset", goos, goarch, strings.Join(best, ", "))
+}
+
+// scoreAsset tells how likely an asset is built for a platform. Assets that
+// are not are given 0.
+func scoreAsset(name, goos, goarch string) int {
+	lower := strings.ToLower(name)
+	if ignoredAsset.MatchString(lower) || !hasAny(lower, osAliases[goos]) {
+		return 0
+	}
+
+	for otherOS, aliases := range osAliases {
+		if otherOS != goos && hasAny(lower, aliases) {
+			return 0
+		}
+	}
+
+	score := 10
+	switch {
+	case hasAny(lower, archAliases[goarch]):
+		score += 10
+	case goos == "darwin" && hasAny(lower, []string{"universal", "all"}):
+		score += 5
+	default:
+		for _, aliases := range archAliases {
+			if hasAny(lower, aliases) {
+				return 0
+			}
+		}
+	}
+
+	switch {
+	case goos == "windows" && (strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".exe")):
+		score += 2
+	case goos != "windows" && (strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")):
+		score += 2
+	case strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.xz") || strings.HasSuffix(lower, ".tar.bz2"):
+		score++
+	}
+
+	// Static binaries run on every distribution.
+	if goos == "linux" && strings.Contains(lower, "musl") {
+		score++
+	}
+
+	return score
+}
+
+// hasAny tells if a name contains one of the aliases as a whole word.
+func hasAny(name string, aliases []string) bool {
+	for _, alias := range aliases {
+		for i := strings.Index(name, alias); i != -1; {
+			end := i + len(alias)
+			if (i == 0 || !isAlphanumeric(name[i-1])) && (end == len(name) || !isAlphanumeric(name[end])) {
+				return true
+			}
+
+			next := strings.Index(name[i+1:], alias)
+			if next == -1 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	return false
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
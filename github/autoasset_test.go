@@ -0,0 +1,47 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickAsset(t *testing.T) {
+	jq := []string{"jq-1.7.1.tar.gz", "jq-linux-amd64", "jq-linux-arm64", "jq-linux-i386", "jq-macos-amd64", "jq-macos-arm64", "jq-windows-amd64.exe", "sha256sum.txt"}
+	gh := []string{"gh_2.40.0_checksums.txt", "gh_2.40.0_linux_amd64.deb", "gh_2.40.0_linux_amd64.tar.gz", "gh_2.40.0_linux_arm64.tar.gz", "gh_2.40.0_macOS_universal.pkg", "gh_2.40.0_macOS_amd64.zip", "gh_2.40.0_windows_amd64.zip", "gh_2.40.0_windows_amd64.msi"}
+	ripgrep := []string{"ripgrep-14.0.3-x86_64-unknown-linux-musl.tar.gz", "ripgrep-14.0.3-x86_64-unknown-linux-musl.tar.gz.sha256", "ripgrep-14.0.3-aarch64-unknown-linux-gnu.tar.gz", "ripgrep-14.0.3-x86_64-apple-darwin.tar.gz", "ripgrep-14.0.3-x86_64-pc-windows-msvc.zip"}
+	compose := []string{"docker-compose-darwin-aarch64", "docker-compose-darwin-x86_64", "docker-compose-linux-x86_64", "docker-compose-linux-armv7", "docker-compose-windows-x86_64.exe"}
+
+	tests := []struct {
+		names    []string
+		goos     string
+		goarch   string
+		expected string
+	}{
+		{jq, "linux", "amd64", "jq-linux-amd64"},
+		{jq, "darwin", "arm64", "jq-macos-arm64"},
+		{jq, "windows", "amd64", "jq-windows-amd64.exe"},
+		{jq, "linux", "386", "jq-linux-i386"},
+		{gh, "linux", "amd64", "gh_2.40.0_linux_amd64.tar.gz"},
+		{gh, "windows", "amd64", "gh_2.40.0_windows_amd64.zip"},
+		{gh, "darwin", "amd64", "gh_2.40.0_macOS_amd64.zip"},
+		{ripgrep, "linux", "amd64", "ripgrep-14.0.3-x86_64-unknown-linux-musl.tar.gz"},
+		{ripgrep, "linux", "arm64", "ripgrep-14.0.3-aarch64-unknown-linux-gnu.tar.gz"},
+		{ripgrep, "darwin", "amd64", "ripgrep-14.0.3-x86_64-apple-darwin.tar.gz"},
+		{ripgrep, "windows", "amd64", "ripgrep-14.0.3-x86_64-pc-windows-msvc.zip"},
+		{compose, "darwin", "arm64", "docker-compose-darwin-aarch64"},
+		{compose, "linux", "arm", "docker-compose-linux-armv7"},
+	}
+
+	for _, test := range tests {
+		name, err := pickAsset(test.names, test.goos, test.goarch)
+		assert.NoError(t, err, "%s/%s", test.goos, test.goarch)
+		assert.Equal(t, test.expected, name, "%s/%s", test.goos, test.goarch)
+	}
+
+	_, err := pickAsset(jq, "freebsd", "amd64")
+	assert.Error(t, err)
+
+	_, err = pickAsset([]string{"tool-linux-amd64.tar.gz", "tool-linux-amd64-debug.tar.gz"}, "linux", "amd64")
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package github
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// ParseChecksum reads the sha256 of an asset from a checksum file, whose lines
+// are `<sha256>  <name>`, as written by sha256sum.
+func ParseChecksum(content, asset string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && isSha256(fields[0]) && strings.TrimPrefix(fields[1], "*") == asset {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", errdefs.Errorf(errdefs.ErrNotFound, "No sha256 of %s in the checksum file", asset)
+}
+
+// VerifySignature verifies the ed25519 signature of some content. Both the
+// signature and the public key are base64 encoded.
+func VerifySignature(content []byte, signature, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("Invalid public key")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return errors.New("Invalid signature")
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(key), content, decoded) {
+		return errors.New("The signature doesn't match")
+	}
+	return nil
+}
+
+func isSha256(value string) bool {
+	if len(value) != 64 {
+		return false
+	}
+	for _, c := range strings.ToLower(value) {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package github
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksum(t *testing.T) {
+	sha := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	other := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+	checksum, err := ParseChecksum(other+"  tool-darwin-amd64\n"+sha+" *tool-linux-amd64\n", "tool-linux-amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, sha, checksum)
+
+	_, err = ParseChecksum(sha+"\n", "tool-linux-amd64")
+	assert.Error(t, err)
+	_, err = ParseChecksum(other+"  tool-darwin-amd64\n", "tool-linux-amd64")
+	assert.Error(t, err)
+	_, err = ParseChecksum(other+"  tool-linux-amd64.sig\n", "tool-linux-amd64")
+	assert.Error(t, err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	key := base64.StdEncoding.EncodeToString(publicKey)
+
+	content := []byte("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  getme_linux_amd64.tar.gz\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, content))
+
+	assert.NoError(t, VerifySignature(content, signature+"\n", key))
+	assert.Error(t, VerifySignature([]byte("tampered"), signature, key))
+	assert.Error(t, VerifySignature(content, "not base64", key))
+	assert.Error(t, VerifySignature(content, signature, ""))
+}
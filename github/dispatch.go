@@ -0,0 +1,212 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/logs"
+)
+
+// Dispatch describes a run of a Github Actions workflow, triggered with a
+// workflow_dispatch event.
+type Dispatch struct {
+	Org      string
+	Project  string
+	Workflow string
+	Ref      string
+	Inputs   map[string]string
+
+	// PollInterval is how often the run is checked. Defaults to 10s.
+	PollInterval time.Duration
+	// Timeout stops waiting for a run that takes too long. No timeout by
+	// default.
+	Timeout time.Duration
+}
+
+// ParseInputs reads workflow inputs given as `key=value`.
+func ParseInputs(inputs []string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, input := range inputs {
+		parts := strings.SplitN(input, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid input [%s]. Should be [key=value]", input)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+type workflowRun struct {
+	Id         int64  `json:"id"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+}
+
+type dispatchResponse struct {
+	WorkflowRunID int64 `json:"workflow_run_id"`
+}
+
+// Run dispatches a workflow, then waits for the run to complete. A run that
+// doesn't succeed is an error. It gives the id of the run.
+func (d Dispatch) Run(ctx context.Context, api string, headers []string) (int64, error) {
+	repo := api + "/repos/" + d.Org + "/" + d.Project
+	workflow := repo + "/actions/workflows/" + url.PathEscape(d.Workflow)
+
+	// Older Github Enterprise servers don't tell which run was started.
+	// It's then the run that didn't exist before the dispatch.
+	before, err := dispatchedRuns(ctx, workflow, d.Ref, headers)
+	if err != nil {
+		return 0, err
+	}
+	existing := map[int64]bool{}
+	for _, id := range before {
+		existing[id] = true
+	}
+
+	inputs := d.Inputs
+	if inputs == nil {
+		inputs = map[string]string{}
+	}
+	dispatched := dispatchResponse{}
+	if err := postJSON(ctx, workflow+"/dispatches", headers, map[string]interface{}{
+		"ref":                d.Ref,
+		"inputs":             inputs,
+		"return_run_details": true,
+	}, &dispatched); err != nil {
+		return 0, fmt.Errorf("Unable to dispatch %s of %s/%s: %w", d.Workflow, d.Org, d.Project, err)
+	}
+
+	runID := dispatched.WorkflowRunID
+	if runID == 0 {
+		if err := d.poll(ctx, "for the run to start", func() (bool, error) {
+			runs, err := dispatchedRuns(ctx, workflow, d.Ref, headers)
+			if err != nil {
+				return false, err
+			}
+			// Runs are listed newest first.
+			for _, id := range runs {
+				if !existing[id] {
+					runID = id
+				}
+			}
+			return runID != 0, nil
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	logs.From(ctx).Infof("Waiting for run %d of %s/%s", runID, d.Org, d.Project)
+
+	var run workflowRun
+	if err := d.poll(ctx, "for run "+strconv.FormatInt(runID, 10)+" to complete", func() (bool, error) {
+		if err := getJSON(ctx, repo+"/actions/runs/"+strconv.FormatInt(runID, 10), headers, &run); err != nil {
+			return false, err
+		}
+		return run.Status == "completed", nil
+	}); err != nil {
+		return 0, err
+	}
+
+	if run.Conclusion != "success" {
+		return 0, fmt.Errorf("Run %d of %s/%s concluded with %s: %s", runID, d.Org, d.Project, run.Conclusion, run.HTMLURL)
+	}
+	return runID, nil
+}
+
+// dispatchedRuns lists the ids of the recent runs of a workflow triggered by
+// a workflow_dispatch event on a ref.
+func dispatchedRuns(ctx context.Context, workflow, ref string, headers []string) ([]int64, error) {
+	params := url.Values{"event": {"workflow_dispatch"}, "per_page": {"20"}}
+	if ref != "" {
+		params.Set("branch", ref)
+	}
+
+	found := struct {
+		WorkflowRuns []workflowRun `json:"workflow_runs"`
+	}{}
+	if err := getJSON(ctx, workflow+"/runs?"+params.Encode(), headers, &found); err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for _, run := range found.WorkflowRuns {
+		ids = append(ids, run.Id)
+	}
+	return ids, nil
+}
+
+// poll calls check until it's done, the timeout expires or the context is
+// done.
+func (d Dispatch) poll(ctx context.Context, what string, check func() (bool, error)) error {
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	var deadline <-chan time.Time
+	if d.Timeout > 0 {
+		timer := time.NewTimer(d.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-deadline:
+			return fmt.Errorf("Timed out after %s waiting %s", d.Timeout, what)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// postJSON sends a json body to the api. The response, if any, is decoded.
+func postJSON(ctx context.Context, url string, headers []string, body interface{}, v interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := http_headers.Add(headers, req); err != nil {
+		return err
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil || len(content) == 0 {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatch(t *testing.T) {
+	var dispatched, polls int32
+	var returnRunDetails bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/tool/actions/workflows/build.yml/runs":
+			assert.Equal(t, "workflow_dispatch", r.URL.Query().Get("event"))
+			assert.Equal(t, "main", r.URL.Query().Get("branch"))
+			if atomic.LoadInt32(&dispatched) == 0 {
+				fmt.Fprint(w, `{"workflow_runs":[{"id":1}]}`)
+			} else {
+				fmt.Fprint(w, `{"workflow_runs":[{"id":2},{"id":1}]}`)
+			}
+		case "/repos/org/tool/actions/workflows/build.yml/dispatches":
+			var body struct {
+				Ref    string            `json:"ref"`
+				Inputs map[string]string `json:"inputs"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "main", body.Ref)
+			assert.Equal(t, map[string]string{"commit": "abc123"}, body.Inputs)
+			atomic.StoreInt32(&dispatched, 1)
+			if returnRunDetails {
+				fmt.Fprint(w, `{"workflow_run_id":3}`)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case "/repos/org/tool/actions/runs/2":
+			if atomic.AddInt32(&polls, 1) == 1 {
+				fmt.Fprint(w, `{"id":2,"status":"in_progress"}`)
+			} else {
+				fmt.Fprint(w, `{"id":2,"status":"completed","conclusion":"success"}`)
+			}
+		case "/repos/org/tool/actions/runs/3":
+			fmt.Fprint(w, `{"id":3,"status":"completed","conclusion":"failure","html_url":"https://github.com/org/tool/actions/runs/3"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dispatch := Dispatch{Org: "org", Project: "tool", Workflow: "build.yml", Ref: "main", Inputs: map[string]string{"commit": "abc123"}, PollInterval: time.Millisecond}
+
+	runID, err := dispatch.Run(context.Background(), server.URL, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), runID)
+
+	returnRunDetails = true
+	_, err = dispatch.Run(context.Background(), server.URL, nil)
+	assert.EqualError(t, err, "Run 3 of org/tool concluded with failure: https://github.com/org/tool/actions/runs/3")
+
+	dispatch.Workflow = "unknown.yml"
+	_, err = dispatch.Run(context.Background(), server.URL, nil)
+	assert.Error(t, err)
+}
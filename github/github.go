@@ -1,36 +1,287 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
 	"regexp"
+	"strings"
+	"sync"
 
+	"github.com/dgageot/getme/errdefs"
 	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/semver"
+	"github.com/gobwas/glob"
 )
 
-var ReleaseURL = regexp.MustCompile(`https://github.com/([^/]*)/([^/]*)/releases/download/([^/]*)/(.*)`)
+// DefaultAPIURL is the api of github.com.
+const DefaultAPIURL = "https://api.github.com"
+
+var (
+	releaseURL       = regexp.MustCompile(`^(https?)://([^/]*)/([^/]*)/([^/]*)/releases/download/([^/]*)/(.*)$`)
+	latestReleaseURL = regexp.MustCompile(`^(https?)://([^/]*)/([^/]*)/([^/]*)/releases/latest/download/(.*)$`)
+)
+
+// Release points to an asset of a Github release. Its tag is `latest` for
+// the latest release.
+type Release struct {
+	Scheme  string
+	Host    string
+	Org     string
+	Project string
+	Tag     string
+	Asset   string
+
+	// API is the url of the Github api serving the release.
+	API string
+}
+
+// ParseReleaseURL parses urls to release assets such as
+// `https://github.com/org/project/releases/download/tag/asset` or
+// `https://github.com/org/project/releases/latest/download/asset`.
+// Hosts other than github.com are Github Enterprise servers. They are
+// recognized if their name starts with `github.` or if their api url is
+// given.
+func ParseReleaseURL(url, apiURL string) (Release, bool) {
+	var release Release
+	if parts := releaseURL.FindStringSubmatch(url); parts != nil {
+		release = Release{Scheme: parts[1], Host: parts[2], Org: parts[3], Project: parts[4], Tag: parts[5], Asset: parts[6]}
+	} else if parts := latestReleaseURL.FindStringSubmatch(url); parts != nil {
+		release = Release{Scheme: parts[1], Host: parts[2], Org: parts[3], Project: parts[4], Tag: "latest", Asset: parts[5]}
+	} else {
+		return Release{}, false
+	}
+
+	switch {
+	case release.Host == "github.com":
+		release.API = DefaultAPIURL
+	case apiURL != "" && hostOf(apiURL) == release.Host:
+		release.API = strings.TrimSuffix(apiURL, "/")
+	case strings.HasPrefix(release.Host, "github."):
+		release.API = release.Scheme + "://" + release.Host + "/api/v3"
+	default:
+		return Release{}, false
+	}
+
+	return release, true
+}
+
+// URL gives the download url of the asset.
+func (r Release) URL() string {
+	if r.Tag == "latest" {
+		return r.Scheme + "://" + r.Host + "/" + r.Org + "/" + r.Project + "/releases/latest/download/" + r.Asset
+	}
+	return r.Scheme + "://" + r.Host + "/" + r.Org + "/" + r.Project + "/releases/download/" + r.Tag + "/" + r.Asset
+}
+
+func (r Release) repoAPI() string {
+	return r.API + "/repos/" + r.Org + "/" + r.Project
+}
+
+func hostOf(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
 
 type release struct {
-	Assets []asset `json:"assets"`
+	Id         int64  `json:"id"`
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
 }
 
 type asset struct {
 	Id                 int64  `json:"id"`
+	Name               string `json:"name"`
 	BrowserDownloadURL string `json:"browser_download_url"`
 	URL                string `json:"url"`
 }
 
-func AssetUrl(url string, headers []string) (string, error) {
-	parts := ReleaseURL.FindStringSubmatch(url)
-	org := parts[1]
-	project := parts[2]
-	tag := parts[3]
-	assetsUrl := "https://api.github.com/repos/" + org + "/" + project + "/releases/tags/" + tag
+// AssetUrl gives the api url of a release asset. Assets of private
+// repositories can only be downloaded through the api.
+func AssetUrl(ctx context.Context, rel Release, headers []string) (string, error) {
+	assets, err := releaseAssets(ctx, rel, headers)
+	if err != nil {
+		return "", err
+	}
+
+	url := rel.URL()
+	for _, relAsset := range assets {
+		if relAsset.BrowserDownloadURL == url {
+			return relAsset.URL, nil
+		}
+	}
+
+	return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find this release: %s", url)
+}
+
+var (
+	latestTagsLock sync.Mutex
+	latestTags     = map[string]string{}
+)
+
+// LatestTag finds the tag of the latest release of a project. It's looked up
+// only once per run.
+func LatestTag(ctx context.Context, rel Release, headers []string) (string, error) {
+	latestTagsLock.Lock()
+	defer latestTagsLock.Unlock()
+
+	if tag, found := latestTags[rel.repoAPI()]; found {
+		return tag, nil
+	}
+
+	latest := release{}
+	if err := getJSON(ctx, rel.repoAPI()+"/releases/latest", headers, &latest); err != nil {
+		return "", err
+	}
+
+	if latest.TagName == "" {
+		return "", errdefs.Errorf(errdefs.ErrNotFound, "Unable to find the latest release of %s/%s", rel.Org, rel.Project)
+	}
+
+	latestTags[rel.repoAPI()] = latest.TagName
+	return latest.TagName, nil
+}
+
+// Pin turns a release of the latest tag into the actual release. If a tag is
+// given, it replaces the tag of the release. The `latest` tag is resolved
+// with the api.
+func Pin(ctx context.Context, rel Release, tag string, headers []string) (Release, error) {
+	if tag != "" {
+		rel.Tag = tag
+	}
+
+	if rel.Tag == "latest" {
+		latest, err := LatestTag(ctx, rel, headers)
+		if err != nil {
+			return Release{}, err
+		}
+		rel.Tag = latest
+	}
+
+	return rel, nil
+}
+
+// MatchVersion finds the tag of the highest release whose version matches a
+// constraint, like `>=1.20 <1.21`.
+func MatchVersion(ctx context.Context, rel Release, constraint semver.Constraint, headers []string) (string, error) {
+	var tags []string
+	next := rel.repoAPI() + "/releases?per_page=100"
+	for next != "" {
+		var page []release
 
-	req, err := http.NewRequest("GET", assetsUrl, nil)
+		var err error
+		if next, err = getJSONPage(ctx, next, headers, &page); err != nil {
+			return "", err
+		}
+
+		for _, r := range page {
+			if !r.Draft && (!r.Prerelease || constraint.Prerelease()) {
+				tags = append(tags, r.TagName)
+			}
+		}
+	}
+
+	tag, err := constraint.Highest(tags)
+	if err != nil {
+		return "", fmt.Errorf("%s/%s: %w", rel.Org, rel.Project, err)
+	}
+
+	return tag, nil
+}
+
+// MatchAsset finds the single asset of a release whose name matches a pattern.
+// The pattern is either a glob, like `*linux_amd64*.tar.gz`, or a regular
+// expression written between slashes, like `/linux.amd64/`.
+func MatchAsset(ctx context.Context, rel Release, pattern string, headers []string) (Release, error) {
+	match, err := compilePattern(pattern)
+	if err != nil {
+		return Release{}, err
+	}
+
+	// Nothing to do if the release already points to a matching asset.
+	if match(rel.Asset) {
+		return rel, nil
+	}
+
+	assets, err := releaseAssets(ctx, rel, headers)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var names, matching []string
+	for _, relAsset := range assets {
+		names = append(names, relAsset.Name)
+		if match(relAsset.Name) {
+			matching = append(matching, relAsset.Name)
+		}
+	}
+
+	switch len(matching) {
+	case 0:
+		return Release{}, errdefs.Errorf(errdefs.ErrNotFound, "No asset of %s/%s %s matches %s. Assets are [%s]", rel.Org, rel.Project, rel.Tag, pattern, strings.Join(names, ", "))
+	case 1:
+		rel.Asset = matching[0]
+		return rel, nil
+	}
+	return Release{}, fmt.Errorf("Several assets of %s/%s %s match %s: [%s]", rel.Org, rel.Project, rel.Tag, pattern, strings.Join(matching, ", "))
+}
+
+func compilePattern(pattern string) (func(string) bool, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return g.Match, nil
+}
+
+// releaseAssets lists all the assets of a release. The release itself only
+// gives the first page of assets so they are listed page by page.
+func releaseAssets(ctx context.Context, rel Release, headers []string) ([]asset, error) {
+	tagged := release{}
+	if err := getJSON(ctx, rel.repoAPI()+"/releases/tags/"+rel.Tag, headers, &tagged); err != nil {
+		return nil, err
+	}
+
+	var assets []asset
+	next := fmt.Sprintf("%s/releases/%d/assets?per_page=100", rel.repoAPI(), tagged.Id)
+	for next != "" {
+		var page []asset
+
+		var err error
+		if next, err = getJSONPage(ctx, next, headers, &page); err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, page...)
+	}
+
+	return assets, nil
+}
+
+func getJSON(ctx context.Context, url string, headers []string, v interface{}) error {
+	_, err := getJSONPage(ctx, url, headers, v)
+	return err
+}
+
+// getJSONPage reads a page of results and gives the url of the next page, if
+// any.
+func getJSONPage(ctx context.Context, url string, headers []string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -39,14 +290,14 @@ func AssetUrl(url string, headers []string) (string, error) {
 		return "", err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= http.StatusBadRequest {
-		return "", errors.New(resp.Status)
+		return "", &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -54,16 +305,21 @@ func AssetUrl(url string, headers []string) (string, error) {
 		return "", err
 	}
 
-	rel := release{}
-	if err := json.Unmarshal(body, &rel); err != nil {
+	if err := json.Unmarshal(body, v); err != nil {
 		return "", err
 	}
 
-	for _, relAsset := range rel.Assets {
-		if relAsset.BrowserDownloadURL == url {
-			return relAsset.URL, nil
-		}
-	}
+	return nextPage(resp.Header.Get("Link")), nil
+}
+
+var nextLink = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="next"`)
 
-	return "", fmt.Errorf("Unable to find this release: %s", url)
+// nextPage finds the url of the next page in a Link header such as
+// `<https://api.github.com/...&page=2>; rel="next", <...>; rel="last"`.
+func nextPage(link string) string {
+	parts := nextLink.FindStringSubmatch(link)
+	if parts == nil {
+		return ""
+	}
+	return parts[1]
 }
@@ -0,0 +1,129 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgageot/getme/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReleaseURL(t *testing.T) {
+	release, ok := ParseReleaseURL("https://github.com/docker/compose/releases/download/1.13.0/docker-compose-Linux-x86_64", "")
+	assert.True(t, ok)
+	assert.Equal(t, Release{Scheme: "https", Host: "github.com", Org: "docker", Project: "compose", Tag: "1.13.0", Asset: "docker-compose-Linux-x86_64", API: DefaultAPIURL}, release)
+	assert.Equal(t, "https://github.com/docker/compose/releases/download/1.13.0/docker-compose-Linux-x86_64", release.URL())
+
+	release, ok = ParseReleaseURL("https://github.com/docker/compose/releases/latest/download/docker-compose-Linux-x86_64", "")
+	assert.True(t, ok)
+	assert.Equal(t, "latest", release.Tag)
+	assert.Equal(t, "https://github.com/docker/compose/releases/latest/download/docker-compose-Linux-x86_64", release.URL())
+
+	release, ok = ParseReleaseURL("https://github.example.com/org/tool/releases/download/v1/tool.tgz", "")
+	assert.True(t, ok)
+	assert.Equal(t, "https://github.example.com/api/v3", release.API)
+
+	release, ok = ParseReleaseURL("https://git.example.com/org/tool/releases/download/v1/tool.tgz", "https://git.example.com/api/v3/")
+	assert.True(t, ok)
+	assert.Equal(t, "https://git.example.com/api/v3", release.API)
+
+	_, ok = ParseReleaseURL("https://gitea.example.com/org/tool/releases/download/v1/tool.tgz", "")
+	assert.False(t, ok)
+	_, ok = ParseReleaseURL("https://example.com/archive.tgz", "")
+	assert.False(t, ok)
+}
+
+func TestPin(t *testing.T) {
+	release, _ := ParseReleaseURL("https://github.com/docker/compose/releases/download/1.13.0/docker-compose-Linux-x86_64", "")
+
+	pinned, err := Pin(context.Background(), release, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/docker/compose/releases/download/1.13.0/docker-compose-Linux-x86_64", pinned.URL())
+
+	pinned, err = Pin(context.Background(), release, "1.14.0", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/docker/compose/releases/download/1.14.0/docker-compose-Linux-x86_64", pinned.URL())
+}
+
+func TestEnterpriseServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/org/tool/releases/latest":
+			fmt.Fprint(w, `{"id":1,"tag_name":"v2.0.0"}`)
+		case "/api/v3/repos/org/tool/releases/tags/v2.0.0":
+			fmt.Fprint(w, `{"id":1,"tag_name":"v2.0.0"}`)
+		case "/api/v3/repos/org/tool/releases/1/assets":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/repos/org/tool/releases/1/assets?per_page=100&page=2>; rel="next"`, r.Host))
+				fmt.Fprint(w, `[{"name":"tool_darwin_amd64.tar.gz"}]`)
+				return
+			}
+			fmt.Fprint(w, `[{"name":"tool_linux_amd64.tar.gz"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	release, ok := ParseReleaseURL(server.URL+"/org/tool/releases/latest/download/", server.URL+"/api/v3")
+	assert.True(t, ok)
+
+	release, err := Pin(context.Background(), release, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2.0.0", release.Tag)
+
+	release, err = MatchAsset(context.Background(), release, "*linux_amd64*", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/org/tool/releases/download/v2.0.0/tool_linux_amd64.tar.gz", release.URL())
+}
+
+func TestMatchVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/org/tool/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{"tag_name":"v1.21.0","prerelease":true},{"tag_name":"v1.20.10"},{"tag_name":"v1.20.11","draft":true},{"tag_name":"v1.20.2"},{"tag_name":"v1.19.0"}]`)
+	}))
+	defer server.Close()
+
+	release, ok := ParseReleaseURL(server.URL+"/org/tool/releases/download/{{.Version}}/tool.tgz", server.URL+"/api/v3")
+	assert.True(t, ok)
+
+	constraint, err := semver.ParseConstraint(">=1.20 <1.22")
+	assert.NoError(t, err)
+
+	tag, err := MatchVersion(context.Background(), release, constraint, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.20.10", tag)
+
+	constraint, err = semver.ParseConstraint(">=2")
+	assert.NoError(t, err)
+
+	_, err = MatchVersion(context.Background(), release, constraint, nil)
+	assert.Error(t, err)
+}
+
+func TestCompilePattern(t *testing.T) {
+	match, err := compilePattern("*linux_amd64*.tar.gz")
+	assert.NoError(t, err)
+	assert.True(t, match("tool_1.2.0_linux_amd64.tar.gz"))
+	assert.False(t, match("tool_1.2.0_darwin_amd64.tar.gz"))
+
+	match, err = compilePattern("/linux.amd64/")
+	assert.NoError(t, err)
+	assert.True(t, match("tool-linux-amd64"))
+	assert.False(t, match("tool-linux-arm64"))
+
+	_, err = compilePattern("/linux(/")
+	assert.Error(t, err)
+}
+
+func TestNextPage(t *testing.T) {
+	assert.Equal(t, "https://api.github.com/repositories/1/releases/2/assets?per_page=100&page=2", nextPage(`<https://api.github.com/repositories/1/releases/2/assets?per_page=100&page=2>; rel="next", <https://api.github.com/repositories/1/releases/2/assets?per_page=100&page=3>; rel="last"`))
+	assert.Equal(t, "", nextPage(`<https://api.github.com/repositories/1/releases/2/assets?per_page=100&page=1>; rel="prev"`))
+	assert.Equal(t, "", nextPage(""))
+}
@@ -0,0 +1,78 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgageot/getme/logs"
+)
+
+// RateLimitWait is how long to wait at most for the api rate limit to reset.
+// By default, hitting the rate limit is an error.
+var RateLimitWait time.Duration
+
+// do sends a request to the api. When the rate limit is exceeded, it either
+// waits for the rate limit to reset or explains the error. Waiting stops when
+// the context of the request is done.
+func do(req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		reset, limited := rateLimited(resp)
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		wait := time.Until(reset)
+		if wait < 0 {
+			wait = 0
+		}
+		if wait > RateLimitWait {
+			return nil, rateLimitError(reset, req)
+		}
+
+		logs.From(req.Context()).Infof("Github api rate limit exceeded. Waiting %s for it to reset", wait.Round(time.Second))
+		select {
+		case <-time.After(wait + time.Second):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// rateLimited tells if a response is a rate limit error and when it's worth
+// retrying. Secondary rate limits give a Retry-After instead of a reset time.
+func rateLimited(resp *http.Response) (time.Time, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second), true
+	}
+
+	if resp.Header.Get("X-Ratelimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+
+	reset, err := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Now(), true
+	}
+	return time.Unix(reset, 0), true
+}
+
+func rateLimitError(reset time.Time, req *http.Request) error {
+	hint := "Use an api token with --authToken or --authTokenEnvVariable to get a higher limit"
+	if req.Header.Get("Authorization") != "" {
+		hint = "Use --github-rate-limit-wait to wait for it"
+	}
+
+	return fmt.Errorf("Github api rate limit exceeded. It resets at %s, in %s. %s", reset.Format("15:04:05"), time.Until(reset).Round(time.Second), hint)
+}
@@ -0,0 +1,77 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitError(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := getJSON(context.Background(), server.URL, nil, &release{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Github api rate limit exceeded")
+}
+
+func TestRateLimitWait(t *testing.T) {
+	defer func(previous time.Duration) { RateLimitWait = previous }(RateLimitWait)
+	RateLimitWait = time.Minute
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"tag_name":"v1.0.0"}`)
+	}))
+	defer server.Close()
+
+	rel := release{}
+	assert.NoError(t, getJSON(context.Background(), server.URL, nil, &rel))
+	assert.Equal(t, "v1.0.0", rel.TagName)
+	assert.Equal(t, 2, calls)
+}
+
+func TestForbiddenIsNotRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := getJSON(context.Background(), server.URL, nil, &release{})
+	assert.EqualError(t, err, "403 Forbidden")
+}
+
+func TestRateLimitWaitIsCancelled(t *testing.T) {
+	defer func(previous time.Duration) { RateLimitWait = previous }(RateLimitWait)
+	RateLimitWait = time.Hour
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := getJSON(ctx, server.URL, nil, &release{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,119 @@
+package github
+
+import (
+	archivetar "archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/tempfile"
+)
+
+var (
+	sourceURL   = regexp.MustCompile(`^github://([^/]+)/([^/@]+)@(.+)$`)
+	codeloadURL = regexp.MustCompile(`^https://codeload\.github\.com/([^/]+)/([^/]+)/(tar\.gz|zip)/(.+)$`)
+)
+
+// IsSourceURL tells if an url points to the source archive of a repository,
+// either `github://org/repo@ref` or a `https://codeload.github.com/` url.
+func IsSourceURL(url string) bool {
+	return sourceURL.MatchString(url) || codeloadURL.MatchString(url)
+}
+
+// OpenSource downloads the source archive of a repository through the api, so
+// that private repositories work too. `github://` urls give a tarball. If a
+// commit is given, the tarball is checked to be an archive of that commit.
+func OpenSource(ctx context.Context, url, api, commit string, headers []string) (io.ReadCloser, error) {
+	var org, project, format, ref string
+	if parts := sourceURL.FindStringSubmatch(url); parts != nil {
+		org, project, format, ref = parts[1], parts[2], "tarball", parts[3]
+	} else if parts := codeloadURL.FindStringSubmatch(url); parts != nil {
+		org, project, ref = parts[1], parts[2], parts[4]
+		format = "tarball"
+		if parts[3] == "zip" {
+			format = "zipball"
+		}
+	} else {
+		return nil, fmt.Errorf("Invalid source url. Should be github://org/repo@ref: %s", url)
+	}
+
+	if commit != "" && format != "tarball" {
+		return nil, fmt.Errorf("Only tarballs can be checked against a commit: %s", url)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", api+"/repos/"+org+"/"+project+"/"+format+"/"+ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := http_headers.Add(headers, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to download the source of %s/%s at %s: %w", org, project, ref, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+	if commit == "" {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	tmp, err := ioutil.TempFile("", "getme-source")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if err := checkCommit(tmp, commit); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("Source of %s/%s at %s: %w", org, project, ref, err)
+	}
+
+	return tempfile.New(tmp), nil
+}
+
+// checkCommit reads the commit recorded by `git archive` in the global header
+// of a tarball.
+func checkCommit(file *os.File, commit string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	archive, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+
+	header, err := archivetar.NewReader(archive).Next()
+	if err != nil {
+		return err
+	}
+
+	actual := header.PAXRecords["comment"]
+	if header.Typeflag != archivetar.TypeXGlobalHeader || actual == "" {
+		return fmt.Errorf("no commit recorded in the tarball")
+	}
+	if actual != commit {
+		return fmt.Errorf("expected commit %s, got %s", commit, actual)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,63 @@
+package github
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSourceURL(t *testing.T) {
+	assert.True(t, IsSourceURL("github://docker/compose@v2.24.0"))
+	assert.True(t, IsSourceURL("https://codeload.github.com/docker/compose/tar.gz/refs/tags/v2.24.0"))
+	assert.True(t, IsSourceURL("https://codeload.github.com/docker/compose/zip/refs/heads/main"))
+
+	assert.False(t, IsSourceURL("github://docker/compose"))
+	assert.False(t, IsSourceURL("https://github.com/docker/compose/archive/v2.24.0.tar.gz"))
+}
+
+func TestOpenSource(t *testing.T) {
+	tarball := sourceTarball(t, "0123456789abcdef")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/docker/compose/tarball/v2.24.0", r.URL.Path)
+		assert.Equal(t, "Bearer TOKEN", r.Header.Get("Authorization"))
+		w.Write(tarball)
+	}))
+	defer server.Close()
+
+	reader, err := OpenSource(context.Background(), "github://docker/compose@v2.24.0", server.URL, "0123456789abcdef", []string{"Authorization=Bearer TOKEN"})
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, tarball, content)
+	assert.NoError(t, reader.Close())
+
+	_, err = OpenSource(context.Background(), "github://docker/compose@v2.24.0", server.URL, "fedcba9876543210", []string{"Authorization=Bearer TOKEN"})
+	assert.EqualError(t, err, "Source of docker/compose at v2.24.0: expected commit fedcba9876543210, got 0123456789abcdef")
+}
+
+func sourceTarball(t *testing.T, commit string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	assert.NoError(t, tarWriter.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": commit},
+	}))
+	assert.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "compose/README.md", Mode: 0644, Size: 6, Typeflag: tar.TypeReg}))
+	_, err := tarWriter.Write([]byte("readme"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+	return buffer.Bytes()
+}
@@ -0,0 +1,106 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+var (
+	// ReleaseURL matches permanent links to release assets, such as
+	// `https://gitlab.com/group/project/-/releases/v1.0.0/downloads/tool.tgz`,
+	// including `/-/releases/permalink/latest/downloads/`.
+	ReleaseURL = regexp.MustCompile(`^https?://[^/]+/.+/-/releases/(permalink/latest|[^/]+)/downloads/.+`)
+
+	// PackageURL matches files of the Generic Package Registry, such as
+	// `https://gitlab.com/api/v4/projects/42/packages/generic/tool/1.0.0/tool.tgz`.
+	PackageURL = regexp.MustCompile(`^https?://[^/]+/api/v4/projects/[^/]+/packages/generic/[^/]+/[^/]+/.+`)
+)
+
+// Options configures access to a GitLab server.
+type Options struct {
+	// Token is a personal, project or group access token. Defaults to
+	// $GITLAB_TOKEN.
+	Token string
+
+	// JobToken is used inside GitLab CI jobs. Defaults to $CI_JOB_TOKEN.
+	JobToken string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// IsGitlabURL tells if an url points to a GitLab release asset or package.
+func IsGitlabURL(url string) bool {
+	return ReleaseURL.MatchString(url) || PackageURL.MatchString(url)
+}
+
+// Open downloads a release asset or a package. Release links redirect to
+// where the asset is actually stored. The token is only sent to the GitLab
+// server itself.
+func Open(ctx context.Context, url string, options Options) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(options.Headers, req); err != nil {
+		return nil, err
+	}
+
+	key, token := options.header()
+	if token != "" {
+		req.Header.Set(key, token)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Host != via[0].URL.Host {
+				req.Header.Del("Private-Token")
+				req.Header.Del("Job-Token")
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		if (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized) && token == "" {
+			return nil, fmt.Errorf("%s. Private projects require --gitlabToken or $GITLAB_TOKEN", resp.Status)
+		}
+		return nil, errors.New(resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// header gives the header used to authenticate, if any.
+func (o Options) header() (string, string) {
+	if token := firstNonEmpty(o.Token, os.Getenv("GITLAB_TOKEN")); token != "" {
+		return "Private-Token", token
+	}
+	if token := firstNonEmpty(o.JobToken, os.Getenv("CI_JOB_TOKEN")); token != "" {
+		return "Job-Token", token
+	}
+	return "", ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
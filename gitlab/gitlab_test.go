@@ -0,0 +1,86 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsGitlabURL(t *testing.T) {
+	assert.True(t, IsGitlabURL("https://gitlab.com/group/project/-/releases/v1.0.0/downloads/tool.tgz"))
+	assert.True(t, IsGitlabURL("https://gitlab.example.com/group/sub/project/-/releases/permalink/latest/downloads/bin/tool"))
+	assert.True(t, IsGitlabURL("https://gitlab.example.com/api/v4/projects/42/packages/generic/tool/1.0.0/tool.tgz"))
+	assert.True(t, IsGitlabURL("https://gitlab.example.com/api/v4/projects/group%2Fproject/packages/generic/tool/1.0.0/tool.tgz"))
+
+	assert.False(t, IsGitlabURL("https://github.com/org/project/releases/download/v1.0.0/tool.tgz"))
+	assert.False(t, IsGitlabURL("https://gitlab.com/group/project/-/archive/v1.0.0/project-v1.0.0.tar.gz"))
+}
+
+func TestOpen(t *testing.T) {
+	os.Unsetenv("GITLAB_TOKEN")
+	os.Unsetenv("CI_JOB_TOKEN")
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Private-Token"))
+		fmt.Fprint(w, "asset")
+	}))
+	defer storage.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != "secret" && r.Header.Get("Job-Token") != "job" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/group/project/-/releases/v1.0.0/downloads/tool.tgz" {
+			http.Redirect(w, r, storage.URL+"/tool.tgz", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "package")
+	}))
+	defer server.Close()
+
+	content, err := read(server.URL+"/group/project/-/releases/v1.0.0/downloads/tool.tgz", Options{Token: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "asset", content)
+
+	content, err = read(server.URL+"/api/v4/projects/42/packages/generic/tool/1.0.0/tool.tgz", Options{JobToken: "job"})
+	assert.NoError(t, err)
+	assert.Equal(t, "package", content)
+
+	_, err = read(server.URL+"/api/v4/projects/42/packages/generic/tool/1.0.0/tool.tgz", Options{})
+	assert.Error(t, err)
+}
+
+func TestOpenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := Open(ctx, server.URL+"/api/v4/projects/42/packages/generic/tool/1.0.0/tool.tgz", Options{Token: "secret"})
+	assert.Error(t, err)
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+}
+
+func read(url string, options Options) (string, error) {
+	reader, err := Open(context.Background(), url, options)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
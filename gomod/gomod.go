@@ -0,0 +1,309 @@
+// Package gomod downloads Go modules from a module proxy, given as
+// `gomod://module@version`, like `gomod://golang.org/x/mod@v0.14.0`.
+//
+// The module zip is downloaded by default. The go.mod file and the version
+// info are downloaded with `?file=mod` and `?file=info`. Without a version,
+// or with `latest`, the latest version is used.
+//
+// Like the go command, the proxies are read from $GOPROXY and zips and go.mod
+// files are verified against the checksum database of $GOSUMDB, except for
+// the modules that match $GONOSUMDB or $GOPRIVATE.
+package gomod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/tempfile"
+)
+
+// DefaultProxy is the module proxy run by Google.
+const DefaultProxy = "https://proxy.golang.org"
+
+// Options configures access to module proxies and to the checksum database.
+type Options struct {
+	// Proxy is a list of proxies, like $GOPROXY. Defaults to $GOPROXY, then
+	// to DefaultProxy.
+	Proxy string
+
+	// SumDB is the checksum database, like $GOSUMDB. Defaults to $GOSUMDB,
+	// then to sum.golang.org.
+	SumDB string
+
+	// Headers are added to every request to the proxies, as `key: value`.
+	Headers []string
+}
+
+// Module is a file of a version of a module: its zip, its go.mod or its info.
+type Module struct {
+	Path    string
+	Version string
+	File    string
+}
+
+// IsModuleURL tells if an url is a gomod:// url.
+func IsModuleURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "gomod://")
+}
+
+// ParseURL parses a gomod:// url.
+func ParseURL(rawURL string) (Module, error) {
+	spec, rawQuery := strings.TrimPrefix(rawURL, "gomod://"), ""
+	if i := strings.Index(spec, "?"); i >= 0 {
+		spec, rawQuery = spec[:i], spec[i+1:]
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Module{}, err
+	}
+
+	module := Module{Path: spec, Version: "latest", File: query.Get("file")}
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		module.Path, module.Version = spec[:i], spec[i+1:]
+	}
+	if module.File == "" {
+		module.File = "zip"
+	}
+
+	if !IsModuleURL(rawURL) || module.Path == "" || module.Version == "" || strings.HasPrefix(module.Path, "/") || strings.HasSuffix(module.Path, "/") {
+		return Module{}, fmt.Errorf("Invalid Go module url. Should be gomod://module@version: %s", rawURL)
+	}
+	if module.File != "zip" && module.File != "mod" && module.File != "info" {
+		return Module{}, fmt.Errorf("Invalid Go module file %q. Should be zip, mod or info: %s", module.File, rawURL)
+	}
+
+	return module, nil
+}
+
+// URL gives the gomod:// url of the module.
+func (m Module) URL() string {
+	url := "gomod://" + m.Path + "@" + m.Version
+	if m.File != "zip" {
+		url += "?file=" + m.File
+	}
+	return url
+}
+
+type info struct {
+	Version string `json:"Version"`
+}
+
+// Pin turns the `latest` version into the actual version, so that the module
+// is cached as such.
+func Pin(ctx context.Context, m Module, options Options) (Module, error) {
+	if m.Version != "latest" {
+		return m, nil
+	}
+
+	content, err := options.get(ctx, escape(m.Path)+"/@latest")
+	if err != nil {
+		return Module{}, fmt.Errorf("Unable to find the latest version of %s: %w", m.Path, err)
+	}
+
+	found := info{}
+	if err := json.Unmarshal(content, &found); err != nil {
+		return Module{}, err
+	}
+	if found.Version == "" {
+		return Module{}, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find the latest version of %s", m.Path)
+	}
+
+	m.Version = found.Version
+	return m, nil
+}
+
+// Open downloads a file of a module. Zips and go.mod files are verified
+// against the checksum database.
+func Open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, error) {
+	m, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, err = Pin(ctx, m, options); err != nil {
+		return nil, err
+	}
+
+	path := escape(m.Path) + "/@v/" + escape(m.Version) + "." + m.File
+	if m.File == "info" {
+		return options.open(ctx, path)
+	}
+
+	var hashes map[string]string
+	if options.checksSums(m.Path) {
+		if hashes, err = options.lookup(ctx, m); err != nil {
+			return nil, err
+		}
+	} else {
+		logs.From(ctx).Infoln("Skip the checksum database for", m.Path)
+	}
+
+	if m.File == "mod" {
+		content, err := options.get(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if hashes != nil {
+			if err := verify(m, hashes[m.Version+"/go.mod"], modHash(content)); err != nil {
+				return nil, err
+			}
+		}
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	body, err := options.open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if hashes == nil {
+		return body, nil
+	}
+
+	// Zips are hashed file by file so they are read from a temporary file.
+	tmp, err := ioutil.TempFile("", "getme-gomod")
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	_, err = io.Copy(tmp, body)
+	body.Close()
+	if err == nil {
+		var hash string
+		if hash, err = zipHash(tmp); err == nil {
+			err = verify(m, hashes[m.Version], hash)
+		}
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tempfile.New(tmp), nil
+}
+
+// proxy is an entry of $GOPROXY. Entries separated by a pipe are tried after
+// any error. Entries separated by a comma are only tried after a not found.
+type proxy struct {
+	url             string
+	fallBackOnError bool
+}
+
+func (o Options) proxies() []proxy {
+	list := o.Proxy
+	if list == "" {
+		list = os.Getenv("GOPROXY")
+	}
+	if list == "" {
+		list = DefaultProxy
+	}
+
+	var proxies []proxy
+	for list != "" {
+		entry, fallBackOnError := list, false
+		if i := strings.IndexAny(list, ",|"); i >= 0 {
+			entry, fallBackOnError, list = list[:i], list[i] == '|', list[i+1:]
+		} else {
+			list = ""
+		}
+
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, proxy{url: strings.TrimSuffix(entry, "/"), fallBackOnError: fallBackOnError})
+		}
+	}
+	return proxies
+}
+
+// open reads a path from the first proxy that has it. Direct downloads from
+// version control systems aren't supported.
+func (o Options) open(ctx context.Context, path string) (io.ReadCloser, error) {
+	var lastErr error
+	for _, proxy := range o.proxies() {
+		switch proxy.url {
+		case "off":
+			return nil, errors.New("Module downloads are disabled by GOPROXY=off")
+		case "direct":
+			lastErr = errors.New("Direct module downloads aren't supported. Use a module proxy in GOPROXY")
+			continue
+		}
+
+		body, err := o.do(ctx, proxy.url+"/"+path)
+		if err == nil {
+			return body, nil
+		}
+		if !proxy.fallBackOnError && !errors.Is(err, errdefs.ErrNotFound) {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("No module proxy is configured")
+	}
+	return nil, lastErr
+}
+
+func (o Options) get(ctx context.Context, path string) ([]byte, error) {
+	body, err := o.open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+func (o Options) do(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to download %s: %w", url, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	return resp.Body, nil
+}
+
+// escape escapes a module path or a version for proxies and checksum
+// databases: upper case letters are written as `!` and the lower case letter.
+func escape(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			escaped.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,188 @@
+package gomod
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	module, err := ParseURL("gomod://golang.org/x/mod@v0.14.0")
+	assert.NoError(t, err)
+	assert.Equal(t, Module{Path: "golang.org/x/mod", Version: "v0.14.0", File: "zip"}, module)
+
+	module, err = ParseURL("gomod://github.com/BurntSushi/toml?file=mod")
+	assert.NoError(t, err)
+	assert.Equal(t, Module{Path: "github.com/BurntSushi/toml", Version: "latest", File: "mod"}, module)
+	assert.Equal(t, "gomod://github.com/BurntSushi/toml@latest?file=mod", module.URL())
+	assert.Equal(t, "github.com/!burnt!sushi/toml", escape(module.Path))
+
+	_, err = ParseURL("gomod://golang.org/x/mod@")
+	assert.Error(t, err)
+	_, err = ParseURL("gomod://golang.org/x/mod@v0.14.0?file=tgz")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, _ := writer.Create("example.com/tool@v1.0.0/go.mod")
+	fmt.Fprint(file, "module example.com/tool\n")
+	writer.Close()
+	zipContent := archive.Bytes()
+	modContent := []byte("module example.com/tool\n")
+
+	modules := map[string][]byte{
+		"/example.com/tool/@latest":        []byte(`{"Version":"v1.0.0"}`),
+		"/example.com/tool/@v/v1.0.0.zip":  zipContent,
+		"/example.com/tool/@v/v1.0.0.mod":  modContent,
+		"/example.com/tool/@v/v1.0.0.info": []byte(`{"Version":"v1.0.0"}`),
+		"/example.com/bad/@v/v1.0.0.zip":   zipContent,
+	}
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, found := modules[r.URL.Path]; found {
+			w.Write(content)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer proxy.Close()
+
+	// The checksum database signs its tree with a key of its own.
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	encoded := append([]byte{1}, public...)
+	hash := make([]byte, 4)
+	binary.BigEndian.PutUint32(hash, keyHash("sumdb.example.com", encoded))
+
+	zipSum, _ := hash1([]string{"example.com/tool@v1.0.0/go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(modContent)), nil
+	})
+	toolRecord := fmt.Sprintf("example.com/tool v1.0.0 %s\nexample.com/tool v1.0.0/go.mod %s\n", zipSum, modHash(modContent))
+	badRecord := "example.com/bad v1.0.0 h1:invalid\n"
+
+	// The records span more than one tile.
+	records := make([][]byte, 300)
+	for i := range records {
+		records[i] = []byte(fmt.Sprintf("example.com/other%d v1.0.0 h1:hash\n", i))
+	}
+	records[2] = []byte(badRecord)
+	records[257] = []byte(toolRecord)
+	tiles, root := buildTiles(records)
+
+	tree := fmt.Sprintf("go.sum database tree\n%d\n%s\n", len(records), base64.StdEncoding.EncodeToString(root[:]))
+	signature := base64.StdEncoding.EncodeToString(append(hash, ed25519.Sign(private, []byte(tree))...))
+	sumdb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tile, found := tiles[r.URL.Path]; found {
+			w.Write(tile)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/lookup/example.com/tool@v1.0.0":
+			fmt.Fprintf(w, "257\n%s\n%s\n— sumdb.example.com %s\n", toolRecord, tree, signature)
+		case "/lookup/example.com/bad@v1.0.0":
+			fmt.Fprintf(w, "2\n%s\n%s\n— sumdb.example.com %s\n", badRecord, tree, signature)
+		case "/lookup/example.com/forged@v1.0.0":
+			fmt.Fprintf(w, "257\nexample.com/forged v1.0.0 %s\n\n%s\n— sumdb.example.com %s\n", zipSum, tree, signature)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer sumdb.Close()
+
+	options := Options{
+		Proxy: missing.URL + "," + proxy.URL,
+		SumDB: fmt.Sprintf("sumdb.example.com+%x+%s %s", hash, base64.StdEncoding.EncodeToString(encoded), sumdb.URL),
+	}
+
+	module, err := Pin(context.Background(), Module{Path: "example.com/tool", Version: "latest", File: "zip"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "gomod://example.com/tool@v1.0.0", module.URL())
+
+	for url, expected := range map[string][]byte{
+		"gomod://example.com/tool@v1.0.0":           zipContent,
+		"gomod://example.com/tool@v1.0.0?file=mod":  modContent,
+		"gomod://example.com/tool@v1.0.0?file=info": []byte(`{"Version":"v1.0.0"}`),
+	} {
+		body, err := Open(context.Background(), url, options)
+		assert.NoError(t, err)
+		content, _ := ioutil.ReadAll(body)
+		body.Close()
+		assert.Equal(t, expected, content)
+	}
+
+	_, err = Open(context.Background(), "gomod://example.com/bad@v1.0.0", options)
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+
+	modules["/example.com/forged/@v/v1.0.0.zip"] = zipContent
+	_, err = Open(context.Background(), "gomod://example.com/forged@v1.0.0", options)
+	assert.EqualError(t, err, "Record 257 isn't in the tree signed by sumdb.example.com")
+
+	options.SumDB = "sumdb.example.com+00000000+" + base64.StdEncoding.EncodeToString(encoded) + " " + sumdb.URL
+	_, err = Open(context.Background(), "gomod://example.com/tool@v1.0.0", options)
+	assert.Error(t, err)
+
+	options.Proxy = missing.URL + ",direct"
+	_, err = Open(context.Background(), "gomod://example.com/tool@v1.0.0?file=info", options)
+	assert.EqualError(t, err, "Direct module downloads aren't supported. Use a module proxy in GOPROXY")
+}
+
+func TestTilePath(t *testing.T) {
+	assert.Equal(t, "/tile/8/0/000", tilePath(0, 0, 256))
+	assert.Equal(t, "/tile/8/1/001.p/5", tilePath(1, 1, 5))
+	assert.Equal(t, "/tile/8/0/x001/x234/067", tilePath(0, 1234067, 256))
+}
+
+// buildTiles computes the tiles and the root hash of a tree of records.
+func buildTiles(records [][]byte) (map[string][]byte, hash) {
+	var level []hash
+	for _, record := range records {
+		level = append(level, recordHash(record))
+	}
+
+	tiles := map[string][]byte{}
+	var pending []hash
+	for height := 0; len(level) > 0; height++ {
+		if height%tileHeight == 0 {
+			for index := 0; index*tileWidth < len(level); index++ {
+				var tile []byte
+				for i := index * tileWidth; i < len(level) && i < (index+1)*tileWidth; i++ {
+					tile = append(tile, level[i][:]...)
+				}
+				tiles[tilePath(height/tileHeight, int64(index), int64(len(tile)/len(hash{})))] = tile
+			}
+		}
+
+		// An incomplete node is only part of the root hash.
+		if len(level)%2 == 1 {
+			pending = append(pending, level[len(level)-1])
+		}
+		var next []hash
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, nodeHash(level[i], level[i+1]))
+		}
+		level = next
+	}
+
+	root := pending[0]
+	for _, left := range pending[1:] {
+		root = nodeHash(left, root)
+	}
+	return tiles, root
+}
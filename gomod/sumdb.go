@@ -0,0 +1,295 @@
+package gomod
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// sumGolangOrg is the verifier key of sum.golang.org.
+const sumGolangOrg = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"
+
+// sumDB is a checksum database. Its key is a note verifier key, like
+// `name+hash+base64 encoded ed25519 key`.
+type sumDB struct {
+	name string
+	hash uint32
+	key  ed25519.PublicKey
+	url  string
+}
+
+// sumDB parses $GOSUMDB: `off`, a known database or a verifier key,
+// optionally followed by the url of the database.
+func (o Options) sumDB() (*sumDB, error) {
+	value := o.SumDB
+	if value == "" {
+		value = os.Getenv("GOSUMDB")
+	}
+	if value == "" {
+		value = "sum.golang.org"
+	}
+	if value == "off" {
+		return nil, nil
+	}
+
+	fields := strings.Fields(value)
+	key, url := fields[0], ""
+	switch key {
+	case "sum.golang.org":
+		key = sumGolangOrg
+	case "sum.golang.google.cn":
+		key, url = sumGolangOrg, "https://sum.golang.google.cn"
+	}
+	if len(fields) > 1 {
+		url = fields[1]
+	}
+
+	parts := strings.SplitN(key, "+", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Invalid GOSUMDB. Should be a known checksum database or a verifier key: %s", value)
+	}
+
+	hash, err := hex.DecodeString(parts[1])
+	if err != nil || len(hash) != 4 {
+		return nil, fmt.Errorf("Invalid hash in the key of %s", parts[0])
+	}
+	encoded, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(encoded) != 1+ed25519.PublicKeySize || encoded[0] != 1 {
+		return nil, fmt.Errorf("Invalid ed25519 key for %s", parts[0])
+	}
+
+	db := &sumDB{name: parts[0], hash: binary.BigEndian.Uint32(hash), key: ed25519.PublicKey(encoded[1:]), url: url}
+	if db.hash != keyHash(db.name, encoded) {
+		return nil, fmt.Errorf("Invalid hash in the key of %s", db.name)
+	}
+	if db.url == "" {
+		db.url = "https://" + db.name
+	}
+	db.url = strings.TrimSuffix(db.url, "/")
+
+	return db, nil
+}
+
+// checksSums tells if a module should be verified against the checksum
+// database. Private modules, that match $GONOSUMDB or $GOPRIVATE, aren't.
+func (o Options) checksSums(modulePath string) bool {
+	if db, err := o.sumDB(); err == nil && db == nil {
+		return false
+	}
+
+	patterns := os.Getenv("GONOSUMDB")
+	if patterns == "" {
+		patterns = os.Getenv("GOPRIVATE")
+	}
+	return !matchesPrefixPatterns(patterns, modulePath)
+}
+
+// lookup reads the hashes of a version of a module from the checksum
+// database, keyed by `version` and `version/go.mod`. The tree note must be
+// signed by the database and the record must be included in that tree.
+func (o Options) lookup(ctx context.Context, m Module) (map[string]string, error) {
+	db, err := o.sumDB()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := db.get(ctx, "/lookup/"+escape(m.Path)+"@"+escape(m.Version))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find %s@%s in the checksum database: %w", m.Path, m.Version, err)
+	}
+
+	// A record is its id, the go.sum lines and the signed tree note.
+	record := string(content)
+	i := strings.Index(record, "\n")
+	j := strings.Index(record, "\n\n")
+	if i < 0 || j < i {
+		return nil, fmt.Errorf("Invalid record of %s@%s in the checksum database", m.Path, m.Version)
+	}
+	lines, note := record[i+1:j+1], record[j+2:]
+
+	id, err := strconv.ParseInt(record[:i], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid record of %s@%s in the checksum database", m.Path, m.Version)
+	}
+
+	text, err := db.verifyNote(note)
+	if err != nil {
+		return nil, err
+	}
+	size, root, err := parseTree(text)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &tree{ctx: ctx, db: db, size: size, tiles: map[string][]byte{}}
+	if err := tree.checkInclusion(id, recordHash([]byte(lines)), root); err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]string{}
+	for _, line := range strings.Split(lines, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == m.Path {
+			hashes[fields[1]] = fields[2]
+		}
+	}
+	return hashes, nil
+}
+
+func (db *sumDB) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", db.url+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// verifyNote checks that a note is signed by the checksum database and
+// returns its text. A note is text, an empty line and signature lines, like
+// `— name base64`.
+func (db *sumDB) verifyNote(note string) (string, error) {
+	i := strings.LastIndex(note, "\n\n")
+	if i < 0 {
+		return "", fmt.Errorf("Invalid tree note from %s", db.name)
+	}
+	text, signatures := note[:i+1], note[i+2:]
+
+	for _, line := range strings.Split(signatures, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 || fields[0] != db.name {
+			continue
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(signature) < 4 || binary.BigEndian.Uint32(signature) != db.hash {
+			continue
+		}
+		if ed25519.Verify(db.key, []byte(text), signature[4:]) {
+			return text, nil
+		}
+	}
+
+	return "", errors.New("The tree note isn't signed by " + db.name)
+}
+
+func keyHash(name string, key []byte) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte("\n"))
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// matchesPrefixPatterns tells if a module path, or one of its parents,
+// matches a comma separated list of glob patterns, like $GOPRIVATE.
+func matchesPrefixPatterns(patterns, modulePath string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+
+		elements := strings.Split(modulePath, "/")
+		n := strings.Count(pattern, "/") + 1
+		if n > len(elements) {
+			continue
+		}
+		if matched, _ := path.Match(pattern, strings.Join(elements[:n], "/")); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func verify(m Module, expected, actual string) error {
+	if expected == "" {
+		return fmt.Errorf("The checksum database has no hash for %s", m.URL())
+	}
+	if expected != actual {
+		return errdefs.Errorf(errdefs.ErrChecksumMismatch, "Invalid hash for %s: expected %s, got %s", m.URL(), expected, actual)
+	}
+	return nil
+}
+
+// hash1 is the `h1:` hash of go.sum files: the sha256 of a summary that
+// lists the sha256 of each file, sorted by name.
+func hash1(names []string, open func(string) (io.ReadCloser, error)) (string, error) {
+	sort.Strings(names)
+
+	summary := sha256.New()
+	for _, name := range names {
+		reader, err := open(name)
+		if err != nil {
+			return "", err
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, reader)
+		reader.Close()
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+func modHash(content []byte) string {
+	hash, _ := hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	})
+	return hash
+}
+
+func zipHash(file *os.File) (string, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := zip.NewReader(file, stat.Size())
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string]*zip.File{}
+	var names []string
+	for _, f := range archive.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+
+	return hash1(names, func(name string) (io.ReadCloser, error) {
+		return files[name].Open()
+	})
+}
@@ -0,0 +1,200 @@
+package gomod
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// The checksum database is a transparent log: a Merkle tree of records, like
+// in RFC 6962, whose hashes are served by tiles of 256 hashes.
+// See https://research.swtch.com/tlog.
+const (
+	tileHeight = 8
+	tileWidth  = 1 << tileHeight
+)
+
+type hash [sha256.Size]byte
+
+func recordHash(data []byte) hash {
+	var sum hash
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func nodeHash(left, right hash) hash {
+	var sum hash
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left[:])
+	h.Write(right[:])
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// parseTree reads the size and the root hash of a tree note, like
+// `go.sum database tree\nsize\nbase64 hash\n`.
+func parseTree(text string) (int64, hash, error) {
+	var root hash
+
+	lines := strings.SplitN(text, "\n", 4)
+	if len(lines) != 4 || lines[0] != "go.sum database tree" || lines[3] != "" {
+		return 0, root, errors.New("Invalid tree note: " + text)
+	}
+
+	size, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil || size <= 0 {
+		return 0, root, errors.New("Invalid size in the tree note: " + lines[1])
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(decoded) != len(root) {
+		return 0, root, errors.New("Invalid hash in the tree note: " + lines[2])
+	}
+	copy(root[:], decoded)
+
+	return size, root, nil
+}
+
+// tree reads the hashes of a tree of a given size from the tiles of a
+// checksum database.
+type tree struct {
+	ctx   context.Context
+	db    *sumDB
+	size  int64
+	tiles map[string][]byte
+}
+
+// checkInclusion makes sure that a record is the leaf `id` of the tree, by
+// computing the root hash from the hash of the record and the hashes of the
+// other subtrees.
+func (t *tree) checkInclusion(id int64, record, root hash) error {
+	if id < 0 || id >= t.size {
+		return fmt.Errorf("Record %d isn't in the tree of %s, of size %d", id, t.db.name, t.size)
+	}
+
+	computed, err := t.subtree(0, t.size, id, record)
+	if err != nil {
+		return err
+	}
+	if computed != root {
+		return fmt.Errorf("Record %d isn't in the tree signed by %s", id, t.db.name)
+	}
+
+	return nil
+}
+
+// subtree computes the hash of the leaves [start, start+n), using the hash of
+// the record instead of reading the leaf `id`.
+func (t *tree) subtree(start, n, id int64, record hash) (hash, error) {
+	contained := id >= start && id < start+n
+	if contained && n == 1 {
+		return record, nil
+	}
+	if !contained && n&(n-1) == 0 {
+		level := bits.TrailingZeros64(uint64(n))
+		return t.node(level, start>>uint(level))
+	}
+
+	// The left subtree is the largest complete tree.
+	k := int64(1)
+	for k*2 < n {
+		k *= 2
+	}
+	left, err := t.subtree(start, k, id, record)
+	if err != nil {
+		return hash{}, err
+	}
+	right, err := t.subtree(start+k, n-k, id, record)
+	if err != nil {
+		return hash{}, err
+	}
+
+	return nodeHash(left, right), nil
+}
+
+// node reads the hash of the complete subtree `index` at a given level. Tiles
+// only store the levels that are multiples of the tile height so the levels
+// in between are computed.
+func (t *tree) node(level int, index int64) (hash, error) {
+	tileLevel, extra := level/tileHeight, uint(level%tileHeight)
+	first, count := index<<extra, int64(1)<<extra
+	tileIndex := first / tileWidth
+
+	hashes, err := t.tile(tileLevel, tileIndex)
+	if err != nil {
+		return hash{}, err
+	}
+
+	offset := first - tileIndex*tileWidth
+	if int64(len(hashes)) < (offset+count)*sha256.Size {
+		return hash{}, fmt.Errorf("Invalid tile %d/%d from %s", tileLevel, tileIndex, t.db.name)
+	}
+
+	nodes := make([]hash, count)
+	for i := range nodes {
+		copy(nodes[i][:], hashes[(offset+int64(i))*sha256.Size:])
+	}
+	for len(nodes) > 1 {
+		for i := 0; i < len(nodes)/2; i++ {
+			nodes[i] = nodeHash(nodes[2*i], nodes[2*i+1])
+		}
+		nodes = nodes[:len(nodes)/2]
+	}
+
+	return nodes[0], nil
+}
+
+// tile reads the hashes of a tile. The last tile of each level is partial.
+// If the tree has grown since, only the full tile might still be served.
+func (t *tree) tile(level int, index int64) ([]byte, error) {
+	width := (t.size >> uint(level*tileHeight)) - index*tileWidth
+	if width > tileWidth {
+		width = tileWidth
+	}
+
+	path := tilePath(level, index, width)
+	if hashes, found := t.tiles[path]; found {
+		return hashes, nil
+	}
+
+	content, err := t.db.get(t.ctx, path)
+	if err != nil && width < tileWidth && errors.Is(err, errdefs.ErrNotFound) {
+		content, err = t.db.get(t.ctx, tilePath(level, index, tileWidth))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read tile %d/%d from %s: %w", level, index, t.db.name, err)
+	}
+	if int64(len(content)) < width*sha256.Size {
+		return nil, fmt.Errorf("Invalid tile %d/%d from %s", level, index, t.db.name)
+	}
+
+	t.tiles[path] = content[:width*sha256.Size]
+	return t.tiles[path], nil
+}
+
+// tilePath is the path of a tile, like `/tile/8/0/x001/234.p/5`: the index is
+// split in groups of three digits and partial tiles end with their width.
+func tilePath(level int, index, width int64) string {
+	digits := fmt.Sprintf("%03d", index%1000)
+	for index >= 1000 {
+		index /= 1000
+		digits = fmt.Sprintf("x%03d/%s", index%1000, digits)
+	}
+
+	path := fmt.Sprintf("/tile/%d/%d/%s", tileHeight, level, digits)
+	if width < tileWidth {
+		path += fmt.Sprintf(".p/%d", width)
+	}
+	return path
+}
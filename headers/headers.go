@@ -6,14 +6,24 @@ import (
 	"strings"
 )
 
+// Add adds headers, given as `key=value` or `key: value`, to a request.
 func Add(headers []string, req *http.Request) error {
 	for _, header := range headers {
-		parts := strings.Split(header, "=")
-		if len(parts) != 2 {
-			return fmt.Errorf("Invalid header [%s]. Should be [key=value]", header)
+		key, value, err := Split(header)
+		if err != nil {
+			return err
 		}
-		req.Header.Add(parts[0], parts[1])
+		req.Header.Add(key, value)
 	}
 
 	return nil
 }
+
+// Split reads a header given as `key=value` or `key: value`.
+func Split(header string) (string, string, error) {
+	i := strings.IndexAny(header, "=:")
+	if i <= 0 {
+		return "", "", fmt.Errorf("Invalid header [%s]. Should be [key=value] or [key: value]", header)
+	}
+	return strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:]), nil
+}
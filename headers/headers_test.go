@@ -0,0 +1,22 @@
+package headers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.com", nil)
+	assert.NoError(t, err)
+
+	err = Add([]string{"Authorization=Basic dXNlcjpzZWNyZXQ=", "X-Cdn-Key: a:b", "Accept=application/octet-stream"}, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", req.Header.Get("Authorization"))
+	assert.Equal(t, "a:b", req.Header.Get("X-Cdn-Key"))
+	assert.Equal(t, "application/octet-stream", req.Header.Get("Accept"))
+
+	assert.Error(t, Add([]string{"invalid"}, req))
+	assert.Error(t, Add([]string{": value"}, req))
+}
@@ -0,0 +1,283 @@
+// Package helm downloads Helm charts from chart repositories, given as
+// `helm://repository/chart@version`, like
+// `helm://charts.bitnami.com/bitnami/nginx@15.0.0`.
+//
+// The chart is looked up in the index.yaml of the repository. The version is
+// an exact version, a constraint, like `^15.0`, or `latest`, the default.
+// Charts are verified against the digest given by the index.
+//
+// Charts pushed to OCI registries are downloaded with oci:// urls instead.
+package helm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/semver"
+)
+
+// Chart is a version, or a range of versions, of a chart.
+type Chart struct {
+	Repository string
+	Name       string
+	Version    string
+}
+
+// Entry is a version of a chart listed by the index of a repository.
+type Entry struct {
+	Version string   `json:"version"`
+	Digest  string   `json:"digest"`
+	URLs    []string `json:"urls"`
+}
+
+// IsChartURL tells if an url is a helm:// url.
+func IsChartURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "helm://")
+}
+
+// ParseURL parses a helm:// url.
+func ParseURL(rawURL string) (Chart, error) {
+	spec := strings.TrimPrefix(rawURL, "helm://")
+
+	chart := Chart{Version: "latest"}
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		spec, chart.Version = spec[:i], spec[i+1:]
+	}
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		chart.Repository, chart.Name = spec[:i], spec[i+1:]
+	}
+
+	if !IsChartURL(rawURL) || chart.Repository == "" || chart.Name == "" || chart.Version == "" {
+		return Chart{}, fmt.Errorf("Invalid Helm url. Should be helm://repository/chart@version: %s", rawURL)
+	}
+	return chart, nil
+}
+
+// URL gives the helm:// url of the chart.
+func (c Chart) URL() string {
+	return "helm://" + c.Repository + "/" + c.Name + "@" + c.Version
+}
+
+// RepositoryURL gives the url of the repository. Like for OCI registries,
+// repositories on localhost are served over http.
+func (c Chart) RepositoryURL() string {
+	scheme := "https"
+	if host := strings.Split(strings.SplitN(c.Repository, "/", 2)[0], ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	return scheme + "://" + strings.TrimSuffix(c.Repository, "/")
+}
+
+// Find reads the index of the repository and gives the entry of the chart's
+// version. Ranges are resolved to the highest version that matches. The urls
+// of the entry are absolute.
+func Find(ctx context.Context, c Chart, headers []string) (Entry, error) {
+	entries, err := index(ctx, c, headers)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entries) == 0 {
+		return Entry{}, errdefs.Errorf(errdefs.ErrNotFound, "No chart named %s in %s", c.Name, c.RepositoryURL())
+	}
+
+	version := c.Version
+	if version == "latest" || semver.IsConstraint(version) {
+		constraint := version
+		if constraint == "latest" {
+			constraint = "*"
+		}
+
+		parsed, err := semver.ParseConstraint(constraint)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		var versions []string
+		for _, entry := range entries {
+			versions = append(versions, entry.Version)
+		}
+		if version, err = parsed.Highest(versions); err != nil {
+			return Entry{}, errdefs.Errorf(errdefs.ErrNotFound, "No version of %s matches %s", c.Name, c.Version)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.Version != version {
+			continue
+		}
+		if len(entry.URLs) == 0 {
+			return Entry{}, fmt.Errorf("The index of %s gives no url for %s %s", c.RepositoryURL(), c.Name, version)
+		}
+
+		// Urls can be relative to the repository.
+		base, err := url.Parse(c.RepositoryURL() + "/")
+		if err != nil {
+			return Entry{}, err
+		}
+		for i, chartURL := range entry.URLs {
+			relative, err := url.Parse(chartURL)
+			if err != nil {
+				return Entry{}, err
+			}
+			entry.URLs[i] = base.ResolveReference(relative).String()
+		}
+		return entry, nil
+	}
+
+	return Entry{}, errdefs.Errorf(errdefs.ErrNotFound, "No version %s of %s in %s", version, c.Name, c.RepositoryURL())
+}
+
+// index reads the entries of a chart in the index of a repository.
+func index(ctx context.Context, c Chart, headers []string) ([]Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.RepositoryURL()+"/index.yaml", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(headers, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("Unable to read the index of %s: %w", c.RepositoryURL(), &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	reader := bufio.NewReader(resp.Body)
+
+	// Some repositories publish their index as json.
+	if first, err := reader.Peek(1); err == nil && first[0] == '{' {
+		var found struct {
+			Entries map[string][]Entry `json:"entries"`
+		}
+		if err := json.NewDecoder(reader).Decode(&found); err != nil {
+			return nil, fmt.Errorf("Invalid index of %s: %s", c.RepositoryURL(), err)
+		}
+		return found.Entries[c.Name], nil
+	}
+
+	return parseIndex(reader, c.Name)
+}
+
+// parseIndex reads the entries of a chart in an index.yaml. Indexes can list
+// thousands of charts so they are scanned line by line, for the fields of the
+// chart's entries, rather than fully parsed. They are expected to be
+// formatted like the indexes written by Helm:
+//
+//	entries:
+//	  nginx:
+//	  - digest: 1a2b3c...
+//	    urls:
+//	    - https://charts.bitnami.com/bitnami/nginx-15.0.0.tgz
+//	    version: 15.0.0
+func parseIndex(reader io.Reader, name string) ([]Entry, error) {
+	var entries []Entry
+
+	inEntries, inChart := false, false
+	chartIndent, fieldIndent := -1, -1
+	key := ""
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(text) - len(trimmed)
+
+		// Top level keys.
+		if indent == 0 {
+			inEntries, inChart = trimmed == "entries:", false
+			continue
+		}
+		if !inEntries {
+			continue
+		}
+
+		// Chart names.
+		if chartIndent == -1 {
+			chartIndent = indent
+		}
+		if indent == chartIndent && !strings.HasPrefix(trimmed, "- ") {
+			if inChart {
+				break
+			}
+			chartName, _ := splitField(trimmed)
+			inChart = chartName == name
+			continue
+		}
+		if !inChart || indent < chartIndent {
+			continue
+		}
+
+		// Each version of the chart is an item of a list. Its first field
+		// follows the dash.
+		if strings.HasPrefix(trimmed, "- ") && (fieldIndent == -1 || indent < fieldIndent) {
+			entries = append(entries, Entry{})
+			fieldIndent = indent + 2
+			indent, trimmed = fieldIndent, strings.TrimLeft(trimmed[2:], " ")
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		entry := &entries[len(entries)-1]
+
+		switch {
+		case indent == fieldIndent && strings.HasPrefix(trimmed, "- "):
+			if key == "urls" {
+				entry.URLs = append(entry.URLs, unquote(strings.TrimSpace(trimmed[2:])))
+			}
+		case indent == fieldIndent:
+			var value string
+			key, value = splitField(trimmed)
+			switch key {
+			case "version":
+				entry.Version = value
+			case "digest":
+				entry.Digest = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// splitField reads `key: value`.
+func splitField(text string) (string, string) {
+	parts := strings.SplitN(text, ":", 2)
+	if len(parts) == 1 {
+		return unquote(parts[0]), ""
+	}
+	return unquote(strings.TrimSpace(parts[0])), unquote(strings.TrimSpace(parts[1]))
+}
+
+func unquote(value string) string {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+	return value
+}
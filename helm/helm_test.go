@@ -0,0 +1,114 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+const indexYAML = `apiVersion: v1
+entries:
+  memcached:
+  - digest: 0000
+    urls:
+    - memcached-7.0.0.tgz
+    version: 7.0.0
+  nginx:
+  - annotations:
+      category: Infrastructure
+    apiVersion: v2
+    description: |-
+      NGINX Open Source is a web server.
+      - It's also a proxy.
+    digest: "1a2b"
+    maintainers:
+    - name: VMware
+      url: https://github.com/bitnami/charts
+    name: nginx
+    urls:
+    - https://charts.example.com/nginx-15.1.0.tgz
+    version: 15.1.0
+  - digest: 3c4d
+    name: nginx
+    urls:
+    - charts/nginx-15.0.0.tgz
+    version: "15.0.0"
+  - digest: 5e6f
+    urls:
+    - charts/nginx-16.0.0-rc.1.tgz
+    version: 16.0.0-rc.1
+  redis:
+  - digest: ffff
+    urls:
+    - redis-18.0.0.tgz
+    version: 18.0.0
+generated: "2024-01-01T00:00:00Z"
+`
+
+func TestParseURL(t *testing.T) {
+	chart, err := ParseURL("helm://charts.bitnami.com/bitnami/nginx@15.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, Chart{Repository: "charts.bitnami.com/bitnami", Name: "nginx", Version: "15.0.0"}, chart)
+	assert.Equal(t, "https://charts.bitnami.com/bitnami", chart.RepositoryURL())
+
+	chart, err = ParseURL("helm://localhost:8080/nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, "helm://localhost:8080/nginx@latest", chart.URL())
+	assert.Equal(t, "http://localhost:8080", chart.RepositoryURL())
+
+	_, err = ParseURL("helm://nginx@15.0.0")
+	assert.Error(t, err)
+}
+
+func TestParseIndex(t *testing.T) {
+	entries, err := parseIndex(strings.NewReader(indexYAML), "nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Version: "15.1.0", Digest: "1a2b", URLs: []string{"https://charts.example.com/nginx-15.1.0.tgz"}},
+		{Version: "15.0.0", Digest: "3c4d", URLs: []string{"charts/nginx-15.0.0.tgz"}},
+		{Version: "16.0.0-rc.1", Digest: "5e6f", URLs: []string{"charts/nginx-16.0.0-rc.1.tgz"}},
+	}, entries)
+
+	entries, err = parseIndex(strings.NewReader(indexYAML), "unknown")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable/index.yaml":
+			fmt.Fprint(w, indexYAML)
+		case "/json/index.yaml":
+			fmt.Fprint(w, `{"entries":{"nginx":[{"version":"1.0.0","digest":"abcd","urls":["nginx-1.0.0.tgz"]}]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	repository := strings.TrimPrefix(server.URL, "http://")
+
+	entry, err := Find(context.Background(), Chart{Repository: repository + "/stable", Name: "nginx", Version: "latest"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "15.1.0", entry.Version)
+
+	entry, err = Find(context.Background(), Chart{Repository: repository + "/stable", Name: "nginx", Version: "~15.0"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{server.URL + "/stable/charts/nginx-15.0.0.tgz"}, entry.URLs)
+
+	entry, err = Find(context.Background(), Chart{Repository: repository + "/json", Name: "nginx", Version: "1.0.0"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Entry{Version: "1.0.0", Digest: "abcd", URLs: []string{server.URL + "/json/nginx-1.0.0.tgz"}}, entry)
+
+	_, err = Find(context.Background(), Chart{Repository: repository + "/stable", Name: "nginx", Version: "14.0.0"}, nil)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+	_, err = Find(context.Background(), Chart{Repository: repository + "/stable", Name: "unknown", Version: "latest"}, nil)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+}
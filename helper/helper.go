@@ -0,0 +1,163 @@
+// Package helper downloads urls of unknown schemes with helper executables,
+// the way git uses remote helpers. The url `foo://...` is downloaded by the
+// `getme-foo` executable found on the PATH.
+//
+// The helper is given the `get` argument and reads `url=...` then an empty
+// line on stdin. It writes `status=...` lines on stdout, an empty line, then
+// the content of the file:
+//
+//	status=ok
+//	size=1234
+//
+//	<content>
+//
+// The status is `ok`, `not-found`, `unauthorized` or `error`. `size` is
+// optional and `message=...` describes an error.
+package helper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+)
+
+// Name gives the name of the executable that downloads the urls of a scheme.
+func Name(scheme string) string {
+	return "getme-" + scheme
+}
+
+// Find gives the path of the helper of a scheme, if there's one on the PATH.
+func Find(scheme string) (string, bool) {
+	if scheme == "" {
+		return "", false
+	}
+
+	path, err := exec.LookPath(Name(scheme))
+	return path, err == nil
+}
+
+// Open runs a helper to download an url. It gives the size of the file, or
+// -1 if the helper doesn't tell. The helper is killed if the context is
+// cancelled or if the file is closed before it's fully read.
+func Open(ctx context.Context, path string, url string) (io.ReadCloser, int64, error) {
+	cmd := exec.CommandContext(ctx, path, "get")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("url=%s\n\n", url))
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, -1, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, -1, err
+	}
+
+	reader := &helperReader{cmd: cmd, stdout: bufio.NewReader(stdout), name: path}
+
+	size, err := reader.readHeader(url)
+	if err != nil {
+		reader.Close()
+		return nil, -1, err
+	}
+
+	return reader, size, nil
+}
+
+type helperReader struct {
+	cmd    *exec.Cmd
+	stdout *bufio.Reader
+	name   string
+	waited bool
+}
+
+// readHeader reads the `key=value` lines written before the content.
+func (r *helperReader) readHeader(url string) (int64, error) {
+	status, message := "", ""
+	size := int64(-1)
+
+	for {
+		line, err := r.stdout.ReadString('\n')
+		if err != nil {
+			if waitErr := r.wait(); waitErr != nil {
+				return -1, waitErr
+			}
+			return -1, fmt.Errorf("Helper %s gave no content for %s", r.name, url)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "status":
+			status = parts[1]
+		case "message":
+			message = parts[1]
+		case "size":
+			if size, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+				return -1, fmt.Errorf("Helper %s gave an invalid size [%s]", r.name, parts[1])
+			}
+		}
+	}
+
+	if message == "" {
+		message = url
+	}
+
+	switch status {
+	case "ok":
+		return size, nil
+	case "not-found":
+		return -1, errdefs.Errorf(errdefs.ErrNotFound, "Helper %s: %s", r.name, message)
+	case "unauthorized":
+		return -1, errdefs.Errorf(errdefs.ErrUnauthorized, "Helper %s: %s", r.name, message)
+	case "":
+		return -1, fmt.Errorf("Helper %s gave no status for %s", r.name, url)
+	}
+	return -1, fmt.Errorf("Helper %s failed: %s", r.name, message)
+}
+
+// Read reads the content. A helper that fails after it has written part of
+// the content gives an error instead of the end of the file.
+func (r *helperReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (r *helperReader) Close() error {
+	if !r.waited {
+		r.cmd.Process.Kill()
+		r.wait()
+	}
+	return nil
+}
+
+func (r *helperReader) wait() error {
+	if r.waited {
+		return nil
+	}
+	r.waited = true
+
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("Helper %s failed: %s", r.name, err)
+	}
+	return nil
+}
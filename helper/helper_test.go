@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-helper-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	helper := filepath.Join(dir, Name("vault"))
+	script := `#!/bin/sh
+[ "$1" = "get" ] || exit 1
+while read line && [ -n "$line" ]; do
+  case "$line" in url=*) url="${line#url=}";; esac
+done
+case "$url" in
+  vault://store/file) printf 'status=ok\nsize=7\n\ncontent';;
+  vault://store/secret) printf 'status=unauthorized\nmessage=Access denied\n\n';;
+  vault://store/broken) printf 'status=ok\n\npartial'; exit 2;;
+  *) printf 'status=not-found\n\n';;
+esac
+`
+	assert.NoError(t, ioutil.WriteFile(helper, []byte(script), 0755))
+
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	path, found := Find("vault")
+	assert.True(t, found)
+	assert.Equal(t, helper, path)
+
+	_, found = Find("unknown")
+	assert.False(t, found)
+
+	ctx := context.Background()
+
+	reader, size, err := Open(ctx, path, "vault://store/file")
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "content", string(content))
+	assert.Equal(t, int64(7), size)
+
+	_, _, err = Open(ctx, path, "vault://store/missing")
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, _, err = Open(ctx, path, "vault://store/secret")
+	assert.True(t, errors.Is(err, errdefs.ErrUnauthorized))
+	assert.Contains(t, err.Error(), "Access denied")
+
+	reader, size, err = Open(ctx, path, "vault://store/broken")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), size)
+	_, err = ioutil.ReadAll(reader)
+	assert.Error(t, err)
+	reader.Close()
+}
@@ -0,0 +1,125 @@
+// Package hooks runs shell commands before urls are downloaded, to rewrite
+// them, and after files are downloaded, copied or extracted. Commands are
+// templates, like `chmod +x {{.Dest}}/bin/*`.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/dgageot/getme/logs"
+)
+
+// Hooks are the commands run around each operation. They're run in order,
+// and the first one that fails fails the operation.
+type Hooks struct {
+	// PreDownload commands rewrite urls: what they print on stdout, if
+	// anything, replaces the url.
+	PreDownload []string
+	// PostDownload commands are run once a file is in the cache.
+	PostDownload []string
+	// PostCopy commands are run once a file is copied.
+	PostCopy []string
+	// PostExtract commands are run once an archive, or some of its files,
+	// are extracted.
+	PostExtract []string
+}
+
+// Data gives the variables of the commands. They're also given to the
+// commands as $GETME_URL, $GETME_PATH and $GETME_DEST. Since urls and paths
+// can come from untrusted sources, like the clients of `getme serve`, they
+// are quoted when they're used in templates.
+type Data struct {
+	// URL is the url of the file.
+	URL string
+	// Path is where the file is cached.
+	Path string
+	// Dest is the destination of a copy or of an extraction.
+	Dest string
+}
+
+// Rewrite runs the PreDownload commands on an url.
+func (h Hooks) Rewrite(ctx context.Context, url string) (string, error) {
+	for _, command := range h.PreDownload {
+		output, err := run(ctx, command, Data{URL: url})
+		if err != nil {
+			return "", err
+		}
+
+		if rewritten := strings.TrimSpace(output); rewritten != "" && rewritten != url {
+			logs.From(ctx).Infoln("Rewrite", url, "to", rewritten)
+			url = rewritten
+		}
+	}
+	return url, nil
+}
+
+// Run runs commands one after the other. Their output goes to stderr so
+// that it doesn't mix with the output of getme.
+func Run(ctx context.Context, commands []string, data Data) error {
+	for _, command := range commands {
+		output, err := run(ctx, command, data)
+		os.Stderr.WriteString(output)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(ctx context.Context, command string, data Data) (string, error) {
+	tmpl, err := template.New("hook").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("Invalid hook [%s]: %s", command, err)
+	}
+
+	var line bytes.Buffer
+	if err := tmpl.Execute(&line, data.quoted()); err != nil {
+		return "", fmt.Errorf("Invalid hook [%s]: %s", command, err)
+	}
+
+	logs.From(ctx).Debugln("Run hook:", line.String())
+
+	var stdout bytes.Buffer
+	cmd := shell(ctx, line.String())
+	cmd.Env = append(os.Environ(), "GETME_URL="+data.URL, "GETME_PATH="+data.Path, "GETME_DEST="+data.Dest)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("Hook [%s] failed: %s", line.String(), err)
+	}
+	return stdout.String(), nil
+}
+
+// quoted quotes the values so that the shell doesn't interpret them.
+func (d Data) quoted() Data {
+	return Data{
+		URL:  quote("GETME_URL", d.URL),
+		Path: quote("GETME_PATH", d.Path),
+		Dest: quote("GETME_DEST", d.Dest),
+	}
+}
+
+// quote quotes a value for sh. cmd can't quote everything, so the value is
+// instead read from its env variable, with a delayed expansion that happens
+// once the command is parsed.
+func quote(env, value string) string {
+	if runtime.GOOS == "windows" {
+		return `"!` + env + `!"`
+	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func shell(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/V:ON", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
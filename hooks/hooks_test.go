@@ -0,0 +1,68 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewrite(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Requires a shell")
+	}
+
+	hooks := Hooks{PreDownload: []string{
+		"echo {{.URL}} | sed s/example.com/mirror.example.com/",
+		"true",
+	}}
+
+	url, err := hooks.Rewrite(context.Background(), "https://example.com/tool.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mirror.example.com/tool.tgz", url)
+
+	_, err = Hooks{PreDownload: []string{"false"}}.Rewrite(context.Background(), "https://example.com/tool.tgz")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-hooks-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := Data{URL: "https://example.com/tool.tgz", Path: "/cache/tool.tgz", Dest: dir}
+	err = Run(context.Background(), []string{
+		"echo {{.URL}} > {{.Dest}}/template",
+		`echo "$GETME_PATH" > "$GETME_DEST/env"`,
+	}, data)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "template"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/tool.tgz\n", string(content))
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "env"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/cache/tool.tgz\n", string(content))
+
+	// Values can't inject commands.
+	evil := Data{URL: "https://example.com/$(touch pwned)';touch pwned;'`touch pwned`", Dest: dir}
+	err = Run(context.Background(), []string{"echo {{.URL}} > {{.Dest}}/evil", "cd {{.Dest}} && test ! -e pwned"}, evil)
+	assert.NoError(t, err)
+	content, err = ioutil.ReadFile(filepath.Join(dir, "evil"))
+	assert.NoError(t, err)
+	assert.Equal(t, evil.URL+"\n", string(content))
+
+	assert.Error(t, Run(context.Background(), []string{"echo {{.Unknown}}"}, data))
+	assert.Error(t, Run(context.Background(), []string{"exit 1", "touch {{.Dest}}/never"}, data))
+	_, err = os.Stat(filepath.Join(dir, "never"))
+	assert.True(t, os.IsNotExist(err))
+}
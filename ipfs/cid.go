@@ -0,0 +1,155 @@
+package ipfs
+
+import (
+	"bytes"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Codecs of the blocks understood by getme.
+const (
+	codecRaw    = 0x55
+	codecDagPB  = 0x70
+	hashSha256  = 0x12
+	sha256Size  = 32
+	cidVersion1 = 1
+)
+
+// CID is a content identifier. Only sha2-256 hashes are supported.
+type CID struct {
+	Codec  uint64
+	Digest []byte
+}
+
+var base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ParseCID parses either a CIDv0 (`Qm...`) or a base32 CIDv1 (`bafy...`).
+func ParseCID(value string) (CID, error) {
+	if strings.HasPrefix(value, "Qm") && len(value) == 46 {
+		decoded, err := decodeBase58(value)
+		if err != nil {
+			return CID{}, fmt.Errorf("Invalid CID %s: %s", value, err)
+		}
+		return cidFromMultihash(codecDagPB, decoded)
+	}
+
+	if strings.HasPrefix(value, "b") {
+		decoded, err := base32Encoding.DecodeString(strings.ToUpper(value[1:]))
+		if err != nil {
+			return CID{}, fmt.Errorf("Invalid CID %s: %s", value, err)
+		}
+		cid, err := decodeCID(decoded)
+		if err != nil {
+			return CID{}, fmt.Errorf("Invalid CID %s: %s", value, err)
+		}
+		return cid, nil
+	}
+
+	return CID{}, fmt.Errorf("Unsupported CID %s. Only CIDv0 and base32 CIDv1 are supported", value)
+}
+
+// decodeCID decodes a binary CID, as found in dag-pb links.
+func decodeCID(value []byte) (CID, error) {
+	// A CIDv0 is a bare multihash.
+	if len(value) == 2+sha256Size && value[0] == hashSha256 {
+		return cidFromMultihash(codecDagPB, value)
+	}
+
+	reader := bytes.NewReader(value)
+	version, err := readVarint(reader)
+	if err != nil {
+		return CID{}, err
+	}
+	if version != cidVersion1 {
+		return CID{}, fmt.Errorf("unsupported CID version %d", version)
+	}
+
+	codec, err := readVarint(reader)
+	if err != nil {
+		return CID{}, err
+	}
+
+	multihash := make([]byte, reader.Len())
+	reader.Read(multihash)
+
+	return cidFromMultihash(codec, multihash)
+}
+
+func cidFromMultihash(codec uint64, multihash []byte) (CID, error) {
+	if len(multihash) != 2+sha256Size || multihash[0] != hashSha256 || multihash[1] != sha256Size {
+		return CID{}, errors.New("only sha2-256 hashes are supported")
+	}
+
+	return CID{Codec: codec, Digest: multihash[2:]}, nil
+}
+
+// Bytes gives the binary CIDv1 form of the CID.
+func (c CID) Bytes() []byte {
+	var buffer bytes.Buffer
+	writeVarint(&buffer, cidVersion1)
+	writeVarint(&buffer, c.Codec)
+	buffer.WriteByte(hashSha256)
+	buffer.WriteByte(sha256Size)
+	buffer.Write(c.Digest)
+
+	return buffer.Bytes()
+}
+
+// String gives the base32 CIDv1 form of the CID.
+func (c CID) String() string {
+	return "b" + strings.ToLower(base32Encoding.EncodeToString(c.Bytes()))
+}
+
+func decodeBase58(value string) ([]byte, error) {
+	number := big.NewInt(0)
+	radix := big.NewInt(58)
+
+	for _, char := range value {
+		index := strings.IndexRune(base58Alphabet, char)
+		if index == -1 {
+			return nil, fmt.Errorf("invalid base58 character %q", char)
+		}
+		number.Mul(number, radix)
+		number.Add(number, big.NewInt(int64(index)))
+	}
+
+	decoded := number.Bytes()
+
+	// Leading ones encode leading zeros.
+	for _, char := range value {
+		if char != '1' {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+
+	return decoded, nil
+}
+
+func readVarint(reader *bytes.Reader) (uint64, error) {
+	var value uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, errors.New("truncated varint")
+		}
+		value |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("varint overflow")
+}
+
+func writeVarint(buffer *bytes.Buffer, value uint64) {
+	for value >= 0x80 {
+		buffer.WriteByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	buffer.WriteByte(byte(value))
+}
@@ -0,0 +1,119 @@
+package ipfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// UnixFS node types.
+const (
+	unixfsRaw       = 0
+	unixfsDirectory = 1
+	unixfsFile      = 2
+)
+
+// node is a decoded dag-pb block holding a UnixFS node.
+type node struct {
+	Type  uint64
+	Data  []byte
+	Links []link
+}
+
+type link struct {
+	CID  CID
+	Name string
+}
+
+// decodeNode decodes the protobuf encoding of a dag-pb block:
+// PBNode { Data = 1; Links = 2 } and PBLink { Hash = 1; Name = 2; Tsize = 3 }.
+// The node's Data is itself a UnixFS message: { Type = 1; Data = 2; ... }.
+func decodeNode(block []byte) (*node, error) {
+	result := &node{Type: unixfsFile}
+
+	err := decodeFields(block, func(field uint64, value []byte) error {
+		switch field {
+		case 1:
+			return decodeFields(value, func(field uint64, value []byte) error {
+				switch field {
+				case 1:
+					kind, err := readVarint(bytes.NewReader(value))
+					if err != nil {
+						return err
+					}
+					result.Type = kind
+				case 2:
+					result.Data = value
+				}
+				return nil
+			})
+		case 2:
+			var l link
+			err := decodeFields(value, func(field uint64, value []byte) error {
+				switch field {
+				case 1:
+					cid, err := decodeCID(value)
+					if err != nil {
+						return err
+					}
+					l.CID = cid
+				case 2:
+					l.Name = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			result.Links = append(result.Links, l)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Invalid dag-pb block: %s", err)
+	}
+
+	return result, nil
+}
+
+// decodeFields walks the fields of a protobuf message. Varint values are given
+// re-encoded so that a single callback handles every wire type used by dag-pb.
+func decodeFields(message []byte, callback func(field uint64, value []byte) error) error {
+	reader := bytes.NewReader(message)
+	for reader.Len() > 0 {
+		key, err := readVarint(reader)
+		if err != nil {
+			return err
+		}
+
+		var value []byte
+		switch key & 7 {
+		case 0:
+			number, err := readVarint(reader)
+			if err != nil {
+				return err
+			}
+			var buffer bytes.Buffer
+			writeVarint(&buffer, number)
+			value = buffer.Bytes()
+		case 2:
+			length, err := readVarint(reader)
+			if err != nil {
+				return err
+			}
+			if length > uint64(reader.Len()) {
+				return errors.New("truncated field")
+			}
+			value = make([]byte, length)
+			reader.Read(value)
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+
+		if err := callback(key>>3, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
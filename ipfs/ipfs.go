@@ -0,0 +1,159 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGateway is used when no gateway is configured.
+const DefaultGateway = "https://ipfs.io"
+
+// Options configures access to IPFS.
+type Options struct {
+	Gateway string
+}
+
+// Open downloads a file given an `ipfs://CID` or an `ipfs://CID/path/in/directory`
+// url. Blocks are fetched one by one from a trustless gateway and each one is
+// checked against its CID, so that the gateway doesn't need to be trusted.
+func Open(ctx context.Context, u *url.URL, options Options) (io.ReadCloser, error) {
+	root, err := ParseCID(u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := strings.TrimSuffix(options.Gateway, "/")
+	if gateway == "" {
+		gateway = DefaultGateway
+	}
+	fetcher := &fetcher{ctx: ctx, gateway: gateway}
+
+	cid, err := fetcher.resolve(root, strings.Split(strings.Trim(u.Path, "/"), "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(fetcher.write(cid, writer))
+	}()
+
+	return reader, nil
+}
+
+// fetcher fetches blocks until its context is cancelled. The context is kept
+// since the file is written after Open returns.
+type fetcher struct {
+	ctx     context.Context
+	gateway string
+}
+
+// resolve walks down directories to find the CID of a file.
+func (f *fetcher) resolve(cid CID, path []string) (CID, error) {
+	for _, name := range path {
+		if name == "" {
+			continue
+		}
+
+		block, err := f.block(cid)
+		if err != nil {
+			return CID{}, err
+		}
+		if cid.Codec != codecDagPB {
+			return CID{}, fmt.Errorf("%s is not a directory", cid)
+		}
+
+		node, err := decodeNode(block)
+		if err != nil {
+			return CID{}, err
+		}
+		if node.Type != unixfsDirectory {
+			return CID{}, fmt.Errorf("%s is not a directory", cid)
+		}
+
+		found := false
+		for _, link := range node.Links {
+			if link.Name == name {
+				cid, found = link.CID, true
+				break
+			}
+		}
+		if !found {
+			return CID{}, fmt.Errorf("%s not found in %s", name, cid)
+		}
+	}
+
+	return cid, nil
+}
+
+// write writes the content of a file, depth first.
+func (f *fetcher) write(cid CID, writer io.Writer) error {
+	block, err := f.block(cid)
+	if err != nil {
+		return err
+	}
+
+	switch cid.Codec {
+	case codecRaw:
+		_, err := writer.Write(block)
+		return err
+	case codecDagPB:
+		node, err := decodeNode(block)
+		if err != nil {
+			return err
+		}
+		if node.Type != unixfsFile && node.Type != unixfsRaw {
+			return fmt.Errorf("%s is not a file", cid)
+		}
+
+		if _, err := writer.Write(node.Data); err != nil {
+			return err
+		}
+		for _, link := range node.Links {
+			if err := f.write(link.CID, writer); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Unsupported codec 0x%x for %s", cid.Codec, cid)
+}
+
+// block fetches a single raw block and checks its hash.
+func (f *fetcher) block(cid CID) ([]byte, error) {
+	req, err := http.NewRequestWithContext(f.ctx, "GET", f.gateway+"/ipfs/"+cid.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("Unable to fetch %s from %s: %s", cid, f.gateway, resp.Status)
+	}
+
+	block, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(block)
+	if !bytes.Equal(sum[:], cid.Digest) {
+		return nil, fmt.Errorf("Block %s served by %s doesn't match its CID", cid, f.gateway)
+	}
+
+	return block, nil
+}
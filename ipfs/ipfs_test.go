@@ -0,0 +1,128 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCID(t *testing.T) {
+	// The empty UnixFS directory.
+	cid, err := ParseCID("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(codecDagPB), cid.Codec)
+	assert.Equal(t, "bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354", cid.String())
+
+	cid, err = ParseCID("bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(codecDagPB), cid.Codec)
+
+	_, err = ParseCID("zdj7WWeQ43G6JJvLWQWZpyHuAMq6uYWRjkBXFad11vE2LHhQ7")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	gateway := newFakeGateway()
+	first := gateway.add(codecRaw, []byte("hello "))
+	second := gateway.add(codecRaw, []byte("world"))
+	file := gateway.add(codecDagPB, dagNode(unixfsFile, []link{{CID: first}, {CID: second}}))
+	directory := gateway.add(codecDagPB, dagNode(unixfsDirectory, []link{{CID: file, Name: "hello.txt"}}))
+
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	content, err := read("ipfs://"+file.String(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", content)
+
+	content, err = read("ipfs://"+directory.String()+"/hello.txt", server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", content)
+
+	_, err = read("ipfs://"+directory.String()+"/unknown.txt", server.URL)
+	assert.Error(t, err)
+}
+
+func TestOpenTamperedBlock(t *testing.T) {
+	gateway := newFakeGateway()
+	cid := gateway.add(codecRaw, []byte("hello"))
+	gateway.blocks[cid.String()] = []byte("tampered")
+
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	_, err := read("ipfs://"+cid.String(), server.URL)
+	assert.Error(t, err)
+}
+
+func read(rawURL, gateway string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := Open(context.Background(), u, Options{Gateway: gateway})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
+
+type fakeGateway struct {
+	blocks map[string][]byte
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{blocks: map[string][]byte{}}
+}
+
+func (g *fakeGateway) add(codec uint64, block []byte) CID {
+	sum := sha256.Sum256(block)
+	cid := CID{Codec: codec, Digest: sum[:]}
+	g.blocks[cid.String()] = block
+	return cid
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	block, ok := g.blocks[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+	if !ok || r.URL.Query().Get("format") != "raw" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(block)
+}
+
+// dagNode encodes a dag-pb block holding a UnixFS node.
+func dagNode(kind uint64, links []link) []byte {
+	var node bytes.Buffer
+	for _, l := range links {
+		var encodedLink bytes.Buffer
+		writeField(&encodedLink, 1, l.CID.Bytes())
+		writeField(&encodedLink, 2, []byte(l.Name))
+		writeField(&node, 2, encodedLink.Bytes())
+	}
+
+	var unixfs bytes.Buffer
+	writeVarint(&unixfs, 1<<3)
+	writeVarint(&unixfs, kind)
+	writeField(&node, 1, unixfs.Bytes())
+
+	return node.Bytes()
+}
+
+func writeField(buffer *bytes.Buffer, field uint64, value []byte) {
+	writeVarint(buffer, field<<3|2)
+	writeVarint(buffer, uint64(len(value)))
+	buffer.Write(value)
+}
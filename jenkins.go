@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/jenkins"
+	"github.com/dgageot/getme/logs"
+	"github.com/spf13/pflag"
+)
+
+// JenkinsBuildAndGet triggers a build of a Jenkins job and downloads its
+// artifact. If the url of the artifact doesn't depend on the build number
+// and the artifact exists, no build is triggered.
+func JenkinsBuildAndGet(ctx context.Context, server jenkins.Server, job string, params map[string]string, urlTemplate string, options files.Options) error {
+	if !strings.Contains(urlTemplate, ".Number") {
+		url, err := jenkins.Build{Job: job, Params: params}.ArtifactURL(urlTemplate)
+		if err != nil {
+			return err
+		}
+
+		err = Download(ctx, url, options)
+		if !errors.Is(err, errdefs.ErrNotFound) {
+			return err
+		}
+		logs.Infoln("Building", url)
+	}
+
+	build, err := server.Run(ctx, job, params)
+	if err != nil {
+		return err
+	}
+
+	url, err := build.ArtifactURL(urlTemplate)
+	if err != nil {
+		return err
+	}
+	return Download(ctx, url, options)
+}
+
+// JenkinsArtifact downloads the artifacts of a Jenkins build that match a
+// glob pattern, then prints the paths to the cached files. The credentials
+// of the server are used, unless others are given.
+func JenkinsArtifact(ctx context.Context, server jenkins.Server, job, build, pattern string, options files.Options) error {
+	artifacts, err := server.Artifacts(ctx, job, build, pattern)
+	if err != nil {
+		return err
+	}
+
+	if options.User == "" && server.Token != "" {
+		options.User = server.User + ":" + server.Token
+	}
+
+	var results []downloadResult
+	for _, artifact := range artifacts {
+		result, err := download(ctx, artifact.URL, options)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	return printDownloads(results, len(results) == 1)
+}
+
+// Pinata downloads the iso of a Pinata build, building it with Jenkins if
+// it doesn't exist.
+func Pinata(ctx context.Context, server jenkins.Server, bucket, isocommit, commit, platform string, options files.Options) error {
+	binary := fmt.Sprintf("https://storage.googleapis.com/%s/%s/docker-for-%s.iso.tgz", bucket, isocommit, platform)
+
+	return JenkinsBuildAndGet(ctx, server, fmt.Sprintf("pinata-%s-iso", platform), map[string]string{"COMMIT_ID": commit}, binary, options)
+}
+
+// addJenkinsFlags adds the flags that configure the Jenkins server and how
+// to wait for builds. Like every flag, they can be given with env variables,
+// like $GETME_JENKINS_TOKEN.
+func addJenkinsFlags(flags *pflag.FlagSet, server *jenkins.Server) {
+	flags.StringVar(&server.URL, "jenkins-url", "", "Url of the Jenkins server")
+	flags.StringVar(&server.User, "jenkins-user", "", "Jenkins user name")
+	flags.StringVar(&server.Token, "jenkins-token", "", "Jenkins api token or password. Prefer $GETME_JENKINS_TOKEN")
+	flags.DurationVar(&server.PollInterval, "poll-interval", 5*time.Second, "How often to check the queue and the build")
+	flags.DurationVar(&server.QueueTimeout, "queue-timeout", 0, "How long a build can wait in the queue, like 10m. No limit by default")
+	flags.DurationVar(&server.BuildTimeout, "build-timeout", 0, "How long a build can run, like 1h. No limit by default")
+}
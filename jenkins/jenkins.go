@@ -0,0 +1,396 @@
+// Package jenkins triggers Jenkins builds and waits for them to finish, so
+// that their artifacts can be downloaded.
+package jenkins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/logs"
+	"github.com/gobwas/glob"
+)
+
+// Server is a Jenkins server. User and Token are optional.
+type Server struct {
+	URL   string
+	User  string
+	Token string
+
+	// HTTPClient, when set, is used instead of http.DefaultClient.
+	HTTPClient *http.Client
+
+	// PollInterval is how often the queue and the builds are checked.
+	// Defaults to 5 seconds.
+	PollInterval time.Duration
+	// QueueTimeout and BuildTimeout limit how long a build can wait in the
+	// queue and run. There is no limit by default.
+	QueueTimeout time.Duration
+	BuildTimeout time.Duration
+}
+
+const defaultPollInterval = 5 * time.Second
+
+// Build is a finished build of a job.
+type Build struct {
+	Job    string
+	Number int64
+	URL    string
+	Params map[string]string
+}
+
+// Artifact is a file archived by a build.
+type Artifact struct {
+	// Path is relative to the artifacts of the build, like
+	// `dist/tool.tar.gz`.
+	Path string
+	URL  string
+}
+
+type buildResponse struct {
+	Number    int64  `json:"number"`
+	URL       string `json:"url"`
+	Building  bool   `json:"building"`
+	Result    string `json:"result"`
+	Artifacts []struct {
+		RelativePath string `json:"relativePath"`
+	} `json:"artifacts"`
+}
+
+type jobResponse struct {
+	Property []struct {
+		ParameterDefinitions []struct {
+			Name string `json:"name"`
+		} `json:"parameterDefinitions"`
+	} `json:"property"`
+}
+
+type queueItemResponse struct {
+	Cancelled  bool   `json:"cancelled"`
+	Why        string `json:"why"`
+	Executable *struct {
+		Number int64 `json:"number"`
+	} `json:"executable"`
+}
+
+// ParseParams reads build parameters given as `key=value`.
+func ParseParams(params []string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, param := range params {
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid param [%s]. Should be [key=value]", param)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+// Run triggers a build of a job with parameters, then waits for it to
+// finish. Jobs in folders are given as `folder/job`. A build that doesn't
+// succeed is an error.
+func (s Server) Run(ctx context.Context, job string, params map[string]string) (*Build, error) {
+	var details jobResponse
+	if err := s.get(ctx, jobPath(job), nil, &details); err != nil {
+		return nil, fmt.Errorf("Unable to find the job %s: %w", job, err)
+	}
+
+	logs.Infoln("Trigger a build of", job)
+	itemID, err := s.trigger(ctx, job, params, len(details.Property) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The queue item tells which build it became, once it leaves the queue.
+	logs.Infoln("Waiting for queue")
+	var number int64
+	err = s.poll(ctx, s.QueueTimeout, "waiting for the build of "+job+" to leave the queue", func(ctx context.Context) (bool, error) {
+		var item queueItemResponse
+		if err := s.get(ctx, "/queue/item/"+strconv.FormatInt(itemID, 10), nil, &item); err != nil {
+			return false, err
+		}
+		if item.Cancelled {
+			return false, fmt.Errorf("The build of %s was cancelled", job)
+		}
+		if item.Executable == nil {
+			logs.Debugln("Build of", job, "is queued:", item.Why)
+			return false, nil
+		}
+
+		number = item.Executable.Number
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var build buildResponse
+	err = s.poll(ctx, s.BuildTimeout, fmt.Sprintf("waiting for %s #%d to finish", job, number), func(ctx context.Context) (bool, error) {
+		if err := s.get(ctx, buildPath(job, number), nil, &build); err != nil {
+			return false, err
+		}
+		if build.Building {
+			logs.Infoln("Job is running, waiting...")
+		}
+		return !build.Building, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if build.Result != "SUCCESS" {
+		return nil, fmt.Errorf("Build %s #%d failed: %s", job, number, build.Result)
+	}
+
+	return &Build{Job: job, Number: number, URL: build.URL, Params: params}, nil
+}
+
+// ArtifactURL expands the url template of an artifact with the job, the
+// number and the parameters of a build, like
+// `https://storage.example.com/{{.Params.COMMIT_ID}}/tool.tgz`.
+func (b Build) ArtifactURL(urlTemplate string) (string, error) {
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(urlTemplate)
+	if err != nil {
+		return "", fmt.Errorf("Invalid artifact url template %s: %s", urlTemplate, err)
+	}
+
+	var expanded bytes.Buffer
+	if err := tmpl.Execute(&expanded, b); err != nil {
+		return "", fmt.Errorf("Invalid artifact url template %s: %s", urlTemplate, err)
+	}
+
+	return expanded.String(), nil
+}
+
+// Artifacts lists the artifacts of a build that match a glob pattern, like
+// `dist/*.tar.gz`. Builds are given by number, or as lastSuccessful,
+// lastStable, lastCompleted or last.
+func (s Server) Artifacts(ctx context.Context, job string, build string, pattern string) ([]Artifact, error) {
+	ref, err := buildRef(build)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := glob.Compile(pattern, '/')
+	if err != nil {
+		return nil, fmt.Errorf("Invalid artifact pattern %s: %s", pattern, err)
+	}
+
+	var details buildResponse
+	if err := s.get(ctx, jobPath(job)+"/"+ref, nil, &details); err != nil {
+		return nil, fmt.Errorf("Unable to find the %s build of %s: %w", build, job, err)
+	}
+
+	var artifacts []Artifact
+	var paths []string
+	for _, artifact := range details.Artifacts {
+		paths = append(paths, artifact.RelativePath)
+		if !match.Match(artifact.RelativePath) {
+			continue
+		}
+
+		artifacts = append(artifacts, Artifact{
+			Path: artifact.RelativePath,
+			URL:  strings.TrimSuffix(s.URL, "/") + buildPath(job, details.Number) + "/artifact/" + escapePath(artifact.RelativePath),
+		})
+	}
+
+	if len(artifacts) == 0 {
+		return nil, errdefs.Errorf(errdefs.ErrNotFound, "No artifact of %s #%d matches %s. Artifacts are [%s]", job, details.Number, pattern, strings.Join(paths, ", "))
+	}
+	return artifacts, nil
+}
+
+// buildRef gives how a build is referred to in urls.
+func buildRef(build string) (string, error) {
+	if _, err := strconv.ParseInt(build, 10, 64); err == nil {
+		return build, nil
+	}
+
+	switch name := strings.TrimSuffix(build, "Build"); name {
+	case "last", "lastSuccessful", "lastStable", "lastCompleted", "lastFailed", "lastUnstable", "lastUnsuccessful":
+		return name + "Build", nil
+	}
+	return "", fmt.Errorf("Invalid build [%s]. Should be a number, lastSuccessful, lastStable, lastCompleted or last", build)
+}
+
+// trigger queues a build and gives the id of the queue item.
+func (s Server) trigger(ctx context.Context, job string, params map[string]string, parameterized bool) (int64, error) {
+	endpoint := jobPath(job) + "/build"
+	if parameterized {
+		endpoint = jobPath(job) + "/buildWithParameters"
+	}
+
+	form := url.Values{}
+	for key, value := range params {
+		form.Set(key, value)
+	}
+
+	resp, err := s.post(ctx, endpoint, form)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("Unable to trigger a build of %s: %w", job, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	// The location is the queue item, like `https://jenkins/queue/item/42/`.
+	location := strings.TrimSuffix(resp.Header.Get("Location"), "/")
+	id, err := strconv.ParseInt(location[strings.LastIndex(location, "/")+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to find the queue item of %s in [%s]", job, location)
+	}
+	return id, nil
+}
+
+// get reads the json api of a path.
+func (s Server) get(ctx context.Context, path string, query url.Values, value interface{}) error {
+	endpoint := strings.TrimSuffix(s.URL, "/") + path + "/api/json"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+// post sends a form. Jenkins protects against CSRF with a crumb that must be
+// sent with every post, unless it's disabled. The crumb is only valid for
+// the session it's issued in.
+func (s Server) post(ctx context.Context, path string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(s.URL, "/")+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	crumbReq, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(s.URL, "/")+"/crumbIssuer/api/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(crumbReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// CSRF protection is disabled.
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("Unable to get a crumb: %w", &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	default:
+		var crumb struct {
+			Field string `json:"crumbRequestField"`
+			Crumb string `json:"crumb"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&crumb); err != nil {
+			return nil, fmt.Errorf("Unable to get a crumb: %s", err)
+		}
+		req.Header.Set(crumb.Field, crumb.Crumb)
+		for _, cookie := range resp.Cookies() {
+			req.AddCookie(cookie)
+		}
+	}
+
+	return s.do(req)
+}
+
+func (s Server) do(req *http.Request) (*http.Response, error) {
+	if s.User != "" || s.Token != "" {
+		req.SetBasicAuth(s.User, s.Token)
+	}
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// jobPath gives the path of a job, which can be in folders, like
+// `/job/folder/job/name`.
+func jobPath(job string) string {
+	var path string
+	for _, name := range strings.Split(job, "/") {
+		path += "/job/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func buildPath(job string, number int64) string {
+	return jobPath(job) + "/" + strconv.FormatInt(number, 10)
+}
+
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// poll checks something every PollInterval until it's done. A timeout, if
+// any, limits how long it can take. The context given to check expires with
+// the timeout.
+func (s Server) poll(ctx context.Context, timeout time.Duration, what string, check func(context.Context) (bool, error)) error {
+	pollCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	for {
+		done, err := check(pollCtx)
+		if err == nil && done {
+			return nil
+		}
+		if err == nil {
+			err = sleep(pollCtx, interval)
+		}
+		if err != nil {
+			if ctx.Err() == nil && pollCtx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("Timed out after %s %s", timeout, what)
+			}
+			return err
+		}
+	}
+}
+
+// sleep waits for a given duration, unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
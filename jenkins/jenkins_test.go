@@ -0,0 +1,146 @@
+package jenkins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeJenkins is a Jenkins server with a job, in a folder, whose builds
+// succeed unless their COMMIT_ID is `broken`. Builds can be stuck in the
+// queue or running. Queue item 100+n becomes build n.
+type fakeJenkins struct {
+	builds   []map[string]interface{}
+	queued   bool
+	building bool
+}
+
+func (f *fakeJenkins) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(value interface{}) {
+		json.NewEncoder(w).Encode(value)
+	}
+
+	var id int
+	switch {
+	case r.URL.Path == "/crumbIssuer/api/json":
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "session"})
+		reply(map[string]string{"crumbRequestField": "Jenkins-Crumb", "crumb": "crumb"})
+	case r.URL.Path == "/job/team/job/tool/api/json":
+		reply(map[string]interface{}{
+			"property": []interface{}{map[string]interface{}{"parameterDefinitions": []interface{}{map[string]string{"name": "COMMIT_ID"}}}},
+		})
+	case r.URL.Path == "/job/team/job/tool/buildWithParameters":
+		if session, err := r.Cookie("JSESSIONID"); err != nil || session.Value != "session" || r.Header.Get("Jenkins-Crumb") != "crumb" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		result := "SUCCESS"
+		if r.FormValue("COMMIT_ID") == "broken" {
+			result = "FAILURE"
+		}
+		f.builds = append(f.builds, map[string]interface{}{"result": result, "building": f.building})
+		w.Header().Set("Location", fmt.Sprintf("http://jenkins/queue/item/%d/", 100+len(f.builds)))
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path == "/job/team/job/tool/lastSuccessfulBuild/api/json":
+		reply(map[string]interface{}{
+			"number":    3,
+			"artifacts": []interface{}{map[string]string{"relativePath": "dist/tool linux.tar.gz"}, map[string]string{"relativePath": "dist/tool.zip"}, map[string]string{"relativePath": "README.md"}},
+		})
+	case scan(r.URL.Path, "/queue/item/%d/api/json", &id):
+		if f.queued {
+			reply(map[string]interface{}{"why": "Waiting for next available executor"})
+		} else {
+			reply(map[string]interface{}{"executable": map[string]interface{}{"number": id - 100}})
+		}
+	case scan(r.URL.Path, "/job/team/job/tool/%d/api/json", &id) && id <= len(f.builds):
+		reply(f.builds[id-1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func scan(path string, format string, id *int) bool {
+	_, err := fmt.Sscanf(path, format, id)
+	return err == nil
+}
+
+func TestRun(t *testing.T) {
+	jenkins := httptest.NewServer(&fakeJenkins{})
+	defer jenkins.Close()
+	server := Server{URL: jenkins.URL}
+
+	build, err := server.Run(context.Background(), "team/tool", map[string]string{"COMMIT_ID": "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), build.Number)
+
+	url, err := build.ArtifactURL("https://storage.example.com/{{.Params.COMMIT_ID}}/tool-{{.Number}}.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage.example.com/abc/tool-1.tgz", url)
+
+	_, err = build.ArtifactURL("https://storage.example.com/{{.Params.VERSION}}/tool.tgz")
+	assert.Error(t, err)
+
+	// Builds of the same commit are told apart.
+	build, err = server.Run(context.Background(), "team/tool", map[string]string{"COMMIT_ID": "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), build.Number)
+
+	_, err = server.Run(context.Background(), "team/tool", map[string]string{"COMMIT_ID": "broken"})
+	assert.EqualError(t, err, "Build team/tool #3 failed: FAILURE")
+}
+
+func TestTimeouts(t *testing.T) {
+	jenkins := httptest.NewServer(&fakeJenkins{queued: true})
+	defer jenkins.Close()
+	server := Server{URL: jenkins.URL, PollInterval: 10 * time.Millisecond, QueueTimeout: 50 * time.Millisecond}
+
+	_, err := server.Run(context.Background(), "team/tool", map[string]string{"COMMIT_ID": "abc"})
+	assert.EqualError(t, err, "Timed out after 50ms waiting for the build of team/tool to leave the queue")
+
+	jenkins = httptest.NewServer(&fakeJenkins{building: true})
+	defer jenkins.Close()
+	server = Server{URL: jenkins.URL, PollInterval: 10 * time.Millisecond, BuildTimeout: 50 * time.Millisecond}
+
+	_, err = server.Run(context.Background(), "team/tool", map[string]string{"COMMIT_ID": "abc"})
+	assert.EqualError(t, err, "Timed out after 50ms waiting for team/tool #1 to finish")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	server.BuildTimeout = 0
+
+	_, err = server.Run(ctx, "team/tool", map[string]string{"COMMIT_ID": "abc"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestArtifacts(t *testing.T) {
+	jenkins := httptest.NewServer(&fakeJenkins{})
+	defer jenkins.Close()
+	server := Server{URL: jenkins.URL}
+
+	artifacts, err := server.Artifacts(context.Background(), "team/tool", "lastSuccessful", "dist/*.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, []Artifact{{Path: "dist/tool linux.tar.gz", URL: jenkins.URL + "/job/team/job/tool/3/artifact/dist/tool%20linux.tar.gz"}}, artifacts)
+
+	_, err = server.Artifacts(context.Background(), "team/tool", "lastSuccessfulBuild", "*.tar.gz")
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = server.Artifacts(context.Background(), "team/tool", "latest", "*")
+	assert.Error(t, err)
+}
+
+func TestParseParams(t *testing.T) {
+	params, err := ParseParams([]string{"COMMIT_ID=abc", "FLAGS=a=b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"COMMIT_ID": "abc", "FLAGS": "a=b"}, params)
+
+	_, err = ParseParams([]string{"COMMIT_ID"})
+	assert.Error(t, err)
+}
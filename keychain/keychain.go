@@ -0,0 +1,37 @@
+package keychain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Service is the name under which getme stores its tokens.
+const Service = "getme"
+
+// Lookup finds the token stored for a host in the keychain of the OS. The
+// port, if any, is part of the host.
+func Lookup(host string) (string, bool) {
+	if host == "" {
+		return "", false
+	}
+
+	token, err := lookup(host)
+	if err != nil || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// Store saves the token of a host in the keychain of the OS: the macOS
+// Keychain, the Windows Credential Manager or the Secret Service.
+func Store(host, token string) error {
+	if host == "" {
+		return errors.New("A host must be provided")
+	}
+	if token == "" {
+		return errors.New("A token must be provided")
+	}
+
+	return store(host, strings.TrimSpace(token))
+}
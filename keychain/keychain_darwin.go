@@ -0,0 +1,30 @@
+package keychain
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+func lookup(host string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", Service, "-a", host, "-w").Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// store gives the token through stdin so that it doesn't show in the list of
+// processes.
+func store(host, token string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", Service, strconv.Quote(host), strconv.Quote(token)))
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Unable to store the token in the keychain: %s %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
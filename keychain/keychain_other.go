@@ -0,0 +1,32 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package keychain
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The Secret Service is reached with secret-tool, from libsecret.
+
+func lookup(host string) (string, error) {
+	output, err := exec.Command("secret-tool", "lookup", "service", Service, "host", host).Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func store(host, token string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", Service+" "+host, "service", Service, "host", host)
+	cmd.Stdin = strings.NewReader(token)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Unable to store the token with secret-tool: %s %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package keychain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupNoHost(t *testing.T) {
+	_, found := Lookup("")
+	assert.False(t, found)
+}
+
+func TestStoreInvalid(t *testing.T) {
+	assert.Error(t, Store("", "token"))
+	assert.Error(t, Store("example.com", ""))
+}
@@ -0,0 +1,248 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Level is the verbosity of the logs.
+type Level int
+
+const (
+	// Quiet only reports errors.
+	Quiet Level = iota - 1
+	// Info tells what is downloaded, copied or extracted.
+	Info
+	// Debug adds the decisions, like cache hits.
+	Debug
+	// Trace adds the http requests.
+	Trace
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Trace:
+		return "trace"
+	}
+	return "info"
+}
+
+// Fields describe an event.
+type Fields map[string]interface{}
+
+// Logger receives the logs. *log.Logger implements it.
+type Logger interface {
+	Println(v ...interface{})
+}
+
+var (
+	level  = Info
+	format = "text"
+
+	// std logs to stderr, unless another logger is given with SetLogger.
+	// The standard logger of the log package is left untouched.
+	std           = log.New(os.Stderr, "", log.LstdFlags)
+	logger Logger = std
+
+	discard = log.New(ioutil.Discard, "", 0)
+)
+
+// SetLevel sets the verbosity.
+func SetLevel(l Level) {
+	level = l
+}
+
+// SetLogger sends the logs to a given logger instead of stderr, so that
+// programs using getme as a library decide where its logs go. A nil logger
+// discards them.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = discard
+	}
+	logger = l
+}
+
+// SetFormat sets the format of the logs: text or json. In json, every event
+// is logged, whatever the verbosity, and each line given to the logger is an
+// object.
+func SetFormat(f string) error {
+	if f != "text" && f != "json" {
+		return fmt.Errorf("Invalid log format [%s]. Should be text or json", f)
+	}
+
+	format = f
+	if format == "json" {
+		// Events have their own time.
+		std.SetFlags(0)
+	} else {
+		std.SetFlags(log.LstdFlags)
+	}
+	return nil
+}
+
+// Enabled tells if logs of a given level are shown.
+func Enabled(l Level) bool {
+	if format == "json" {
+		return level >= Info
+	}
+	return level >= l
+}
+
+type contextKey struct{}
+
+// WithLogger gives a context whose logs go to a given logger instead of the
+// one given to SetLogger, like the logs of the downloads of a client. A nil
+// logger discards them.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	if l == nil {
+		l = discard
+	}
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// Log sends logs to a logger, in the format given to SetFormat. Its zero
+// value sends them to the logger given to SetLogger.
+type Log struct {
+	logger Logger
+}
+
+// From gives the logs of a context: they go to the logger given to
+// WithLogger, if any, or else to the one given to SetLogger.
+func From(ctx context.Context) Log {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return Log{l}
+	}
+	return Log{}
+}
+
+// Infoln logs what is downloaded, copied or extracted.
+func Infoln(v ...interface{}) {
+	Log{}.Infoln(v...)
+}
+
+// Infof logs what is downloaded, copied or extracted.
+func Infof(format string, v ...interface{}) {
+	Log{}.Infof(format, v...)
+}
+
+// Debugln logs the decisions taken along a download.
+func Debugln(v ...interface{}) {
+	Log{}.Debugln(v...)
+}
+
+// Debugf logs the decisions taken along a download.
+func Debugf(format string, v ...interface{}) {
+	Log{}.Debugf(format, v...)
+}
+
+// Event logs something that happened, like a cache hit. In text, the message
+// is logged if the level is enabled. In json, the event and its fields are.
+func Event(l Level, name string, fields Fields, msg ...interface{}) {
+	Log{}.Event(l, name, fields, msg...)
+}
+
+// Infoln logs what is downloaded, copied or extracted.
+func (l Log) Infoln(v ...interface{}) {
+	l.Event(Info, "log", nil, v...)
+}
+
+// Infof logs what is downloaded, copied or extracted.
+func (l Log) Infof(format string, v ...interface{}) {
+	l.Event(Info, "log", nil, fmt.Sprintf(format, v...))
+}
+
+// Debugln logs the decisions taken along a download.
+func (l Log) Debugln(v ...interface{}) {
+	l.Event(Debug, "log", nil, v...)
+}
+
+// Debugf logs the decisions taken along a download.
+func (l Log) Debugf(format string, v ...interface{}) {
+	l.Event(Debug, "log", nil, fmt.Sprintf(format, v...))
+}
+
+// Event logs something that happened, like the Event function.
+func (l Log) Event(level Level, name string, fields Fields, msg ...interface{}) {
+	if Enabled(level) {
+		l.emit(level, name, fields, msg...)
+	}
+}
+
+func (l Log) emit(level Level, name string, fields Fields, msg ...interface{}) {
+	if format != "json" {
+		if level >= Debug {
+			msg = append([]interface{}{strings.ToUpper(level.String())}, msg...)
+		}
+		l.target().Println(msg...)
+		return
+	}
+
+	event := Fields{}
+	for key, value := range fields {
+		event[key] = value
+	}
+	event["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	event["level"] = level.String()
+	event["event"] = name
+	event["msg"] = strings.TrimSuffix(fmt.Sprintln(msg...), "\n")
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	l.target().Println(string(encoded))
+}
+
+func (l Log) target() Logger {
+	if l.logger == nil {
+		return logger
+	}
+	return l.logger
+}
+
+// Transport logs http requests and their responses, whatever the verbosity.
+type Transport struct {
+	http.RoundTripper
+}
+
+// redact hides the password and the values of the query of an url. Query
+// parameters often carry tokens and signatures, like pre-signed S3 urls.
+func redact(u *url.URL) string {
+	redacted := *u
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for key := range query {
+			query[key] = []string{"xxxxx"}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.Redacted()
+}
+
+// RoundTrip implements http.RoundTripper. Requests are logged to the logger
+// of their context.
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	url := redact(req.URL)
+	l := From(req.Context())
+	l.emit(Trace, "http_request", Fields{"method": req.Method, "url": url}, req.Method, url)
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		l.emit(Trace, "http_error", Fields{"method": req.Method, "url": url, "error": err.Error(), "duration": time.Since(start).Seconds()}, req.Method, url, "failed after", time.Since(start), err)
+		return nil, err
+	}
+
+	l.emit(Trace, "http_response", Fields{"method": req.Method, "url": url, "status": resp.StatusCode, "duration": time.Since(start).Seconds()}, req.Method, url, resp.Status, "after", time.Since(start))
+	return resp, nil
+}
@@ -0,0 +1,122 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevels(t *testing.T) {
+	defer SetLevel(Info)
+	defer SetLogger(std)
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+
+	SetLevel(Info)
+	Debugln("cache miss")
+	assert.Empty(t, buf.String())
+
+	SetLevel(Debug)
+	Debugf("cache %s", "hit")
+	assert.Contains(t, buf.String(), "DEBUG cache hit")
+
+	SetLevel(Quiet)
+	assert.False(t, Enabled(Info))
+	Infoln("Copy")
+	assert.NotContains(t, buf.String(), "Copy")
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(std)
+
+	var buf, global bytes.Buffer
+	log.SetOutput(&global)
+	defer log.SetOutput(os.Stderr)
+
+	SetLogger(log.New(&buf, "getme: ", 0))
+	Infof("Extract %s", "tool.tgz")
+	assert.Equal(t, "getme: Extract tool.tgz\n", buf.String())
+	assert.Empty(t, global.String())
+
+	SetLogger(nil)
+	Infoln("Discarded")
+	assert.Empty(t, global.String())
+}
+
+func TestTransport(t *testing.T) {
+	defer SetLogger(std)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+
+	client := &http.Client{Transport: Transport{http.DefaultTransport}}
+	resp, err := client.Get(server.URL + "/file.tgz")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Contains(t, buf.String(), "TRACE GET "+server.URL+"/file.tgz\n")
+	assert.Contains(t, buf.String(), "404 Not Found after")
+
+	buf.Reset()
+	resp, err = client.Get(server.URL + "/file.tgz?X-Amz-Credential=KEY&X-Amz-Signature=secret")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Contains(t, buf.String(), "TRACE GET "+server.URL+"/file.tgz?X-Amz-Credential=xxxxx&X-Amz-Signature=xxxxx\n")
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestWithLogger(t *testing.T) {
+	defer SetLogger(std)
+
+	var global, client bytes.Buffer
+	SetLogger(log.New(&global, "", 0))
+
+	ctx := WithLogger(context.Background(), log.New(&client, "", 0))
+	From(ctx).Infoln("Download", "tool.tgz")
+	From(context.Background()).Infoln("Copy", "tool.tgz")
+
+	assert.Equal(t, "Download tool.tgz\n", client.String())
+	assert.Equal(t, "Copy tool.tgz\n", global.String())
+}
+
+func TestJSONFormat(t *testing.T) {
+	defer SetLogger(std)
+	defer SetFormat("text")
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+
+	assert.NoError(t, SetFormat("json"))
+	assert.Error(t, SetFormat("xml"))
+
+	Event(Debug, "cache_hit", Fields{"url": "https://example.com/tool.tgz"}, "Already in cache:", "https://example.com/tool.tgz")
+	Infoln("Github release url detected")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal(lines[0], &event))
+	assert.Equal(t, "cache_hit", event["event"])
+	assert.Equal(t, "debug", event["level"])
+	assert.Equal(t, "https://example.com/tool.tgz", event["url"])
+	assert.Equal(t, "Already in cache: https://example.com/tool.tgz", event["msg"])
+
+	assert.NoError(t, json.Unmarshal(lines[1], &event))
+	assert.Equal(t, "log", event["event"])
+	assert.Equal(t, "Github release url detected", event["msg"])
+}
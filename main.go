@@ -1,260 +1,1059 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/dgageot/getme/audit"
 	"github.com/dgageot/getme/cache"
+	"github.com/dgageot/getme/config"
+	"github.com/dgageot/getme/cookies"
+	"github.com/dgageot/getme/doctor"
 	"github.com/dgageot/getme/files"
-	"github.com/dgageot/getme/tar"
-	"github.com/dgageot/getme/urls"
+	"github.com/dgageot/getme/getme"
+	"github.com/dgageot/getme/github"
+	"github.com/dgageot/getme/hooks"
+	"github.com/dgageot/getme/ipfs"
+	"github.com/dgageot/getme/jenkins"
+	"github.com/dgageot/getme/keychain"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/server"
+	"github.com/dgageot/getme/tracing"
 	"github.com/dgageot/getme/zip"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"github.com/bndr/gojenkins"
-	"time"
+	"github.com/spf13/pflag"
 	"os"
 )
 
 var (
-	force bool
+	force          bool
+	xattrs         bool
+	ifMissing      bool
+	concurrency    int
+	deadline       time.Duration
+	onDeadline     string
+	output         string
+	verbosity      int
+	quiet          bool
+	logFormat      string
+	progressFormat string
+	progressFile   string
+	otelEndpoint   string
+	configFile     string
+	proxy          string
+	ifExists       string
+	link           string
+	linkMode       files.Link
+	stream         bool
+	streamToCache  bool
+	extractOptions files.ExtractOptions
+	clientHooks    hooks.Hooks
+	auditLogPath   string
+	auditLog       *audit.Log
+	progress       progressReporter
 )
 
 func main() {
 	var rootCmd = &cobra.Command{Use: "getme"}
 
+	ctx, interrupted := withSignals(context.Background())
+	var rootSpan *tracing.Span
 	options := files.Options{}
+	var cacheLocation string
+	var cookie, cookieJar string
+	var jar *cookies.Jar
 
 	rootCmd.PersistentFlags().StringVar(&options.AuthToken, "authToken", "", "Api authentication token")
 	rootCmd.PersistentFlags().StringVar(&options.AuthTokenEnvVariable, "authTokenEnvVariable", "", "Env variable containing an api authentication token")
+	rootCmd.PersistentFlags().StringVar(&options.AuthTokenVaultPath, "authTokenVaultPath", "", "Vault secret containing an api authentication token, like secret/data/ci#token")
+	rootCmd.PersistentFlags().StringVar(&options.User, "user", "", "Basic authentication credentials, as user:password")
+	rootCmd.PersistentFlags().StringArrayVar(&options.Headers, "header", nil, "Additional http header, as 'Key: Value'. Can be repeated")
+	rootCmd.PersistentFlags().BoolVar(&options.Negotiate, "negotiate", false, "Use Kerberos negotiate authentication, with the ticket from kinit or the Windows session. Requires curl")
+	rootCmd.PersistentFlags().StringVar(&cookie, "cookie", "", "Cookies to send, as 'name=value; other=value', or a Netscape cookie file to read them from")
+	rootCmd.PersistentFlags().StringVar(&cookieJar, "cookie-jar", "", "Netscape cookie file to write the cookies to")
 	rootCmd.PersistentFlags().StringVar(&options.S3AccessKey, "s3AccessKey", "", "Amazon S3 access key")
 	rootCmd.PersistentFlags().StringVar(&options.S3SecretKey, "s3SecretKey", "", "Amazon S3 secret key")
-	rootCmd.PersistentFlags().StringVar(&options.Sha256, "sha256", "", "Checksum to check downloaded files")
+	rootCmd.PersistentFlags().StringVar(&options.S3AccessKeyVaultPath, "s3AccessKeyVaultPath", "", "Vault secret containing the Amazon S3 access key, like secret/data/s3#access_key")
+	rootCmd.PersistentFlags().StringVar(&options.S3SecretKeyVaultPath, "s3SecretKeyVaultPath", "", "Vault secret containing the Amazon S3 secret key, like secret/data/s3#secret_key")
+	rootCmd.PersistentFlags().StringVar(&options.S3SessionToken, "s3SessionToken", "", "Amazon S3 session token, for temporary credentials")
+	rootCmd.PersistentFlags().StringVar(&options.S3RoleArn, "s3RoleArn", "", "Amazon IAM role to assume before accessing S3")
+	rootCmd.PersistentFlags().StringVar(&options.S3ExternalID, "s3ExternalId", "", "External id required to assume the IAM role")
+	rootCmd.PersistentFlags().StringVar(&options.S3Endpoint, "s3Endpoint", "", "Endpoint of an S3 compatible object store, like http://minio:9000")
+	rootCmd.PersistentFlags().BoolVar(&options.S3ForcePathStyle, "s3ForcePathStyle", false, "Use path style urls instead of virtual-hosted ones, as often required by S3 compatible object stores")
+	rootCmd.PersistentFlags().StringVar(&options.S3Profile, "profile", "", "Profile of ~/.aws/credentials to use for Amazon S3. Defaults to the standard AWS credential chain")
+	rootCmd.PersistentFlags().BoolVar(&options.S3RequesterPays, "s3-requester-pays", false, "Accept to pay for requests to Amazon S3 requester-pays buckets")
+	rootCmd.PersistentFlags().StringVar(&options.GCSCredentials, "gcsCredentials", "", "Google Cloud service account json file. Defaults to Application Default Credentials")
+	rootCmd.PersistentFlags().StringVar(&options.GoogleAPIKey, "googleApiKey", "", "Google api key used to download Google Drive files through the Drive api")
+	rootCmd.PersistentFlags().StringVar(&options.AzureSASToken, "azureSasToken", "", "Azure Blob Storage shared access signature. Defaults to Azure AD authentication")
+	rootCmd.PersistentFlags().StringVar(&options.WebDAVUser, "webdavUser", "", "WebDAV user name")
+	rootCmd.PersistentFlags().StringVar(&options.WebDAVPassword, "webdavPassword", "", "WebDAV password or app password")
+	rootCmd.PersistentFlags().StringVar(&options.IPFSGateway, "ipfsGateway", ipfs.DefaultGateway, "IPFS gateway used to fetch ipfs:// urls. Blocks are verified against their CID")
+	rootCmd.PersistentFlags().StringArrayVar(&options.PlatformNames, "platform-name", nil, "Rename {{.OS}} or {{.Arch}} in urls, like amd64=x86_64 or darwin=macos. Can be repeated")
+	rootCmd.PersistentFlags().StringVar(&options.GitHubTag, "tag", "", "Download assets of this Github release instead. Use latest for the latest release")
+	rootCmd.PersistentFlags().StringVar(&options.GitHubAsset, "asset", "", "Pick the asset of a Github release by glob, like '*linux_amd64*.tar.gz', or by /regexp/")
+	rootCmd.PersistentFlags().BoolVar(&options.GitHubAutoAsset, "auto-asset", false, "Pick the asset of a Github release built for the current OS and architecture")
+	rootCmd.PersistentFlags().StringVar(&options.GitHubAPIURL, "github-api-url", "", "Api of a Github Enterprise server, like https://github.example.com/api/v3. Defaults to the release url host")
+	rootCmd.PersistentFlags().StringVar(&options.CircleToken, "circle-token", "", "CircleCI api token. Defaults to $CIRCLE_TOKEN")
+	rootCmd.PersistentFlags().StringVar(&options.BuildkiteToken, "buildkite-token", "", "Buildkite api token. Defaults to $BUILDKITE_API_TOKEN")
+	rootCmd.PersistentFlags().StringVar(&options.ArtifactoryURL, "artifactory-url", "", "Url of an Artifactory server, like https://example.com/artifactory. Defaults to urls with an /artifactory/ path")
+	rootCmd.PersistentFlags().StringVar(&options.ArtifactoryAPIKey, "artifactory-api-key", "", "Artifactory api key. Defaults to $ARTIFACTORY_API_KEY")
+	rootCmd.PersistentFlags().StringVar(&options.ArtifactoryToken, "artifactory-token", "", "Artifactory access token. Defaults to $ARTIFACTORY_ACCESS_TOKEN")
+	rootCmd.PersistentFlags().StringVar(&options.NexusURL, "nexus-url", "", "Url of the Nexus server of nexus:// urls, like https://nexus.example.com")
+	rootCmd.PersistentFlags().StringVar(&options.NexusToken, "nexus-token", "", "Nexus user token, as name:password. Defaults to $NEXUS_TOKEN")
+	rootCmd.PersistentFlags().StringVar(&options.MavenRepository, "maven-repository", "", "Maven repository of maven:// urls. Defaults to Maven Central")
+	rootCmd.PersistentFlags().StringVar(&options.NPMRegistry, "npm-registry", "", "npm registry of npm:// urls. Defaults to https://registry.npmjs.org")
+	rootCmd.PersistentFlags().StringVar(&options.NPMToken, "npm-token", "", "npm registry access token. Defaults to $NPM_TOKEN")
+	rootCmd.PersistentFlags().StringVar(&options.PyPIIndex, "pypi-index", "", "Json api of the package index of pypi:// urls. Defaults to https://pypi.org/pypi")
+	rootCmd.PersistentFlags().StringVar(&options.GoProxy, "go-proxy", "", "Go module proxies of gomod:// urls. Defaults to $GOPROXY, then to https://proxy.golang.org")
+	rootCmd.PersistentFlags().StringVar(&options.GoSumDB, "go-sumdb", "", "Checksum database that verifies gomod:// urls. Defaults to $GOSUMDB, then to sum.golang.org")
+	rootCmd.PersistentFlags().StringVar(&options.TerraformToken, "terraform-token", "", "Terraform registry api token. Defaults to $TF_TOKEN_<hostname>, like $TF_TOKEN_app_terraform_io")
+	rootCmd.PersistentFlags().StringVar(&options.GitlabToken, "gitlabToken", "", "Gitlab access token. Defaults to $GITLAB_TOKEN, or $CI_JOB_TOKEN in Gitlab CI jobs")
+	rootCmd.PersistentFlags().StringVar(&options.GiteaURL, "giteaUrl", "", "Url of a self-hosted Gitea or Forgejo server")
+	rootCmd.PersistentFlags().StringVar(&options.GiteaToken, "giteaToken", "", "Gitea or Forgejo access token. Defaults to $GITEA_TOKEN")
+	rootCmd.PersistentFlags().StringVar(&options.GitHubCommit, "commit", "", "Commit that github:// source tarballs must be an archive of")
+	rootCmd.PersistentFlags().DurationVar(&github.RateLimitWait, "github-rate-limit-wait", 0, "How long to wait at most for the Github api rate limit to reset, like 10m")
+	rootCmd.PersistentFlags().BoolVar(&options.GitHubEnvToken, "github-env-token", true, "Authenticate to Github with $GITHUB_TOKEN or $GH_TOKEN when no token is given")
+	rootCmd.PersistentFlags().StringArrayVar(&options.CredentialHelpers, "credential-helper", nil, "Command giving credentials, like git credential helpers. Use host=command for a single host")
+	rootCmd.PersistentFlags().StringVar(&options.Sha256, "sha256", "", "Sha256 that downloaded files must have. Cached files that don't are downloaded again")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Log the decisions taken, like cache hits. Use -vv to also log http requests")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only log errors")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of the logs: text or json, one event per line")
+	rootCmd.PersistentFlags().StringVar(&progressFormat, "progress", "", "Show the progress of downloads: bar, or json for one event per line")
+	rootCmd.PersistentFlags().StringVar(&progressFile, "progress-file", "", "File or named pipe to write json progress events to. Defaults to stderr")
+	rootCmd.PersistentFlags().StringVar(&otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint to export traces to, like http://localhost:4318. Defaults to $OTEL_EXPORTER_OTLP_ENDPOINT")
 	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Force download")
+	rootCmd.PersistentFlags().StringVar(&output, "output", "text", "Format of the output of download and version: text or json")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 1, "How many urls to download at the same time. Hosts that answer 429 or 503 get fewer at once, then more again as downloads succeed")
+	rootCmd.PersistentFlags().DurationVar(&deadline, "deadline", 0, "How long a batch of urls can run, like 10m. Then no new download is started and a partial report is printed")
+	rootCmd.PersistentFlags().StringVar(&onDeadline, "on-deadline", "finish", "What to do with the downloads running at the deadline: finish or cancel")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", config.Path(), "Config file giving default flag values and per-host settings")
+	rootCmd.PersistentFlags().StringVar(&proxy, "proxy", "", "Proxy for http and https requests. Defaults to $HTTPS_PROXY or $HTTP_PROXY")
+	rootCmd.PersistentFlags().IntVar(&options.Retries, "retries", 0, "How many times to retry failed downloads")
+	rootCmd.PersistentFlags().StringVar(&cacheLocation, "cache", "", "Where to cache files: a directory, an s3://bucket/prefix or a gs://bucket/prefix url. Defaults to ~/.getme")
+	rootCmd.PersistentFlags().BoolVar(&xattrs, "xattrs", false, "Record the source url and sha256 as extended attributes on copied and extracted files")
+	rootCmd.PersistentFlags().StringArrayVar(&clientHooks.PreDownload, "pre-download", nil, "Command run before a download, like 'mirror-url {{.URL}}'. What it prints replaces the url. Can be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&clientHooks.PostDownload, "post-download", nil, "Command run after a download, like 'scan {{.Path}}'. Can be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&clientHooks.PostCopy, "post-copy", nil, "Command run after a copy, like 'chmod +x {{.Dest}}'. Can be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&clientHooks.PostExtract, "post-extract", nil, "Command run after an extraction, like 'chmod +x {{.Dest}}/bin/*'. Can be repeated")
+	rootCmd.PersistentFlags().StringVar(&auditLogPath, "audit-log", "", "File to append a json record to for every fetched file: url, resolved url, sha256, size, time, user and host")
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := bindEnv(cmd); err != nil {
+			return err
+		}
+
+		hosts, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		options.Hosts = hosts
+
+		switch {
+		case quiet:
+			logs.SetLevel(logs.Quiet)
+		case verbosity == 1:
+			logs.SetLevel(logs.Debug)
+		case verbosity > 1:
+			logs.SetLevel(logs.Trace)
+			http.DefaultTransport = logs.Transport{RoundTripper: http.DefaultTransport}
+		}
+		if err := logs.SetFormat(logFormat); err != nil {
+			return err
+		}
+
+		if output != "text" && output != "json" {
+			return fmt.Errorf("Invalid output [%s]. Should be text or json", output)
+		}
+
+		switch progressFormat {
+		case "":
+		case "bar":
+			progress = newProgressBar(os.Stderr)
+		case "json":
+			out, err := openProgressFile(progressFile)
+			if err != nil {
+				return err
+			}
+			progress = newProgressJSON(out)
+		default:
+			return fmt.Errorf("Invalid progress [%s]. Should be bar or json", progressFormat)
+		}
+
+		if otelEndpoint == "" {
+			otelEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		}
+		if otelEndpoint != "" {
+			if err := tracing.Setup(otelEndpoint, os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")); err != nil {
+				return err
+			}
+			ctx, rootSpan = tracing.Start(ctx, "getme "+cmd.Name(), tracing.Attributes{"args": len(args)})
+		}
+
+		if extractOptions.IfExists, err = files.ParseIfExists(ifExists); err != nil {
+			return err
+		}
+		if linkMode, err = files.ParseLink(link); err != nil {
+			return err
+		}
+
+		if err := options.LoadSecrets(); err != nil {
+			return err
+		}
+
+		if auditLogPath != "" {
+			if auditLog, err = audit.Open(auditLogPath); err != nil {
+				return err
+			}
+		}
+
+		if cookie != "" || cookieJar != "" {
+			jar = cookies.NewJar()
+			if strings.Contains(cookie, "=") {
+				if err := jar.AddHeader(cookie); err != nil {
+					return err
+				}
+			} else if cookie != "" {
+				if err := jar.Load(cookie); err != nil {
+					return err
+				}
+			}
+			options.Jar = jar
+		}
+
+		backend, err := cache.NewBackend(cacheLocation, options)
+		if err != nil {
+			return err
+		}
+
+		cache.UseBackend(backend)
+		return nil
+	}
+
+	rootCmd.PersistentPostRunE = func(cmd *cobra.Command, args []string) error {
+		if cookieJar == "" {
+			return nil
+		}
+		return jar.Save(cookieJar)
+	}
+
+	var fromFile string
+
+	downloadCmd := &cobra.Command{
+		Use:     "download <url>...",
+		Aliases: []string{"get", "Download"},
+		Short:   "Download urls to the cache and print the paths of the cached files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			batch, err := batchOf(args, fromFile, 1, errors.New("An url must be provided"))
+			if err != nil {
+				return err
+			}
+
+			// Results are printed in the order of the urls.
+			results := make([]downloadResult, len(batch))
+			err = runBatch(ctx, batch, func(ctx context.Context, i int, job []string) error {
+				url := job[0]
+
+				var err error
+				results[i], err = download(ctx, url, options)
+				return err
+			})
+
+			if printErr := printDownloads(results, len(args) == 1 && fromFile == ""); printErr != nil {
+				return printErr
+			}
+			return err
+		},
+	}
+	downloadCmd.Flags().StringVar(&fromFile, "from-file", "", "File listing the urls to download, one per line. Use - for stdin")
+	rootCmd.AddCommand(downloadCmd)
+
+	copyCmd := &cobra.Command{
+		Use:     "copy <url> <destination> [<url> <destination>...]",
+		Aliases: []string{"Copy"},
+		Short:   "Download urls and copy them to destination files or directories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			batch, err := batchOf(args, fromFile, 2, errors.New("An url and a destination must be provided"))
+			if err != nil {
+				return err
+			}
+
+			return runBatch(ctx, batch, func(ctx context.Context, i int, job []string) error {
+				url := job[0]
+				destination := job[1]
+
+				return Copy(ctx, url, options, destination)
+			})
+		},
+	}
+	copyCmd.Flags().StringVar(&fromFile, "from-file", "", "File listing an url and a destination per line. Use - for stdin")
+	copyCmd.Flags().BoolVar(&ifMissing, "if-missing", false, "Do nothing if the destination already exists")
+	copyCmd.Flags().StringVar(&ifExists, "if-exists", "overwrite", "What to do with existing files: overwrite, skip, error or update")
+	copyCmd.Flags().StringVar(&link, "link", "copy", "How to place cached files: hard, reflink, symlink or copy. Falls back to copy when links aren't supported")
+	rootCmd.AddCommand(copyCmd)
+
+	var chmod, umask string
+	var excludes []string
+	extractCmd := &cobra.Command{
+		Use:     "extract <url> <directory> | extract <url> <file> <destination> [<file> <destination>...]",
+		Aliases: []string{"Extract", "Unzip", "UnzipSingleFile"},
+		Short:   "Download archives and extract them, or some of their files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			batch := [][]string{args}
+			if fromFile != "" {
+				if len(args) > 0 {
+					return errors.New("Urls can't be given both as arguments and with --from-file")
+				}
+
+				var err error
+				if batch, err = readBatch(fromFile); err != nil {
+					return err
+				}
+			}
+
+			var err error
+			if chmod != "" {
+				if extractOptions.Chmod, err = files.ParseMode(chmod); err != nil {
+					return err
+				}
+			}
+			if extractOptions.Umask, err = files.ParseMode(umask); err != nil {
+				return err
+			}
+			if extractOptions.Excludes, err = files.ParsePatterns(excludes); err != nil {
+				return err
+			}
+
+			return runBatch(ctx, batch, func(ctx context.Context, i int, job []string) error {
+				return extract(ctx, job, options)
+			})
+		},
+	}
+	extractCmd.Flags().StringArrayVar(&excludes, "exclude", nil, "Pattern of archive entries not to extract. Can be repeated")
+	extractCmd.Flags().BoolVar(&extractOptions.AllowUnsafe, "allow-unsafe", false, "Allow archive entries to be extracted outside of the destination folder")
+	extractCmd.Flags().BoolVar(&stream, "stream", false, "Extract tar archives while they are downloaded, without caching them first. Archives with a --sha256 are still downloaded first, to be verified before they are extracted")
+	extractCmd.Flags().BoolVar(&streamToCache, "stream-to-cache", false, "When streaming, also store the archive in the cache")
+	extractCmd.Flags().BoolVar(&extractOptions.Dereference, "dereference", false, "Extract links as regular files, for file systems that don't support links")
+	extractCmd.Flags().BoolVar(&ifMissing, "if-missing", false, "Do nothing if the destinations already exist")
+	extractCmd.Flags().StringVar(&ifExists, "if-exists", "overwrite", "What to do with existing files: overwrite, skip, error or update")
+	extractCmd.Flags().StringVar(&chmod, "chmod", "", "Octal mode given to every extracted file, instead of the mode stored in the archive")
+	extractCmd.Flags().StringVar(&umask, "umask", "022", "Octal mask removed from the mode of extracted files")
+	extractCmd.Flags().StringVar(&fromFile, "from-file", "", "File listing the arguments of an extraction per line: an url and a destination, or an url and pairs of file and destination. Use - for stdin")
+	rootCmd.AddCommand(extractCmd)
 
 	rootCmd.AddCommand(&cobra.Command{
-		Use: "Download",
+		Use:     "cat <url> <file>",
+		Aliases: []string{"Cat"},
+		Short:   "Print a file of an archive",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 1 {
-				return errors.New("An url must be provided")
+			if len(args) != 2 {
+				return errors.New("An url and a file name must be provided")
 			}
 			url := args[0]
+			file := args[1]
 
-			return Download(url, options)
+			return Cat(ctx, url, options, file)
 		},
 	})
 
 	rootCmd.AddCommand(&cobra.Command{
-		Use: "Copy",
+		Use:     "list <url>",
+		Aliases: []string{"List"},
+		Short:   "List the files of an archive",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 2 {
-				return errors.New("An url and a destination must be provided")
+			if len(args) != 1 {
+				return errors.New("An url must be provided")
 			}
 			url := args[0]
-			destination := args[1]
 
-			return Copy(url, options, destination)
+			return List(ctx, url, options)
 		},
 	})
 
-	rootCmd.AddCommand(&cobra.Command{
-		Use:     "Extract",
-		Aliases: []string{"Unzip", "UnzipSingleFile"},
+	var artifactQuery github.ArtifactQuery
+	artifactCmd := &cobra.Command{
+		Use:     "artifact <org/repo> <name> <directory>",
+		Aliases: []string{"Artifact"},
+		Short:   "Download and extract an artifact of a Github Actions workflow run",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 2 {
-				return errors.New("An url, a file name and a destination must be provided")
+			if len(args) != 3 {
+				return errors.New("A repository, an artifact name and a destination must be provided")
+			}
+			parts := strings.SplitN(args[0], "/", 2)
+			if len(parts) != 2 {
+				return errors.New("Invalid repository. Should be org/repo: " + args[0])
 			}
+			artifactQuery.Org = parts[0]
+			artifactQuery.Project = parts[1]
+			artifactQuery.Name = args[1]
+			destinationDirectory := args[2]
 
-			url := args[0]
+			return Artifact(ctx, artifactQuery, options, destinationDirectory)
+		},
+	}
+	artifactCmd.Flags().Int64Var(&artifactQuery.RunID, "run", 0, "Id of the workflow run. Defaults to the latest successful run")
+	artifactCmd.Flags().StringVar(&artifactQuery.Branch, "branch", "", "Only consider runs on this branch")
+	artifactCmd.Flags().StringVar(&artifactQuery.Workflow, "workflow", "", "Only consider runs of this workflow, given by file name or id")
+	rootCmd.AddCommand(artifactCmd)
 
-			// All files
-			if len(args) == 2 {
-				destinationFolder := args[1]
+	var dispatch github.Dispatch
+	var inputs []string
+	dispatchCmd := &cobra.Command{
+		Use:   "dispatch <org/repo> <workflow> <name> <directory>",
+		Short: "Run a Github Actions workflow, wait for the run to complete, then download and extract one of its artifacts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 4 {
+				return errors.New("A repository, a workflow, an artifact name and a destination must be provided")
+			}
+			parts := strings.SplitN(args[0], "/", 2)
+			if len(parts) != 2 {
+				return errors.New("Invalid repository. Should be org/repo: " + args[0])
+			}
+			dispatch.Org = parts[0]
+			dispatch.Project = parts[1]
+			dispatch.Workflow = args[1]
+			name := args[2]
+			destinationDirectory := args[3]
 
-				return Extract(url, options, destinationFolder)
+			var err error
+			if dispatch.Inputs, err = github.ParseInputs(inputs); err != nil {
+				return err
 			}
 
-			// Some files
-			extractedFiles := []files.ExtractedFile{}
-			for i := 1; i < len(args); i += 2 {
-				extractedFiles = append(extractedFiles, files.ExtractedFile{
-					Source:      args[i],
-					Destination: args[i+1],
-				})
+			return Dispatch(ctx, dispatch, name, options, destinationDirectory)
+		},
+	}
+	dispatchCmd.Flags().StringVar(&dispatch.Ref, "ref", "main", "Branch or tag to run the workflow on")
+	dispatchCmd.Flags().StringArrayVar(&inputs, "input", nil, "Input of the workflow, as key=value. Can be repeated")
+	dispatchCmd.Flags().DurationVar(&dispatch.PollInterval, "poll-interval", 10*time.Second, "How often to check the run")
+	dispatchCmd.Flags().DurationVar(&dispatch.Timeout, "timeout", 0, "How long to wait at most for the run to complete, like 1h")
+	rootCmd.AddCommand(dispatchCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:     "login <host>",
+		Aliases: []string{"Login"},
+		Short:   "Store a token for a host in the keychain, read from stdin",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("A host must be provided")
 			}
+			host := args[0]
+
+			return Login(host, os.Stdin)
+		},
+	})
+
+	var listen string
+	var trustClients bool
+	var schemes []string
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the cache over http: GET /fetch?url=<url> downloads the url if it's absent and streams the file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Serve(ctx, options, listen, trustClients, schemes)
+		},
+	}
+	serveCmd.Flags().StringVar(&listen, "listen", "localhost:8080", "Address to listen on, like :8080 to accept connections from other hosts")
+	serveCmd.Flags().BoolVar(&trustClients, "trust-clients", false, "Download the urls of clients with the credentials of getme, and with every downloader, like git or aria2c. Otherwise, only http and https urls are downloaded, anonymously")
+	serveCmd.Flags().StringArrayVar(&schemes, "allow-scheme", nil, "Scheme of urls, besides http and https, that trusted clients can fetch, like s3 or oci. Can be repeated")
+	rootCmd.AddCommand(serveCmd)
+
+	var proxyListen, caCert, caKey string
+	proxyCmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Run an http proxy that serves archives and packages from the cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Proxy(ctx, options, proxyListen, caCert, caKey)
+		},
+	}
+	proxyCmd.Flags().StringVar(&proxyListen, "listen", "localhost:3128", "Address to listen on, like :3128 to accept connections from other hosts")
+	proxyCmd.Flags().StringVar(&caCert, "ca-cert", "", "Pem file of the CA used to intercept, and cache, https requests. Created with --ca-key if missing")
+	proxyCmd.Flags().StringVar(&caKey, "ca-key", "", "Pem file of the private key of the CA")
+	rootCmd.AddCommand(proxyCmd)
 
-			return ExtractFiles(url, options, extractedFiles)
+	rootCmd.AddCommand(&cobra.Command{
+		Use:     "doctor [<url>...]",
+		Aliases: []string{"Doctor"},
+		Short:   "Diagnose the cache, proxy, connectivity and credentials",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Doctor(options, args)
 		},
 	})
 
 	rootCmd.AddCommand(&cobra.Command{
-		Use: "Pinata",
+		Use:   "version",
+		Short: "Print the version, commit, build date and Go version of getme",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 7 {
-				return errors.New("A commit and platform must be provided")
-			}
-			jenkins := args[0]
-			user := args[1]
-			token := args[2]
-			bucket := args[3]
-			isocommit := args[4]
-			commit := args[5]
-			platform := args[6]
-
-			return Pinata(jenkins, user, token, bucket, isocommit, commit, platform, options)
+			return Version(os.Stdout, output)
 		},
 	})
 
-	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "self-update",
+		Short: selfUpdateHelp(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return SelfUpdate(ctx, options)
+		},
+	})
+
+	var jenkinsServer jenkins.Server
+	jenkinsCmd := &cobra.Command{
+		Use:   "jenkins",
+		Short: "Trigger Jenkins builds and download their artifacts",
+	}
+	addJenkinsFlags(jenkinsCmd.PersistentFlags(), &jenkinsServer)
+
+	var job string
+	var params []string
+	buildAndGetCmd := &cobra.Command{
+		Use:   "build-and-get <artifact url template>",
+		Short: "Trigger a build of a job, wait for it to succeed and download its artifact. The url template can use {{.Job}}, {{.Number}} and {{.Params.KEY}}",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("An artifact url template must be provided")
+			}
+			if jenkinsServer.URL == "" || job == "" {
+				return errors.New("A --jenkins-url and a --job must be provided")
+			}
+			parsedParams, err := jenkins.ParseParams(params)
+			if err != nil {
+				return err
+			}
+
+			return JenkinsBuildAndGet(ctx, jenkinsServer, job, parsedParams, args[0], options)
+		},
+	}
+	buildAndGetCmd.Flags().StringVar(&job, "job", "", "Job to build. Jobs in folders are given as folder/job")
+	buildAndGetCmd.Flags().StringArrayVar(&params, "param", nil, "Build parameter, as key=value. Can be repeated")
+	jenkinsCmd.AddCommand(buildAndGetCmd)
+
+	var build, artifactPattern string
+	jenkinsArtifactCmd := &cobra.Command{
+		Use:   "artifact",
+		Short: "Download the artifacts of a build to the cache and print the paths of the cached files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jenkinsServer.URL == "" || job == "" || artifactPattern == "" {
+				return errors.New("A --jenkins-url, a --job and an --artifact must be provided")
+			}
+
+			return JenkinsArtifact(ctx, jenkinsServer, job, build, artifactPattern, options)
+		},
+	}
+	jenkinsArtifactCmd.Flags().StringVar(&job, "job", "", "Job of the build. Jobs in folders are given as folder/job")
+	jenkinsArtifactCmd.Flags().StringVar(&build, "build", "lastSuccessful", "Build number, or lastSuccessful, lastStable, lastCompleted or last")
+	jenkinsArtifactCmd.Flags().StringVar(&artifactPattern, "artifact", "", "Glob pattern of the artifacts, relative to the archived files, like 'dist/*.tar.gz'")
+	jenkinsCmd.AddCommand(jenkinsArtifactCmd)
+	rootCmd.AddCommand(jenkinsCmd)
+
+	var ensureURL, trigger string
+	var ensureInterval, ensureTimeout time.Duration
+	ensureCmd := &cobra.Command{
+		Use:   "ensure",
+		Short: "Download an url. If it doesn't exist, run a trigger to build it and wait for the url to exist",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ensureURL == "" || trigger == "" {
+				return errors.New("An --url and a --trigger must be provided")
+			}
+
+			return Ensure(ctx, ensureURL, trigger, ensureInterval, ensureTimeout, options)
+		},
+	}
+	ensureCmd.Flags().StringVar(&ensureURL, "url", "", "Url of the artifact")
+	ensureCmd.Flags().StringVar(&trigger, "trigger", "", "Command run to build a missing artifact, like 'make-release {{.URL}}', or a webhook url to post {\"url\": <url>} to")
+	ensureCmd.Flags().DurationVar(&ensureInterval, "poll-interval", 10*time.Second, "How often to check if the artifact exists")
+	ensureCmd.Flags().DurationVar(&ensureTimeout, "timeout", 30*time.Minute, "How long to wait for the artifact to exist")
+	rootCmd.AddCommand(ensureCmd)
+
+	var bucket, isoCommit, commit, platform string
+	pinataCmd := &cobra.Command{
+		Use:     "pinata",
+		Aliases: []string{"Pinata"},
+		Short:   "Download the iso of a Pinata build, building it with Jenkins if it doesn't exist",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return errors.New("Pinata takes flags, like --pinata-commit, instead of arguments")
+			}
+			if jenkinsServer.URL == "" || bucket == "" || commit == "" || platform == "" {
+				return errors.New("A --jenkins-url, a --bucket, a --pinata-commit and a --platform must be provided")
+			}
+			if isoCommit == "" {
+				isoCommit = commit
+			}
+
+			return Pinata(ctx, jenkinsServer, bucket, isoCommit, commit, platform, options)
+		},
+	}
+	addJenkinsFlags(pinataCmd.Flags(), &jenkinsServer)
+	pinataCmd.Flags().StringVar(&bucket, "bucket", "", "Google Cloud Storage bucket of the isos")
+	pinataCmd.Flags().StringVar(&commit, "pinata-commit", "", "Commit to build")
+	pinataCmd.Flags().StringVar(&isoCommit, "iso-commit", "", "Commit the iso is stored under in the bucket. Defaults to --pinata-commit")
+	pinataCmd.Flags().StringVar(&platform, "platform", "", "Platform of the iso, like mac or windows")
+	rootCmd.AddCommand(pinataCmd)
+
+	// `getme --version` prints the build information. On the commands,
+	// `--version` is the version substituted in urls.
+	rootCmd.Version = buildVersion
+	rootCmd.SetVersionTemplate(versionTemplate())
+	rootCmd.Flags().Bool("version", false, "Print the version, commit, build date and Go version of getme")
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() != "version" {
+			cmd.PersistentFlags().StringVar(&options.Version, "version", "", "Version substituted to {{.Version}} in urls. A constraint, like '>=1.20 <1.21', picks the highest matching Github release")
+		}
+	}
+
+	// Errors are printed below, once, even with --quiet.
+	rootCmd.SilenceErrors = true
+
+	err := rootCmd.Execute()
+	if progress != nil {
+		progress.Close()
+	}
+	rootSpan.End(err)
+	if flushErr := tracing.Flush(context.Background()); flushErr != nil {
+		logs.Infoln(flushErr)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		if interrupted() {
+			os.Exit(exitInterrupted)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
 // Download retrieves an url from the cache or download it if it's absent.
 // Then print the path to that file to stdout.
-func Pinata(jenkins, user, token, bucket, isocommit, commit, platform string, options files.Options) error {
-	binary := fmt.Sprintf("https://storage.googleapis.com/%s/%s/docker-for-%s.iso.tgz", bucket, isocommit, platform)
-	err := Download(binary, options)
+func Download(ctx context.Context, url string, options files.Options) error {
+	result, err := download(ctx, url, options)
 	if err != nil {
-		log.SetOutput(os.Stdout)
-		log.Println("Building", binary)
-		log.Println("Trigger jenkins build")
-		jenkins := gojenkins.CreateJenkins(nil, jenkins, user, token)
-		_, err := jenkins.Init()
-		if err != nil {
-			return err
-		}
+		return err
+	}
 
-		job, err := jenkins.GetJob(fmt.Sprintf("pinata-%s-iso", platform))
-		if err != nil {
-			return err
-		}
+	return printDownloads([]downloadResult{result}, true)
+}
 
-		taskId, err := job.InvokeSimple(map[string]string{
-			"COMMIT_ID": commit,
-		})
-		if err != nil {
-			return err
+// downloadResult describes a downloaded file for --output json.
+type downloadResult struct {
+	URL    string `json:"url"`
+	Path   string `json:"path,omitempty"`
+	Sha256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Cached bool   `json:"cached"`
+	Error  string `json:"error,omitempty"`
+}
+
+// download retrieves an url from the cache or download it if it's absent.
+// It describes the file.
+func download(ctx context.Context, url string, options files.Options) (downloadResult, error) {
+	entry, err := newClient(options).Download(ctx, url)
+	if err != nil {
+		return downloadResult{URL: url, Error: err.Error()}, err
+	}
+
+	result := downloadResult{URL: entry.URL, Path: entry.Path, Sha256: entry.Sha256, Cached: entry.Cached}
+	if output != "json" {
+		return result, nil
+	}
+
+	info, err := os.Stat(entry.Path)
+	if err != nil {
+		return result, err
+	}
+	result.Size = info.Size()
+
+	if result.Sha256 == "" {
+		if result.Sha256, err = cache.Sha256(entry.Path); err != nil {
+			return result, err
 		}
+	}
 
-		log.Println("Waiting for queue")
-		for {
-			queue, err := jenkins.GetQueue()
-			if err != nil {
-				return err
-			}
-			task := queue.GetTaskById(taskId)
-			if task == nil {
-				break
-			}
-			time.Sleep(time.Second)
+	return result, nil
+}
+
+// printDownloads prints the paths to the downloaded files, or describes them
+// with --output json. A single download is described by an object, a batch by
+// an array.
+func printDownloads(results []downloadResult, single bool) error {
+	if progress != nil {
+		progress.Wait()
+	}
+
+	if output == "json" {
+		var v interface{} = results
+		if single {
+			v = results[0]
 		}
 
-		ids, err := job.GetAllBuildIds()
+		encoded, err := json.Marshal(v)
 		if err != nil {
 			return err
 		}
-		for _, id := range ids {
-			build, err := job.GetBuild(id.Number)
-			if err != nil {
-				return err
-			}
-			if build.GetParameters()[0].Value == commit {
-				for {
-					if !build.IsRunning() {
-						break
-					}
-					log.Println("Job is running, waiting...")
-					time.Sleep(5 * time.Second)
-					build, err = job.GetBuild(id.Number)
-					if err != nil {
-						return err
-					}
-				}
-				if build.IsGood() {
-					return Download(binary, options)
-				}
-				return fmt.Errorf("Build failed")
-			}
+		fmt.Println(string(encoded))
+		return nil
+	}
+
+	for _, result := range results {
+		if result.Path != "" {
+			fmt.Println(result.Path)
 		}
-		return fmt.Errorf("Build not found")
 	}
 	return nil
 }
 
-// Download retrieves an url from the cache or download it if it's absent.
-// Then print the path to that file to stdout.
-func Download(url string, options files.Options) error {
-	// Discard all the logs. We only want to output the path to the file
-	log.SetOutput(ioutil.Discard)
+// Copy retrieves an url from the cache or download it if it's absent.
+// Then it copies the file to a destination path.
+func Copy(ctx context.Context, url string, options files.Options, destination string) error {
+	return newClient(options).Copy(ctx, url, destination)
+}
+
+// extract runs an extraction given the arguments of the Extract command.
+func extract(ctx context.Context, args []string, options files.Options) error {
+	if len(args) < 2 || (len(args) > 2 && len(args)%2 == 0) {
+		return errors.New("An url, a file name and a destination must be provided")
+	}
+
+	url := args[0]
+
+	// All files
+	if len(args) == 2 {
+		destinationFolder := args[1]
+
+		return Extract(ctx, url, options, destinationFolder)
+	}
+
+	// Some files
+	extractedFiles := []files.ExtractedFile{}
+	for i := 1; i < len(args); i += 2 {
+		extractedFiles = append(extractedFiles, files.ExtractedFile{
+			Source:      args[i],
+			Destination: args[i+1],
+		})
+	}
+
+	return ExtractFiles(ctx, url, options, extractedFiles)
+}
+
+// Extract retrieves an url from the cache or download it if it's absent.
+// Then it unzips the file to a destination directory.
+func Extract(ctx context.Context, url string, options files.Options, destinationDirectory string) error {
+	return newClient(options).Extract(ctx, url, destinationDirectory)
+}
+
+// ExtractFiles retrieves an url from the cache or download it if it's absent.
+// Then it unzips some files from that zip to a destination path.
+func ExtractFiles(ctx context.Context, url string, options files.Options, filesToExtract []files.ExtractedFile) error {
+	return newClient(options).ExtractFiles(ctx, url, filesToExtract)
+}
+
+// Artifact downloads an artifact of a Github Actions workflow run to the cache.
+// Then it extracts the files from the zip archive that wraps the artifact to a
+// destination directory.
+func Artifact(ctx context.Context, query github.ArtifactQuery, options files.Options, destinationDirectory string) error {
+	headers := options.GitHubHeaders(ctx, options.GitHubAPI())
+	if len(headers) == 0 {
+		return errors.New("Downloading artifacts requires a Github token. Use $GITHUB_TOKEN, --authToken or --authTokenEnvVariable")
+	}
+
+	url, err := github.ArtifactUrl(ctx, options.GitHubAPI(), query, headers)
+	if err != nil {
+		return err
+	}
 
-	source, err := cache.Download(url, options, force)
+	entry, err := newClient(options).Download(ctx, url)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(source)
+	logs.Infoln("Extract artifact", query.Name, "to", destinationDirectory)
 
-	return nil
+	return zip.Extract(entry.Path, destinationDirectory, extractOptions)
 }
 
-// Copy retrieves an url from the cache or download it if it's absent.
-// Then it copies the file to a destination path.
-func Copy(url string, options files.Options, destination string) error {
-	// Discard all the logs. We only want to output the path to the file
-	if destination == "-" {
-		log.SetOutput(ioutil.Discard)
+// Dispatch runs a Github Actions workflow and waits for the run to complete.
+// Then it downloads and extracts an artifact of the run, like Artifact.
+func Dispatch(ctx context.Context, dispatch github.Dispatch, name string, options files.Options, destinationDirectory string) error {
+	headers := options.GitHubHeaders(ctx, options.GitHubAPI())
+	if len(headers) == 0 {
+		return errors.New("Running workflows requires a Github token. Use $GITHUB_TOKEN, --authToken or --authTokenEnvVariable")
 	}
 
-	source, err := cache.Download(url, options, force)
+	runID, err := dispatch.Run(ctx, options.GitHubAPI(), headers)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Copy", url, "to", destination)
+	return Artifact(ctx, github.ArtifactQuery{Org: dispatch.Org, Project: dispatch.Project, Name: name, RunID: runID}, options, destinationDirectory)
+}
 
-	return files.Copy(source, destination)
+// Cat retrieves an url from the cache or download it if it's absent.
+// Then it prints a single file from that archive to stdout.
+func Cat(ctx context.Context, url string, options files.Options, file string) error {
+	return ExtractFiles(ctx, url, options, []files.ExtractedFile{{Source: file, Destination: "-"}})
 }
 
-// Extract retrieves an url from the cache or download it if it's absent.
-// Then it unzips the file to a destination directory.
-func Extract(url string, options files.Options, destinationDirectory string) error {
-	source, err := cache.Download(url, options, force)
+// List retrieves an url from the cache or download it if it's absent.
+// Then it prints the entries of that archive to stdout.
+func List(ctx context.Context, url string, options files.Options) error {
+	entries, err := newClient(options).List(ctx, url)
 	if err != nil {
 		return err
 	}
+	if progress != nil {
+		progress.Wait()
+	}
 
-	log.Println("Extract", url, "to", destinationDirectory)
+	for _, entry := range entries {
+		if entry.Linkname != "" {
+			fmt.Printf("%s %12d %s -> %s\n", entry.Mode, entry.Size, entry.Name, entry.Linkname)
+		} else {
+			fmt.Printf("%s %12d %s\n", entry.Mode, entry.Size, entry.Name)
+		}
+	}
 
-	if urls.IsZipArchive(url) {
-		return zip.Extract(source, destinationDirectory)
+	return nil
+}
+
+// Serve serves the cache over http until the context is cancelled. Only http
+// and https urls can be fetched, anonymously, unless the clients are trusted.
+// Trusted clients can also fetch urls with one of the given schemes.
+func Serve(ctx context.Context, options files.Options, listen string, trusted bool, schemes []string) error {
+	if len(schemes) > 0 && !trusted {
+		return errors.New("Other schemes than http and https can only be allowed with --trust-clients")
 	}
-	if urls.IsTarArchive(url) {
-		return tar.Extract(url, source, destinationDirectory)
+
+	server := &server.Server{Client: newClient(options), Trusted: trusted, Schemes: schemes}
+	return server.ListenAndServe(ctx, listen)
+}
+
+// Proxy runs a caching http proxy until the context is cancelled. With a CA,
+// https requests are cached too.
+func Proxy(ctx context.Context, options files.Options, listen, caCert, caKey string) error {
+	proxy := &server.Proxy{Client: newClient(options)}
+
+	if caCert != "" || caKey != "" {
+		if caCert == "" || caKey == "" {
+			return errors.New("Both --ca-cert and --ca-key must be given")
+		}
+
+		ca, err := server.LoadCA(caCert, caKey)
+		if err != nil {
+			return err
+		}
+		proxy.CA = ca
 	}
 
-	return errors.New("Unsupported archive: " + source)
+	return proxy.ListenAndServe(ctx, listen)
 }
 
-// ExtractFiles retrieves an url from the cache or download it if it's absent.
-// Then it unzips some files from that zip to a destination path.
-func ExtractFiles(url string, options files.Options, files []files.ExtractedFile) error {
-	source, err := cache.Download(url, options, force)
+// Login reads a token and stores it in the keychain of the OS. Downloads
+// from this host then use it.
+func Login(host string, input io.Reader) error {
+	fmt.Fprintf(os.Stderr, "Token for %s: ", host)
+
+	token, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if err := keychain.Store(host, strings.TrimSpace(token)); err != nil {
+		return err
+	}
+
+	logs.Infoln("Token stored for", host)
+	return nil
+}
+
+// Doctor diagnoses the environment: cache directory, proxies, connectivity
+// and credentials. Urls to check can be given.
+func Doctor(options files.Options, urls []string) error {
+	cacheDir, err := cache.LocalDir()
 	if err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		log.Println("Extract", file.Source, "from", url, "to", file.Destination)
+	return doctor.Run(os.Stdout, doctor.Config{
+		CacheDir:  cacheDir,
+		AuthToken: options.Token(),
+		S3:        options.S3(),
+		URLs:      urls,
+	})
+}
+
+// newClient gives a client configured by the flags.
+func newClient(options files.Options) *getme.Client {
+	client := &getme.Client{
+		Options:        options,
+		Force:          force,
+		IfMissing:      ifMissing,
+		IfExists:       extractOptions.IfExists,
+		Link:           linkMode,
+		ExtractOptions: extractOptions,
+		Stream:         stream,
+		StreamToCache:  streamToCache,
+		Xattrs:         xattrs,
+		Hooks:          clientHooks,
+		Audit:          auditLog,
+	}
+	if progress != nil {
+		client.Events = progress.Events()
+	}
+	return client
+}
+
+// batchOf groups the arguments of a command by jobs of a given size. The
+// jobs can also be read from a file, with one job per line.
+func batchOf(args []string, fromFile string, size int, usage error) ([][]string, error) {
+	if fromFile != "" {
+		if len(args) > 0 {
+			return nil, errors.New("Urls can't be given both as arguments and with --from-file")
+		}
+
+		lines, err := readBatch(fromFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if len(line) != size {
+				return nil, fmt.Errorf("Expected %d arguments per line of %s: %s", size, fromFile, strings.Join(line, " "))
+			}
+		}
+		return lines, nil
+	}
+
+	if len(args) == 0 || len(args)%size != 0 {
+		return nil, usage
+	}
+
+	var batch [][]string
+	for i := 0; i < len(args); i += size {
+		batch = append(batch, args[i:i+size])
+	}
+	return batch, nil
+}
+
+// readBatch reads the arguments of jobs from a file, or from stdin with `-`.
+// Each line is a job. Empty lines and lines starting with # are ignored.
+func readBatch(path string) ([][]string, error) {
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var batch [][]string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		batch = append(batch, strings.Fields(line))
+	}
+
+	return batch, scanner.Err()
+}
+
+// bindEnv gives the flags that are not on the command line the value of their
+// GETME_* env variable, like GETME_SHA256 for --sha256 or GETME_S3_ACCESS_KEY
+// for --s3AccessKey. They take precedence over the config file.
+func bindEnv(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		name := envName(flag.Name)
+
+		value := os.Getenv(name)
+		if err != nil || flag.Changed || value == "" {
+			return
+		}
+
+		if setErr := cmd.Flags().Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("Invalid value for %s: %s", name, setErr)
+		}
+	})
+	return err
+}
+
+// envName gives the env variable bound to a flag: `authToken` and `auth-token`
+// give `GETME_AUTH_TOKEN`.
+func envName(flag string) string {
+	name := "GETME_"
+	for i, c := range flag {
+		switch {
+		case c == '-':
+			name += "_"
+		case unicode.IsUpper(c) && i > 0 && !unicode.IsUpper(rune(flag[i-1])) && flag[i-1] != '-':
+			name += "_" + string(c)
+		default:
+			name += string(unicode.ToUpper(c))
+		}
+	}
+	return name
+}
+
+// loadConfig reads the config file. Its settings are the default values of
+// the flags that are not given on the command line. The settings of each host
+// are returned.
+func loadConfig(cmd *cobra.Command) (config.Hosts, error) {
+	settings, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
 	}
 
-	if urls.IsZipArchive(url) {
-		return zip.ExtractFiles(source, files)
+	for name, values := range settings.Flags {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			if !isFlag(cmd.Root(), name) {
+				return nil, fmt.Errorf("Unknown setting [%s] in %s", name, configFile)
+			}
+			continue
+		}
+		if flag.Changed || name == "config" {
+			continue
+		}
+
+		for _, value := range values {
+			if err := flag.Value.Set(value); err != nil {
+				return nil, fmt.Errorf("Invalid setting [%s] in %s: %s", name, configFile, err)
+			}
+		}
 	}
-	if urls.IsTarArchive(url) {
-		return tar.ExtractFiles(url, source, files)
+
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy [%s]: %s", proxy, err)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		http.DefaultTransport = transport
 	}
 
-	return errors.New("Unsupported archive: " + source)
+	http.DefaultTransport, err = settings.Hosts.Transport(http.DefaultTransport)
+	return settings.Hosts, err
+}
+
+// isFlag tells if a command, or any of its sub-commands, has a given flag.
+func isFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range cmd.Commands() {
+		if isFlag(sub, name) {
+			return true
+		}
+	}
+	return false
 }
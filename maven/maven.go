@@ -0,0 +1,178 @@
+// Package maven downloads artifacts given by their Maven coordinates, as
+// `maven://groupId:artifactId:version[:classifier][@packaging]`, from Maven
+// Central or another Maven repository.
+//
+// The `LATEST` and `RELEASE` versions are read from the maven-metadata.xml
+// of the artifact. Artifacts are verified against the `.sha256` or `.sha1`
+// file published next to them.
+package maven
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// DefaultRepository is Maven Central.
+const DefaultRepository = "https://repo1.maven.org/maven2"
+
+// Artifact is given by Maven coordinates.
+type Artifact struct {
+	Group      string
+	Name       string
+	Version    string
+	Classifier string
+	Packaging  string
+}
+
+// IsArtifactURL tells if an url is a maven:// url.
+func IsArtifactURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "maven://")
+}
+
+// ParseURL parses a maven:// url.
+func ParseURL(rawURL string) (Artifact, error) {
+	coordinates := strings.TrimPrefix(rawURL, "maven://")
+
+	packaging := "jar"
+	if i := strings.LastIndex(coordinates, "@"); i >= 0 {
+		coordinates, packaging = coordinates[:i], coordinates[i+1:]
+	}
+
+	parts := strings.Split(coordinates, ":")
+	if !IsArtifactURL(rawURL) || len(parts) < 3 || len(parts) > 4 || packaging == "" || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Artifact{}, fmt.Errorf("Invalid Maven url. Should be maven://groupId:artifactId:version[:classifier][@packaging]: %s", rawURL)
+	}
+
+	artifact := Artifact{Group: parts[0], Name: parts[1], Version: parts[2], Packaging: packaging}
+	if len(parts) == 4 {
+		artifact.Classifier = parts[3]
+	}
+	return artifact, nil
+}
+
+// URL gives the maven:// url of the artifact.
+func (a Artifact) URL() string {
+	url := "maven://" + a.Group + ":" + a.Name + ":" + a.Version
+	if a.Classifier != "" {
+		url += ":" + a.Classifier
+	}
+	if a.Packaging != "jar" {
+		url += "@" + a.Packaging
+	}
+	return url
+}
+
+// Location gives the url of the artifact in a repository.
+func (a Artifact) Location(repository string) string {
+	name := a.Name + "-" + a.Version
+	if a.Classifier != "" {
+		name += "-" + a.Classifier
+	}
+	return a.directory(repository) + "/" + a.Version + "/" + name + "." + a.Packaging
+}
+
+func (a Artifact) directory(repository string) string {
+	return strings.TrimSuffix(repository, "/") + "/" + strings.Replace(a.Group, ".", "/", -1) + "/" + a.Name
+}
+
+type metadata struct {
+	Versioning struct {
+		Latest  string `xml:"latest"`
+		Release string `xml:"release"`
+	} `xml:"versioning"`
+}
+
+// Pin turns the `LATEST` and `RELEASE` versions into the actual version, so
+// that the artifact is cached as such.
+func Pin(ctx context.Context, a Artifact, repository string, headers []string) (Artifact, error) {
+	if a.Version != "LATEST" && a.Version != "RELEASE" {
+		return a, nil
+	}
+
+	content, err := get(ctx, a.directory(repository)+"/maven-metadata.xml", headers)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	found := metadata{}
+	if err := xml.Unmarshal(content, &found); err != nil {
+		return Artifact{}, err
+	}
+
+	version := found.Versioning.Release
+	if a.Version == "LATEST" {
+		version = found.Versioning.Latest
+	}
+	if version == "" {
+		return Artifact{}, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find the %s version of %s:%s", a.Version, a.Group, a.Name)
+	}
+
+	a.Version = version
+	return a, nil
+}
+
+// Checksum reads the digest published next to an artifact: its sha256 or,
+// for older artifacts, its sha1. It gives an empty digest if there's none.
+func Checksum(ctx context.Context, location string, headers []string) (string, string, error) {
+	for _, algorithm := range []string{"sha256", "sha1"} {
+		content, err := get(ctx, location+"."+algorithm, headers)
+		if errors.Is(err, errdefs.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+
+		// Some checksum files are written as `<digest>  <file name>`.
+		fields := strings.Fields(string(content))
+		if len(fields) == 0 {
+			continue
+		}
+		return algorithm, fields[0], nil
+	}
+
+	return "", "", nil
+}
+
+func get(ctx context.Context, url string, headers []string) ([]byte, error) {
+	body, err := Open(ctx, url, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// Open opens a file of a repository.
+func Open(ctx context.Context, url string, headers []string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(headers, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,63 @@
+package maven
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	artifact, err := ParseURL("maven://org.flywaydb:flyway-commandline:10.8.1:linux-x64@tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, Artifact{Group: "org.flywaydb", Name: "flyway-commandline", Version: "10.8.1", Classifier: "linux-x64", Packaging: "tar.gz"}, artifact)
+	assert.Equal(t, "maven://org.flywaydb:flyway-commandline:10.8.1:linux-x64@tar.gz", artifact.URL())
+	assert.Equal(t, "https://repo1.maven.org/maven2/org/flywaydb/flyway-commandline/10.8.1/flyway-commandline-10.8.1-linux-x64.tar.gz", artifact.Location(DefaultRepository))
+
+	artifact, err = ParseURL("maven://com.example:tool:1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "maven://com.example:tool:1.0", artifact.URL())
+	assert.Equal(t, "https://repo.example.com/com/example/tool/1.0/tool-1.0.jar", artifact.Location("https://repo.example.com/"))
+
+	_, err = ParseURL("maven://com.example:tool")
+	assert.Error(t, err)
+	_, err = ParseURL("maven://com.example:tool:1.0@")
+	assert.Error(t, err)
+}
+
+func TestPinAndChecksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/com/example/tool/maven-metadata.xml":
+			fmt.Fprint(w, `<metadata><versioning><latest>1.1-SNAPSHOT</latest><release>1.0</release></versioning></metadata>`)
+		case "/com/example/tool/1.0/tool-1.0.jar.sha1":
+			fmt.Fprint(w, "040f06fd774092478d450774f5ba30c5da78acc8  tool-1.0.jar\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	artifact, err := Pin(context.Background(), Artifact{Group: "com.example", Name: "tool", Version: "RELEASE", Packaging: "jar"}, server.URL, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", artifact.Version)
+
+	latest, err := Pin(context.Background(), Artifact{Group: "com.example", Name: "tool", Version: "LATEST", Packaging: "jar"}, server.URL, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1-SNAPSHOT", latest.Version)
+
+	algorithm, checksum, err := Checksum(context.Background(), artifact.Location(server.URL), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha1", algorithm)
+	assert.Equal(t, "040f06fd774092478d450774f5ba30c5da78acc8", checksum)
+
+	_, checksum, err = Checksum(context.Background(), server.URL+"/com/example/other/1.0/other-1.0.jar", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, checksum)
+
+	_, err = Pin(context.Background(), Artifact{Group: "com.example", Name: "other", Version: "LATEST"}, server.URL, nil)
+	assert.Error(t, err)
+}
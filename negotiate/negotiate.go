@@ -0,0 +1,75 @@
+package negotiate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/tempfile"
+)
+
+// Open downloads an url from a server protected by Kerberos, with SPNEGO
+// negotiate authentication. It relies on curl so that the Kerberos ticket
+// cache, filled by kinit, or Windows integrated authentication are used.
+func Open(ctx context.Context, url string, headers []string) (io.ReadCloser, error) {
+	tmp, err := ioutil.TempFile("", "getme-negotiate")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+
+	args, err := curlArgs(url, headers, tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "curl", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp.Name())
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if _, lookErr := exec.LookPath("curl"); lookErr != nil {
+			return nil, fmt.Errorf("Negotiate authentication requires the curl command")
+		}
+		return nil, fmt.Errorf("Unable to download %s: %s", url, strings.TrimSpace(stderr.String()))
+	}
+
+	file, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tempfile.New(file), nil
+}
+
+// curlArgs gives the arguments of curl. `-u :` takes the identity from the
+// Kerberos ticket.
+func curlArgs(url string, headers []string, output string) ([]string, error) {
+	args := []string{"--negotiate", "-u", ":", "--fail", "--location", "--silent", "--show-error", "-o", output}
+
+	for _, header := range headers {
+		key, value, err := http_headers.Split(header)
+		if err != nil {
+			return nil, err
+		}
+		// Negotiate replaces any other authentication.
+		if strings.EqualFold(key, "Authorization") {
+			continue
+		}
+		args = append(args, "-H", key+": "+value)
+	}
+
+	return append(args, "--", url), nil
+}
@@ -0,0 +1,47 @@
+package negotiate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurlArgs(t *testing.T) {
+	args, err := curlArgs("https://artifactory.corp/file.zip", []string{"Authorization=Bearer token", "X-Team: build"}, "/tmp/out")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--negotiate", "-u", ":", "--fail", "--location", "--silent", "--show-error", "-o", "/tmp/out", "-H", "X-Team: build", "--", "https://artifactory.corp/file.zip"}, args)
+
+	_, err = curlArgs("https://artifactory.corp/file.zip", []string{"invalid"}, "/tmp/out")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("Requires curl")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "content")
+	}))
+	defer server.Close()
+
+	reader, err := Open(context.Background(), server.URL+"/file.zip", nil)
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "content", string(content))
+
+	_, err = Open(context.Background(), server.URL+"/missing.zip", nil)
+	assert.Error(t, err)
+}
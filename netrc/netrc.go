@@ -0,0 +1,137 @@
+package netrc
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Machine holds the credentials of a host.
+type Machine struct {
+	Login    string
+	Password string
+}
+
+// Path gives the path to the netrc file. $NETRC takes precedence over
+// `~/.netrc`, or `~/_netrc` on Windows.
+func Path() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home := os.Getenv("HOME")
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		home = os.Getenv("USERPROFILE")
+		name = "_netrc"
+	}
+	if home == "" {
+		return ""
+	}
+
+	return filepath.Join(home, name)
+}
+
+// Lookup finds the credentials of a host in the netrc file. The port, if
+// any, is ignored. The `default` entry is used for unknown hosts.
+func Lookup(host string) (Machine, bool) {
+	path := Path()
+	if path == "" {
+		return Machine{}, false
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Machine{}, false
+	}
+
+	return find(parse(strings.NewReader(string(content))), host)
+}
+
+func find(machines map[string]Machine, host string) (Machine, bool) {
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	if machine, found := machines[host]; found {
+		return machine, true
+	}
+	machine, found := machines[""]
+	return machine, found
+}
+
+// parse reads the machines of a netrc file. The `default` machine is stored
+// under an empty name. Macros are skipped.
+func parse(r io.Reader) map[string]Machine {
+	machines := map[string]Machine{}
+
+	scanner := bufio.NewScanner(r)
+	var tokens []string
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" {
+				// The macro starts on the next line and ends with a blank line.
+				fields = fields[:i]
+				inMacro = true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+
+	name, current := "", (*Machine)(nil)
+	store := func() {
+		if current != nil {
+			if _, found := machines[name]; !found {
+				machines[name] = *current
+			}
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		next := func() string {
+			if i+1 >= len(tokens) {
+				return ""
+			}
+			i++
+			return tokens[i]
+		}
+
+		switch tokens[i] {
+		case "machine":
+			store()
+			name, current = next(), &Machine{}
+		case "default":
+			store()
+			name, current = "", &Machine{}
+		case "login":
+			if login := next(); current != nil {
+				current.Login = login
+			}
+		case "password":
+			if password := next(); current != nil {
+				current.Password = password
+			}
+		case "account":
+			next()
+		}
+	}
+	store()
+
+	return machines
+}
@@ -0,0 +1,69 @@
+package netrc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const content = `# Private servers
+machine artifacts.example.com login user password secret
+machine api.github.com
+  login token
+  password ghp_123
+  account ignored
+
+macdef init
+machine evil.example.com login evil password evil
+
+default login anonymous password guest
+`
+
+func TestParse(t *testing.T) {
+	machines := parse(strings.NewReader(content))
+
+	assert.Equal(t, map[string]Machine{
+		"artifacts.example.com": {Login: "user", Password: "secret"},
+		"api.github.com":        {Login: "token", Password: "ghp_123"},
+		"":                      {Login: "anonymous", Password: "guest"},
+	}, machines)
+}
+
+func TestFind(t *testing.T) {
+	machines := parse(strings.NewReader(content))
+
+	machine, found := find(machines, "artifacts.example.com:8443")
+	assert.True(t, found)
+	assert.Equal(t, "user", machine.Login)
+
+	machine, found = find(machines, "other.example.com")
+	assert.True(t, found)
+	assert.Equal(t, "anonymous", machine.Login)
+
+	_, found = find(parse(strings.NewReader("machine host login user")), "other")
+	assert.False(t, found)
+}
+
+func TestLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-netrc-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "netrc")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	defer os.Setenv("NETRC", os.Getenv("NETRC"))
+	os.Setenv("NETRC", path)
+
+	machine, found := Lookup("api.github.com")
+	assert.True(t, found)
+	assert.Equal(t, Machine{Login: "token", Password: "ghp_123"}, machine)
+
+	os.Setenv("NETRC", filepath.Join(dir, "missing"))
+	_, found = Lookup("api.github.com")
+	assert.False(t, found)
+}
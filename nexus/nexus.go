@@ -0,0 +1,247 @@
+// Package nexus downloads Maven artifacts from Sonatype Nexus Repository 3,
+// given as `nexus://repo/group/artifact/version[/classifier]`.
+//
+// The extension defaults to jar and can be given with `?ext=`, like
+// `?ext=tgz`. The `LATEST` version is the highest version, snapshots
+// included, and `RELEASE` is the highest release.
+package nexus
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// Options configures access to a Nexus server.
+type Options struct {
+	// URL of the server, like https://nexus.example.com.
+	URL string
+
+	// Token is a user token, or credentials, given as `name:password`.
+	// Defaults to $NEXUS_TOKEN.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Artifact points to a file of a Maven component.
+type Artifact struct {
+	Repository string
+	Group      string
+	Name       string
+	Version    string
+	Classifier string
+	Extension  string
+}
+
+type asset struct {
+	DownloadURL string `json:"downloadUrl"`
+	Maven2      struct {
+		Version    string `json:"version"`
+		Classifier string `json:"classifier"`
+		Extension  string `json:"extension"`
+	} `json:"maven2"`
+}
+
+type searchResults struct {
+	Items             []asset `json:"items"`
+	ContinuationToken string  `json:"continuationToken"`
+}
+
+// IsArtifactURL tells if an url is a nexus:// url.
+func IsArtifactURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "nexus://")
+}
+
+// ParseURL parses a nexus:// url.
+func ParseURL(rawURL string) (Artifact, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	parts := strings.Split(strings.TrimPrefix(parsed.Path, "/"), "/")
+	if parsed.Scheme != "nexus" || parsed.Host == "" || len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Artifact{}, fmt.Errorf("Invalid Nexus url. Should be nexus://repo/group/artifact/version[/classifier]: %s", rawURL)
+	}
+
+	artifact := Artifact{
+		Repository: parsed.Host,
+		Group:      parts[0],
+		Name:       parts[1],
+		Version:    parts[2],
+		Extension:  parsed.Query().Get("ext"),
+	}
+	if len(parts) == 4 {
+		artifact.Classifier = parts[3]
+	}
+	if artifact.Extension == "" {
+		artifact.Extension = "jar"
+	}
+
+	return artifact, nil
+}
+
+// URL gives the nexus:// url of the artifact.
+func (a Artifact) URL() string {
+	path := "/" + a.Group + "/" + a.Name + "/" + a.Version
+	if a.Classifier != "" {
+		path += "/" + a.Classifier
+	}
+
+	u := url.URL{Scheme: "nexus", Host: a.Repository, Path: path}
+	if a.Extension != "jar" {
+		u.RawQuery = url.Values{"ext": {a.Extension}}.Encode()
+	}
+	return u.String()
+}
+
+// Pin turns the `LATEST` and `RELEASE` versions into the actual version, so
+// that the artifact is cached as such.
+func Pin(ctx context.Context, a Artifact, options Options) (Artifact, error) {
+	if a.Version != "LATEST" && a.Version != "RELEASE" {
+		return a, nil
+	}
+
+	params := a.params()
+	params.Del("version")
+	params.Set("sort", "version")
+	params.Set("direction", "desc")
+	if a.Version == "RELEASE" {
+		params.Set("prerelease", "false")
+	}
+
+	found, err := find(ctx, a, params, options)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	a.Version = found.Maven2.Version
+	return a, nil
+}
+
+// ArtifactURL finds the download url of an artifact.
+func ArtifactURL(ctx context.Context, a Artifact, options Options) (string, error) {
+	a, err := Pin(ctx, a, options)
+	if err != nil {
+		return "", err
+	}
+
+	found, err := find(ctx, a, a.params(), options)
+	if err != nil {
+		return "", err
+	}
+	return found.DownloadURL, nil
+}
+
+// Open downloads an artifact.
+func Open(ctx context.Context, rawURL string, options Options) (io.ReadCloser, error) {
+	a, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadURL, err := ArtifactURL(ctx, a, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return options.do(ctx, downloadURL)
+}
+
+func (a Artifact) params() url.Values {
+	params := url.Values{
+		"repository":       {a.Repository},
+		"maven.groupId":    {a.Group},
+		"maven.artifactId": {a.Name},
+		"maven.extension":  {a.Extension},
+		"version":          {a.Version},
+	}
+	if a.Classifier != "" {
+		params.Set("maven.classifier", a.Classifier)
+	}
+	return params
+}
+
+// find gives the first asset that matches a search. The search api ignores
+// an empty classifier so assets with a classifier are skipped here.
+func find(ctx context.Context, a Artifact, params url.Values, options Options) (asset, error) {
+	for {
+		var results searchResults
+		if err := options.get(ctx, "/service/rest/v1/search/assets?"+params.Encode(), &results); err != nil {
+			return asset{}, err
+		}
+
+		for _, item := range results.Items {
+			if item.Maven2.Classifier == a.Classifier && item.Maven2.Extension == a.Extension {
+				return item, nil
+			}
+		}
+
+		if results.ContinuationToken == "" {
+			return asset{}, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find %s", a.URL())
+		}
+		params.Set("continuationToken", results.ContinuationToken)
+	}
+}
+
+func (o Options) get(ctx context.Context, path string, v interface{}) error {
+	if o.URL == "" {
+		return errors.New("Nexus urls require the url of the server. Use --nexus-url")
+	}
+
+	body, err := o.do(ctx, strings.TrimSuffix(o.URL, "/")+path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+func (o Options) do(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return nil, err
+	}
+
+	token := o.Token
+	if token == "" {
+		token = os.Getenv("NEXUS_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(token)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,97 @@
+package nexus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	artifact, err := ParseURL("nexus://releases/com.example/tool/1.0.0/linux?ext=tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, Artifact{Repository: "releases", Group: "com.example", Name: "tool", Version: "1.0.0", Classifier: "linux", Extension: "tgz"}, artifact)
+	assert.Equal(t, "nexus://releases/com.example/tool/1.0.0/linux?ext=tgz", artifact.URL())
+
+	artifact, err = ParseURL("nexus://releases/com.example/tool/RELEASE")
+	assert.NoError(t, err)
+	assert.Equal(t, "jar", artifact.Extension)
+	assert.Equal(t, "nexus://releases/com.example/tool/RELEASE", artifact.URL())
+
+	_, err = ParseURL("nexus://releases/com.example/tool")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	os.Unsetenv("NEXUS_TOKEN")
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "name" || password != "code" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/service/rest/v1/search/assets" {
+			fmt.Fprint(w, "content of "+r.URL.Path)
+			return
+		}
+
+		query := r.URL.Query()
+		assert.Equal(t, "releases", query.Get("repository"))
+		assert.Equal(t, "com.example", query.Get("maven.groupId"))
+		assert.Equal(t, "tool", query.Get("maven.artifactId"))
+
+		switch {
+		case query.Get("sort") == "version" && query.Get("prerelease") == "false":
+			fmt.Fprint(w, `{"items":[{"maven2":{"version":"1.1.0","extension":"jar"}}]}`)
+		case query.Get("sort") == "version":
+			fmt.Fprint(w, `{"items":[{"maven2":{"version":"1.2.0-SNAPSHOT","extension":"jar"}}]}`)
+		case query.Get("version") == "1.1.0" && query.Get("continuationToken") == "":
+			fmt.Fprint(w, `{"items":[{"downloadUrl":"`+server.URL+`/tool-1.1.0-sources.jar","maven2":{"version":"1.1.0","classifier":"sources","extension":"jar"}}],"continuationToken":"next"}`)
+		case query.Get("version") == "1.1.0":
+			fmt.Fprint(w, `{"items":[{"downloadUrl":"`+server.URL+`/tool-1.1.0.jar","maven2":{"version":"1.1.0","extension":"jar"}}]}`)
+		default:
+			fmt.Fprint(w, `{"items":[]}`)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{URL: server.URL, Token: "name:code"}
+
+	artifact, err := Pin(context.Background(), Artifact{Repository: "releases", Group: "com.example", Name: "tool", Version: "LATEST", Extension: "jar"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0-SNAPSHOT", artifact.Version)
+
+	content, err := read("nexus://releases/com.example/tool/RELEASE", options)
+	assert.NoError(t, err)
+	assert.Equal(t, "content of /tool-1.1.0.jar", content)
+
+	_, err = read("nexus://releases/com.example/tool/0.1.0", options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, err = read("nexus://releases/com.example/tool/1.1.0", Options{URL: server.URL})
+	assert.True(t, errors.Is(err, errdefs.ErrUnauthorized))
+
+	_, err = read("nexus://releases/com.example/tool/1.1.0", Options{})
+	assert.Error(t, err)
+}
+
+func read(url string, options Options) (string, error) {
+	reader, err := Open(context.Background(), url, options)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
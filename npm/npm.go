@@ -0,0 +1,171 @@
+// Package npm downloads the tarballs of packages published to an npm
+// registry, given as `npm://package@version`, like `npm://typescript@5.4.2`
+// or `npm://@angular/cli@latest`.
+//
+// The version is either an exact version or a dist-tag, `latest` by default.
+// Tarballs are verified against the integrity given by the registry.
+package npm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// DefaultRegistry is the public npm registry.
+const DefaultRegistry = "https://registry.npmjs.org"
+
+// Options configures access to an npm registry.
+type Options struct {
+	// Registry is the url of the registry. Defaults to DefaultRegistry.
+	Registry string
+
+	// Token is an access token. Defaults to $NPM_TOKEN.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Package is a version of a package.
+type Package struct {
+	Name    string
+	Version string
+}
+
+// Dist tells where the tarball of a version is, and its digest.
+type Dist struct {
+	Version   string
+	Tarball   string
+	Algorithm string
+	Digest    string
+}
+
+// IsPackageURL tells if an url is an npm:// url.
+func IsPackageURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "npm://")
+}
+
+// ParseURL parses an npm:// url. Scoped packages start with `@`.
+func ParseURL(rawURL string) (Package, error) {
+	spec := strings.TrimPrefix(rawURL, "npm://")
+
+	pkg := Package{Name: spec, Version: "latest"}
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		pkg.Name, pkg.Version = spec[:i], spec[i+1:]
+	}
+
+	slashes := 0
+	if strings.HasPrefix(pkg.Name, "@") {
+		slashes = 1
+	}
+	if !IsPackageURL(rawURL) || pkg.Name == "" || pkg.Version == "" || strings.Count(pkg.Name, "/") != slashes {
+		return Package{}, fmt.Errorf("Invalid npm url. Should be npm://package@version or npm://@scope/package@version: %s", rawURL)
+	}
+
+	return pkg, nil
+}
+
+// URL gives the npm:// url of the package.
+func (p Package) URL() string {
+	return "npm://" + p.Name + "@" + p.Version
+}
+
+type manifest struct {
+	Version string `json:"version"`
+	Dist    struct {
+		Tarball   string `json:"tarball"`
+		Integrity string `json:"integrity"`
+		Shasum    string `json:"shasum"`
+	} `json:"dist"`
+}
+
+// Resolve reads where the tarball of a version is, and its digest. Dist-tags
+// are resolved to the version they point to.
+func Resolve(ctx context.Context, p Package, options Options) (Dist, error) {
+	// The slash of scoped packages is escaped.
+	found := manifest{}
+	if err := options.get(ctx, options.RegistryURL()+"/"+url.PathEscape(p.Name)+"/"+url.PathEscape(p.Version), &found); err != nil {
+		return Dist{}, fmt.Errorf("Unable to find %s@%s: %w", p.Name, p.Version, err)
+	}
+	if found.Dist.Tarball == "" {
+		return Dist{}, errdefs.Errorf(errdefs.ErrNotFound, "Unable to find the tarball of %s@%s", p.Name, p.Version)
+	}
+
+	dist := Dist{Version: found.Version, Tarball: found.Dist.Tarball}
+
+	// Integrity is a subresource integrity, like `sha512-<base64 digest>`.
+	// Older packages only have the sha1 shasum.
+	if parts := strings.SplitN(found.Dist.Integrity, "-", 2); len(parts) == 2 && (parts[0] == "sha512" || parts[0] == "sha256") {
+		digest, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return Dist{}, fmt.Errorf("Invalid integrity of %s@%s: %s", p.Name, p.Version, err)
+		}
+		dist.Algorithm, dist.Digest = parts[0], hex.EncodeToString(digest)
+	} else if found.Dist.Shasum != "" {
+		dist.Algorithm, dist.Digest = "sha1", found.Dist.Shasum
+	}
+
+	return dist, nil
+}
+
+// RegistryURL gives the url of the registry.
+func (o Options) RegistryURL() string {
+	if o.Registry == "" {
+		return DefaultRegistry
+	}
+	return strings.TrimSuffix(o.Registry, "/")
+}
+
+// AuthHeaders gives the headers that authenticate to the registry, if any.
+func (o Options) AuthHeaders() []string {
+	token := o.Token
+	if token == "" {
+		token = os.Getenv("NPM_TOKEN")
+	}
+	if token == "" {
+		return nil
+	}
+	return []string{"Authorization=Bearer " + token}
+}
+
+func (o Options) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return err
+	}
+	if err := http_headers.Add(o.AuthHeaders(), req); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
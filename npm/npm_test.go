@@ -0,0 +1,65 @@
+package npm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	pkg, err := ParseURL("npm://typescript@5.4.2")
+	assert.NoError(t, err)
+	assert.Equal(t, Package{Name: "typescript", Version: "5.4.2"}, pkg)
+
+	pkg, err = ParseURL("npm://@angular/cli")
+	assert.NoError(t, err)
+	assert.Equal(t, Package{Name: "@angular/cli", Version: "latest"}, pkg)
+	assert.Equal(t, "npm://@angular/cli@latest", pkg.URL())
+
+	_, err = ParseURL("npm://typescript@")
+	assert.Error(t, err)
+	_, err = ParseURL("npm://org/tool@1.0.0")
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	os.Unsetenv("NPM_TOKEN")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		switch r.URL.EscapedPath() {
+		case "/@angular%2Fcli/latest":
+			fmt.Fprint(w, `{"version":"17.3.0","dist":{"tarball":"https://registry.npmjs.org/@angular/cli/-/cli-17.3.0.tgz","integrity":"sha512-7QAG/XGkGKYgVR6GCfCV02MhDnKXnmmK99jnVQGl5MYKq7UsTRqMmgSJOv3bMdFCAeHmPc7+xrP2N6ry/LdmFQ==","shasum":"ignored"}}`)
+		case "/left-pad/1.0.0":
+			fmt.Fprint(w, `{"version":"1.0.0","dist":{"tarball":"https://registry.npmjs.org/left-pad/-/left-pad-1.0.0.tgz","shasum":"040f06fd774092478d450774f5ba30c5da78acc8"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{Registry: server.URL + "/", Token: "token"}
+
+	dist, err := Resolve(context.Background(), Package{Name: "@angular/cli", Version: "latest"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "17.3.0", dist.Version)
+	assert.Equal(t, "https://registry.npmjs.org/@angular/cli/-/cli-17.3.0.tgz", dist.Tarball)
+	assert.Equal(t, "sha512", dist.Algorithm)
+	assert.Equal(t, "ed0006fd71a418a620551e8609f095d363210e72979e698af7d8e75501a5e4c60aabb52c4d1a8c9a04893afddb31d14201e1e63dcefec6b3f637aaf2fcb76615", dist.Digest)
+
+	dist, err = Resolve(context.Background(), Package{Name: "left-pad", Version: "1.0.0"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha1", dist.Algorithm)
+	assert.Equal(t, "040f06fd774092478d450774f5ba30c5da78acc8", dist.Digest)
+
+	_, err = Resolve(context.Background(), Package{Name: "left-pad", Version: "9.9.9"}, options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+}
@@ -0,0 +1,122 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerConfig is the part of ~/.docker/config.json that holds credentials.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// credentials finds the credentials to a registry the same way the docker cli
+// does: first with a registry specific credential helper, then with the
+// default credentials store and finally in the config file itself.
+// Anonymous access is used if none is found.
+func credentials(ctx context.Context, registry string) (username, secret string, err error) {
+	config, err := readDockerConfig()
+	if err != nil || config == nil {
+		return "", "", err
+	}
+
+	server := registry
+	if registry == dockerHub {
+		server = "https://index.docker.io/v1/"
+	}
+
+	if helper, ok := config.CredHelpers[registry]; ok {
+		return credentialHelper(ctx, helper, server)
+	}
+	if config.CredsStore != "" {
+		return credentialHelper(ctx, config.CredsStore, server)
+	}
+
+	for key, auth := range config.Auths {
+		if key != server && strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://") != registry {
+			continue
+		}
+
+		if auth.IdentityToken != "" {
+			return "<token>", auth.IdentityToken, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("Invalid auth for %s in docker config", key)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("Invalid auth for %s in docker config", key)
+		}
+		return parts[0], parts[1], nil
+	}
+
+	return "", "", nil
+}
+
+func readDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			home = os.Getenv("USERPROFILE")
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config dockerConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("Invalid docker config: %s", err)
+	}
+
+	return &config, nil
+}
+
+// credentialHelper runs `docker-credential-<helper> get`.
+func credentialHelper(ctx context.Context, helper, server string) (username, secret string, err error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Helpers fail when they don't know the server.
+		if strings.Contains(stdout.String()+stderr.String(), "credentials not found") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("Unable to get credentials from docker-credential-%s: %s", helper, err)
+	}
+
+	var response struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return "", "", fmt.Errorf("Invalid response from docker-credential-%s: %s", helper, err)
+	}
+
+	return response.Username, response.Secret, nil
+}
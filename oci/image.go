@@ -0,0 +1,255 @@
+package oci
+
+import (
+	archivetar "archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/dgageot/getme/tempfile"
+)
+
+// Whiteouts mark files deleted by an upper layer.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// IsImageURL tells if an url points to a container image.
+func IsImageURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "docker://")
+}
+
+// OpenImage downloads a container image, given a `docker://alpine:3.19` url,
+// and gives its flattened root filesystem as a tar archive. For multi-platform
+// images, the current architecture is picked unless a `?platform=linux/arm64`
+// is given.
+func OpenImage(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	// `alpine:3.19` is not a valid host for url.Parse.
+	name := strings.TrimPrefix(rawURL, "docker://")
+
+	var platform string
+	if question := strings.Index(name, "?"); question != -1 {
+		query, err := url.ParseQuery(name[question+1:])
+		if err != nil {
+			return nil, err
+		}
+		name, platform = name[:question], query.Get("platform")
+	}
+
+	reference, err := ParseReference(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if platform == "" {
+		platform = "linux/" + runtime.GOARCH
+	}
+
+	registry := NewRegistry(reference)
+
+	manifest, err := registry.Manifest(ctx, reference.Ref())
+	if err != nil {
+		return nil, err
+	}
+	if manifest.IsIndex() {
+		digest, err := findPlatform(manifest.Manifests, platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", reference, err)
+		}
+		if manifest, err = registry.Manifest(ctx, digest); err != nil {
+			return nil, err
+		}
+	}
+
+	tmp, err := ioutil.TempFile("", "getme-image")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flatten(ctx, registry, manifest.Layers, tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tempfile.New(tmp), nil
+}
+
+func findPlatform(manifests []Descriptor, platform string) (string, error) {
+	var available []string
+	for _, manifest := range manifests {
+		if manifest.Platform == nil {
+			continue
+		}
+
+		name := manifest.Platform.OS + "/" + manifest.Platform.Architecture
+		if name == platform || name+"/"+manifest.Platform.Variant == platform {
+			return manifest.Digest, nil
+		}
+		available = append(available, name)
+	}
+
+	return "", fmt.Errorf("no image for %s. Available platforms are [%s]", platform, strings.Join(available, ", "))
+}
+
+// flatten writes the content of all the layers as a single tar archive. Layers
+// are downloaded first and then read from the top most, so that the first
+// version of a file that's found is the one that ends up in the image.
+func flatten(ctx context.Context, registry *Registry, layers []Descriptor, writer io.Writer) error {
+	var downloaded []string
+	defer func() {
+		for _, name := range downloaded {
+			os.Remove(name)
+		}
+	}()
+
+	for _, layer := range layers {
+		if strings.HasSuffix(layer.MediaType, "zstd") {
+			return fmt.Errorf("Unsupported layer compression: %s", layer.MediaType)
+		}
+
+		name, err := download(ctx, registry, layer.Digest)
+		if err != nil {
+			return err
+		}
+		downloaded = append(downloaded, name)
+	}
+
+	tarWriter := archivetar.NewWriter(writer)
+
+	seen := map[string]bool{}
+	deleted := map[string]bool{}
+	for i := len(downloaded) - 1; i >= 0; i-- {
+		if err := copyLayer(downloaded[i], tarWriter, seen, deleted); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+func download(ctx context.Context, registry *Registry, digest string) (string, error) {
+	blob, err := registry.Blob(ctx, digest)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	tmp, err := ioutil.TempFile("", "getme-layer")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, blob); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// copyLayer copies the entries of a layer that are neither hidden by an upper
+// layer nor deleted by one. Its own whiteouts apply to the lower layers only.
+func copyLayer(name string, tarWriter *archivetar.Writer, seen, deleted map[string]bool) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := decompress(file)
+	if err != nil {
+		return err
+	}
+
+	var whiteouts []string
+	tarReader := archivetar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		dir, base := path.Split(entry)
+
+		if base == whiteoutOpaque {
+			whiteouts = append(whiteouts, path.Clean(dir)+"/")
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			whiteouts = append(whiteouts, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+
+		if seen[entry] || isDeleted(entry, deleted) {
+			continue
+		}
+		seen[entry] = true
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+
+	for _, whiteout := range whiteouts {
+		deleted[whiteout] = true
+	}
+
+	return nil
+}
+
+// isDeleted tells if an entry, or one of its parents, was deleted. Opaque
+// directories are recorded with a trailing slash since only their content is
+// hidden.
+func isDeleted(entry string, deleted map[string]bool) bool {
+	for current := entry; ; {
+		if deleted[current] {
+			return true
+		}
+
+		parent := path.Dir(current)
+		if deleted[parent+"/"] {
+			return true
+		}
+		if parent == current || parent == "." || parent == "/" {
+			return false
+		}
+		current = parent
+	}
+}
+
+// decompress detects gzip compressed layers by their magic number.
+func decompress(file *os.File) (io.Reader, error) {
+	magic := make([]byte, 2)
+	n, _ := io.ReadFull(file, magic)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(file)
+	}
+	return file, nil
+}
@@ -0,0 +1,111 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenImage(t *testing.T) {
+	base := layer(t, map[string]string{
+		"bin/sh":          "sh",
+		"etc/motd":        "welcome",
+		"etc/removed":     "removed",
+		"var/cache/index": "index",
+	})
+	top := layer(t, map[string]string{
+		"etc/motd":               "updated",
+		"etc/.wh.removed":        "",
+		"var/cache/.wh..wh..opq": "",
+		"var/cache/fresh":        "fresh",
+		"usr/local/bin/kubectl":  "kubectl",
+	})
+
+	image := Manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers: []Descriptor{
+			{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digestOf(base)},
+			{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digestOf(top)},
+		},
+	}
+	index := Manifest{
+		MediaType: mediaTypeOCIIndex,
+		Manifests: []Descriptor{platform("linux", "arm64", "sha256:unknown"), platform("linux", "s390x", "image")},
+	}
+	server := fakeRegistry(t, map[string]Manifest{"v1": index, "image": image}, base, top)
+	defer server.Close()
+
+	reader, err := OpenImage(context.Background(), "docker://"+server.Listener.Addr().String()+"/tools:v1?platform=linux/s390x")
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, map[string]string{
+		"bin/sh":                "sh",
+		"etc/motd":              "updated",
+		"var/cache/fresh":       "fresh",
+		"usr/local/bin/kubectl": "kubectl",
+	}, entries(t, reader))
+}
+
+func TestOpenImageUnknownPlatform(t *testing.T) {
+	index := Manifest{
+		MediaType: mediaTypeOCIIndex,
+		Manifests: []Descriptor{platform("linux", "arm64", "image")},
+	}
+	server := fakeRegistry(t, map[string]Manifest{"v1": index})
+	defer server.Close()
+
+	_, err := OpenImage(context.Background(), "docker://"+server.Listener.Addr().String()+"/tools:v1?platform=windows/amd64")
+	assert.EqualError(t, err, server.Listener.Addr().String()+"/tools:v1: no image for windows/amd64. Available platforms are [linux/arm64]")
+}
+
+func platform(os, architecture, digest string) Descriptor {
+	descriptor := Descriptor{Digest: digest}
+	descriptor.Platform = &struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	}{OS: os, Architecture: architecture}
+	return descriptor
+}
+
+func layer(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for name, content := range files {
+		assert.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+	return buffer.Bytes()
+}
+
+func entries(t *testing.T, reader io.Reader) map[string]string {
+	entries := map[string]string{}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadAll(tarReader)
+		assert.NoError(t, err)
+		entries[header.Name] = string(content)
+	}
+
+	return entries
+}
@@ -0,0 +1,76 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// TitleAnnotation names the file pushed as a layer by ORAS.
+const TitleAnnotation = "org.opencontainers.image.title"
+
+// HelmChartMediaType is the media type of the layer of Helm charts. Charts
+// can have a second layer, for their provenance file.
+const HelmChartMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+// Open downloads an artifact given an `oci://registry/repository:tag` url.
+// Artifacts with several layers require the layer to be named in the url's
+// fragment: `oci://registry/repository:tag#file.tgz`. For Helm charts, the
+// chart is downloaded.
+func Open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	reference, err := ParseReference(u.Host + u.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := NewRegistry(reference)
+
+	manifest, err := registry.Manifest(ctx, reference.Ref())
+	if err != nil {
+		return nil, err
+	}
+	if manifest.IsIndex() {
+		return nil, fmt.Errorf("%s is a multi-platform image, not an artifact", reference)
+	}
+
+	layer, err := findLayer(manifest.Layers, u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", reference, err)
+	}
+
+	return registry.Blob(ctx, layer.Digest)
+}
+
+func findLayer(layers []Descriptor, name string) (*Descriptor, error) {
+	if name == "" {
+		if len(layers) == 1 {
+			return &layers[0], nil
+		}
+		for i := range layers {
+			if layers[i].MediaType == HelmChartMediaType {
+				return &layers[i], nil
+			}
+		}
+		return nil, fmt.Errorf("the artifact has %d layers. Pick one of [%s] with #name", len(layers), strings.Join(titles(layers), ", "))
+	}
+
+	for i := range layers {
+		if layers[i].Annotations[TitleAnnotation] == name {
+			return &layers[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no layer named %s. Pick one of [%s]", name, strings.Join(titles(layers), ", "))
+}
+
+func titles(layers []Descriptor) []string {
+	var titles []string
+	for _, layer := range layers {
+		if title := layer.Annotations[TitleAnnotation]; title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
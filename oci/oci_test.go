@@ -0,0 +1,154 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReference(t *testing.T) {
+	reference, err := ParseReference("ghcr.io/org/tools:v1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, Reference{Registry: "ghcr.io", Repository: "org/tools", Tag: "v1.0"}, reference)
+
+	reference, err = ParseReference("alpine")
+	assert.NoError(t, err)
+	assert.Equal(t, Reference{Registry: "docker.io", Repository: "library/alpine", Tag: "latest"}, reference)
+
+	reference, err = ParseReference("localhost:5000/tools@sha256:abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, Reference{Registry: "localhost:5000", Repository: "tools", Digest: "sha256:abcd"}, reference)
+	assert.Equal(t, "sha256:abcd", reference.Ref())
+
+	_, err = ParseReference("ghcr.io/org/tools@md5:abcd")
+	assert.Error(t, err)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`)
+
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, "https://auth.docker.io/token", params["realm"])
+	assert.Equal(t, "registry.docker.io", params["service"])
+	assert.Equal(t, "repository:library/alpine:pull", params["scope"])
+}
+
+func TestOpen(t *testing.T) {
+	manifest, blobs := artifact(map[string]string{"kubectl": "binary", "README.md": "readme"})
+	server := fakeRegistry(t, map[string]Manifest{"v1": manifest}, blobs...)
+	defer server.Close()
+
+	content, err := read("oci://" + server.Listener.Addr().String() + "/tools:v1#kubectl")
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", content)
+
+	_, err = read("oci://" + server.Listener.Addr().String() + "/tools:v1")
+	assert.Error(t, err)
+
+	_, err = read("oci://" + server.Listener.Addr().String() + "/tools:v1#unknown")
+	assert.Error(t, err)
+}
+
+func TestOpenHelmChart(t *testing.T) {
+	manifest, blobs := artifact(map[string]string{"nginx-15.0.0.tgz": "chart", "nginx-15.0.0.tgz.prov": "provenance"})
+	for i := range manifest.Layers {
+		if manifest.Layers[i].Digest == digestOf([]byte("chart")) {
+			manifest.Layers[i].MediaType = HelmChartMediaType
+		}
+	}
+	server := fakeRegistry(t, map[string]Manifest{"15.0.0": manifest}, blobs...)
+	defer server.Close()
+
+	content, err := read("oci://" + server.Listener.Addr().String() + "/tools:15.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "chart", content)
+}
+
+func read(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := Open(context.Background(), u)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
+
+// fakeRegistry serves manifests, given by tag or digest, and blobs from a
+// repository named tools. It requires a bearer token.
+func fakeRegistry(t *testing.T, manifests map[string]Manifest, contents ...[]byte) *httptest.Server {
+	blobs := map[string][]byte{}
+	for _, content := range contents {
+		blobs[digestOf(content)] = content
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"token":"secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/v2/tools/manifests/") {
+			manifest, ok := manifests[strings.TrimPrefix(r.URL.Path, "/v2/tools/manifests/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", manifest.MediaType)
+			assert.NoError(t, json.NewEncoder(w).Encode(manifest))
+			return
+		}
+
+		content, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/tools/blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+
+	return server
+}
+
+// artifact describes an ORAS artifact with one layer per file.
+func artifact(files map[string]string) (Manifest, [][]byte) {
+	manifest := Manifest{MediaType: mediaTypeOCIManifest}
+	var blobs [][]byte
+	for name, content := range files {
+		blobs = append(blobs, []byte(content))
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType:   "application/octet-stream",
+			Digest:      digestOf([]byte(content)),
+			Size:        int64(len(content)),
+			Annotations: map[string]string{TitleAnnotation: name},
+		})
+	}
+	return manifest, blobs
+}
+
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
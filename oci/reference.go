@@ -0,0 +1,82 @@
+package oci
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// Reference points to a manifest in a repository of a registry, either by
+// tag or by digest.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses `registry/repository:tag` or
+// `registry/repository@sha256:...`. Without a registry, Docker Hub is used.
+func ParseReference(value string) (Reference, error) {
+	var reference Reference
+
+	name := value
+	if at := strings.Index(name, "@"); at != -1 {
+		name, reference.Digest = name[:at], name[at+1:]
+		if !strings.HasPrefix(reference.Digest, "sha256:") {
+			return Reference{}, fmt.Errorf("Invalid digest in %s", value)
+		}
+	}
+
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name, reference.Tag = name[:colon], name[colon+1:]
+	}
+	if reference.Tag == "" && reference.Digest == "" {
+		reference.Tag = "latest"
+	}
+
+	// The first component is a registry only if it looks like a host.
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		reference.Registry, reference.Repository = parts[0], parts[1]
+	} else {
+		reference.Registry, reference.Repository = dockerHub, name
+	}
+
+	if reference.Registry == dockerHub && !strings.Contains(reference.Repository, "/") {
+		reference.Repository = "library/" + reference.Repository
+	}
+
+	if reference.Repository == "" || reference.Tag == "" && reference.Digest == "" {
+		return Reference{}, fmt.Errorf("Invalid reference: %s", value)
+	}
+
+	return reference, nil
+}
+
+// Ref gives the digest or, if there's none, the tag.
+func (r Reference) Ref() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r Reference) String() string {
+	if r.Digest != "" {
+		return r.Registry + "/" + r.Repository + "@" + r.Digest
+	}
+	return r.Registry + "/" + r.Repository + ":" + r.Tag
+}
+
+// host gives the host serving the registry api.
+func (r Reference) host() string {
+	if r.Registry == dockerHub {
+		return dockerHubRegistry
+	}
+	return r.Registry
+}
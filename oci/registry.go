@@ -0,0 +1,300 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Media types of the manifests understood by getme.
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Descriptor describes a blob or a manifest.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// Manifest is either an image manifest, with layers, or an index pointing to
+// one manifest per platform.
+type Manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    Descriptor   `json:"config"`
+	Layers    []Descriptor `json:"layers"`
+	Manifests []Descriptor `json:"manifests"`
+}
+
+// IsIndex tells if the manifest is an index of per platform manifests.
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == mediaTypeOCIIndex || m.MediaType == mediaTypeDockerList || len(m.Manifests) > 0
+}
+
+// Registry talks to a registry implementing the OCI distribution api.
+type Registry struct {
+	reference Reference
+	token     string
+}
+
+// NewRegistry creates a client to the repository of a reference.
+func NewRegistry(reference Reference) *Registry {
+	return &Registry{reference: reference}
+}
+
+// Manifest fetches a manifest, given a tag or a digest.
+func (r *Registry) Manifest(ctx context.Context, ref string) (*Manifest, error) {
+	accept := strings.Join([]string{mediaTypeOCIManifest, mediaTypeOCIIndex, mediaTypeDockerManifest, mediaTypeDockerList}, ", ")
+
+	resp, err := r.get(ctx, "/manifests/"+ref, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("Invalid manifest for %s: %s", r.reference, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = resp.Header.Get("Content-Type")
+	}
+
+	return &manifest, nil
+}
+
+// Blob opens a blob for reading. Its content is checked against its digest
+// once read entirely.
+func (r *Registry) Blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("Unsupported digest: %s", digest)
+	}
+
+	resp, err := r.get(ctx, "/blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifiedReader{
+		ReadCloser: resp.Body,
+		hash:       sha256.New(),
+		expected:   strings.TrimPrefix(digest, "sha256:"),
+	}, nil
+}
+
+func (r *Registry) get(ctx context.Context, path, accept string) (*http.Response, error) {
+	scheme := "https"
+	if host := strings.Split(r.reference.host(), ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	url := scheme + "://" + r.reference.host() + "/v2/" + r.reference.Repository + path
+
+	resp, err := r.do(ctx, url, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		challenge := resp.Header.Get("Www-Authenticate")
+		resp.Body.Close()
+
+		if err := r.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = r.do(ctx, url, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to fetch %s from %s: %s", path[1:], r.reference, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (r *Registry) do(ctx context.Context, url, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", r.token)
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+// authenticate answers the challenge given by the registry, either with basic
+// auth or by getting a bearer token.
+func (r *Registry) authenticate(ctx context.Context, challenge string) error {
+	username, secret, err := credentials(ctx, r.reference.Registry)
+	if err != nil {
+		return err
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if username == "" {
+			return fmt.Errorf("No credentials for %s. Run docker login %s", r.reference.Registry, r.reference.Registry)
+		}
+		req, _ := http.NewRequest("GET", "", nil)
+		req.SetBasicAuth(username, secret)
+		r.token = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+		token, err := fetchToken(ctx, params, "repository:"+r.reference.Repository+":pull", username, secret)
+		if err != nil {
+			return err
+		}
+		r.token = "Bearer " + token
+		return nil
+	}
+
+	return fmt.Errorf("Unsupported authentication for %s: %s", r.reference.Registry, challenge)
+}
+
+func fetchToken(ctx context.Context, params map[string]string, scope, username, secret string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.New("Invalid authentication challenge: no realm")
+	}
+	if params["scope"] != "" {
+		scope = params["scope"]
+	}
+
+	var req *http.Request
+	var err error
+	if username == "<token>" {
+		// Identity tokens are refresh tokens for the OAuth2 endpoint.
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {secret},
+			"service":       {params["service"]},
+			"scope":         {scope},
+			"client_id":     {"getme"},
+		}
+		req, err = http.NewRequestWithContext(ctx, "POST", realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{"scope": {scope}}
+		if params["service"] != "" {
+			query.Set("service", params["service"])
+		}
+		req, err = http.NewRequestWithContext(ctx, "GET", realm+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		if username != "" {
+			req.SetBasicAuth(username, secret)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("Unable to authenticate to %s: %s", realm, resp.Status)
+	}
+
+	var response struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if response.Token != "" {
+		return response.Token, nil
+	}
+	return response.AccessToken, nil
+}
+
+// parseChallenge parses a `Www-Authenticate` header such as
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	scheme := strings.ToLower(parts[0])
+
+	params := map[string]string{}
+	if len(parts) == 2 {
+		rest := parts[1]
+		for rest != "" {
+			equal := strings.Index(rest, "=")
+			if equal == -1 {
+				break
+			}
+			key := strings.ToLower(strings.TrimSpace(rest[:equal]))
+			rest = rest[equal+1:]
+
+			var value string
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.Index(rest[1:], `"`)
+				if end == -1 {
+					break
+				}
+				value, rest = rest[1:end+1], rest[end+2:]
+			} else if comma := strings.Index(rest, ","); comma != -1 {
+				value, rest = rest[:comma], rest[comma:]
+			} else {
+				value, rest = rest, ""
+			}
+
+			params[key] = value
+			rest = strings.TrimLeft(rest, ", ")
+		}
+	}
+
+	return scheme, params
+}
+
+// verifiedReader fails at the end of the stream if the content doesn't match
+// the expected digest.
+type verifiedReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (r *verifiedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if actual := hex.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			return n, fmt.Errorf("Invalid blob digest: expected sha256:%s, got sha256:%s", r.expected, actual)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dgageot/getme/getme"
+)
+
+// progressReporter shows the progress events of the clients.
+type progressReporter interface {
+	// Events is the channel the clients send their events to.
+	Events() chan<- getme.ProgressEvent
+	// Wait waits for the events sent so far to be shown.
+	Wait()
+	// Close waits for the pending events to be shown.
+	Close()
+}
+
+// progressBar renders the progress of the downloads on a single line. It's fed
+// with the progress events of the clients.
+type progressBar struct {
+	out    io.Writer
+	events chan getme.ProgressEvent
+	flush  chan chan struct{}
+	done   chan struct{}
+
+	downloads []getme.ProgressEvent
+	width     int
+	rendered  time.Time
+}
+
+func newProgressBar(out io.Writer) *progressBar {
+	bar := &progressBar{
+		out:    out,
+		events: make(chan getme.ProgressEvent),
+		flush:  make(chan chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go bar.run()
+	return bar
+}
+
+// Events implements progressReporter.
+func (b *progressBar) Events() chan<- getme.ProgressEvent {
+	return b.events
+}
+
+// Wait waits for the events sent so far to be rendered, so that the line is
+// cleared once the downloads are finished. It's called before printing to
+// stdout.
+func (b *progressBar) Wait() {
+	flushed := make(chan struct{})
+	b.flush <- flushed
+	<-flushed
+}
+
+// Close waits for the pending events to be rendered and clears the line.
+func (b *progressBar) Close() {
+	close(b.events)
+	<-b.done
+}
+
+func (b *progressBar) run() {
+	defer close(b.done)
+
+	for {
+		select {
+		case event, ok := <-b.events:
+			if !ok {
+				b.print("")
+				return
+			}
+
+			var finished bool
+			switch event.Phase {
+			case getme.PhaseProgress:
+				finished = event.BytesDone == event.BytesTotal
+			case getme.PhaseDone, getme.PhaseFailed:
+				finished = true
+			default:
+				continue
+			}
+			b.update(event, finished)
+
+			// Rendering every event would be too slow for fast downloads.
+			// The line is cleared as soon as the last download is finished.
+			if finished || time.Since(b.rendered) > 100*time.Millisecond {
+				b.render()
+			}
+		case flushed := <-b.flush:
+			close(flushed)
+		}
+	}
+}
+
+// update records the progress of a download. Finished downloads are removed.
+func (b *progressBar) update(event getme.ProgressEvent, finished bool) {
+	for i, download := range b.downloads {
+		if download.URL == event.URL {
+			if finished {
+				b.downloads = append(b.downloads[:i], b.downloads[i+1:]...)
+			} else {
+				b.downloads[i] = event
+			}
+			return
+		}
+	}
+
+	if !finished {
+		b.downloads = append(b.downloads, event)
+	}
+}
+
+func (b *progressBar) render() {
+	var parts []string
+	for _, download := range b.downloads {
+		if download.BytesTotal > 0 {
+			parts = append(parts, fmt.Sprintf("%s %3d%% of %s", fileName(download.URL), download.BytesDone*100/download.BytesTotal, formatSize(download.BytesTotal)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s", fileName(download.URL), formatSize(download.BytesDone)))
+		}
+	}
+
+	b.print(strings.Join(parts, "  "))
+	b.rendered = time.Now()
+}
+
+// print replaces the current line, padding it to erase the previous one.
+func (b *progressBar) print(line string) {
+	if line == "" && b.width == 0 {
+		return
+	}
+
+	padding := b.width - len(line)
+	if padding < 0 {
+		padding = 0
+	}
+	fmt.Fprint(b.out, "\r"+line+strings.Repeat(" ", padding))
+	if line == "" {
+		fmt.Fprint(b.out, "\r")
+	}
+
+	b.width = len(line)
+}
+
+func fileName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		return path.Base(u.Path)
+	}
+	return rawURL
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgageot/getme/getme"
+)
+
+// progressJSON writes the progress events of the clients as json, one event
+// per line, for tools wrapping getme.
+type progressJSON struct {
+	out    io.WriteCloser
+	events chan getme.ProgressEvent
+	done   chan struct{}
+
+	written map[string]time.Time
+}
+
+type jsonEvent struct {
+	Event      getme.Phase `json:"event"`
+	URL        string      `json:"url"`
+	BytesDone  *int64      `json:"bytes_done,omitempty"`
+	BytesTotal *int64      `json:"bytes_total,omitempty"`
+	Percent    *int64      `json:"percent,omitempty"`
+	File       string      `json:"file,omitempty"`
+	FilesDone  *int        `json:"files_done,omitempty"`
+	FilesTotal *int        `json:"files_total,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// openProgressFile opens the file or named pipe json events are written to.
+// Opening a named pipe waits for a reader.
+func openProgressFile(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{os.Stderr}, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// newProgressJSON writes the events to out, which is closed by Close.
+func newProgressJSON(out io.WriteCloser) *progressJSON {
+	p := &progressJSON{
+		out:     out,
+		events:  make(chan getme.ProgressEvent),
+		done:    make(chan struct{}),
+		written: map[string]time.Time{},
+	}
+	go p.run()
+	return p
+}
+
+// Events implements progressReporter.
+func (p *progressJSON) Events() chan<- getme.ProgressEvent {
+	return p.events
+}
+
+// Wait implements progressReporter. Events are written as they're received.
+func (p *progressJSON) Wait() {
+}
+
+// Close waits for the pending events to be written.
+func (p *progressJSON) Close() {
+	close(p.events)
+	<-p.done
+}
+
+func (p *progressJSON) run() {
+	defer close(p.done)
+	defer p.out.Close()
+
+	encoder := json.NewEncoder(p.out)
+	for event := range p.events {
+		if event.Phase == getme.PhaseProgress {
+			// Writing every event would be too verbose for fast downloads.
+			finished := event.BytesDone == event.BytesTotal
+			if !finished && time.Since(p.written[event.URL]) < 100*time.Millisecond {
+				continue
+			}
+			p.written[event.URL] = time.Now()
+		}
+		if event.Phase == getme.PhaseDone || event.Phase == getme.PhaseFailed {
+			delete(p.written, event.URL)
+		}
+
+		encoder.Encode(toJSON(event))
+	}
+}
+
+func toJSON(event getme.ProgressEvent) jsonEvent {
+	encoded := jsonEvent{Event: event.Phase, URL: event.URL}
+
+	switch event.Phase {
+	case getme.PhaseProgress:
+		encoded.BytesDone = &event.BytesDone
+		if event.BytesTotal >= 0 {
+			encoded.BytesTotal = &event.BytesTotal
+		}
+		if event.BytesTotal > 0 {
+			percent := event.BytesDone * 100 / event.BytesTotal
+			encoded.Percent = &percent
+		}
+	case getme.PhaseExtracting:
+		encoded.File = event.File
+		encoded.FilesDone = &event.FilesDone
+		if event.FilesTotal >= 0 {
+			encoded.FilesTotal = &event.FilesTotal
+		}
+	case getme.PhaseFailed:
+		if event.Err != nil {
+			encoded.Error = event.Err.Error()
+		}
+	}
+
+	return encoded
+}
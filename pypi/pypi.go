@@ -0,0 +1,238 @@
+// Package pypi downloads the files of Python packages published to PyPI,
+// given as `pypi://package==version`, like `pypi://requests==2.31.0`.
+//
+// Without a version, the latest version is used. The pure Python wheel is
+// picked, or the source distribution if there's none. Wheels built for a
+// platform are picked with `?platform=`, like `?platform=manylinux2014_x86_64`,
+// and `?python=`, like `?python=cp312`. Files are verified against the sha256
+// given by the index.
+package pypi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// DefaultIndex is the json api of PyPI.
+const DefaultIndex = "https://pypi.org/pypi"
+
+// Options configures access to a package index.
+type Options struct {
+	// Index is the url of the json api of the index. Defaults to DefaultIndex.
+	Index string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Package selects a file of a version of a package.
+type Package struct {
+	Name     string
+	Version  string
+	Platform string
+	Python   string
+
+	// File is the name of the selected file, once resolved.
+	File string
+}
+
+// File is a file of a package, a wheel or a source distribution.
+type File struct {
+	Filename    string `json:"filename"`
+	URL         string `json:"url"`
+	PackageType string `json:"packagetype"`
+	Yanked      bool   `json:"yanked"`
+	Digests     struct {
+		Sha256 string `json:"sha256"`
+	} `json:"digests"`
+}
+
+type release struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	URLs []File `json:"urls"`
+}
+
+// IsPackageURL tells if an url is a pypi:// url.
+func IsPackageURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "pypi://")
+}
+
+// ParseURL parses a pypi:// url. Resolved urls give the name of the selected
+// file as a fragment.
+func ParseURL(rawURL string) (Package, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "pypi" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+		return Package{}, fmt.Errorf("Invalid PyPI url. Should be pypi://package==version: %s", rawURL)
+	}
+
+	parts := strings.SplitN(parsed.Host, "==", 2)
+	pkg := Package{
+		Name:     parts[0],
+		Platform: parsed.Query().Get("platform"),
+		Python:   parsed.Query().Get("python"),
+		File:     parsed.Fragment,
+	}
+	if len(parts) == 2 {
+		if pkg.Version = parts[1]; pkg.Version == "" {
+			return Package{}, fmt.Errorf("Invalid PyPI url. Should be pypi://package==version: %s", rawURL)
+		}
+	}
+
+	return pkg, nil
+}
+
+// URL gives the pypi:// url of the package.
+func (p Package) URL() string {
+	u := "pypi://" + p.Name
+	if p.Version != "" {
+		u += "==" + p.Version
+	}
+
+	query := url.Values{}
+	if p.Platform != "" {
+		query.Set("platform", p.Platform)
+	}
+	if p.Python != "" {
+		query.Set("python", p.Python)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	if p.File != "" {
+		u += "#" + p.File
+	}
+	return u
+}
+
+// Resolve finds the file of a package to download. The package is given
+// back with its version and file pinned.
+func Resolve(ctx context.Context, p Package, options Options) (Package, File, error) {
+	releaseURL := options.IndexURL() + "/" + url.PathEscape(p.Name) + "/json"
+	if p.Version != "" {
+		releaseURL = options.IndexURL() + "/" + url.PathEscape(p.Name) + "/" + url.PathEscape(p.Version) + "/json"
+	}
+
+	found := release{}
+	if err := options.get(ctx, releaseURL, &found); err != nil {
+		return Package{}, File{}, fmt.Errorf("Unable to find %s: %w", p.URL(), err)
+	}
+
+	var names []string
+	var matching []File
+	for _, file := range found.URLs {
+		if file.Yanked {
+			continue
+		}
+		names = append(names, file.Filename)
+		if p.File != "" && file.Filename == p.File {
+			matching = []File{file}
+			break
+		}
+		if p.File == "" && p.matches(file) {
+			matching = append(matching, file)
+		}
+	}
+
+	// Source distributions are only used when there's no pure Python wheel.
+	if len(matching) == 0 && p.File == "" && p.Platform == "" && p.Python == "" {
+		for _, file := range found.URLs {
+			if !file.Yanked && file.PackageType == "sdist" {
+				matching = append(matching, file)
+			}
+		}
+	}
+
+	switch len(matching) {
+	case 0:
+		return Package{}, File{}, errdefs.Errorf(errdefs.ErrNotFound, "No file of %s %s matches. Files are [%s]", p.Name, found.Info.Version, strings.Join(names, ", "))
+	case 1:
+		p.Version = found.Info.Version
+		p.File = matching[0].Filename
+		return p, matching[0], nil
+	}
+
+	var matchingNames []string
+	for _, file := range matching {
+		matchingNames = append(matchingNames, file.Filename)
+	}
+	return Package{}, File{}, fmt.Errorf("Several files of %s %s match: [%s]. Use ?platform= and ?python= to pick one", p.Name, found.Info.Version, strings.Join(matchingNames, ", "))
+}
+
+// matches tells if a file is a wheel for the platform and the python of the
+// package. Without a platform, only pure Python wheels match.
+func (p Package) matches(file File) bool {
+	if file.PackageType != "bdist_wheel" {
+		return false
+	}
+
+	// Wheels are named name-version(-build)?-python-abi-platform.whl. Tags
+	// can be compressed, like `manylinux_2_17_x86_64.manylinux2014_x86_64`.
+	parts := strings.Split(strings.TrimSuffix(file.Filename, ".whl"), "-")
+	if len(parts) < 5 {
+		return false
+	}
+	python, platform := parts[len(parts)-3], parts[len(parts)-1]
+
+	wanted := p.Platform
+	if wanted == "" {
+		wanted = "any"
+	}
+	return hasTag(platform, wanted) && (p.Python == "" || hasTag(python, p.Python))
+}
+
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.Split(tags, ".") {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexURL gives the url of the json api of the index.
+func (o Options) IndexURL() string {
+	if o.Index == "" {
+		return DefaultIndex
+	}
+	return strings.TrimSuffix(o.Index, "/")
+}
+
+func (o Options) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,76 @@
+package pypi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	pkg, err := ParseURL("pypi://requests==2.31.0")
+	assert.NoError(t, err)
+	assert.Equal(t, Package{Name: "requests", Version: "2.31.0"}, pkg)
+
+	pkg, err = ParseURL("pypi://numpy?platform=manylinux2014_x86_64&python=cp312#numpy-1.26.4-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl")
+	assert.NoError(t, err)
+	assert.Equal(t, Package{Name: "numpy", Platform: "manylinux2014_x86_64", Python: "cp312", File: "numpy-1.26.4-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl"}, pkg)
+	assert.Equal(t, "pypi://numpy?platform=manylinux2014_x86_64&python=cp312#numpy-1.26.4-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", pkg.URL())
+
+	_, err = ParseURL("pypi://requests==")
+	assert.Error(t, err)
+	_, err = ParseURL("pypi://org/requests")
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/requests/json":
+			fmt.Fprint(w, `{"info":{"version":"2.31.0"},"urls":[
+				{"filename":"requests-2.31.0-py3-none-any.whl","packagetype":"bdist_wheel","url":"https://files.example.com/requests.whl","digests":{"sha256":"58cd"}},
+				{"filename":"requests-2.31.0.tar.gz","packagetype":"sdist","url":"https://files.example.com/requests.tar.gz","digests":{"sha256":"942c"}}]}`)
+		case "/numpy/1.26.4/json":
+			fmt.Fprint(w, `{"info":{"version":"1.26.4"},"urls":[
+				{"filename":"numpy-1.26.4-cp311-cp311-manylinux_2_17_x86_64.manylinux2014_x86_64.whl","packagetype":"bdist_wheel","url":"https://files.example.com/cp311.whl"},
+				{"filename":"numpy-1.26.4-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl","packagetype":"bdist_wheel","url":"https://files.example.com/cp312.whl"},
+				{"filename":"numpy-1.26.4-cp312-cp312-win_amd64.whl","packagetype":"bdist_wheel","url":"https://files.example.com/win.whl","yanked":true},
+				{"filename":"numpy-1.26.4.tar.gz","packagetype":"sdist","url":"https://files.example.com/numpy.tar.gz"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{Index: server.URL + "/"}
+
+	pinned, file, err := Resolve(context.Background(), Package{Name: "requests"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "pypi://requests==2.31.0#requests-2.31.0-py3-none-any.whl", pinned.URL())
+	assert.Equal(t, "https://files.example.com/requests.whl", file.URL)
+	assert.Equal(t, "58cd", file.Digests.Sha256)
+
+	// Without a pure Python wheel, the source distribution is used.
+	_, file, err = Resolve(context.Background(), Package{Name: "numpy", Version: "1.26.4"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "numpy-1.26.4.tar.gz", file.Filename)
+
+	_, _, err = Resolve(context.Background(), Package{Name: "numpy", Version: "1.26.4", Platform: "manylinux2014_x86_64"}, options)
+	assert.EqualError(t, err, "Several files of numpy 1.26.4 match: [numpy-1.26.4-cp311-cp311-manylinux_2_17_x86_64.manylinux2014_x86_64.whl, numpy-1.26.4-cp312-cp312-manylinux_2_17_x86_64.manylinux2014_x86_64.whl]. Use ?platform= and ?python= to pick one")
+
+	_, file, err = Resolve(context.Background(), Package{Name: "numpy", Version: "1.26.4", Platform: "manylinux2014_x86_64", Python: "cp312"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://files.example.com/cp312.whl", file.URL)
+
+	// Yanked files are ignored.
+	_, _, err = Resolve(context.Background(), Package{Name: "numpy", Version: "1.26.4", Platform: "win_amd64"}, options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	_, _, err = Resolve(context.Background(), Package{Name: "requests", Version: "9.9.9"}, options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+}
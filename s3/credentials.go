@@ -0,0 +1,378 @@
+package s3
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials are used to sign requests to S3.
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// credentialsProvider looks for credentials in one place. It returns nil
+// credentials if it has nothing to offer.
+type credentialsProvider func(options Options) (*Credentials, error)
+
+// The standard AWS credential chain.
+var providers = []credentialsProvider{
+	fromOptions,
+	fromEnv,
+	fromWebIdentity,
+	fromSharedFile,
+	fromContainer,
+	fromInstanceMetadata,
+}
+
+var (
+	resolvedLock sync.Mutex
+	resolved     = map[Options]Credentials{}
+)
+
+// ResolveCredentials finds credentials by walking the standard AWS chain:
+// explicit options, environment variables, web identity token, shared
+// credentials file, ECS task role and EC2 instance profile. Empty credentials
+// mean anonymous access. If a role is given, it's assumed with the
+// credentials found in the chain.
+func ResolveCredentials(options Options) (Credentials, error) {
+	resolvedLock.Lock()
+	defer resolvedLock.Unlock()
+
+	if credentials, found := resolved[options]; found {
+		return credentials, nil
+	}
+
+	credentials, err := chain(options)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	if options.RoleArn != "" {
+		if credentials.AccessKey == "" {
+			return Credentials{}, fmt.Errorf("No credentials found to assume role %s", options.RoleArn)
+		}
+
+		assumed, err := assumeRole(credentials, options.RoleArn, options.ExternalID)
+		if err != nil {
+			return Credentials{}, err
+		}
+		credentials = *assumed
+	}
+
+	resolved[options] = credentials
+	return credentials, nil
+}
+
+func chain(options Options) (Credentials, error) {
+	for _, provider := range providers {
+		credentials, err := provider(options)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if credentials != nil {
+			return *credentials, nil
+		}
+	}
+
+	return Credentials{}, nil
+}
+
+func fromOptions(options Options) (*Credentials, error) {
+	if options.AccessKey == "" {
+		return nil, nil
+	}
+
+	return &Credentials{
+		AccessKey:    options.AccessKey,
+		SecretKey:    options.SecretKey,
+		SessionToken: options.SessionToken,
+	}, nil
+}
+
+func fromEnv(options Options) (*Credentials, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	if accessKey == "" || options.Profile != "" {
+		return nil, nil
+	}
+
+	return &Credentials{
+		AccessKey:    accessKey,
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+func fromWebIdentity(options Options) (*Credentials, error) {
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	roleArn := os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleArn == "" || options.Profile != "" {
+		return nil, nil
+	}
+
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "getme"
+	}
+
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", roleArn)
+	query.Set("RoleSessionName", sessionName)
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	resp, err := http.Get("https://sts.amazonaws.com/?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("Unable to assume role %s with a web identity: %s", roleArn, resp.Status)
+	}
+
+	var result struct {
+		Credentials stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Credentials.toCredentials(), nil
+}
+
+func fromSharedFile(options Options) (*Credentials, error) {
+	profile := options.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	explicit := profile != ""
+	if !explicit {
+		profile = "default"
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home := homeDir()
+		if home == "" {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	sections, err := readIni(path)
+	if os.IsNotExist(err) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	section, found := sections[profile]
+	if !found || section["aws_access_key_id"] == "" {
+		if explicit {
+			return nil, fmt.Errorf("No credentials for profile [%s] in %s", profile, path)
+		}
+		return nil, nil
+	}
+
+	return &Credentials{
+		AccessKey:    section["aws_access_key_id"],
+		SecretKey:    section["aws_secret_access_key"],
+		SessionToken: section["aws_session_token"],
+	}, nil
+}
+
+func fromContainer(options Options) (*Credentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	}
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	var credentials metadataCredentials
+	if err := getJSON(req, &credentials); err != nil {
+		return nil, err
+	}
+
+	return credentials.toCredentials(), nil
+}
+
+const instanceMetadata = "http://169.254.169.254/latest"
+
+func fromInstanceMetadata(options Options) (*Credentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, nil
+	}
+
+	// IMDSv2 needs a session token. Not being able to get one quickly means
+	// that we are not running on EC2.
+	req, err := http.NewRequest("PUT", instanceMetadata+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "60")
+
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := getMetadata(client, "/meta-data/iam/security-credentials/", string(token))
+	if err != nil || roles == "" {
+		return nil, nil
+	}
+	role := strings.SplitN(roles, "\n", 2)[0]
+
+	req, err = http.NewRequest("GET", instanceMetadata+"/meta-data/iam/security-credentials/"+role, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+
+	var credentials metadataCredentials
+	if err := getJSON(req, &credentials); err != nil {
+		return nil, err
+	}
+
+	return credentials.toCredentials(), nil
+}
+
+func getMetadata(client *http.Client, path string, token string) (string, error) {
+	req, err := http.NewRequest("GET", instanceMetadata+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return strings.TrimSpace(string(body)), err
+}
+
+func getJSON(req *http.Request, value interface{}) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Unable to get credentials from %s: %s", req.URL.Host, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+type metadataCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+}
+
+func (c metadataCredentials) toCredentials() *Credentials {
+	return &Credentials{AccessKey: c.AccessKeyID, SecretKey: c.SecretAccessKey, SessionToken: c.Token}
+}
+
+type stsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+}
+
+func (c stsCredentials) toCredentials() *Credentials {
+	return &Credentials{AccessKey: c.AccessKeyID, SecretKey: c.SecretAccessKey, SessionToken: c.SessionToken}
+}
+
+// readIni reads the sections of an ini file, such as ~/.aws/credentials.
+func readIni(path string) (map[string]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sections := map[string]map[string]string{}
+	var current map[string]string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			name = strings.TrimSpace(strings.TrimPrefix(name, "profile "))
+			current = map[string]string{}
+			sections[name] = current
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || current == nil {
+			continue
+		}
+		current[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return sections, scanner.Err()
+}
+
+func homeDir() string {
+	if runtime.GOOS == "windows" {
+		return os.Getenv("USERPROFILE")
+	}
+	return os.Getenv("HOME")
+}
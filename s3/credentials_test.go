@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromSharedFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "credentials")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	file.WriteString(`[default]
+aws_access_key_id = DEFAULT_KEY
+aws_secret_access_key = DEFAULT_SECRET
+
+# Build agents
+[ci]
+aws_access_key_id=CI_KEY
+aws_secret_access_key=CI_SECRET
+aws_session_token=CI_TOKEN
+`)
+	file.Close()
+
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", file.Name())
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	credentials, err := fromSharedFile(Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Credentials{AccessKey: "DEFAULT_KEY", SecretKey: "DEFAULT_SECRET"}, credentials)
+
+	credentials, err = fromSharedFile(Options{Profile: "ci"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Credentials{AccessKey: "CI_KEY", SecretKey: "CI_SECRET", SessionToken: "CI_TOKEN"}, credentials)
+
+	_, err = fromSharedFile(Options{Profile: "unknown"})
+	assert.Error(t, err)
+}
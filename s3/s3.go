@@ -0,0 +1,260 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/minio/minio-go/pkg/s3signer"
+	"github.com/minio/minio-go/pkg/s3utils"
+)
+
+const defaultRegion = "us-east-1"
+
+// ErrNotFound is returned when an object doesn't exist.
+var ErrNotFound = fmt.Errorf("404 %w", errdefs.ErrNotFound)
+
+// Options configures access to S3 objects.
+type Options struct {
+	AccessKey     string
+	SecretKey     string
+	SessionToken  string
+	Profile       string
+	RoleArn       string
+	ExternalID    string
+	RequesterPays bool
+
+	// Endpoint of an S3 compatible object store, such as MinIO or Ceph.
+	Endpoint       string
+	ForcePathStyle bool
+}
+
+// Open opens an S3 object for reading.
+func Open(ctx context.Context, bucket, key string, options Options) (io.ReadCloser, error) {
+	resp, err := do(ctx, "GET", bucket, key, nil, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Put uploads a file to S3.
+func Put(ctx context.Context, bucket, key string, file *os.File, options Options) error {
+	resp, err := do(ctx, "PUT", bucket, key, file, options)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func do(ctx context.Context, method, bucket, key string, file *os.File, options Options) (*http.Response, error) {
+	credentials, err := ResolveCredentials(options)
+	if err != nil {
+		return nil, err
+	}
+
+	region := defaultRegion
+	if options.Endpoint == "" {
+		if region, err = bucketRegion(ctx, bucket); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint, err := endpointURL(options, region)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = objectURL(endpoint, bucket, key, options.ForcePathStyle)
+	req.Host = req.URL.Host
+
+	if file != nil {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body = ioutil.NopCloser(file)
+		req.ContentLength = info.Size()
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if options.RequesterPays {
+		req.Header.Set("X-Amz-Request-Payer", "requester")
+	}
+
+	req = sign(req, credentials, region)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if resp.StatusCode == http.StatusForbidden && !options.RequesterPays {
+			return nil, fmt.Errorf("%w. The bucket might be requester-pays, try --s3-requester-pays", &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return nil, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp, nil
+}
+
+// CheckCredentials validates credentials without downloading anything.
+func CheckCredentials(credentials Credentials) error {
+	req, err := http.NewRequest("GET", "https://s3.amazonaws.com/", nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	req = sign(req, credentials, defaultRegion)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Valid credentials might not be allowed to list buckets.
+	if strings.Contains(string(body), "<Code>AccessDenied</Code>") {
+		return nil
+	}
+	if strings.Contains(string(body), "<Code>InvalidAccessKeyId</Code>") {
+		return errors.New("Unknown access key")
+	}
+	if strings.Contains(string(body), "<Code>SignatureDoesNotMatch</Code>") {
+		return errors.New("Invalid secret key")
+	}
+	return errors.New(resp.Status)
+}
+
+func sign(req *http.Request, credentials Credentials, region string) *http.Request {
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	return s3signer.SignV4(*req, credentials.AccessKey, credentials.SecretKey, region)
+}
+
+// bucketRegion finds the region of a bucket. S3 gives it away in a header
+// even to anonymous requests.
+func bucketRegion(ctx context.Context, bucket string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", "https://"+bucket+".s3.amazonaws.com/", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	region := resp.Header.Get("X-Amz-Bucket-Region")
+	if region == "" {
+		return defaultRegion, nil
+	}
+
+	return region, nil
+}
+
+// endpointURL gives the url of either Amazon S3 in a given region or the
+// configured S3 compatible endpoint.
+func endpointURL(options Options, region string) (*url.URL, error) {
+	if options.Endpoint == "" {
+		host := "s3.amazonaws.com"
+		if region != defaultRegion {
+			host = "s3." + region + ".amazonaws.com"
+		}
+		return &url.URL{Scheme: "https", Host: host}, nil
+	}
+
+	endpoint := options.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return nil, errors.New("Invalid S3 endpoint: " + options.Endpoint)
+	}
+	return &url.URL{Scheme: parsed.Scheme, Host: parsed.Host}, nil
+}
+
+func objectURL(endpoint *url.URL, bucket, key string, forcePathStyle bool) *url.URL {
+	host := endpoint.Host
+	path := "/" + key
+
+	// Buckets with dots in their names break virtual-hosted TLS certificates.
+	if forcePathStyle || strings.Contains(bucket, ".") {
+		path = "/" + bucket + path
+	} else {
+		host = bucket + "." + host
+	}
+
+	return &url.URL{
+		Scheme:  endpoint.Scheme,
+		Host:    host,
+		Path:    path,
+		RawPath: s3utils.EncodePath(path),
+	}
+}
+
+var (
+	virtualHostedURL = regexp.MustCompile(`^https://([^/]+)\.s3[.-]([a-z0-9-]+\.)?amazonaws\.com/(.+)$`)
+	pathStyleURL     = regexp.MustCompile(`^https://s3[.-]([a-z0-9-]+\.)?amazonaws\.com/([^/]+)/(.+)$`)
+)
+
+// ParseURL extracts the bucket and the key of an object from an `s3://bucket/key`
+// url or from an https url to Amazon S3, either virtual-hosted or path style.
+func ParseURL(rawURL string) (bucket, key string, ok bool) {
+	if strings.HasPrefix(rawURL, "s3://") {
+		parts := strings.SplitN(strings.TrimPrefix(rawURL, "s3://"), "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", "", false
+		}
+		return parts[0], parts[1], true
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", false
+	}
+	withoutQuery := parsed.Scheme + "://" + parsed.Host + parsed.Path
+
+	if parts := pathStyleURL.FindStringSubmatch(withoutQuery); parts != nil {
+		return parts[2], parts[3], true
+	}
+	if parts := virtualHostedURL.FindStringSubmatch(withoutQuery); parts != nil {
+		return parts[1], parts[3], true
+	}
+	return "", "", false
+}
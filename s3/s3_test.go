@@ -0,0 +1,59 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	assertObject(t, "bucket", "path/to/file.tgz", "s3://bucket/path/to/file.tgz")
+	assertObject(t, "bucket", "file.tgz", "https://bucket.s3.amazonaws.com/file.tgz")
+	assertObject(t, "bucket", "file.tgz", "https://bucket.s3.eu-west-1.amazonaws.com/file.tgz")
+	assertObject(t, "bucket", "file.tgz", "https://bucket.s3-eu-west-1.amazonaws.com/file.tgz")
+	assertObject(t, "bucket", "path/file.tgz", "https://s3.amazonaws.com/bucket/path/file.tgz")
+	assertObject(t, "my.bucket", "file.tgz", "https://s3.eu-west-1.amazonaws.com/my.bucket/file.tgz")
+	assertObject(t, "bucket", "file.tgz", "https://bucket.s3.amazonaws.com/file.tgz?versionId=1")
+
+	assertNotObject(t, "s3://bucket")
+	assertNotObject(t, "s3://bucket/")
+	assertNotObject(t, "https://github.com/org/project/releases/download/v1/file.tgz")
+}
+
+func TestObjectURL(t *testing.T) {
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/path/file.tgz", amazonObjectURL(t, "bucket", "path/file.tgz", "us-east-1"))
+	assert.Equal(t, "https://bucket.s3.eu-west-1.amazonaws.com/file%20name.tgz", amazonObjectURL(t, "bucket", "file name.tgz", "eu-west-1"))
+	assert.Equal(t, "https://s3.amazonaws.com/my.bucket/file.tgz", amazonObjectURL(t, "my.bucket", "file.tgz", "us-east-1"))
+}
+
+func TestCustomEndpoint(t *testing.T) {
+	endpoint, err := endpointURL(Options{Endpoint: "http://minio:9000"}, defaultRegion)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://minio:9000/bucket/file.tgz", objectURL(endpoint, "bucket", "file.tgz", true).String())
+	assert.Equal(t, "http://bucket.minio:9000/file.tgz", objectURL(endpoint, "bucket", "file.tgz", false).String())
+
+	endpoint, err = endpointURL(Options{Endpoint: "ceph.local"}, defaultRegion)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ceph.local/bucket/file.tgz", objectURL(endpoint, "bucket", "file.tgz", true).String())
+}
+
+func amazonObjectURL(t *testing.T, bucket, key, region string) string {
+	endpoint, err := endpointURL(Options{}, region)
+	assert.NoError(t, err)
+
+	return objectURL(endpoint, bucket, key, false).String()
+}
+
+func assertObject(t *testing.T, expectedBucket, expectedKey, url string) {
+	bucket, key, ok := ParseURL(url)
+
+	assert.True(t, ok, url)
+	assert.Equal(t, expectedBucket, bucket, url)
+	assert.Equal(t, expectedKey, key, url)
+}
+
+func assertNotObject(t *testing.T, url string) {
+	_, _, ok := ParseURL(url)
+
+	assert.False(t, ok, url)
+}
@@ -0,0 +1,133 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const stsEndpoint = "https://sts.amazonaws.com/"
+
+// assumeRole exchanges credentials for temporary credentials of a role.
+func assumeRole(base Credentials, roleArn, externalID string) (*Credentials, error) {
+	query := url.Values{}
+	query.Set("Action", "AssumeRole")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", roleArn)
+	query.Set("RoleSessionName", "getme")
+	if externalID != "" {
+		query.Set("ExternalId", externalID)
+	}
+
+	req, err := http.NewRequest("GET", stsEndpoint+"?"+canonicalQuery(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	signSts(req, query, base, time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		var stsErr struct {
+			Message string `xml:"Error>Message"`
+		}
+		if xml.Unmarshal(body, &stsErr) == nil && stsErr.Message != "" {
+			return nil, fmt.Errorf("Unable to assume role %s: %s", roleArn, stsErr.Message)
+		}
+		return nil, fmt.Errorf("Unable to assume role %s: %s", roleArn, resp.Status)
+	}
+
+	var result struct {
+		Credentials stsCredentials `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Credentials.toCredentials(), nil
+}
+
+// signSts signs a GET request to STS with AWS Signature Version 4.
+func signSts(req *http.Request, query url.Values, credentials Credentials, t time.Time) {
+	date := t.Format("20060102")
+	timestamp := t.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders string
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		hexSha256(""),
+	}, "\n")
+
+	scope := date + "/" + defaultRegion + "/sts/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		timestamp,
+		scope,
+		hexSha256(canonicalRequest),
+	}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+credentials.SecretKey), date)
+	key = hmacSha256(key, defaultRegion)
+	key = hmacSha256(key, "sts")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+credentials.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery encodes a query the way AWS expects: sorted keys and
+// spaces encoded as %20.
+func canonicalQuery(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
+func hexSha256(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/files"
+	"github.com/dgageot/getme/getme"
+	"github.com/dgageot/getme/github"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/urls"
+)
+
+// selfUpdateURL points to the latest release of getme. The asset built for
+// the current platform is picked automatically.
+const selfUpdateURL = "https://github.com/dgageot/getme/releases/latest/download/"
+
+// The sha256 of the assets of a release are listed in checksumsAsset, which is
+// signed with ed25519. The signature is base64 encoded in signatureAsset.
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+)
+
+// selfUpdateKey is the base64 encoded ed25519 public key that signs the
+// checksums of the releases. It's injected at build time with:
+//
+//	go build -ldflags "-X main.selfUpdateKey=<key>"
+var selfUpdateKey = ""
+
+// selfUpdateHelp describes the self-update command, or tells that it's not
+// available in builds without a public key.
+func selfUpdateHelp() string {
+	if selfUpdateKey == "" {
+		return "Unavailable in this build of getme, which has no public key to verify updates"
+	}
+	return "Replace getme with its latest release, or the one given with --tag"
+}
+
+// selfUpdateOptions are the only options of the downloads of an update: the
+// release given with --tag and the Github token. Other flags, like headers,
+// hooks or --xattrs, don't apply to getme itself.
+func selfUpdateOptions(options files.Options) files.Options {
+	return files.Options{
+		GitHubTag:       options.GitHubTag,
+		GitHubAutoAsset: true,
+		GitHubEnvToken:  options.GitHubEnvToken,
+	}
+}
+
+// SelfUpdate replaces the running binary with the latest release of getme, or
+// the one given with --tag. The release must give the signed sha256 of its
+// assets, which are verified before the binary is replaced.
+func SelfUpdate(ctx context.Context, options files.Options) error {
+	if selfUpdateKey == "" {
+		return errors.New("This build of getme can't verify updates since it wasn't given a public key. Download the new version instead")
+	}
+
+	options = selfUpdateOptions(options)
+
+	url, err := files.Resolve(ctx, selfUpdateURL, options)
+	if err != nil {
+		return err
+	}
+
+	release, ok := github.ParseReleaseURL(url, "")
+	if !ok {
+		return fmt.Errorf("Unable to find a release of getme: %s", url)
+	}
+
+	if strings.TrimPrefix(release.Tag, "v") == strings.TrimPrefix(buildVersion, "v") && !force {
+		logs.Infoln("getme", buildVersion, "is up to date")
+		return nil
+	}
+
+	if options.Sha256, err = releaseChecksum(ctx, release, options); err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	// The new binary is written next to the current one so that it can be
+	// renamed atomically.
+	updated := executable + ".new"
+	defer os.Remove(updated)
+
+	if err := writeBinary(ctx, url, options, updated); err != nil {
+		return err
+	}
+	if err := os.Chmod(updated, 0755); err != nil {
+		return err
+	}
+
+	if err := replace(executable, updated); err != nil {
+		return err
+	}
+
+	logs.Infoln("Updated", executable, "to getme", release.Tag)
+	return nil
+}
+
+// releaseChecksum reads the sha256 of a release asset from the checksum file
+// of the release, once its signature is verified.
+func releaseChecksum(ctx context.Context, release github.Release, options files.Options) (string, error) {
+	checksums := release
+	checksums.Asset = checksumsAsset
+	content, err := readAsset(ctx, checksums, options)
+	if err != nil {
+		return "", err
+	}
+
+	signature := release
+	signature.Asset = signatureAsset
+	signed, err := readAsset(ctx, signature, options)
+	if err != nil {
+		return "", err
+	}
+
+	if err := github.VerifySignature(content, string(signed), selfUpdateKey); err != nil {
+		return "", fmt.Errorf("Unable to verify the checksums of getme %s: %s", release.Tag, err)
+	}
+
+	return github.ParseChecksum(string(content), release.Asset)
+}
+
+// readAsset reads a small asset of a release.
+func readAsset(ctx context.Context, release github.Release, options files.Options) ([]byte, error) {
+	reader, err := files.Open(ctx, release.URL(), options)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(io.LimitReader(reader, 1<<20))
+}
+
+// writeBinary downloads the asset of a release, whose sha256 is verified, and
+// writes the getme binary it contains to a destination. The client has the
+// default settings, whatever the flags.
+func writeBinary(ctx context.Context, url string, options files.Options, destination string) error {
+	client := &getme.Client{Options: options}
+
+	if !urls.IsZipArchive(url) && !urls.IsTarArchive(url) {
+		entry, err := client.Download(ctx, url)
+		if err != nil {
+			return err
+		}
+
+		return files.Copy(entry.Path, destination)
+	}
+
+	name := "getme"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	// The binary is either at the root of the archive or in a folder.
+	err := client.ExtractFirst(ctx, url, []string{name, "*/" + name}, destination)
+	if errors.Is(err, errdefs.ErrNotFound) {
+		return errdefs.Errorf(errdefs.ErrNotFound, "Unable to find %s in %s", name, url)
+	}
+	return err
+}
+
+// replace atomically replaces a binary. A running binary can't be replaced on
+// Windows so it's moved away first.
+func replace(executable, updated string) error {
+	if runtime.GOOS != "windows" {
+		return os.Rename(updated, executable)
+	}
+
+	old := executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return err
+	}
+	if err := os.Rename(updated, executable); err != nil {
+		os.Rename(old, executable)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+type comparator struct {
+	op      string
+	version Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "!=":
+		return cmp != 0
+	}
+	return cmp == 0
+}
+
+// Constraint is a set of version ranges, like `>=1.20 <1.21`, `~1.20`,
+// `^2.1`, `1.20.x` or `1.2 || 1.4`.
+type Constraint struct {
+	raw        string
+	ranges     [][]comparator
+	prerelease bool
+}
+
+// IsConstraint tells if a version is a constraint rather than an exact
+// version.
+func IsConstraint(s string) bool {
+	return strings.ContainsAny(s, "<>=~^*|, ") || strings.HasSuffix(s, ".x")
+}
+
+// ParseConstraint reads a constraint. Comparators separated by spaces or
+// commas must all match. Ranges separated by `||` are alternatives.
+func ParseConstraint(s string) (Constraint, error) {
+	constraint := Constraint{raw: s}
+
+	for _, alternative := range strings.Split(s, "||") {
+		var comparators []comparator
+		for _, field := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' }) {
+			parsed, err := parseComparator(field)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("Invalid version constraint %s: %s", s, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+
+		if len(comparators) == 0 {
+			return Constraint{}, fmt.Errorf("Invalid version constraint %s", s)
+		}
+		constraint.ranges = append(constraint.ranges, comparators)
+	}
+
+	// Prereleases are only considered if the constraint asks for them.
+	constraint.prerelease = strings.Contains(s, "-")
+
+	return constraint, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			break
+		}
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(s, op), ".x"), ".*")
+	if s == "*" || s == "x" {
+		return []comparator{{op: ">=", version: Version{}}}, nil
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "~":
+		// ~1.2.3 and ~1.2 allow patches, ~1 allows minors.
+		parts := 2
+		if v.parts == 1 {
+			parts = 1
+		}
+		return []comparator{{">=", v}, {"<", v.next(parts)}}, nil
+	case "^":
+		// ^1.2.3 allows minors, ^0.2.3 patches only.
+		parts := 1
+		if v.Major == 0 && v.parts > 1 {
+			parts = 2
+			if v.Minor == 0 && v.parts > 2 {
+				parts = 3
+			}
+		}
+		return []comparator{{">=", v}, {"<", v.next(parts)}}, nil
+	case "", "=":
+		// A partial version, like 1.20, is a range.
+		if v.parts < 3 {
+			return []comparator{{">=", v}, {"<", v.next(v.parts)}}, nil
+		}
+		return []comparator{{"=", v}}, nil
+	case "<=", ">":
+		// <=1.20 includes 1.20.5, >1.20 excludes it.
+		if v.parts < 3 {
+			next := v.next(v.parts)
+			if op == "<=" {
+				return []comparator{{"<", next}}, nil
+			}
+			return []comparator{{">=", next}}, nil
+		}
+	}
+
+	return []comparator{{op, v}}, nil
+}
+
+// Prerelease tells if the constraint accepts prereleases.
+func (c Constraint) Prerelease() bool {
+	return c.prerelease
+}
+
+// Matches tells if a version is in the constraint.
+func (c Constraint) Matches(v Version) bool {
+	if v.Prerelease != "" && !c.prerelease {
+		return false
+	}
+
+	for _, comparators := range c.ranges {
+		matches := true
+		for _, comparator := range comparators {
+			if !comparator.matches(v) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Highest gives the highest of the versions, like tags, that matches the
+// constraint. Versions that can't be parsed are ignored.
+func (c Constraint) Highest(versions []string) (string, error) {
+	best := ""
+	var bestVersion Version
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil || !c.Matches(v) {
+			continue
+		}
+
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best, bestVersion = raw, v
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("No version matches %s", c.raw)
+	}
+	return best, nil
+}
@@ -0,0 +1,128 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Partial versions, like `1.20`, and tags
+// with a `v` prefix are accepted.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+
+	// parts tells how many of major, minor and patch were given.
+	parts int
+}
+
+// Parse reads a version, like `v1.20.3` or `1.21.0-rc.1`.
+func Parse(s string) (Version, error) {
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	// Build metadata is ignored.
+	if i := strings.Index(s, "+"); i != -1 {
+		s = s[:i]
+	}
+
+	var v Version
+	if i := strings.Index(s, "-"); i != -1 {
+		v.Prerelease = s[i+1:]
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || s == "" {
+		return Version{}, fmt.Errorf("Invalid version %s", raw)
+	}
+
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("Invalid version %s", raw)
+		}
+		*numbers[i] = n
+	}
+	v.parts = len(parts)
+
+	return v, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare gives -1, 0 or 1 if v is lower, equal or greater than other.
+// Prereleases are lower than their release.
+func (v Version) Compare(other Version) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil && bErr != nil:
+			return -1
+		case aErr != nil && bErr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		case as[i] > bs[i]:
+			return 1
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// next gives the lowest version above all the versions starting with the
+// given parts: `1.20` gives `1.21.0`.
+func (v Version) next(parts int) Version {
+	switch parts {
+	case 1:
+		return Version{Major: v.Major + 1, parts: 3}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1, parts: 3}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, parts: 3}
+}
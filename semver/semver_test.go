@@ -0,0 +1,102 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.20.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.20.3", v.String())
+
+	v, err = Parse("1.21.0-rc.1+build.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.21.0-rc.1", v.String())
+
+	v, err = Parse("1.20")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.20.0", v.String())
+
+	for _, invalid := range []string{"", "latest", "1.2.3.4", "v1.x", "1.-2"} {
+		_, err := Parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{"0.9.0", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "1.10.0", "2.0.0"}
+
+	for i := 1; i < len(ordered); i++ {
+		lower, _ := Parse(ordered[i-1])
+		higher, _ := Parse(ordered[i])
+		assert.Equal(t, -1, lower.Compare(higher), "%s < %s", ordered[i-1], ordered[i])
+		assert.Equal(t, 1, higher.Compare(lower), "%s > %s", ordered[i], ordered[i-1])
+		assert.Equal(t, 0, higher.Compare(higher))
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matching   []string
+		others     []string
+	}{
+		{">=1.20 <1.21", []string{"1.20.0", "v1.20.14"}, []string{"1.19.9", "1.21.0", "1.21.0-rc.1"}},
+		{">=1.20, <1.21", []string{"1.20.5"}, []string{"1.21.0"}},
+		{"1.20", []string{"1.20.0", "1.20.9"}, []string{"1.2.0", "1.21.0"}},
+		{"1.20.x", []string{"1.20.7"}, []string{"1.21.0"}},
+		{"=1.20.3", []string{"1.20.3"}, []string{"1.20.4"}},
+		{"~1.20.3", []string{"1.20.3", "1.20.9"}, []string{"1.20.2", "1.21.0"}},
+		{"^1.20.3", []string{"1.20.3", "1.99.0"}, []string{"1.20.2", "2.0.0"}},
+		{"^0.4.1", []string{"0.4.1", "0.4.8"}, []string{"0.5.0"}},
+		{"<=1.20", []string{"1.20.9", "1.0.0"}, []string{"1.21.0"}},
+		{">1.20", []string{"1.21.0"}, []string{"1.20.9"}},
+		{"!=1.20.3 >=1.20 <1.21", []string{"1.20.4"}, []string{"1.20.3"}},
+		{"1.2 || 1.4", []string{"1.2.1", "1.4.0"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "5.0.0"}, []string{"5.0.0-beta"}},
+		{">=2.0.0-0", []string{"2.0.0-rc.1", "2.0.0"}, []string{"1.9.9"}},
+	}
+
+	for _, test := range tests {
+		constraint, err := ParseConstraint(test.constraint)
+		assert.NoError(t, err, test.constraint)
+
+		for _, raw := range test.matching {
+			v, _ := Parse(raw)
+			assert.True(t, constraint.Matches(v), "%s matches %s", raw, test.constraint)
+		}
+		for _, raw := range test.others {
+			v, _ := Parse(raw)
+			assert.False(t, constraint.Matches(v), "%s doesn't match %s", raw, test.constraint)
+		}
+	}
+
+	_, err := ParseConstraint(">=latest")
+	assert.Error(t, err)
+
+	_, err = ParseConstraint("1.2 ||")
+	assert.Error(t, err)
+}
+
+func TestHighest(t *testing.T) {
+	constraint, err := ParseConstraint(">=1.20 <1.21")
+	assert.NoError(t, err)
+
+	highest, err := constraint.Highest([]string{"v1.19.3", "v1.20.2", "v1.20.10", "nightly", "v1.21.0-rc.1", "v1.21.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.20.10", highest)
+
+	_, err = constraint.Highest([]string{"v1.19.3"})
+	assert.Error(t, err)
+}
+
+func TestIsConstraint(t *testing.T) {
+	assert.True(t, IsConstraint(">=1.20 <1.21"))
+	assert.True(t, IsConstraint("~1.20"))
+	assert.True(t, IsConstraint("1.20.x"))
+
+	assert.False(t, IsConstraint("v1.20.3"))
+	assert.False(t, IsConstraint("1.20"))
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+
+	"github.com/dgageot/getme/getme"
+	"github.com/dgageot/getme/logs"
+	"github.com/dgageot/getme/urls"
+)
+
+// Proxy is an http forward proxy that serves artifacts, like archives and
+// packages, from the cache. Other requests are forwarded as is. Artifacts are
+// downloaded anonymously, since they're served to every client of the proxy.
+//
+// Https requests are tunneled, and so not cached, unless a CA is given. The
+// proxy then intercepts them with certificates signed by the CA, which the
+// tools going through the proxy must trust.
+type Proxy struct {
+	Client *getme.Client
+	CA     *CA
+
+	downloads downloads
+	forwarder *httputil.ReverseProxy
+	once      sync.Once
+}
+
+// ListenAndServe runs the proxy on an address, like localhost:3128, until
+// the context is cancelled.
+func (p *Proxy) ListenAndServe(ctx context.Context, addr string) error {
+	logs.Infoln("Proxy on", addr)
+
+	return listenAndServe(ctx, addr, p)
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.once.Do(func() {
+		// Requests are sent as they were received, to their absolute url.
+		p.forwarder = &httputil.ReverseProxy{Rewrite: func(*httputil.ProxyRequest) {}}
+	})
+
+	if r.Method == http.MethodConnect {
+		p.connect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "Not a proxy request: "+r.URL.String(), http.StatusBadRequest)
+		return
+	}
+
+	p.handle(w, r)
+}
+
+func (p *Proxy) handle(w http.ResponseWriter, r *http.Request) {
+	if !cacheable(r) {
+		logs.Debugln("Forward", r.Method, r.URL)
+		p.forwarder.ServeHTTP(w, r)
+		return
+	}
+
+	p.downloads.serve(w, r, anonymous(p.Client), r.URL.String(), "")
+}
+
+// cacheable tells if a request can be answered from the cache. Requests with
+// credentials are forwarded since the cache is shared.
+func cacheable(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		return false
+	}
+	return urls.IsArtifact(r.URL.String())
+}
+
+// connect handles https requests. They're either tunneled to their host or,
+// with a CA, intercepted.
+func (p *Proxy) connect(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Https is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var upstream net.Conn
+	if p.CA == nil {
+		var err error
+		if upstream, err = net.Dial("tcp", r.Host); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+
+	if upstream != nil {
+		logs.Debugln("Tunnel to", r.Host)
+		tunnel(conn, upstream)
+		return
+	}
+
+	logs.Debugln("Intercept https requests to", r.Host)
+	p.intercept(conn, r.Host)
+}
+
+// intercept serves the https requests sent through a connection, as if it
+// were the host they're sent to.
+func (p *Proxy) intercept(conn net.Conn, host string) {
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = hostname(host)
+			}
+			return p.CA.Certificate(name)
+		},
+	})
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = "https"
+		// https://example.com:443/tool.tgz and https://example.com/tool.tgz
+		// are cached once.
+		r.URL.Host = strings.TrimSuffix(host, ":443")
+		p.handle(w, r)
+	})}
+	server.Serve(&connListener{conn: tlsConn})
+}
+
+// hostname removes the port of a host, if any.
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return host
+}
+
+func tunnel(client, upstream net.Conn) {
+	done := make(chan struct{}, 2)
+	copy := func(to, from net.Conn) {
+		io.Copy(to, from)
+		to.Close()
+		done <- struct{}{}
+	}
+
+	go copy(upstream, client)
+	go copy(client, upstream)
+	<-done
+	<-done
+}
+
+// connListener is a listener that accepts a single connection.
+type connListener struct {
+	conn net.Conn
+	once sync.Once
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() {
+		conn = l.conn
+	})
+	if conn == nil {
+		return nil, io.EOF
+	}
+	return conn, nil
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
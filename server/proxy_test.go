@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dgageot/getme/getme"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOrigin(hits *int32, start func(http.Handler) *httptest.Server) *httptest.Server {
+	return start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+}
+
+func get(t *testing.T, client *http.Client, rawURL string) string {
+	resp, err := client.Get(rawURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	return string(body)
+}
+
+func TestProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-proxy-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var hits int32
+	origin := newOrigin(&hits, httptest.NewServer)
+	defer origin.Close()
+
+	proxy := httptest.NewServer(&Proxy{Client: &getme.Client{CacheDir: filepath.Join(dir, "cache")}})
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	assert.Equal(t, "content of /tool.tgz", get(t, client, origin.URL+"/tool.tgz"))
+	assert.Equal(t, "content of /tool.tgz", get(t, client, origin.URL+"/tool.tgz"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	assert.Equal(t, "content of /index.html", get(t, client, origin.URL+"/index.html"))
+	assert.Equal(t, "content of /index.html", get(t, client, origin.URL+"/index.html"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
+
+func TestProxyWithoutCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-proxy-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var authorization string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer origin.Close()
+
+	getmeClient := &getme.Client{CacheDir: filepath.Join(dir, "cache")}
+	getmeClient.Options.AuthToken = "secret"
+	proxy := httptest.NewServer(&Proxy{Client: getmeClient})
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	assert.Equal(t, "content of /tool.tgz", get(t, client, origin.URL+"/tool.tgz"))
+	assert.Empty(t, authorization)
+}
+
+func TestHttpsProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-proxy-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var hits int32
+	origin := newOrigin(&hits, httptest.NewTLSServer)
+	defer origin.Close()
+
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	ca, err := LoadCA(certFile, keyFile)
+	assert.NoError(t, err)
+
+	// Tunneled
+	tunnel := httptest.NewServer(&Proxy{Client: &getme.Client{CacheDir: filepath.Join(dir, "cache")}})
+	defer tunnel.Close()
+
+	tunnelURL, _ := url.Parse(tunnel.URL)
+	transport := origin.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(tunnelURL)
+
+	assert.Equal(t, "content of /tool.tgz", get(t, &http.Client{Transport: transport}, origin.URL+"/tool.tgz"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// Intercepted
+	intercepting := httptest.NewServer(&Proxy{Client: &getme.Client{CacheDir: filepath.Join(dir, "cache"), HTTPClient: origin.Client()}, CA: ca})
+	defer intercepting.Close()
+
+	pem, err := ioutil.ReadFile(certFile)
+	assert.NoError(t, err)
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(pem))
+
+	interceptingURL, _ := url.Parse(intercepting.URL)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(interceptingURL),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+
+	assert.Equal(t, "content of /other.tgz", get(t, client, origin.URL+"/other.tgz"))
+	assert.Equal(t, "content of /other.tgz", get(t, client, origin.URL+"/other.tgz"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// The CA is reused
+	_, err = LoadCA(certFile, keyFile)
+	assert.NoError(t, err)
+	reloaded, err := ioutil.ReadFile(certFile)
+	assert.NoError(t, err)
+	assert.Equal(t, pem, reloaded)
+}
@@ -0,0 +1,219 @@
+// Package server exposes the cache over http, so that containers or hosts
+// can share a cache without sharing a directory. `GET /fetch?url=...`
+// downloads the url, if it's not already cached, and streams the file. The
+// cache can also be used through a forward proxy.
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgageot/getme/cache"
+	"github.com/dgageot/getme/errdefs"
+	"github.com/dgageot/getme/getme"
+	"github.com/dgageot/getme/logs"
+)
+
+// Server serves the files downloaded by a client. Concurrent requests for
+// the same url share a single download.
+type Server struct {
+	Client *getme.Client
+	// Trusted lets clients download urls like the client would for itself:
+	// with its credentials, and with every downloader, some of which run
+	// commands like git or aria2c. Otherwise, only http and https urls are
+	// downloaded, anonymously.
+	Trusted bool
+	// Schemes are the schemes of the urls that can be fetched by trusted
+	// clients, besides http and https, like s3 or oci. Other schemes can read
+	// local files or run commands, so they must be allowed explicitly.
+	Schemes []string
+
+	downloads downloads
+}
+
+// ListenAndServe serves the cache on an address, like localhost:8080, until
+// the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	logs.Infoln("Serve the cache on", addr)
+
+	return listenAndServe(ctx, addr, s)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/fetch" {
+		http.NotFound(w, r)
+		return
+	}
+	s.fetch(w, r)
+}
+
+func (s *Server) fetch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		http.Error(w, "An url must be provided, like /fetch?url=https://example.com/file", http.StatusBadRequest)
+		return
+	}
+	if parsed, err := url.Parse(rawURL); err != nil || !s.allowed(parsed.Scheme) {
+		http.Error(w, "Invalid url: "+rawURL, http.StatusBadRequest)
+		return
+	}
+
+	s.downloads.serve(w, r, s.client(), rawURL, r.URL.Query().Get("sha256"))
+}
+
+// client gives the client that downloads the urls of the requests.
+func (s *Server) client() *getme.Client {
+	if s.Trusted {
+		return s.Client
+	}
+	return anonymous(s.Client)
+}
+
+// anonymous gives a copy of a client that downloads http and https urls
+// without its credentials, cookies or headers, and without running commands.
+func anonymous(client *getme.Client) *getme.Client {
+	c := *client
+	c.Options.Anonymous = true
+	return &c
+}
+
+// allowed tells if urls with a given scheme can be fetched. Serving file urls
+// would expose any file of the host, so they're never allowed.
+func (s *Server) allowed(scheme string) bool {
+	scheme = strings.ToLower(scheme)
+	switch scheme {
+	case "http", "https":
+		return true
+	case "file", "":
+		return false
+	}
+	if !s.Trusted {
+		return false
+	}
+	for _, allowed := range s.Schemes {
+		if strings.EqualFold(allowed, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return ctx.Err()
+}
+
+// downloads are the downloads running for http requests.
+type downloads struct {
+	lock     sync.Mutex
+	inflight map[string]*call
+}
+
+// call is a download shared by concurrent requests.
+type call struct {
+	done  chan struct{}
+	entry cache.Entry
+	err   error
+}
+
+// serve downloads an url to the cache and sends the cached file.
+func (d *downloads) serve(w http.ResponseWriter, r *http.Request, client *getme.Client, rawURL string, sha256 string) {
+	entry, err := d.get(r.Context(), client, rawURL, sha256)
+	if err != nil {
+		logs.Infoln("Unable to fetch", rawURL, "for", r.RemoteAddr+":", err)
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logs.Debugln("Serve", entry.URL, "to", r.RemoteAddr)
+
+	w.Header().Set("X-Getme-Cached", strconv.FormatBool(entry.Cached))
+	http.ServeContent(w, r, path.Base(entry.URL), info.ModTime(), file)
+}
+
+// get downloads an url, unless a download of the same url is already
+// running, in which case its result is shared. The download isn't stopped if
+// the request that started it is cancelled, since others might wait for it.
+func (d *downloads) get(ctx context.Context, client *getme.Client, url string, sha256 string) (cache.Entry, error) {
+	key := url + " " + sha256
+
+	d.lock.Lock()
+	if d.inflight == nil {
+		d.inflight = map[string]*call{}
+	}
+	c, found := d.inflight[key]
+	if !found {
+		c = &call{done: make(chan struct{})}
+		d.inflight[key] = c
+
+		go func() {
+			client := *client
+			if sha256 != "" {
+				client.Options.Sha256 = sha256
+			}
+			c.entry, c.err = client.Download(context.WithoutCancel(ctx), url)
+
+			d.lock.Lock()
+			delete(d.inflight, key)
+			d.lock.Unlock()
+			close(c.done)
+		}()
+	}
+	d.lock.Unlock()
+
+	select {
+	case <-c.done:
+		return c.entry, c.err
+	case <-ctx.Done():
+		return cache.Entry{}, ctx.Err()
+	}
+}
+
+// statusOf gives the http status of a failed download.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errdefs.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errdefs.ErrUnauthorized):
+		return http.StatusForbidden
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgageot/getme/getme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-server-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var hits int32
+	release := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte("content"))
+	}))
+	defer origin.Close()
+
+	server := httptest.NewServer(&Server{Client: &getme.Client{CacheDir: filepath.Join(dir, "cache")}})
+	defer server.Close()
+
+	fetch := func(rawURL string) (int, string) {
+		resp, err := http.Get(server.URL + "/fetch?url=" + url.QueryEscape(rawURL))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, body := fetch(origin.URL + "/file.txt")
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "content", body)
+		}()
+	}
+	for atomic.LoadInt32(&hits) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	status, body := fetch(origin.URL + "/file.txt")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "content", body)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	status, _ = fetch(origin.URL + "/missing.txt")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = fetch("file:///etc/passwd")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = fetch("git://example.com/repo.git")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = fetch("")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestUntrustedClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-server-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var authorizations []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer origin.Close()
+
+	client := &getme.Client{CacheDir: filepath.Join(dir, "cache")}
+	client.Options.AuthToken = "secret"
+	client.Options.Negotiate = true
+	server := httptest.NewServer(&Server{Client: client})
+	defer server.Close()
+
+	// Torrents and --negotiate would run aria2c and curl.
+	for _, path := range []string{"/file.txt", "/file.torrent"} {
+		resp, err := http.Get(server.URL + "/fetch?url=" + url.QueryEscape(origin.URL+path))
+		assert.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "content of "+path, string(body))
+	}
+	assert.Equal(t, []string{"", ""}, authorizations)
+}
+
+func TestAllowedSchemes(t *testing.T) {
+	server := &Server{Schemes: []string{"s3", "file"}}
+
+	assert.True(t, server.allowed("https"))
+	assert.True(t, server.allowed("HTTP"))
+	assert.False(t, server.allowed("s3"))
+	assert.False(t, server.allowed("file"))
+
+	server.Trusted = true
+	assert.True(t, server.allowed("s3"))
+	assert.False(t, server.allowed("oci"))
+	assert.False(t, server.allowed("file"))
+	assert.False(t, server.allowed(""))
+}
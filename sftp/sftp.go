@@ -0,0 +1,76 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dgageot/getme/tempfile"
+)
+
+// Open downloads a file over SSH, given an `sftp://user@host:port/path` or an
+// `scp://` url. It relies on the OpenSSH client so that keys, the ssh agent
+// and ~/.ssh/config are honored. Use `/~/` to give a path relative to the
+// home directory.
+func Open(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	if u.Host == "" || u.Path == "" || u.Path == "/" {
+		return nil, fmt.Errorf("Invalid %s url. Should be %s://user@host/path: %s", u.Scheme, u.Scheme, u.String())
+	}
+
+	tmp, err := ioutil.TempFile("", "getme-sftp")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+
+	// BatchMode fails instead of prompting for a password.
+	args := []string{"-q", "-B", "-o", "BatchMode=yes"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	args = append(args, remote(u), tmp.Name())
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "scp", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp.Name())
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if _, lookErr := exec.LookPath("scp"); lookErr != nil {
+			return nil, fmt.Errorf("Downloading %s urls requires the OpenSSH scp command", u.Scheme)
+		}
+		return nil, fmt.Errorf("Unable to download %s: %s", u.String(), strings.TrimSpace(stderr.String()))
+	}
+
+	file, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tempfile.New(file), nil
+}
+
+// remote gives the `user@host:path` form expected by scp.
+func remote(u *url.URL) string {
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+
+	path := u.Path
+	if strings.HasPrefix(path, "/~/") {
+		path = strings.TrimPrefix(path, "/~/")
+	}
+
+	return host + ":" + path
+}
@@ -0,0 +1,21 @@
+package sftp
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemote(t *testing.T) {
+	assert.Equal(t, "vendor@drop.example.com:/outgoing/firmware.zip", remoteOf(t, "sftp://vendor@drop.example.com/outgoing/firmware.zip"))
+	assert.Equal(t, "vendor@drop.example.com:firmware.zip", remoteOf(t, "sftp://vendor@drop.example.com:2222/~/firmware.zip"))
+	assert.Equal(t, "drop.example.com:/firmware.zip", remoteOf(t, "scp://drop.example.com/firmware.zip"))
+}
+
+func remoteOf(t *testing.T, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+
+	return remote(u)
+}
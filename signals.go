@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/dgageot/getme/logs"
+)
+
+// withSignals gives a context that's cancelled on SIGINT or SIGTERM. Running
+// transfers are aborted and return, which removes their partial files and
+// releases the cache locks. A second signal kills getme right away. The
+// returned function tells if getme was interrupted.
+func withSignals(parent context.Context) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(parent)
+
+	var interrupted int32
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			atomic.StoreInt32(&interrupted, 1)
+			signal.Stop(signals)
+			logs.Infoln("Stopping on", sig)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+		}
+	}()
+
+	return ctx, func() bool {
+		return atomic.LoadInt32(&interrupted) == 1
+	}
+}
@@ -0,0 +1,100 @@
+package tar
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgageot/getme/files"
+)
+
+// link is a symbolic or hard link found in an archive. Links are created once
+// all the regular files are extracted so that their targets exist.
+type link struct {
+	path    string
+	target  string
+	hard    bool
+	modTime time.Time
+}
+
+func createLinks(destinationFolder string, links []link, options files.ExtractOptions) error {
+	for _, l := range links {
+		if err := createLink(destinationFolder, l, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createLink(destinationFolder string, l link, options files.ExtractOptions) error {
+	// Links created before this one can change where it leads.
+	if err := options.CheckPath(destinationFolder, filepath.Dir(l.path)); err != nil {
+		return err
+	}
+	if l.hard {
+		if err := options.CheckPath(destinationFolder, l.target); err != nil {
+			return err
+		}
+	} else if err := options.CheckLink(destinationFolder, l.path, l.target); err != nil {
+		return err
+	}
+
+	write, err := options.IfExists.ShouldWrite(options.Log, l.path, l.modTime)
+	if err != nil || !write {
+		return err
+	}
+
+	if err := os.RemoveAll(l.path); err != nil {
+		return err
+	}
+	if err := files.MkdirAll(filepath.Dir(l.path)); err != nil {
+		return err
+	}
+
+	if options.Dereference {
+		target := l.target
+		if !l.hard {
+			target = filepath.Join(filepath.Dir(l.path), l.target)
+		}
+		return copyTree(target, l.path, options)
+	}
+
+	if l.hard {
+		err = os.Link(l.target, l.path)
+	} else {
+		err = os.Symlink(l.target, l.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	options.Wrote(l.path)
+	return nil
+}
+
+// copyTree copies a file, or a directory recursively, to a destination.
+func copyTree(source, destination string, options files.ExtractOptions) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+
+		reader, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		return options.WriteFile(target, info, reader)
+	})
+}
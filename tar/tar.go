@@ -3,34 +3,37 @@ package tar
 import (
 	archivetar "archive/tar"
 	"compress/gzip"
-	"errors"
 	"io"
+	"io/ioutil"
 	"os"
-	"path/filepath"
 
+	"github.com/dgageot/getme/errdefs"
 	"github.com/dgageot/getme/files"
 	"github.com/dgageot/getme/urls"
 )
 
-func Extract(url string, source string, destinationFolder string) error {
+// Extract extracts all the files of a tar archive to a destination folder.
+func Extract(url string, source string, destinationFolder string, options files.ExtractOptions) error {
 	reader, err := os.Open(source)
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	var tarReader *archivetar.Reader
-	if urls.IsGzipArchive(url) {
-		archive, err := gzip.NewReader(reader)
-		if err != nil {
-			return err
-		}
-		defer archive.Close()
-		tarReader = archivetar.NewReader(archive)
-	} else {
-		tarReader = archivetar.NewReader(reader)
+	return ExtractFrom(url, reader, destinationFolder, options)
+}
+
+// ExtractFrom extracts all the files of a tar archive, read from a stream,
+// to a destination folder.
+func ExtractFrom(url string, reader io.Reader, destinationFolder string, options files.ExtractOptions) error {
+	tarReader, closer, err := newReader(url, reader)
+	if err != nil {
+		return err
 	}
+	defer closer.Close()
 
+	var links []link
+	extracted := 0
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -40,7 +43,20 @@ func Extract(url string, source string, destinationFolder string) error {
 			return err
 		}
 
-		path := filepath.Join(destinationFolder, header.Name)
+		// Archives made by `git archive` start with a global header.
+		if header.Typeflag == archivetar.TypeXGlobalHeader {
+			continue
+		}
+
+		if options.IsExcluded(header.Name) {
+			continue
+		}
+
+		path, err := options.EntryPath(destinationFolder, header.Name)
+		if err != nil {
+			return err
+		}
+
 		info := header.FileInfo()
 		if info.IsDir() {
 			if err = os.MkdirAll(path, info.Mode()); err != nil {
@@ -49,37 +65,52 @@ func Extract(url string, source string, destinationFolder string) error {
 			continue
 		}
 
-		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-			os.Symlink(header.Linkname, path)
+		if header.Typeflag == archivetar.TypeSymlink {
+			if err := options.CheckLink(destinationFolder, path, header.Linkname); err != nil {
+				return err
+			}
+			links = append(links, link{path: path, target: header.Linkname, modTime: info.ModTime()})
+			continue
+		}
+
+		if header.Typeflag == archivetar.TypeLink {
+			target, err := options.EntryPath(destinationFolder, header.Linkname)
+			if err != nil {
+				return err
+			}
+			links = append(links, link{path: path, target: target, hard: true, modTime: info.ModTime()})
 			continue
 		}
 
-		if err := files.CopyFrom(path, info.Mode(), tarReader); err != nil {
+		if err := options.WriteFile(path, info, tarReader); err != nil {
 			return err
 		}
+
+		extracted++
+		options.Extracted(header.Name, extracted, -1)
 	}
 
-	return nil
+	return createLinks(destinationFolder, links, options)
 }
 
-func ExtractFiles(url string, source string, filesToExtract []files.ExtractedFile) error {
+// ExtractFiles extracts some files of a tar archive.
+func ExtractFiles(url string, source string, filesToExtract []files.ExtractedFile, options files.ExtractOptions) error {
 	reader, err := os.Open(source)
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	var tarReader *archivetar.Reader
-	if urls.IsGzipArchive(url) {
-		archive, err := gzip.NewReader(reader)
-		if err != nil {
-			return err
-		}
-		defer archive.Close()
-		tarReader = archivetar.NewReader(archive)
-	} else {
-		tarReader = archivetar.NewReader(reader)
+	return ExtractFilesFrom(url, reader, filesToExtract, options)
+}
+
+// ExtractFilesFrom extracts some files of a tar archive read from a stream.
+func ExtractFilesFrom(url string, reader io.Reader, filesToExtract []files.ExtractedFile, options files.ExtractOptions) error {
+	tarReader, closer, err := newReader(url, reader)
+	if err != nil {
+		return err
 	}
+	defer closer.Close()
 
 	extracted := 0
 	for {
@@ -91,20 +122,76 @@ func ExtractFiles(url string, source string, filesToExtract []files.ExtractedFil
 			return err
 		}
 
+		if options.IsExcluded(header.Name) {
+			continue
+		}
+
 		fileToExtract := files.FindExtractedFile(header.Name, filesToExtract)
 		if fileToExtract == nil {
 			continue
 		}
 
-		if err := files.CopyFrom(fileToExtract.Destination, header.FileInfo().Mode(), tarReader); err != nil {
+		if err := options.WriteFile(fileToExtract.Destination, header.FileInfo(), tarReader); err != nil {
 			return err
 		}
 
 		extracted++
+		options.Extracted(header.Name, extracted, len(filesToExtract))
 		if extracted == len(filesToExtract) {
 			return nil
 		}
 	}
 
-	return errors.New("Files not found")
+	return errdefs.Errorf(errdefs.ErrNotFound, "Files not found")
+}
+
+// List lists the entries of a tar archive.
+func List(url string, source string) ([]files.Entry, error) {
+	reader, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	tarReader, closer, err := newReader(url, reader)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	var entries []files.Entry
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag == archivetar.TypeXGlobalHeader {
+			continue
+		}
+
+		entries = append(entries, files.Entry{
+			Name:     header.Name,
+			Size:     header.Size,
+			Mode:     header.FileInfo().Mode(),
+			Linkname: header.Linkname,
+		})
+	}
+
+	return entries, nil
+}
+
+func newReader(url string, reader io.Reader) (*archivetar.Reader, io.Closer, error) {
+	if !urls.IsGzipArchive(url) {
+		return archivetar.NewReader(reader), ioutil.NopCloser(nil), nil
+	}
+
+	archive, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return archivetar.NewReader(archive), archive, nil
 }
@@ -0,0 +1,105 @@
+package tar
+
+import (
+	archivetar "archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dgageot/getme/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "archive.tar")
+	writeArchive(t, source, []*archivetar.Header{
+		{Name: "lib/libfoo.so", Typeflag: archivetar.TypeSymlink, Linkname: "libfoo.so.1"},
+		{Name: "lib/libfoo.so.1", Typeflag: archivetar.TypeReg, Mode: 0755, Size: 3},
+		{Name: "lib/libfoo.so.1.0", Typeflag: archivetar.TypeLink, Linkname: "lib/libfoo.so.1"},
+	})
+
+	destination := filepath.Join(dir, "links")
+	assert.NoError(t, Extract(source, source, destination, files.ExtractOptions{}))
+
+	target, err := os.Readlink(filepath.Join(destination, "lib", "libfoo.so"))
+	assert.NoError(t, err)
+	assert.Equal(t, "libfoo.so.1", target)
+	assertContent(t, "foo", filepath.Join(destination, "lib", "libfoo.so.1.0"))
+
+	destination = filepath.Join(dir, "dereferenced")
+	assert.NoError(t, Extract(source, source, destination, files.ExtractOptions{Dereference: true}))
+
+	info, err := os.Lstat(filepath.Join(destination, "lib", "libfoo.so"))
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	assertContent(t, "foo", filepath.Join(destination, "lib", "libfoo.so"))
+}
+
+func TestExtractUnsafeLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "archive.tar")
+	writeArchive(t, source, []*archivetar.Header{
+		{Name: "etc", Typeflag: archivetar.TypeSymlink, Linkname: "../../etc"},
+	})
+
+	assert.Error(t, Extract(source, source, filepath.Join(dir, "out"), files.ExtractOptions{}))
+}
+
+func TestExtractChainedLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symbolic links need privileges on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "getme")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	victim := filepath.Join(dir, "victim")
+	assert.NoError(t, os.MkdirAll(victim, 0755))
+
+	source := filepath.Join(dir, "archive.tar")
+	writeArchive(t, source, []*archivetar.Header{
+		{Name: "a/b/c/d", Typeflag: archivetar.TypeSymlink, Linkname: "../../.."},
+		{Name: "a/b/c/d/l", Typeflag: archivetar.TypeSymlink, Linkname: "../../.."},
+		{Name: "l/victim", Typeflag: archivetar.TypeSymlink, Linkname: "."},
+	})
+
+	destination := filepath.Join(dir, "out")
+	assert.Error(t, Extract(source, source, destination, files.ExtractOptions{IfExists: files.Overwrite}))
+
+	_, err = os.Stat(victim)
+	assert.NoError(t, err)
+	_, err = os.Lstat(filepath.Join(dir, "l"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func writeArchive(t *testing.T, path string, headers []*archivetar.Header) {
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	writer := archivetar.NewWriter(file)
+	for _, header := range headers {
+		assert.NoError(t, writer.WriteHeader(header))
+		if header.Size > 0 {
+			_, err := writer.Write([]byte("foo"))
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, writer.Close())
+}
+
+func assertContent(t *testing.T, expected string, path string) {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
@@ -0,0 +1,32 @@
+// Package tempfile gives downloaders temporary files that are deleted once
+// they are read and closed.
+package tempfile
+
+import "os"
+
+// File is a temporary file that is deleted once closed.
+type File struct {
+	*os.File
+	dir string
+}
+
+// New wraps a temporary file so that it's deleted once closed.
+func New(file *os.File) *File {
+	return &File{File: file}
+}
+
+// NewInDir wraps a file of a temporary directory so that the whole directory
+// is deleted once the file is closed.
+func NewInDir(file *os.File, dir string) *File {
+	return &File{File: file, dir: dir}
+}
+
+func (f *File) Close() error {
+	err := f.File.Close()
+	if f.dir != "" {
+		os.RemoveAll(f.dir)
+	} else {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+package tempfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClose(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "getme-tempfile-test")
+	assert.NoError(t, err)
+
+	assert.NoError(t, New(tmp).Close())
+	_, err = os.Stat(tmp.Name())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCloseInDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-tempfile-test")
+	assert.NoError(t, err)
+	file, err := os.Create(filepath.Join(dir, "file"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, NewInDir(file, dir).Close())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
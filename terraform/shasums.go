@@ -0,0 +1,123 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+)
+
+// Checksum reads the sha256 of a build in the SHA256SUMS of the provider,
+// once their signature is verified with the signing keys given by the
+// registry. It has to be the sha256 given by the registry.
+func Checksum(ctx context.Context, d Download, headers []string) (string, error) {
+	if d.ShasumsURL == "" || d.ShasumsSignatureURL == "" {
+		return "", fmt.Errorf("The registry gives no signed SHA256SUMS for %s", d.Filename)
+	}
+
+	var keys []string
+	for _, key := range d.SigningKeys.GPGPublicKeys {
+		keys = append(keys, key.ASCIIArmor)
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("The registry gives no signing key for %s", d.Filename)
+	}
+
+	sums, err := get(ctx, d.ShasumsURL, headers)
+	if err != nil {
+		return "", err
+	}
+	signature, err := get(ctx, d.ShasumsSignatureURL, headers)
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifySignature(sums, signature, strings.Join(keys, "\n")); err != nil {
+		return "", errdefs.Errorf(errdefs.ErrChecksumMismatch, "Invalid signature of %s: %s", d.ShasumsURL, err)
+	}
+
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[1] != d.Filename {
+			continue
+		}
+		if d.Shasum != "" && !strings.EqualFold(fields[0], d.Shasum) {
+			return "", errdefs.Errorf(errdefs.ErrChecksumMismatch, "The sha256 of %s given by the registry isn't the one of %s", d.Filename, d.ShasumsURL)
+		}
+		return fields[0], nil
+	}
+
+	return "", errdefs.Errorf(errdefs.ErrNotFound, "%s isn't listed in %s", d.Filename, d.ShasumsURL)
+}
+
+// verifySignature checks a detached signature with the gpg command, in a
+// throw away keyring that only has the given keys.
+func verifySignature(content, signature []byte, armoredKeys string) error {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return errors.New("Verifying the signature of Terraform providers requires the gpg command")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-gpg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{"keys.asc": []byte(armoredKeys), "SHA256SUMS": content, "SHA256SUMS.sig": signature}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			return err
+		}
+	}
+
+	if err := gpg(dir, "--import", filepath.Join(dir, "keys.asc")); err != nil {
+		return err
+	}
+	return gpg(dir, "--verify", filepath.Join(dir, "SHA256SUMS.sig"), filepath.Join(dir, "SHA256SUMS"))
+}
+
+func gpg(home string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", append([]string{"--homedir", home, "--batch", "--no-tty", "--quiet"}, args...)...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.New(message)
+		}
+		return err
+	}
+	return nil
+}
+
+func get(ctx context.Context, url string, headers []string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(headers, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("Unable to download %s: %w", url, &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,301 @@
+// Package terraform downloads Terraform providers from registries that
+// implement the provider registry protocol, given as
+// `terraform://namespace/type@version`, like `terraform://hashicorp/aws@5.0.0`.
+//
+// The registry defaults to registry.terraform.io. Others are given as
+// `terraform://hostname/namespace/type@version`. The version is an exact
+// version, a constraint, like `~>5.0`, or `latest`, the default. The build of
+// the current platform is downloaded, unless another is picked with
+// `?platform=os/arch`, like `?platform=linux/arm64`.
+//
+// The SHA256SUMS of the provider are verified with the signing keys given by
+// the registry, using the gpg command, and the provider against its sha256.
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/dgageot/getme/errdefs"
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/semver"
+)
+
+// DefaultRegistry is the public Terraform registry.
+const DefaultRegistry = "registry.terraform.io"
+
+// Options configures access to a registry.
+type Options struct {
+	// Token is an api token. Defaults to $TF_TOKEN_<hostname>, like the
+	// terraform command.
+	Token string
+
+	// Headers are added to every request, as `key: value`.
+	Headers []string
+}
+
+// Provider is a version, or a range of versions, of a provider, for a
+// platform.
+type Provider struct {
+	Hostname  string
+	Namespace string
+	Type      string
+	Version   string
+	OS        string
+	Arch      string
+}
+
+// Download tells where a build of a provider is and how to verify it.
+type Download struct {
+	Filename            string `json:"filename"`
+	DownloadURL         string `json:"download_url"`
+	ShasumsURL          string `json:"shasums_url"`
+	ShasumsSignatureURL string `json:"shasums_signature_url"`
+	Shasum              string `json:"shasum"`
+	SigningKeys         struct {
+		GPGPublicKeys []GPGPublicKey `json:"gpg_public_keys"`
+	} `json:"signing_keys"`
+}
+
+// GPGPublicKey is a key that signs the SHA256SUMS of a provider.
+type GPGPublicKey struct {
+	KeyID      string `json:"key_id"`
+	ASCIIArmor string `json:"ascii_armor"`
+}
+
+type versions struct {
+	Versions []struct {
+		Version   string `json:"version"`
+		Platforms []struct {
+			OS   string `json:"os"`
+			Arch string `json:"arch"`
+		} `json:"platforms"`
+	} `json:"versions"`
+}
+
+// IsProviderURL tells if an url is a terraform:// url.
+func IsProviderURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "terraform://")
+}
+
+// ParseURL parses a terraform:// url.
+func ParseURL(rawURL string) (Provider, error) {
+	spec, rawQuery := strings.TrimPrefix(rawURL, "terraform://"), ""
+	if i := strings.Index(spec, "?"); i >= 0 {
+		spec, rawQuery = spec[:i], spec[i+1:]
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Provider{}, err
+	}
+
+	provider := Provider{Hostname: DefaultRegistry, Version: "latest", OS: runtime.GOOS, Arch: runtime.GOARCH}
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		spec, provider.Version = spec[:i], spec[i+1:]
+	}
+
+	parts := strings.Split(spec, "/")
+	if len(parts) == 3 {
+		provider.Hostname, parts = parts[0], parts[1:]
+	}
+	if platform := query.Get("platform"); platform != "" {
+		osArch := strings.Split(platform, "/")
+		if len(osArch) != 2 || osArch[0] == "" || osArch[1] == "" {
+			return Provider{}, fmt.Errorf("Invalid platform %s. Should be os/arch, like linux/amd64", platform)
+		}
+		provider.OS, provider.Arch = osArch[0], osArch[1]
+	}
+
+	if !IsProviderURL(rawURL) || len(parts) != 2 || provider.Hostname == "" || parts[0] == "" || parts[1] == "" || provider.Version == "" {
+		return Provider{}, fmt.Errorf("Invalid Terraform url. Should be terraform://namespace/type@version: %s", rawURL)
+	}
+	provider.Namespace, provider.Type = parts[0], parts[1]
+
+	return provider, nil
+}
+
+// URL gives the terraform:// url of the provider. The platform is always
+// given so that each platform is cached on its own.
+func (p Provider) URL() string {
+	url := "terraform://"
+	if p.Hostname != DefaultRegistry {
+		url += p.Hostname + "/"
+	}
+	return url + p.Namespace + "/" + p.Type + "@" + p.Version + "?platform=" + p.OS + "/" + p.Arch
+}
+
+// Pin resolves `latest` and constraints to the highest version that's built
+// for the platform.
+func Pin(ctx context.Context, p Provider, options Options) (Provider, error) {
+	if p.Version != "latest" && !semver.IsConstraint(p.Version) {
+		return p, nil
+	}
+
+	constraint := p.Version
+	if constraint == "latest" {
+		constraint = "*"
+	}
+	parsed, err := semver.ParseConstraint(pessimistic.ReplaceAllStringFunc(constraint, toRange))
+	if err != nil {
+		return Provider{}, err
+	}
+
+	base, err := options.providersURL(ctx, p)
+	if err != nil {
+		return Provider{}, err
+	}
+
+	found := versions{}
+	if err := options.fetch(ctx, p, base.String()+"versions", &found); err != nil {
+		return Provider{}, fmt.Errorf("Unable to find %s/%s: %w", p.Namespace, p.Type, err)
+	}
+
+	var candidates []string
+	for _, version := range found.Versions {
+		for _, platform := range version.Platforms {
+			if platform.OS == p.OS && platform.Arch == p.Arch {
+				candidates = append(candidates, version.Version)
+				break
+			}
+		}
+	}
+
+	version, err := parsed.Highest(candidates)
+	if err != nil {
+		return Provider{}, errdefs.Errorf(errdefs.ErrNotFound, "No version of %s/%s for %s/%s matches %s", p.Namespace, p.Type, p.OS, p.Arch, p.Version)
+	}
+
+	p.Version = version
+	return p, nil
+}
+
+// Find gives where the build of a version of a provider is. Its urls are
+// absolute.
+func Find(ctx context.Context, p Provider, options Options) (Download, error) {
+	base, err := options.providersURL(ctx, p)
+	if err != nil {
+		return Download{}, err
+	}
+	endpoint, err := base.Parse(p.Version + "/download/" + p.OS + "/" + p.Arch)
+	if err != nil {
+		return Download{}, err
+	}
+
+	download := Download{}
+	if err := options.fetch(ctx, p, endpoint.String(), &download); err != nil {
+		return Download{}, fmt.Errorf("Unable to find %s/%s %s for %s/%s: %w", p.Namespace, p.Type, p.Version, p.OS, p.Arch, err)
+	}
+	if download.DownloadURL == "" {
+		return Download{}, errdefs.Errorf(errdefs.ErrNotFound, "No build of %s/%s %s for %s/%s", p.Namespace, p.Type, p.Version, p.OS, p.Arch)
+	}
+
+	// Urls can be relative to the download endpoint.
+	for _, u := range []*string{&download.DownloadURL, &download.ShasumsURL, &download.ShasumsSignatureURL} {
+		if *u == "" {
+			continue
+		}
+		absolute, err := endpoint.Parse(*u)
+		if err != nil {
+			return Download{}, err
+		}
+		*u = absolute.String()
+	}
+
+	return download, nil
+}
+
+// pessimistic matches the `~>` constraints of Terraform, that only allow the
+// right most part of the version to increase.
+var pessimistic = regexp.MustCompile(`~>\s*[0-9][0-9.]*`)
+
+func toRange(constraint string) string {
+	version := strings.TrimSpace(strings.TrimPrefix(constraint, "~>"))
+
+	parts := strings.Split(version, ".")
+	switch len(parts) {
+	case 1:
+		return ">=" + version
+	case 2:
+		major, _ := strconv.Atoi(parts[0])
+		return ">=" + version + " <" + strconv.Itoa(major+1)
+	}
+	return "~" + version
+}
+
+// registryURL gives the base url of the registry. Like for OCI registries,
+// registries on localhost are served over http.
+func (p Provider) registryURL() string {
+	if host := strings.Split(p.Hostname, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		return "http://" + p.Hostname
+	}
+	return "https://" + p.Hostname
+}
+
+// providersURL discovers the base url of the providers api of a registry.
+func (o Options) providersURL(ctx context.Context, p Provider) (*url.URL, error) {
+	base, err := url.Parse(p.registryURL() + "/.well-known/terraform.json")
+	if err != nil {
+		return nil, err
+	}
+
+	services := map[string]interface{}{}
+	if err := o.fetch(ctx, p, base.String(), &services); err != nil {
+		return nil, fmt.Errorf("Unable to discover the services of %s: %w", p.Hostname, err)
+	}
+
+	path, ok := services["providers.v1"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a Terraform provider registry", p.Hostname)
+	}
+
+	return base.Parse(strings.TrimSuffix(path, "/") + "/" + p.Namespace + "/" + p.Type + "/")
+}
+
+func (o Options) fetch(ctx context.Context, p Provider, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := http_headers.Add(o.Headers, req); err != nil {
+		return err
+	}
+	if token := o.token(p.Hostname); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &errdefs.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// token gives the api token of a registry. Like the terraform command, dots
+// of the hostname are written as underscores in the name of the variable.
+func (o Options) token(hostname string) string {
+	if o.Token != "" {
+		return o.Token
+	}
+	return os.Getenv("TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(hostname))
+}
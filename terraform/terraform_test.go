@@ -0,0 +1,129 @@
+package terraform
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/dgageot/getme/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	sums      = "242b18ab826d3a902ed97fc7d5ba412249a86387ac6a9d2d1fff362fca4ce833  terraform-provider-example_1.0.0_linux_amd64.zip\n"
+	signature = "iHUEABYIAB0WIQQxTSVudxM/JkmWBu1iE0zG19HKPQUCatJL+gAKCRBiE0zG19HKPRD6AQCB90ouE7las5hU+LByahgZ5/3+QbIl2fyJvxFrhhBBsgD+Id8wb/5i9f/xM7mMrlVb71Iry3a+fjwuPqDcASw0ggk="
+	publicKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatJL+hYJKwYBBAHaRw8BAQdAwmJcJ32fq8xubUpsNLBjB9VQFqnygjJFK6IT
++U0qPyC0HWdldG1lIHRlc3QgPHRlc3RAZXhhbXBsZS5jb20+iJAEExYIADgWIQQx
+TSVudxM/JkmWBu1iE0zG19HKPQUCatJL+gIbAwULCQgHAgYVCgkICwIEFgIDAQIe
+AQIXgAAKCRBiE0zG19HKPS66APwNm5w1v7w1ccPM3jbrQjRxqOOuzYYJjnIqAYjf
+f/E/jgD+LrVz40oa1TGo9RZYJat5cW1ZQxuNqRT036NdHheXXA0=
+=8UMY
+-----END PGP PUBLIC KEY BLOCK-----`
+)
+
+func TestParseURL(t *testing.T) {
+	provider, err := ParseURL("terraform://hashicorp/aws@5.0.0?platform=linux/arm64")
+	assert.NoError(t, err)
+	assert.Equal(t, Provider{Hostname: DefaultRegistry, Namespace: "hashicorp", Type: "aws", Version: "5.0.0", OS: "linux", Arch: "arm64"}, provider)
+	assert.Equal(t, "terraform://hashicorp/aws@5.0.0?platform=linux/arm64", provider.URL())
+
+	provider, err = ParseURL("terraform://app.terraform.io/org/tool?platform=darwin/amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, "terraform://app.terraform.io/org/tool@latest?platform=darwin/amd64", provider.URL())
+
+	_, err = ParseURL("terraform://aws@5.0.0")
+	assert.Error(t, err)
+	_, err = ParseURL("terraform://hashicorp/aws@5.0.0?platform=linux")
+	assert.Error(t, err)
+}
+
+func TestToRange(t *testing.T) {
+	assert.Equal(t, ">=5.1 <6", pessimistic.ReplaceAllStringFunc("~>5.1", toRange))
+	assert.Equal(t, "~5.1.2, !=5.1.4", pessimistic.ReplaceAllStringFunc("~> 5.1.2, !=5.1.4", toRange))
+}
+
+func TestFind(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/.well-known/terraform.json":
+			fmt.Fprint(w, `{"providers.v1":"/v1/providers/"}`)
+		case "/v1/providers/example/example/versions":
+			fmt.Fprint(w, `{"versions":[
+				{"version":"1.0.0","platforms":[{"os":"linux","arch":"amd64"}]},
+				{"version":"1.1.0","platforms":[{"os":"darwin","arch":"arm64"}]},
+				{"version":"2.0.0","platforms":[{"os":"linux","arch":"amd64"}]}]}`)
+		case "/v1/providers/example/example/1.0.0/download/linux/amd64":
+			download := map[string]interface{}{
+				"filename":              "terraform-provider-example_1.0.0_linux_amd64.zip",
+				"download_url":          "/files/terraform-provider-example_1.0.0_linux_amd64.zip",
+				"shasums_url":           server.URL + "/files/SHA256SUMS",
+				"shasums_signature_url": "/files/SHA256SUMS.sig",
+				"shasum":                "242b18ab826d3a902ed97fc7d5ba412249a86387ac6a9d2d1fff362fca4ce833",
+				"signing_keys":          map[string]interface{}{"gpg_public_keys": []map[string]string{{"key_id": "62134CC6D7D1CA3D", "ascii_armor": publicKey}}},
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(download))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	hostname := strings.TrimPrefix(server.URL, "http://")
+	options := Options{Token: "token"}
+
+	provider, err := Pin(context.Background(), Provider{Hostname: hostname, Namespace: "example", Type: "example", Version: "~>1.0", OS: "linux", Arch: "amd64"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", provider.Version)
+
+	_, err = Pin(context.Background(), Provider{Hostname: hostname, Namespace: "example", Type: "example", Version: "latest", OS: "windows", Arch: "amd64"}, options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+
+	download, err := Find(context.Background(), provider, options)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/files/terraform-provider-example_1.0.0_linux_amd64.zip", download.DownloadURL)
+	assert.Equal(t, server.URL+"/files/SHA256SUMS.sig", download.ShasumsSignatureURL)
+
+	_, err = Find(context.Background(), Provider{Hostname: hostname, Namespace: "example", Type: "example", Version: "2.0.0", OS: "linux", Arch: "amd64"}, options)
+	assert.True(t, errors.Is(err, errdefs.ErrNotFound))
+}
+
+func TestChecksum(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("Requires gpg")
+	}
+
+	signed, _ := base64.StdEncoding.DecodeString(signature)
+	content := map[string]string{"/SHA256SUMS": sums, "/SHA256SUMS.sig": string(signed), "/TAMPERED": strings.Replace(sums, "242b", "0000", 1)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content[r.URL.Path])
+	}))
+	defer server.Close()
+
+	download := Download{
+		Filename:            "terraform-provider-example_1.0.0_linux_amd64.zip",
+		ShasumsURL:          server.URL + "/SHA256SUMS",
+		ShasumsSignatureURL: server.URL + "/SHA256SUMS.sig",
+		Shasum:              "242b18ab826d3a902ed97fc7d5ba412249a86387ac6a9d2d1fff362fca4ce833",
+	}
+	download.SigningKeys.GPGPublicKeys = []GPGPublicKey{{KeyID: "62134CC6D7D1CA3D", ASCIIArmor: publicKey}}
+
+	sha256, err := Checksum(context.Background(), download, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, download.Shasum, sha256)
+
+	download.ShasumsURL = server.URL + "/TAMPERED"
+	_, err = Checksum(context.Background(), download, nil)
+	assert.True(t, errors.Is(err, errdefs.ErrChecksumMismatch))
+}
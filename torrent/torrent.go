@@ -0,0 +1,118 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgageot/getme/tempfile"
+)
+
+// IsTorrentURL tells if an url is either a magnet link or an http, https or
+// file url to a `.torrent` file.
+func IsTorrentURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	switch parsed.Scheme {
+	case "magnet":
+		return true
+	case "http", "https", "file":
+		return strings.HasSuffix(parsed.Path, ".torrent")
+	}
+	return false
+}
+
+// Open downloads the payload of a torrent, given a magnet link or an url to a
+// `.torrent` file. It relies on aria2 that handles trackers, DHT and peers.
+// The download stops as soon as the payload is complete, without seeding.
+// Only torrents with a single file are supported.
+func Open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	if !IsTorrentURL(rawURL) {
+		return nil, fmt.Errorf("Unsupported torrent url: %s. Should be a magnet link or an http, https or file url to a .torrent file", rawURL)
+	}
+	source := rawURL
+	// aria2c reads local .torrent files given by their path.
+	if parsed, _ := url.Parse(rawURL); parsed.Scheme == "file" {
+		source = filepath.FromSlash(parsed.Path)
+	}
+
+	if _, err := exec.LookPath("aria2c"); err != nil {
+		return nil, fmt.Errorf("Downloading torrents requires the aria2c command")
+	}
+
+	dir, err := ioutil.TempDir("", "getme-torrent")
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "aria2c",
+		"--dir", dir,
+		"--seed-time=0",
+		"--follow-torrent=mem",
+		"--bt-save-metadata=false",
+		"--summary-interval=0",
+		"--console-log-level=error",
+		"--quiet",
+		"--",
+		source)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Unable to download %s: %s", rawURL, strings.TrimSpace(stderr.String()))
+	}
+
+	payload, err := findPayload(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	file, err := os.Open(payload)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return tempfile.NewInDir(file, dir), nil
+}
+
+// findPayload finds the single file downloaded by aria2, ignoring its control
+// files.
+func findPayload(dir string) (string, error) {
+	var payloads []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && !strings.HasSuffix(path, ".aria2") {
+			payloads = append(payloads, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(payloads) {
+	case 0:
+		return "", fmt.Errorf("The torrent is empty")
+	case 1:
+		return payloads[0], nil
+	}
+	return "", fmt.Errorf("Torrents with %d files are not supported. Only single file torrents are", len(payloads))
+}
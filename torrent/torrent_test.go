@@ -0,0 +1,49 @@
+package torrent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTorrentURL(t *testing.T) {
+	assert.True(t, IsTorrentURL("magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+	assert.True(t, IsTorrentURL("https://releases.ubuntu.com/22.04/ubuntu-22.04-desktop-amd64.iso.torrent"))
+
+	assert.True(t, IsTorrentURL("file:///tmp/ubuntu-22.04-desktop-amd64.iso.torrent"))
+
+	assert.False(t, IsTorrentURL("https://releases.ubuntu.com/22.04/ubuntu-22.04-desktop-amd64.iso"))
+	assert.False(t, IsTorrentURL("ftp://example.com/ubuntu.iso.torrent"))
+	assert.False(t, IsTorrentURL("--on-download-complete=/tmp/script.torrent"))
+}
+
+func TestOpenUnsupportedURL(t *testing.T) {
+	_, err := Open(context.Background(), "--on-download-complete=/tmp/script.torrent")
+	assert.Error(t, err)
+}
+
+func TestFindPayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "getme-torrent-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = findPayload(dir)
+	assert.Error(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "ubuntu"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ubuntu", "ubuntu.iso"), []byte("iso"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ubuntu", "ubuntu.iso.aria2"), []byte("control"), 0644))
+
+	payload, err := findPayload(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "ubuntu", "ubuntu.iso"), payload)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ubuntu", "README"), []byte("readme"), 0644))
+
+	_, err = findPayload(dir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,270 @@
+// Package tracing records the steps of downloads as OpenTelemetry spans and
+// exports them to an OTLP/HTTP collector, like `http://localhost:4318`.
+//
+// Tracing is disabled until Setup is called. Spans are then nil and cost
+// nothing. A `$TRACEPARENT`, as set by CI systems, makes getme's spans part of
+// the trace of the CI job.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgageot/getme/logs"
+)
+
+// Attributes describe a span, like the url being downloaded.
+type Attributes map[string]interface{}
+
+// Span is a timed step of a download.
+type Span struct {
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes Attributes
+	err        error
+
+	lock sync.Mutex
+}
+
+type spanKey struct{}
+
+var (
+	lock     sync.Mutex
+	exporter *otlpExporter
+	pending  []*Span
+	ticker   sync.Once
+)
+
+// flushInterval is how often spans are exported, for long running commands
+// like serve.
+const flushInterval = 5 * time.Second
+
+// Setup exports spans to an OTLP/HTTP endpoint. `/v1/traces` is added to
+// endpoints without a path. Headers are given as `key=value,key=value`, like
+// $OTEL_EXPORTER_OTLP_HEADERS.
+func Setup(endpoint string, headers string) error {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return fmt.Errorf("Invalid otel endpoint [%s]. Should be an http or https url", endpoint)
+	}
+	if strings.Count(endpoint, "/") == 2 || strings.HasSuffix(endpoint, "/") {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+
+	parsedHeaders := map[string]string{}
+	for _, header := range strings.Split(headers, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid otel header [%s]. Should be [key=value]", header)
+		}
+		parsedHeaders[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	exporter = &otlpExporter{endpoint: endpoint, headers: parsedHeaders}
+
+	ticker.Do(func() {
+		go func() {
+			for range time.Tick(flushInterval) {
+				if err := Flush(context.Background()); err != nil {
+					logs.Debugln(err)
+				}
+			}
+		}()
+	})
+	return nil
+}
+
+// Start starts a span. It's a child of the span of the context, if any, or
+// of $TRACEPARENT. The returned context carries the new span.
+func Start(ctx context.Context, name string, attributes Attributes) (context.Context, *Span) {
+	lock.Lock()
+	enabled := exporter != nil
+	lock.Unlock()
+	if !enabled {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, start: time.Now(), attributes: Attributes{}, spanID: randomID(8)}
+	for key, value := range attributes {
+		span.attributes[key] = value
+	}
+
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else if traceID, parentID, ok := parseTraceparent(os.Getenv("TRACEPARENT")); ok {
+		span.traceID, span.parentID = traceID, parentID
+	} else {
+		span.traceID = randomID(16)
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Set adds an attribute to a span.
+func (s *Span) Set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attributes[key] = value
+}
+
+// End ends a span. A non nil error marks the span as failed.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	s.end = time.Now()
+	s.err = err
+	s.lock.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+	pending = append(pending, s)
+}
+
+// Flush exports the ended spans.
+func Flush(ctx context.Context) error {
+	lock.Lock()
+	spans, current := pending, exporter
+	pending = nil
+	lock.Unlock()
+
+	if current == nil || len(spans) == 0 {
+		return nil
+	}
+	return current.export(ctx, spans)
+}
+
+// parseTraceparent reads a w3c traceparent, like
+// `00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01`.
+func parseTraceparent(value string) (string, string, bool) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+}
+
+// export sends spans using the json encoding of OTLP/HTTP.
+func (e *otlpExporter) export(ctx context.Context, spans []*Span) error {
+	var encoded []interface{}
+	for _, span := range spans {
+		encoded = append(encoded, span.otlp())
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(Attributes{"service.name": "getme"}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/dgageot/getme"},
+				"spans": encoded,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to export traces to %s: %s", e.endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Unable to export traces to %s: %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (s *Span) otlp() map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	span := map[string]interface{}{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              1,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attributes),
+	}
+	if s.parentID != "" {
+		span["parentSpanId"] = s.parentID
+	}
+	if s.err != nil {
+		span["status"] = map[string]interface{}{"code": 2, "message": s.err.Error()}
+	}
+	return span
+}
+
+func otlpAttributes(attributes Attributes) []interface{} {
+	encoded := []interface{}{}
+	for key, value := range attributes {
+		var v map[string]interface{}
+		switch value := value.(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": v})
+	}
+	return encoded
+}
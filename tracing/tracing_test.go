@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type exported struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string `json:"name"`
+				Status       struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestExport(t *testing.T) {
+	_, span := Start(context.Background(), "disabled", nil)
+	assert.Nil(t, span)
+	span.Set("key", "value")
+	span.End(nil)
+
+	var received exported
+	var path, auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer collector.Close()
+
+	assert.NoError(t, Setup(collector.URL, "Authorization=Bearer token"))
+	defer func() { exporter = nil }()
+
+	os.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	defer os.Unsetenv("TRACEPARENT")
+
+	ctx, root := Start(context.Background(), "getme download", nil)
+	_, child := Start(ctx, "download", Attributes{"url": "https://example.com/tool.tgz"})
+	child.Set("size", int64(42))
+	child.End(errors.New("Not Found"))
+	root.End(nil)
+
+	assert.NoError(t, Flush(context.Background()))
+	assert.Equal(t, "/v1/traces", path)
+	assert.Equal(t, "Bearer token", auth)
+
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "download", spans[0].Name)
+	assert.Equal(t, 2, spans[0].Status.Code)
+	assert.Equal(t, "Not Found", spans[0].Status.Message)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[0].TraceID)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[1].TraceID)
+	assert.Equal(t, "b7ad6b7169203331", spans[1].ParentSpanID)
+
+	assert.Error(t, Setup("localhost:4318", ""))
+}
@@ -0,0 +1,94 @@
+package urls
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// Platform gives the variables of url templates, like
+// `https://example.com/tool_{{.Version}}_{{.OS}}_{{.Arch}}.tar.gz`.
+type Platform struct {
+	// OS and Arch are the GOOS and GOARCH of the current platform, renamed
+	// if a release uses other names, like macos or x86_64.
+	OS   string
+	Arch string
+
+	// GOOS and GOARCH are never renamed.
+	GOOS   string
+	GOARCH string
+
+	Version string
+}
+
+// CurrentPlatform gives the variables for the current platform. Names are
+// given as `name=replacement`, like `amd64=x86_64` or `darwin=macos`.
+func CurrentPlatform(version string, names []string) (Platform, error) {
+	renames := map[string]string{}
+	for _, name := range names {
+		parts := strings.SplitN(name, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return Platform{}, fmt.Errorf("Invalid platform name [%s]. Should be [name=replacement]", name)
+		}
+		renames[parts[0]] = parts[1]
+	}
+
+	rename := func(name string) string {
+		if replacement, found := renames[name]; found {
+			return replacement
+		}
+		return name
+	}
+
+	return Platform{
+		OS:      rename(runtime.GOOS),
+		Arch:    rename(runtime.GOARCH),
+		GOOS:    runtime.GOOS,
+		GOARCH:  runtime.GOARCH,
+		Version: version,
+	}, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trimv": func(s string) string { return strings.TrimPrefix(s, "v") },
+}
+
+// IsTemplate tells if an url has placeholders.
+func IsTemplate(rawURL string) bool {
+	return strings.Contains(rawURL, "{{")
+}
+
+// Expand substitutes the placeholders of an url. Besides the variables of
+// the platform, the `title`, `upper`, `lower` and `trimv` functions can be
+// used, like `{{.OS | title}}` or `{{.Version | trimv}}`.
+func Expand(rawURL string, platform Platform) (string, error) {
+	if !IsTemplate(rawURL) {
+		return rawURL, nil
+	}
+
+	tmpl, err := template.New("url").Funcs(templateFuncs).Option("missingkey=error").Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("Invalid url template %s: %s", rawURL, err)
+	}
+
+	if strings.Contains(rawURL, ".Version") && platform.Version == "" {
+		return "", fmt.Errorf("The url %s requires a --version", rawURL)
+	}
+
+	var expanded bytes.Buffer
+	if err := tmpl.Execute(&expanded, platform); err != nil {
+		return "", fmt.Errorf("Invalid url template %s: %s", rawURL, err)
+	}
+
+	return expanded.String(), nil
+}
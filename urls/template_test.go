@@ -0,0 +1,45 @@
+package urls
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	platform := Platform{OS: "macos", Arch: "x86_64", GOOS: "darwin", GOARCH: "amd64", Version: "v1.2.3"}
+
+	url, err := Expand("https://example.com/tool/{{.Version}}/tool_{{.Version | trimv}}_{{.OS | title}}_{{.Arch}}.tar.gz", platform)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/tool/v1.2.3/tool_1.2.3_Macos_x86_64.tar.gz", url)
+
+	url, err = Expand("https://example.com/tool-{{.GOOS}}-{{.GOARCH}}", platform)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/tool-darwin-amd64", url)
+
+	url, err = Expand("https://example.com/tool.tgz", Platform{})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/tool.tgz", url)
+
+	_, err = Expand("https://example.com/tool-{{.Version}}", Platform{})
+	assert.Error(t, err)
+
+	_, err = Expand("https://example.com/tool-{{.Unknown}}", platform)
+	assert.Error(t, err)
+
+	_, err = Expand("https://example.com/tool-{{.OS", platform)
+	assert.Error(t, err)
+}
+
+func TestCurrentPlatform(t *testing.T) {
+	platform, err := CurrentPlatform("1.0.0", []string{runtime.GOARCH + "=renamed"})
+	assert.NoError(t, err)
+	assert.Equal(t, runtime.GOOS, platform.OS)
+	assert.Equal(t, "renamed", platform.Arch)
+	assert.Equal(t, runtime.GOARCH, platform.GOARCH)
+	assert.Equal(t, "1.0.0", platform.Version)
+
+	_, err = CurrentPlatform("", []string{"invalid"})
+	assert.Error(t, err)
+}
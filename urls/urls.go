@@ -2,32 +2,133 @@ package urls
 
 import (
 	"net/url"
+	"path"
 	"strings"
 )
 
 func IsTarArchive(rawURL string) bool {
+	// Container images are downloaded as tar archives of their root filesystem.
+	if strings.HasPrefix(rawURL, "docker://") || isSourceTarball(rawURL) || isPackageTarball(rawURL) {
+		return true
+	}
+
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
 		return false
 	}
 
-	return strings.HasSuffix(parsed.Path, ".tar") || strings.HasSuffix(parsed.Path, ".tar.gz") || strings.HasSuffix(parsed.Path, ".tgz")
+	// Git repositories are downloaded as tar archives.
+	if strings.HasPrefix(parsed.Scheme, "git+") {
+		return true
+	}
+
+	return strings.HasSuffix(name(parsed), ".tar") || strings.HasSuffix(name(parsed), ".tar.gz") || strings.HasSuffix(name(parsed), ".tgz")
 }
 
 func IsGzipArchive(rawURL string) bool {
+	if isSourceTarball(rawURL) || isPackageTarball(rawURL) {
+		return true
+	}
+
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
 		return false
 	}
 
-	return strings.HasSuffix(parsed.Path, ".tar.gz") || strings.HasSuffix(parsed.Path, ".tgz")
+	return strings.HasSuffix(name(parsed), ".tar.gz") || strings.HasSuffix(name(parsed), ".tgz")
 }
 
 func IsZipArchive(rawURL string) bool {
+	if strings.HasPrefix(rawURL, "https://codeload.github.com/") && strings.Contains(rawURL, "/zip/") {
+		return true
+	}
+	if isModuleZip(rawURL) || strings.HasPrefix(rawURL, "terraform://") {
+		return true
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(name(parsed), ".zip") || strings.HasSuffix(name(parsed), ".whl")
+}
+
+// name gives the name of the downloaded file. For OCI artifacts, that's the
+// name of the layer, if any, and for PyPI packages, the name of the file.
+func name(parsed *url.URL) string {
+	if (parsed.Scheme == "oci" || parsed.Scheme == "pypi") && parsed.Fragment != "" {
+		return parsed.Fragment
+	}
+	return parsed.Path
+}
+
+// isPackageTarball tells if an url points to a package published as a
+// gzipped tarball, like npm packages and Helm charts.
+func isPackageTarball(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "npm://") || strings.HasPrefix(rawURL, "helm://")
+}
+
+// isModuleZip tells if an url points to the zip of a Go module, rather than
+// to its go.mod or its info.
+func isModuleZip(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "gomod://") && (!strings.Contains(rawURL, "?") || strings.HasSuffix(rawURL, "?file=zip"))
+}
+
+// isSourceTarball tells if an url points to the source tarball of a Github
+// repository.
+func isSourceTarball(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "github://") || strings.HasPrefix(rawURL, "https://codeload.github.com/") && strings.Contains(rawURL, "/tar.gz/")
+}
+
+// artifactExtensions are the extensions of files that are worth caching:
+// archives, packages and installers.
+var artifactExtensions = []string{
+	".zip", ".tar", ".tgz", ".gz", ".bz2", ".xz", ".zst", ".7z",
+	".jar", ".war", ".whl", ".gem", ".nupkg", ".crate",
+	".deb", ".rpm", ".apk", ".msi", ".exe", ".dmg", ".pkg", ".iso",
+}
+
+// IsArtifact tells if an url points to an archive, a package or an installer,
+// rather than to an api or a page that might change.
+func IsArtifact(rawURL string) bool {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
 		return false
 	}
 
-	return strings.HasSuffix(parsed.Path, ".zip")
+	path := strings.ToLower(name(parsed))
+	for _, extension := range artifactExtensions {
+		if strings.HasSuffix(path, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filename gives the name of the file an url points to, if its path ends
+// with one. Packages, whose file names are only known by their registries,
+// have none.
+func Filename(rawURL string) string {
+	for _, prefix := range []string{"npm://", "helm://", "gomod://", "terraform://", "maven://"} {
+		if strings.HasPrefix(rawURL, prefix) {
+			return ""
+		}
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return Basename(name(parsed))
+}
+
+// Basename gives the last element of a slash or backslash separated path, if
+// it's a file name.
+func Basename(p string) string {
+	name := path.Base(strings.Replace(p, `\`, "/", -1))
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+	return name
 }
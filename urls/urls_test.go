@@ -14,6 +14,14 @@ func TestIsTarArchive(t *testing.T) {
 	assert.True(t, IsTarArchive("http://domain.com/artefact.tgz"))
 	assert.True(t, IsTarArchive("http://domain.com/artefact.tar.gz"))
 	assert.True(t, IsTarArchive("http://domain.com/artefact.tar.gz?key=value"))
+	assert.True(t, IsTarArchive("oci://ghcr.io/org/tools:v1#tools.tgz"))
+	assert.True(t, IsTarArchive("docker://alpine:3.19"))
+	assert.True(t, IsTarArchive("github://docker/compose@v2.24.0"))
+	assert.True(t, IsTarArchive("https://codeload.github.com/docker/compose/tar.gz/refs/tags/v2.24.0"))
+	assert.True(t, IsTarArchive("git+https://github.com/user/repo.git@v1.0.0"))
+	assert.True(t, IsTarArchive("npm://@angular/cli@17.3.0"))
+	assert.True(t, IsTarArchive("helm://charts.bitnami.com/bitnami/nginx@15.0.0"))
+	assert.True(t, IsTarArchive("pypi://requests==2.31.0#requests-2.31.0.tar.gz"))
 }
 
 func TestIsZipArchive(t *testing.T) {
@@ -22,4 +30,29 @@ func TestIsZipArchive(t *testing.T) {
 
 	assert.True(t, IsZipArchive("http://domain.com/artefact.zip"))
 	assert.True(t, IsZipArchive("http://domain.com/artefact.zip?key=value"))
+	assert.True(t, IsZipArchive("https://codeload.github.com/docker/compose/zip/refs/tags/v2.24.0"))
+	assert.True(t, IsZipArchive("pypi://requests==2.31.0#requests-2.31.0-py3-none-any.whl"))
+	assert.True(t, IsZipArchive("gomod://golang.org/x/mod@v0.14.0"))
+	assert.False(t, IsZipArchive("gomod://golang.org/x/mod@v0.14.0?file=mod"))
+	assert.True(t, IsZipArchive("terraform://hashicorp/aws@5.0.0?platform=linux/amd64"))
+}
+
+func TestIsArtifact(t *testing.T) {
+	assert.False(t, IsArtifact("https://pypi.org/simple/requests/"))
+	assert.False(t, IsArtifact("https://api.github.com/repos/org/repo/releases"))
+	assert.False(t, IsArtifact("http://domain.com/artefact.txt"))
+
+	assert.True(t, IsArtifact("http://domain.com/artefact.tar.gz?key=value"))
+	assert.True(t, IsArtifact("https://files.pythonhosted.org/packages/requests-2.31.0-py3-none-any.whl"))
+	assert.True(t, IsArtifact("https://repo1.maven.org/maven2/junit/junit/4.13.2/junit-4.13.2.JAR"))
+}
+
+func TestFilename(t *testing.T) {
+	assert.Equal(t, "artefact.tar.gz", Filename("http://domain.com/path/artefact.tar.gz?key=value"))
+	assert.Equal(t, "requests-2.31.0-py3-none-any.whl", Filename("pypi://requests==2.31.0#requests-2.31.0-py3-none-any.whl"))
+	assert.Equal(t, "", Filename("https://domain.com/"))
+	assert.Equal(t, "", Filename("npm://lodash@4.17.21"))
+
+	assert.Equal(t, "report.pdf", Basename(`..\..\report.pdf`))
+	assert.Equal(t, "", Basename(".."))
 }
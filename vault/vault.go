@@ -0,0 +1,112 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Client reads secrets from a HashiCorp Vault server.
+type Client struct {
+	Address   string
+	Token     string
+	Namespace string
+}
+
+// FromEnv configures a client the way the vault cli does, with $VAULT_ADDR,
+// $VAULT_TOKEN, or else `~/.vault-token`, and $VAULT_NAMESPACE.
+func FromEnv() (Client, error) {
+	client := Client{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+
+	if client.Address == "" {
+		return Client{}, errors.New("VAULT_ADDR is not defined")
+	}
+
+	if client.Token == "" {
+		home, _ := os.UserHomeDir()
+		if content, err := ioutil.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			client.Token = strings.TrimSpace(string(content))
+		}
+	}
+	if client.Token == "" {
+		return Client{}, errors.New("No Vault token. Define VAULT_TOKEN or run vault login")
+	}
+
+	return client, nil
+}
+
+// Read reads the secret at a path, like `secret/data/ci`. Secrets of a KV
+// version 2 engine are unwrapped.
+func (c Client) Read(path string) (map[string]interface{}, error) {
+	url := strings.TrimSuffix(c.Address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("Unable to read %s from Vault: %s", path, resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, err
+	}
+
+	// KV version 2 nests the secret next to its metadata.
+	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return data, nil
+		}
+	}
+
+	return secret.Data, nil
+}
+
+// ReadField reads a field of a secret given by `path#field`, like
+// `secret/data/ci#token`.
+func (c Client) ReadField(ref string) (string, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("Invalid Vault path. Should be path#field: %s", ref)
+	}
+
+	data, err := c.Read(parts[0])
+	if err != nil {
+		return "", err
+	}
+
+	value, found := data[parts[1]]
+	if !found {
+		return "", fmt.Errorf("No field %s in the Vault secret %s", parts[1], parts[0])
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("The field %s of the Vault secret %s is not a string", parts[1], parts[0])
+	}
+
+	return str, nil
+}
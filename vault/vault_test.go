@@ -0,0 +1,54 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadField(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" || r.Header.Get("X-Vault-Namespace") != "ci" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/ci":
+			fmt.Fprint(w, `{"data":{"data":{"token":"secret","ttl":3600},"metadata":{"version":3}}}`)
+		case "/v1/kv/s3":
+			fmt.Fprint(w, `{"data":{"access_key":"AKIA"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := Client{Address: server.URL, Token: "vault-token", Namespace: "ci"}
+
+	value, err := client.ReadField("secret/data/ci#token")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+
+	value, err = client.ReadField("kv/s3#access_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "AKIA", value)
+
+	_, err = client.ReadField("secret/data/ci#missing")
+	assert.Error(t, err)
+
+	_, err = client.ReadField("secret/data/ci#ttl")
+	assert.Error(t, err)
+
+	_, err = client.ReadField("secret/data/ci")
+	assert.Error(t, err)
+
+	_, err = client.ReadField("secret/data/other#token")
+	assert.Error(t, err)
+
+	_, err = Client{Address: server.URL, Token: "wrong"}.ReadField("secret/data/ci#token")
+	assert.Error(t, err)
+}
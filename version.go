@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build information, injected at build time with:
+//
+//	go build -ldflags "-X main.buildVersion=1.2.0 -X main.buildCommit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	buildVersion = "dev"
+	buildCommit  = ""
+	buildDate    = ""
+)
+
+// buildInfo describes the getme binary.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuild gives the build information. Without ldflags, the commit and
+// date recorded by the go tool, if any, are used.
+func currentBuild() buildInfo {
+	info := buildInfo{
+		Version:   buildVersion,
+		Commit:    buildCommit,
+		Date:      buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// Version prints the build information, as text or json.
+func Version(w io.Writer, format string) error {
+	info := currentBuild()
+
+	if format == "json" {
+		encoded, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Version:    %s\nCommit:     %s\nBuild date: %s\nGo version: %s\nPlatform:   %s\n", info.Version, orUnknown(info.Commit), orUnknown(info.Date), info.GoVersion, info.Platform)
+	return err
+}
+
+// versionTemplate is the template of `getme --version`. It prints the build
+// information as text, like the version command.
+func versionTemplate() string {
+	var text strings.Builder
+	Version(&text, "text")
+	return strings.NewReplacer("{{", `{{"{{"}}`).Replace(text.String())
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
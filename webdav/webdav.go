@@ -0,0 +1,77 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	http_headers "github.com/dgageot/getme/headers"
+	"github.com/dgageot/getme/netrc"
+)
+
+// Options configures access to a WebDAV server. Credentials given in the url
+// take precedence. Without credentials, those of the netrc file are used.
+type Options struct {
+	Username string
+	Password string
+	Token    string
+
+	// Headers are added to the request, as `key: value`.
+	Headers []string
+}
+
+// Open downloads a file from a WebDAV server, given a `dav://host/path` or,
+// for TLS, a `davs://host/path` url.
+func Open(ctx context.Context, u *url.URL, options Options) (io.ReadCloser, error) {
+	actual := *u
+	actual.User = nil
+	switch u.Scheme {
+	case "dav":
+		actual.Scheme = "http"
+	case "davs":
+		actual.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("Invalid WebDAV url. Should be dav:// or davs://: %s", u.String())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", actual.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := http_headers.Add(options.Headers, req); err != nil {
+		return nil, err
+	}
+
+	username, password := options.Username, options.Password
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	} else if options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+options.Token)
+	} else if machine, found := netrc.Lookup(u.Host); found {
+		req.SetBasicAuth(machine.Login, machine.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%s. Give credentials with --webdavUser and --webdavPassword, or --authToken", resp.Status)
+		}
+		return nil, errors.New(resp.Status)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,59 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if r.Header.Get("Authorization") != "Bearer token" && (!ok || username != "user" || password != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "content of "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	content, err := read("dav://user:secret@"+host+"/remote.php/dav/files/user/archive.tgz", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "content of /remote.php/dav/files/user/archive.tgz", content)
+
+	content, err = read("dav://"+host+"/archive.tgz", Options{Username: "user", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "content of /archive.tgz", content)
+
+	content, err = read("dav://"+host+"/archive.tgz", Options{Token: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "content of /archive.tgz", content)
+
+	_, err = read("dav://"+host+"/archive.tgz", Options{})
+	assert.Error(t, err)
+}
+
+func read(rawURL string, options Options) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := Open(context.Background(), u, options)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
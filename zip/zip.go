@@ -3,13 +3,12 @@ package zip
 import (
 	"archive/zip"
 	"os"
-	"path/filepath"
 
+	"github.com/dgageot/getme/errdefs"
 	"github.com/dgageot/getme/files"
-	"github.com/pkg/errors"
 )
 
-func Extract(source string, destinationFolder string) error {
+func Extract(source string, destinationFolder string, options files.ExtractOptions) error {
 	r, err := zip.OpenReader(source)
 	if err != nil {
 		return err
@@ -23,25 +22,46 @@ func Extract(source string, destinationFolder string) error {
 		}
 		defer rc.Close()
 
-		path := filepath.Join(destinationFolder, f.Name)
+		path, err := options.EntryPath(destinationFolder, f.Name)
+		if err != nil {
+			return err
+		}
+
 		if f.FileInfo().IsDir() {
 			return os.MkdirAll(path, f.Mode())
 		}
 
-		return files.CopyFrom(path, f.Mode(), rc)
+		return options.WriteFile(path, f.FileInfo(), rc)
+	}
+
+	total := 0
+	for _, f := range r.File {
+		if !options.IsExcluded(f.Name) && !f.FileInfo().IsDir() {
+			total++
+		}
 	}
 
+	extracted := 0
 	for _, f := range r.File {
+		if options.IsExcluded(f.Name) {
+			continue
+		}
+
 		err := extractFile(f)
 		if err != nil {
 			return err
 		}
+
+		if !f.FileInfo().IsDir() {
+			extracted++
+			options.Extracted(f.Name, extracted, total)
+		}
 	}
 
 	return nil
 }
 
-func ExtractFiles(source string, filesToExtract []files.ExtractedFile) error {
+func ExtractFiles(source string, filesToExtract []files.ExtractedFile, options files.ExtractOptions) error {
 	r, err := zip.OpenReader(source)
 	if err != nil {
 		return err
@@ -49,6 +69,10 @@ func ExtractFiles(source string, filesToExtract []files.ExtractedFile) error {
 	defer r.Close()
 
 	extractFile := func(f *zip.File) (bool, error) {
+		if options.IsExcluded(f.Name) {
+			return false, nil
+		}
+
 		fileToExtract := files.FindExtractedFile(f.Name, filesToExtract)
 		if fileToExtract == nil {
 			return false, nil
@@ -60,7 +84,7 @@ func ExtractFiles(source string, filesToExtract []files.ExtractedFile) error {
 		}
 		defer rc.Close()
 
-		if err := files.CopyFrom(fileToExtract.Destination, f.Mode(), rc); err != nil {
+		if err := options.WriteFile(fileToExtract.Destination, f.FileInfo(), rc); err != nil {
 			return false, err
 		}
 
@@ -76,11 +100,32 @@ func ExtractFiles(source string, filesToExtract []files.ExtractedFile) error {
 
 		if done {
 			extracted++
+			options.Extracted(f.Name, extracted, len(filesToExtract))
 			if extracted == len(filesToExtract) {
 				return nil
 			}
 		}
 	}
 
-	return errors.New("Files not found")
+	return errdefs.Errorf(errdefs.ErrNotFound, "Files not found")
+}
+
+// List lists the entries of a zip archive.
+func List(source string) ([]files.Entry, error) {
+	r, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var entries []files.Entry
+	for _, f := range r.File {
+		entries = append(entries, files.Entry{
+			Name: f.Name,
+			Size: int64(f.UncompressedSize64),
+			Mode: f.Mode(),
+		})
+	}
+
+	return entries, nil
 }